@@ -0,0 +1,221 @@
+// Package client is the Go client of the task manager's REST API.
+//
+// Requests that fail with 429 or a 5xx status, or that don't reach the
+// server, are retried with exponential backoff. Creating a task isn't
+// idempotent, so it is only retried when the server can't have created it:
+// on 429 and 503, or when the input has an external ID that makes a
+// repeated create fail with ErrExternalIDTaken instead of duplicating it.
+//
+//	c := client.New("http://localhost:8080", client.Options{})
+//	for task, err := range c.Tasks(ctx, client.ListOptions{Project: "home"}) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxRetries  = 3
+	DefaultBaseBackoff = 200 * time.Millisecond
+	DefaultMaxBackoff  = 10 * time.Second
+	// maxErrorBody is how much of an error response becomes the message of
+	// the Error.
+	maxErrorBody = 4096
+)
+
+type Options struct {
+	// HTTPClient sends the requests; http.DefaultClient if nil.
+	HTTPClient *http.Client
+	// MaxRetries is how often a failed request is retried. Zero uses
+	// DefaultMaxRetries; negative values disable retries.
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// UserAgent is sent with every request if it isn't empty.
+	UserAgent string
+}
+
+type Client struct {
+	baseURL *url.URL
+	options Options
+}
+
+// New returns a client of the API at baseURL, such as
+// "http://localhost:8080". It panics if baseURL isn't an absolute URL.
+func New(baseURL string, options Options) *Client {
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || !parsed.IsAbs() {
+		panic(fmt.Sprintf("client: invalid base URL %q", baseURL))
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = http.DefaultClient
+	}
+	if options.MaxRetries == 0 {
+		options.MaxRetries = DefaultMaxRetries
+	} else if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+	if options.BaseBackoff <= 0 {
+		options.BaseBackoff = DefaultBaseBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = DefaultMaxBackoff
+	}
+	return &Client{baseURL: parsed, options: options}
+}
+
+// request describes a request to the API. idempotent requests are
+// retried on any 5xx status and on transport errors.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	// rawBody is sent with contentType instead of body as JSON, if it
+	// isn't nil.
+	rawBody     []byte
+	contentType string
+	accept      string
+	idempotent  bool
+	// okStatus is a status other than 2xx whose response the caller reads
+	// like a 2xx one, if it isn't 0.
+	okStatus int
+}
+
+// do sends the request, retrying it when it may succeed later, and returns
+// the response of a 2xx status. Other statuses are returned as *Error.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	body, contentType := req.rawBody, req.contentType
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("marshal request: %w", err)
+		}
+		contentType = "application/json"
+	}
+	endpoint := c.baseURL.JoinPath(req.path)
+	endpoint.RawQuery = req.query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		httpRequest, err := http.NewRequestWithContext(ctx, req.method, endpoint.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			httpRequest.Header.Set("Content-Type", contentType)
+		}
+		if req.accept != "" {
+			httpRequest.Header.Set("Accept", req.accept)
+		}
+		if c.options.UserAgent != "" {
+			httpRequest.Header.Set("User-Agent", c.options.UserAgent)
+		}
+
+		response, err := c.options.HTTPClient.Do(httpRequest)
+		var retryAfter time.Duration
+		if err != nil {
+			if ctx.Err() != nil || !req.idempotent || attempt >= c.options.MaxRetries {
+				return nil, fmt.Errorf("%s %s: %w", req.method, req.path, err)
+			}
+		} else {
+			if response.StatusCode < 300 || response.StatusCode == req.okStatus {
+				return response, nil
+			}
+			apiErr := readError(req, response)
+			if !retryable(req, response.StatusCode) || attempt >= c.options.MaxRetries {
+				return nil, apiErr
+			}
+			retryAfter = parseRetryAfter(response.Header.Get("Retry-After"))
+		}
+
+		delay := max(c.backoff(attempt), retryAfter)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// doJSON sends the request and decodes the response body into out, if
+// out isn't nil.
+func (c *Client) doJSON(ctx context.Context, req request, out any) error {
+	response, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(response.Body)
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+func readError(req request, response *http.Response) *Error {
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(response.Body)
+	message, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+	return &Error{
+		Method:     req.method,
+		Path:       req.path,
+		StatusCode: response.StatusCode,
+		Message:    strings.TrimSpace(string(message)),
+		Code:       response.Header.Get(errorCodeHeader),
+	}
+}
+
+// retryable reports whether a response with status may succeed when the
+// request is sent again. The server hasn't acted on requests it answered
+// with 429 or 503, so those are retried even if they aren't idempotent.
+func retryable(req request, status int) bool {
+	switch {
+	case status == http.StatusTooManyRequests, status == http.StatusServiceUnavailable:
+		return true
+	case status >= 500:
+		return req.idempotent
+	}
+	return false
+}
+
+// backoff returns the delay before retry attempt+1: BaseBackoff doubled
+// per attempt up to MaxBackoff, with up to 20% jitter.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.options.BaseBackoff
+	for i := 0; i < attempt && delay < c.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > c.options.MaxBackoff {
+		delay = c.options.MaxBackoff
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
+
+// parseRetryAfter returns the delay of a Retry-After header in seconds,
+// or zero.
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,341 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+
+	"task-manager/internal/events"
+	"task-manager/internal/handler"
+	"task-manager/internal/migrations"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+// newTaskAPI returns the task, comment and import routes of the server,
+// over an in-memory database.
+func newTaskAPI(t *testing.T) http.Handler {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	taskRepository, workflowRepository, commentRepository := repository.NewSQLiteTaskRepository(db), repository.NewSQLiteWorkflowRepository(db), repository.NewSQLiteCommentRepository(db)
+	bus, transactor := events.NewBus(), repository.NewSQLTransactor(db)
+	mux := http.NewServeMux()
+	handler.NewTaskHandler(service.NewTaskService(taskRepository, workflowRepository, bus, transactor)).RegisterRoutes(mux)
+	handler.NewCommentHandler(service.NewCommentService(commentRepository, taskRepository, bus, transactor)).RegisterRoutes(mux)
+	handler.NewImportHandler(service.NewImportService(taskRepository, commentRepository, workflowRepository, bus, transactor)).RegisterRoutes(mux)
+	return mux
+}
+
+func newTestClient(t *testing.T, api http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	return New(server.URL, Options{HTTPClient: server.Client(), BaseBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+}
+
+func TestClient(t *testing.T) {
+	c := newTestClient(t, newTaskAPI(t))
+	ctx := t.Context()
+
+	if err := c.Health(ctx); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	due := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	task, err := c.CreateTask(ctx, CreateTaskInput{Project: "home", Title: "Pay rent", Assignee: "alex", DueAt: &due})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if task.ID == uuid.Nil || task.Status != TaskStatusNew || task.DueAt == nil || !task.DueAt.Equal(due) {
+		t.Errorf("created task %+v", task)
+	}
+
+	got, err := c.GetTask(ctx, task.ID)
+	if err != nil || got.Title != "Pay rent" || got.Assignee != "alex" {
+		t.Errorf("GetTask: %+v, %v", got, err)
+	}
+
+	status, assignee := TaskStatusDone, ""
+	updated, err := c.UpdateTask(ctx, task.ID, UpdateTaskInput{Status: &status, Assignee: &assignee})
+	if err != nil || updated.Status != TaskStatusDone || updated.Assignee != "" || updated.Title != "Pay rent" {
+		t.Errorf("UpdateTask: %+v, %v", updated, err)
+	}
+
+	tasks, err := c.ListTasks(ctx, ListOptions{Project: "home", Status: TaskStatusDone})
+	if err != nil || len(tasks) != 1 || tasks[0].ID != task.ID {
+		t.Errorf("ListTasks: %v, %v", tasks, err)
+	}
+
+	export, err := c.ExportTasks(ctx, ExportOptions{ListOptions: ListOptions{Project: "home"}, Format: ExportCSV, Columns: []string{"title", "status"}})
+	if err != nil {
+		t.Fatalf("ExportTasks: %v", err)
+	}
+	body, err := io.ReadAll(export)
+	_ = export.Close()
+	if err != nil || string(body) != "title,status\nPay rent,done\n" {
+		t.Errorf("export %q, %v", body, err)
+	}
+
+	if err := c.DeleteTask(ctx, task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if _, err := c.GetTask(ctx, task.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTask after delete: %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	c := newTestClient(t, newTaskAPI(t))
+	ctx := t.Context()
+	task, err := c.CreateTask(ctx, CreateTaskInput{Title: "Pay rent", ExternalID: "rent"})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	done, reopened, unknown := TaskStatusDone, TaskStatusNew, TaskStatus("archived")
+	if _, err := c.UpdateTask(ctx, task.ID, UpdateTaskInput{Status: &done}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want []error
+	}{
+		{"short title", func() error {
+			_, err := c.CreateTask(ctx, CreateTaskInput{Title: "x"})
+			return err
+		}, []error{ErrTitleTooShort, ErrBadRequest}},
+		{"taken external id", func() error {
+			_, err := c.CreateTask(ctx, CreateTaskInput{Title: "Pay rent again", ExternalID: "rent"})
+			return err
+		}, []error{ErrExternalIDTaken, ErrConflict}},
+		{"unknown task", func() error {
+			return c.DeleteTask(ctx, uuid.New())
+		}, []error{ErrNotFound}},
+		{"unknown status", func() error {
+			_, err := c.UpdateTask(ctx, task.ID, UpdateTaskInput{Status: &unknown})
+			return err
+		}, []error{ErrInvalidStatus}},
+		{"unknown status filter", func() error {
+			_, err := c.ListTasks(ctx, ListOptions{Project: "home", Status: unknown})
+			return err
+		}, []error{ErrInvalidStatus, ErrBadRequest}},
+		{"invalid transition", func() error {
+			_, err := c.UpdateTask(ctx, task.ID, UpdateTaskInput{Status: &reopened})
+			return err
+		}, []error{ErrInvalidTransition, ErrConflict}},
+		{"invalid limit", func() error {
+			_, err := c.ListTasks(ctx, ListOptions{Limit: -1})
+			return err
+		}, []error{ErrBadRequest}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error %v isn't an *Error", err)
+			}
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Errorf("error %v isn't %v", err, want)
+				}
+			}
+			if errors.Is(err, ErrServer) {
+				t.Errorf("error %v is a server error", err)
+			}
+		})
+	}
+}
+
+// The finer errors are told apart by the server's error codes, which must
+// not drift from them.
+func TestErrorCodesMatchServer(t *testing.T) {
+	for _, tt := range []struct{ client, server string }{
+		{errorCodeHeader, handler.ErrorCodeHeader},
+		{codeTitleTooShort, handler.ErrCodeTitleTooShort},
+		{codeExternalIDTaken, handler.ErrCodeExternalIDTaken},
+		{codeInvalidStatus, handler.ErrCodeInvalidStatus},
+		{codeInvalidTransition, handler.ErrCodeInvalidTransition},
+	} {
+		if tt.client != tt.server {
+			t.Errorf("client has %q, server %q", tt.client, tt.server)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	c := newTestClient(t, newTaskAPI(t))
+	ctx := t.Context()
+	task, err := c.CreateTask(ctx, CreateTaskInput{Title: "Pay rent"})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	for _, body := range []string{"Sent it", "It arrived"} {
+		if _, err := c.CreateComment(ctx, task.ID, CreateCommentInput{Author: "alex", Body: body}); err != nil {
+			t.Fatalf("CreateComment: %v", err)
+		}
+	}
+	comments, err := c.ListComments(ctx, task.ID)
+	if err != nil || len(comments) != 2 || comments[0].Body != "Sent it" || comments[1].Author != "alex" || comments[1].TaskID != task.ID {
+		t.Errorf("ListComments: %+v, %v", comments, err)
+	}
+	if _, err := c.CreateComment(ctx, task.ID, CreateCommentInput{Body: " "}); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("CreateComment without a body: %v", err)
+	}
+	if _, err := c.ListComments(ctx, uuid.New()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ListComments of an unknown task: %v", err)
+	}
+}
+
+func TestImportTasks(t *testing.T) {
+	c := newTestClient(t, newTaskAPI(t))
+	ctx := t.Context()
+	todo := "Pay rent +bills id:rent\nx Water plants id:plants\n"
+	mapping := &ImportMapping{Projects: map[string]string{"+bills": "finance"}}
+
+	report, err := c.ImportTasks(ctx, strings.NewReader(todo), ImportOptions{Format: ImportTodoTxt, DryRun: true, Mapping: mapping})
+	if err != nil || !report.DryRun || report.Imported || report.Created != 2 {
+		t.Fatalf("dry run: %+v, %v", report, err)
+	}
+	report, err = c.ImportTasks(ctx, strings.NewReader(todo), ImportOptions{Format: ImportTodoTxt, Mapping: mapping})
+	if err != nil || !report.Imported || report.Created != 2 {
+		t.Fatalf("import: %+v, %v", report, err)
+	}
+	tasks, err := c.ListTasks(ctx, ListOptions{Project: "finance"})
+	if err != nil || len(tasks) != 1 || tasks[0].ExternalID != "todotxt:rent" {
+		t.Errorf("imported tasks: %+v, %v", tasks, err)
+	}
+
+	// Rows with errors are reported, and nothing is imported.
+	report, err = c.ImportTasks(ctx, strings.NewReader("Call mum due:soon\n"), ImportOptions{Format: ImportTodoTxt})
+	if err != nil || report.Imported || len(report.Errors) != 1 || report.Errors[0].Line != 1 {
+		t.Errorf("import with errors: %+v, %v", report, err)
+	}
+	if _, err := c.ImportTasks(ctx, strings.NewReader(todo), ImportOptions{Format: "xlsx"}); !errors.As(err, new(*Error)) {
+		t.Errorf("import of an unknown format: %v", err)
+	}
+}
+
+func TestTasksIterator(t *testing.T) {
+	c := newTestClient(t, newTaskAPI(t))
+	ctx := t.Context()
+	for _, title := range []string{"Task one", "Task two", "Task three", "Task four", "Task five"} {
+		if _, err := c.CreateTask(ctx, CreateTaskInput{Project: "home", Title: title}); err != nil {
+			t.Fatalf("CreateTask: %v", err)
+		}
+	}
+	if _, err := c.CreateTask(ctx, CreateTaskInput{Project: "work", Title: "Write report"}); err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+
+	var titles []string
+	for task, err := range c.Tasks(ctx, ListOptions{Project: "home", Limit: 2}) {
+		if err != nil {
+			t.Fatalf("Tasks: %v", err)
+		}
+		titles = append(titles, task.Title)
+	}
+	if strings.Join(titles, ",") != "Task five,Task four,Task three,Task two,Task one" {
+		t.Errorf("titles %v", titles)
+	}
+
+	var seen int
+	for _, err := range c.Tasks(ctx, ListOptions{Limit: 2}) {
+		if err != nil {
+			t.Fatalf("Tasks: %v", err)
+		}
+		if seen++; seen == 3 {
+			break
+		}
+	}
+
+	for task, err := range c.Tasks(ctx, ListOptions{Project: "home", Status: "archived"}) {
+		if task != nil || !errors.Is(err, ErrInvalidStatus) {
+			t.Errorf("Tasks with an unknown status: %v, %v", task, err)
+		}
+	}
+}
+
+// flaky fails the first failures requests with status before passing
+// requests on to next.
+func flaky(next http.Handler, status, failures int) (http.Handler, *atomic.Int32) {
+	var requests atomic.Int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(requests.Add(1)) <= failures {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}), &requests
+}
+
+func TestClientRetries(t *testing.T) {
+	ctx := t.Context()
+
+	api, requests := flaky(newTaskAPI(t), http.StatusBadGateway, 2)
+	c := newTestClient(t, api)
+	if _, err := c.ListTasks(ctx, ListOptions{}); err != nil || requests.Load() != 3 {
+		t.Errorf("ListTasks after 2 failures: %v, %d requests", err, requests.Load())
+	}
+
+	api, requests = flaky(newTaskAPI(t), http.StatusTooManyRequests, 1)
+	c = newTestClient(t, api)
+	if _, err := c.CreateTask(ctx, CreateTaskInput{Title: "Pay rent"}); err != nil || requests.Load() != 2 {
+		t.Errorf("CreateTask after 429: %v, %d requests", err, requests.Load())
+	}
+
+	// The server may have created the task before failing, so the create
+	// isn't repeated unless an external ID prevents duplicates.
+	api, requests = flaky(newTaskAPI(t), http.StatusInternalServerError, 1)
+	c = newTestClient(t, api)
+	if _, err := c.CreateTask(ctx, CreateTaskInput{Title: "Pay rent"}); !errors.Is(err, ErrServer) || requests.Load() != 1 {
+		t.Errorf("CreateTask after 500: %v, %d requests", err, requests.Load())
+	}
+	if _, err := c.CreateTask(ctx, CreateTaskInput{Title: "Pay rent", ExternalID: "rent"}); err != nil {
+		t.Errorf("CreateTask with an external ID: %v", err)
+	}
+
+	api, requests = flaky(newTaskAPI(t), http.StatusServiceUnavailable, 100)
+	c = newTestClient(t, api)
+	if err := c.Health(ctx); !errors.Is(err, ErrUnavailable) || requests.Load() != DefaultMaxRetries+1 {
+		t.Errorf("Health while unavailable: %v, %d requests", err, requests.Load())
+	}
+
+	// Client errors aren't retried.
+	api, requests = flaky(newTaskAPI(t), http.StatusServiceUnavailable, 0)
+	c = newTestClient(t, api)
+	if _, err := c.GetTask(ctx, uuid.New()); !errors.Is(err, ErrNotFound) || requests.Load() != 1 {
+		t.Errorf("GetTask of an unknown task: %v, %d requests", err, requests.Load())
+	}
+}
+
+func TestClientRetriesStopWithContext(t *testing.T) {
+	api, _ := flaky(newTaskAPI(t), http.StatusServiceUnavailable, 100)
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	c := New(server.URL, Options{MaxRetries: 100, BaseBackoff: time.Hour})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Health(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Health: %v", err)
+	}
+}
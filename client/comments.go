@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Comment struct {
+	ID        uuid.UUID `json:"id"`
+	TaskID    uuid.UUID `json:"task_id"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateCommentInput struct {
+	Author string `json:"author,omitempty"`
+	Body   string `json:"body"`
+}
+
+// CreateComment adds a comment to the task. It isn't retried unless the
+// server can't have added it, as a repeat would add it twice.
+func (c *Client) CreateComment(ctx context.Context, taskID uuid.UUID, input CreateCommentInput) (*Comment, error) {
+	var comment Comment
+	req := request{method: http.MethodPost, path: "/tasks/" + taskID.String() + "/comments", body: input}
+	if err := c.doJSON(ctx, req, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// ListComments returns the comments of the task, oldest first.
+func (c *Client) ListComments(ctx context.Context, taskID uuid.UUID) ([]*Comment, error) {
+	var comments []*Comment
+	req := request{method: http.MethodGet, path: "/tasks/" + taskID.String() + "/comments", idempotent: true}
+	if err := c.doJSON(ctx, req, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
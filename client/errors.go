@@ -0,0 +1,86 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors that *Error matches with errors.Is, by the status code of the
+// response and, for the finer ones, the server's error code.
+var (
+	ErrBadRequest    = errors.New("bad request")
+	ErrNotFound      = errors.New("task not found")
+	ErrNotAcceptable = errors.New("not acceptable")
+	ErrConflict      = errors.New("conflict")
+	ErrRateLimited   = errors.New("rate limited")
+	ErrUnavailable   = errors.New("server unavailable")
+	ErrServer        = errors.New("server error")
+
+	// ErrTitleTooShort and ErrExternalIDTaken are errors of creating and
+	// updating tasks; ErrExternalIDTaken is also an ErrConflict.
+	ErrTitleTooShort   = errors.New("title too short")
+	ErrExternalIDTaken = errors.New("external ID is taken")
+	// ErrInvalidStatus means the status isn't one of the project's
+	// workflow; ErrInvalidTransition that the workflow doesn't allow the
+	// change. The latter is also an ErrConflict.
+	ErrInvalidStatus     = errors.New("invalid status")
+	ErrInvalidTransition = errors.New("invalid status transition")
+)
+
+// errorCodeHeader is the header with the server's code of an error; the
+// finer errors are told apart by the codes.
+const errorCodeHeader = "X-Error-Code"
+
+const (
+	codeTitleTooShort     = "title_too_short"
+	codeExternalIDTaken   = "external_id_taken"
+	codeInvalidStatus     = "invalid_status"
+	codeInvalidTransition = "invalid_transition"
+)
+
+// Error is a response of the API with a status code other than 2xx.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Message is the body of the response, the server's plain text error.
+	Message string
+	// Code is the server's code of the error, for the errors that have one.
+	Code string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrNotAcceptable:
+		return e.StatusCode == http.StatusNotAcceptable
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUnavailable:
+		return e.StatusCode == http.StatusServiceUnavailable
+	case ErrServer:
+		return e.StatusCode >= 500
+	case ErrTitleTooShort:
+		return e.StatusCode == http.StatusBadRequest && e.Code == codeTitleTooShort
+	case ErrExternalIDTaken:
+		return e.StatusCode == http.StatusConflict && e.Code == codeExternalIDTaken
+	case ErrInvalidStatus:
+		return e.StatusCode == http.StatusBadRequest && e.Code == codeInvalidStatus
+	case ErrInvalidTransition:
+		return e.StatusCode == http.StatusConflict && e.Code == codeInvalidTransition
+	}
+	return false
+}
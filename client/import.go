@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type ImportFormat string
+
+const (
+	ImportCSV     ImportFormat = "csv"
+	ImportNDJSON  ImportFormat = "ndjson"
+	ImportTrello  ImportFormat = "trello"
+	ImportJira    ImportFormat = "jira"
+	ImportTodoTxt ImportFormat = "todotxt"
+)
+
+// ImportMapping adjusts how the fields of Trello, Jira and todo.txt
+// exports map to tasks.
+type ImportMapping struct {
+	Project   string                `json:"project,omitempty"`
+	Statuses  map[string]TaskStatus `json:"statuses,omitempty"`
+	Projects  map[string]string     `json:"projects,omitempty"`
+	Assignees map[string]string     `json:"assignees,omitempty"`
+	Archived  bool                  `json:"archived,omitempty"`
+}
+
+// ImportOptions describe an import. DryRun only validates the rows.
+type ImportOptions struct {
+	Format  ImportFormat
+	DryRun  bool
+	Mapping *ImportMapping
+}
+
+type ImportReport struct {
+	DryRun   bool             `json:"dry_run"`
+	Imported bool             `json:"imported"`
+	Rows     int              `json:"rows"`
+	Created  int              `json:"created"`
+	Updated  int              `json:"updated"`
+	Comments int              `json:"comments"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// ImportRowError is a problem with a row of an import.
+type ImportRowError struct {
+	Line       int    `json:"line"`
+	ExternalID string `json:"external_id,omitempty"`
+	Message    string `json:"message"`
+}
+
+// ImportTasks imports the tasks of file, which it reads to the end. An
+// import with rows in error imports nothing and returns the report with
+// the errors rather than an error. Imports are only retried when the
+// server can't have imported them, as rows without external IDs would be
+// imported twice; dry runs are retried like reads.
+func (c *Client) ImportTasks(ctx context.Context, file io.Reader, options ImportOptions) (*ImportReport, error) {
+	body, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("read import: %w", err)
+	}
+	query := url.Values{}
+	query.Set("format", string(options.Format))
+	if options.DryRun {
+		query.Set("dry_run", strconv.FormatBool(true))
+	}
+	if options.Mapping != nil {
+		mapping, err := json.Marshal(options.Mapping)
+		if err != nil {
+			return nil, fmt.Errorf("marshal mapping: %w", err)
+		}
+		query.Set("mapping", string(mapping))
+	}
+
+	var report ImportReport
+	req := request{
+		method:      http.MethodPost,
+		path:        "/tasks/import",
+		query:       query,
+		rawBody:     body,
+		contentType: "application/octet-stream",
+		idempotent:  options.DryRun,
+		okStatus:    http.StatusUnprocessableEntity,
+	}
+	if err := c.doJSON(ctx, req, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
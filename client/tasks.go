@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultPageSize is the page size of Tasks, and the server's default
+// limit of ListTasks.
+const DefaultPageSize = 50
+
+type TaskStatus string
+
+// Statuses of the default workflow; projects may define others.
+const (
+	TaskStatusNew        TaskStatus = "new"
+	TaskStatusInProgress TaskStatus = "in_progress"
+	TaskStatusDone       TaskStatus = "done"
+)
+
+type Task struct {
+	ID           uuid.UUID  `json:"id"`
+	ExternalID   string     `json:"external_id,omitempty"`
+	Project      string     `json:"project"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Status       TaskStatus `json:"status"`
+	Assignee     string     `json:"assignee,omitempty"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	RecurrenceID *uuid.UUID `json:"recurrence_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type CreateTaskInput struct {
+	// ExternalID identifies the task in another system. Creates with one
+	// are safe to retry, since the server rejects a second task with it.
+	ExternalID  string     `json:"external_id,omitempty"`
+	Project     string     `json:"project,omitempty"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Assignee    string     `json:"assignee,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+}
+
+// UpdateTaskInput changes the fields that aren't nil.
+type UpdateTaskInput struct {
+	Title       *string     `json:"title,omitempty"`
+	Description *string     `json:"description,omitempty"`
+	Status      *TaskStatus `json:"status,omitempty"`
+	Assignee    *string     `json:"assignee,omitempty"`
+	DueAt       *time.Time  `json:"due_at,omitempty"`
+}
+
+// ListOptions filters tasks; empty fields don't filter. Limit is the size
+// of a page, DefaultPageSize if zero.
+type ListOptions struct {
+	Project  string
+	Status   TaskStatus
+	Assignee string
+	Limit    int
+	Offset   int
+}
+
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+	if o.Project != "" {
+		query.Set("project", o.Project)
+	}
+	if o.Status != "" {
+		query.Set("status", string(o.Status))
+	}
+	if o.Assignee != "" {
+		query.Set("assignee", o.Assignee)
+	}
+	if o.Limit != 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset != 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	return query
+}
+
+type ExportFormat string
+
+const (
+	ExportCSV    ExportFormat = "csv"
+	ExportNDJSON ExportFormat = "ndjson"
+	ExportJSON   ExportFormat = "json"
+)
+
+// ExportOptions filters exported tasks like ListOptions, except that a
+// zero Limit exports all of them. Columns chooses and orders the columns,
+// all of them if empty.
+type ExportOptions struct {
+	ListOptions
+	Format  ExportFormat
+	Columns []string
+}
+
+// Health returns nil if the server is up and can reach its database.
+func (c *Client) Health(ctx context.Context) error {
+	return c.doJSON(ctx, request{method: http.MethodGet, path: "/health", idempotent: true}, nil)
+}
+
+func (c *Client) CreateTask(ctx context.Context, input CreateTaskInput) (*Task, error) {
+	var task Task
+	req := request{method: http.MethodPost, path: "/tasks", body: input, idempotent: input.ExternalID != ""}
+	if err := c.doJSON(ctx, req, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *Client) GetTask(ctx context.Context, id uuid.UUID) (*Task, error) {
+	var task Task
+	if err := c.doJSON(ctx, request{method: http.MethodGet, path: "/tasks/" + id.String(), idempotent: true}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ListTasks returns one page of tasks, newest first.
+func (c *Client) ListTasks(ctx context.Context, options ListOptions) ([]*Task, error) {
+	var tasks []*Task
+	req := request{method: http.MethodGet, path: "/tasks", query: options.query(), idempotent: true}
+	if err := c.doJSON(ctx, req, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// Tasks iterates over all tasks matching options from options.Offset on,
+// fetching pages of options.Limit tasks as it goes. It stops after the
+// first error. Tasks created while it runs shift the pages, so it may
+// yield a task twice.
+func (c *Client) Tasks(ctx context.Context, options ListOptions) iter.Seq2[*Task, error] {
+	return func(yield func(*Task, error) bool) {
+		if options.Limit <= 0 {
+			options.Limit = DefaultPageSize
+		}
+		for {
+			tasks, err := c.ListTasks(ctx, options)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, task := range tasks {
+				if !yield(task, nil) {
+					return
+				}
+			}
+			if len(tasks) < options.Limit {
+				return
+			}
+			options.Offset += len(tasks)
+		}
+	}
+}
+
+// UpdateTask changes the fields of input that are set and returns the
+// updated task.
+func (c *Client) UpdateTask(ctx context.Context, id uuid.UUID, input UpdateTaskInput) (*Task, error) {
+	var task Task
+	req := request{method: http.MethodPut, path: "/tasks/" + id.String(), body: input, idempotent: true}
+	if err := c.doJSON(ctx, req, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// DeleteTask deletes the task. A retry after the server deleted it but the
+// response got lost reports ErrNotFound.
+func (c *Client) DeleteTask(ctx context.Context, id uuid.UUID) error {
+	return c.doJSON(ctx, request{method: http.MethodDelete, path: "/tasks/" + id.String(), idempotent: true}, nil)
+}
+
+// ExportTasks starts an export and returns its body, which the caller
+// must close. Exports are streamed, so errors after the response started
+// surface as read errors.
+func (c *Client) ExportTasks(ctx context.Context, options ExportOptions) (io.ReadCloser, error) {
+	query := options.query()
+	if options.Format != "" {
+		query.Set("format", string(options.Format))
+	}
+	if len(options.Columns) > 0 {
+		query.Set("columns", strings.Join(options.Columns, ","))
+	}
+	response, err := c.do(ctx, request{method: http.MethodGet, path: "/tasks/export", query: query, idempotent: true})
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
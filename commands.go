@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"task-manager/internal/backup"
+	"task-manager/internal/config"
+	"task-manager/internal/encryption"
+	"task-manager/internal/importer"
+	"task-manager/internal/migrations"
+	"task-manager/internal/outbox"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+const usage = `usage:
+  task-manager                           serve the API
+  task-manager backup [-o path]          take a snapshot of the SQLite database
+  task-manager restore [-force] snapshot restore the SQLite database from a snapshot
+  task-manager generate-key              print a new key for the encryption key file
+  task-manager rotate-keys [-batch n]    re-encrypt everything under the primary key
+  task-manager import [-format f] [-mapping m] [-dry-run] file
+                                         import tasks from a CSV or NDJSON file, a Trello,
+                                         Jira or todo.txt export, or - for stdin`
+
+// runCommand runs the command line subcommand name. Serving the API is the
+// default and is not a subcommand.
+func runCommand(cfg config.Config, name string, args []string) error {
+	if cfg.DBDriver != "sqlite" && (name == "backup" || name == "restore") {
+		return fmt.Errorf("%s needs %s=sqlite", name, config.TaskManagerDBDriver)
+	}
+	if cfg.DBDriver == "memory" && (name == "rotate-keys" || name == "import") {
+		return fmt.Errorf("%s needs a database, not %s=memory", name, config.TaskManagerDBDriver)
+	}
+	switch name {
+	case "backup":
+		return runBackup(cfg, args)
+	case "restore":
+		return runRestore(cfg, args)
+	case "generate-key":
+		key, err := encryption.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	case "rotate-keys":
+		return runRotateKeys(cfg, args)
+	case "import":
+		return runImport(cfg, args)
+	case "help", "-h", "-help", "--help":
+		fmt.Println(usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n%s", name, usage)
+}
+
+// runBackup takes a snapshot while the server may be running: to -o if
+// given, otherwise into the backup directory, applying its retention.
+func runBackup(cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	output := flags.String("o", "", "write the snapshot to this file instead of the backup directory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v\n%s", flags.Args(), usage)
+	}
+
+	ctx := context.Background()
+	manager := backup.NewManager(cfg.SQLitePath, cfg.BackupDir, cfg.BackupKeep)
+	if *output != "" {
+		if err := manager.Snapshot(ctx, *output); err != nil {
+			return err
+		}
+		log.Printf("backed up %s to %s", cfg.SQLitePath, *output)
+		return nil
+	}
+	if cfg.BackupDir == "" {
+		return fmt.Errorf("backup needs -o or %s", config.TaskManagerBackupDir)
+	}
+	created, err := manager.Create(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	log.Printf("backed up %s to %s (%d bytes)", cfg.SQLitePath, created.Name, created.Size)
+	return nil
+}
+
+// runRestore replaces the database with a snapshot, given as a path or as
+// the name of a backup in the backup directory. The server must be stopped.
+func runRestore(cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	force := flags.Bool("force", false, "restore even if the database looks like it is in use")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("restore needs exactly one snapshot\n%s", usage)
+	}
+
+	snapshot := flags.Arg(0)
+	if _, err := os.Stat(snapshot); err != nil && cfg.BackupDir != "" {
+		if path, err := backup.NewManager(cfg.SQLitePath, cfg.BackupDir, cfg.BackupKeep).Path(snapshot); err == nil {
+			snapshot = path
+		}
+	}
+	if err := backup.Restore(context.Background(), snapshot, cfg.SQLitePath, *force); err != nil {
+		return err
+	}
+	log.Printf("restored %s from %s; the previous database is %s.pre-restore", cfg.SQLitePath, snapshot, cfg.SQLitePath)
+	return nil
+}
+
+// runRotateKeys re-encrypts the encrypted fields that are not under the
+// primary key of the key file, and encrypts plaintext ones, in small
+// batches. It can run while the server is running: the server reloads the
+// key file when it reads a value under a key it doesn't know yet.
+func runRotateKeys(cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ContinueOnError)
+	batch := flags.Int("batch", 500, "values to re-encrypt per transaction")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if cfg.EncryptionKeyFile == "" {
+		return fmt.Errorf("rotate-keys needs %s", config.TaskManagerEncryptionKeyFile)
+	}
+	if *batch <= 0 {
+		return fmt.Errorf("-batch must be positive")
+	}
+	keyring, err := encryption.LoadKeyring(cfg.EncryptionKeyFile)
+	if err != nil {
+		return err
+	}
+
+	db, _, closeDB, err := openMigratedDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	total := 0
+	for {
+		n, err := repository.ReencryptFields(ctx, db, keyring, *batch)
+		total += n
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		log.Printf("re-encrypted %d values under key %s", total, keyring.PrimaryKeyID())
+	}
+	remaining, err := repository.CountFieldsToReencrypt(ctx, db, keyring)
+	if err != nil {
+		return err
+	}
+	log.Printf("re-encrypted %d values under key %s, %d left", total, keyring.PrimaryKeyID(), remaining)
+	if remaining > 0 {
+		return fmt.Errorf("%d values were changed while re-encrypting them; run rotate-keys again", remaining)
+	}
+	return nil
+}
+
+// runImport imports tasks from a file like POST /tasks/import. Their events
+// go to the outbox, which the server relays to the sinks.
+func runImport(cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := flags.String("format", "", "csv, ndjson, trello, jira or todotxt; by default the extension of the file")
+	mappingPath := flags.String("mapping", "", "JSON file with mapping rules for trello, jira and todotxt")
+	dryRun := flags.Bool("dry-run", false, "only validate the rows")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("import needs exactly one file\n%s", usage)
+	}
+	path := flags.Arg(0)
+	if *formatName == "" {
+		*formatName = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	format, err := importer.ParseFormat(*formatName)
+	if err != nil {
+		return fmt.Errorf("%w; set -format", err)
+	}
+	var mapping importer.Mapping
+	if *mappingPath != "" {
+		f, err := os.Open(*mappingPath)
+		if err != nil {
+			return err
+		}
+		mapping, err = importer.ReadMapping(f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", *mappingPath, err)
+		}
+	}
+
+	input := os.Stdin
+	if path != "-" {
+		if input, err = os.Open(path); err != nil {
+			return err
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(input)
+	}
+	rows, err := importer.Read(input, format, mapping)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var cipher repository.FieldCipher
+	if cfg.EncryptionKeyFile != "" {
+		keyring, err := encryption.LoadKeyring(cfg.EncryptionKeyFile)
+		if err != nil {
+			return err
+		}
+		cipher = keyring
+	}
+	db, readDB, closeDB, err := openMigratedDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	transactor := repository.NewSQLTransactor(db)
+	relay := outbox.New(repository.NewSQLiteOutboxRepository(db).WithCipher(cipher), transactor, outbox.Options{})
+	comments := repository.NewSQLiteCommentRepository(db).WithReader(readDB).WithCipher(cipher)
+	imports := service.NewImportService(newTaskRepository(cfg, db, readDB, cipher), comments, repository.NewSQLiteWorkflowRepository(db), relay, transactor)
+
+	report, err := imports.ImportTasks(context.Background(), rows, *dryRun)
+	if err != nil {
+		return err
+	}
+	for _, rowErr := range report.Errors {
+		log.Printf("%s:%d: %s", path, rowErr.Line, rowErr.Message)
+	}
+	switch {
+	case len(report.Errors) > 0:
+		return fmt.Errorf("%d of %d rows have errors; nothing was imported", len(report.Errors), report.Rows)
+	case report.DryRun:
+		log.Printf("dry run: %d rows would create %d and update %d tasks and add %d comments", report.Rows, report.Created, report.Updated, report.Comments)
+	default:
+		log.Printf("imported %d rows: created %d and updated %d tasks and added %d comments", report.Rows, report.Created, report.Updated, report.Comments)
+	}
+	return nil
+}
+
+// openMigratedDB opens the database of cfg and migrates it, for commands
+// that may run next to the server.
+func openMigratedDB(cfg config.Config) (*sql.DB, *sql.DB, func(), error) {
+	db, readDB, err := openDB(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	closeDB := func() {
+		if readDB != nil {
+			_ = readDB.Close()
+		}
+		_ = db.Close()
+	}
+	if err := migrations.Run(db); err != nil {
+		closeDB()
+		return nil, nil, nil, err
+	}
+	return db, readDB, closeDB, nil
+}
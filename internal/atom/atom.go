@@ -0,0 +1,135 @@
+// Package atom writes Atom feeds (RFC 4287).
+package atom
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+const namespace = "http://www.w3.org/2005/Atom"
+
+// ContentType is the media type of Atom feeds.
+const ContentType = "application/atom+xml; charset=utf-8"
+
+type Feed struct {
+	ID      string
+	Title   string
+	Updated time.Time
+	// Self is the URL of the feed, Alternate that of its HTML or JSON
+	// counterpart if there is one.
+	Self      string
+	Alternate string
+	Author    string
+	Entries   []Entry
+}
+
+type Entry struct {
+	// ID must never change, so that readers don't show an entry twice.
+	ID        string
+	Title     string
+	Updated   time.Time
+	Published time.Time
+	Link      string
+	Summary   string
+	// Categories are the terms the entry is filed under.
+	Categories []string
+}
+
+type xmlFeed struct {
+	XMLName xml.Name   `xml:"feed"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	ID      string     `xml:"id"`
+	Title   xmlText    `xml:"title"`
+	Updated string     `xml:"updated"`
+	Links   []xmlLink  `xml:"link"`
+	Author  *xmlPerson `xml:"author,omitempty"`
+	Gen     xmlText    `xml:"generator"`
+	Entries []xmlEntry `xml:"entry"`
+}
+
+type xmlEntry struct {
+	ID         string        `xml:"id"`
+	Title      xmlText       `xml:"title"`
+	Updated    string        `xml:"updated"`
+	Published  string        `xml:"published,omitempty"`
+	Links      []xmlLink     `xml:"link"`
+	Summary    *xmlText      `xml:"summary,omitempty"`
+	Categories []xmlCategory `xml:"category"`
+}
+
+type xmlText struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type xmlLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type xmlPerson struct {
+	Name string `xml:"name"`
+}
+
+type xmlCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// FormatTime formats t as the date-time of Atom, in UTC.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Write writes feed as an Atom document to w.
+func Write(w io.Writer, feed Feed) error {
+	out := xmlFeed{
+		Xmlns:   namespace,
+		ID:      feed.ID,
+		Title:   xmlText{Type: "text", Value: feed.Title},
+		Updated: FormatTime(feed.Updated),
+		Gen:     xmlText{Value: "task-manager"},
+	}
+	if feed.Self != "" {
+		out.Links = append(out.Links, xmlLink{Rel: "self", Type: "application/atom+xml", Href: feed.Self})
+	}
+	if feed.Alternate != "" {
+		out.Links = append(out.Links, xmlLink{Rel: "alternate", Href: feed.Alternate})
+	}
+	// Feeds need an author unless all of their entries have one.
+	if feed.Author != "" {
+		out.Author = &xmlPerson{Name: feed.Author}
+	}
+	for _, entry := range feed.Entries {
+		x := xmlEntry{
+			ID:      entry.ID,
+			Title:   xmlText{Type: "text", Value: entry.Title},
+			Updated: FormatTime(entry.Updated),
+		}
+		if !entry.Published.IsZero() {
+			x.Published = FormatTime(entry.Published)
+		}
+		if entry.Link != "" {
+			x.Links = append(x.Links, xmlLink{Rel: "alternate", Href: entry.Link})
+		}
+		if entry.Summary != "" {
+			x.Summary = &xmlText{Type: "text", Value: entry.Summary}
+		}
+		for _, term := range entry.Categories {
+			x.Categories = append(x.Categories, xmlCategory{Term: term})
+		}
+		out.Entries = append(out.Entries, x)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,318 @@
+// Package backup takes consistent snapshots of the SQLite database while the
+// server is running and restores them while it is stopped.
+//
+// Snapshots are written with VACUUM INTO on a connection of their own. It
+// reads the database in a single read transaction, so in WAL mode a backup
+// neither blocks nor is blocked by writers and never contains half of one.
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"task-manager/internal/migrations"
+)
+
+const (
+	DefaultInterval = 24 * time.Hour
+	DefaultKeep     = 7
+
+	namePrefix = "tasks-"
+	nameSuffix = ".db"
+	// nameLayout keeps the names of backups in creation order when sorted.
+	nameLayout = "20060102T150405.000Z"
+)
+
+var (
+	ErrBackupNotFound = errors.New("backup not found")
+	ErrNoBackupDir    = errors.New("no backup directory configured")
+	ErrDatabaseInUse  = errors.New("database is in use")
+)
+
+// Backup describes a snapshot in the backup directory.
+type Backup struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Snapshot writes a consistent copy of the database at dbPath to target,
+// which must not exist, and verifies it. A failed snapshot leaves nothing
+// behind.
+func Snapshot(ctx context.Context, dbPath, target string) error {
+	db, err := sql.Open("sqlite3", dbPath+"?_busy_timeout=5000")
+	if err != nil {
+		return err
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("snapshot %s: %w", target, fs.ErrExist)
+	}
+	partial := target + ".partial"
+	_ = os.Remove(partial)
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, partial); err != nil {
+		_ = os.Remove(partial)
+		return fmt.Errorf("snapshot %s: %w", dbPath, err)
+	}
+	if _, err := Verify(ctx, partial); err != nil {
+		_ = os.Remove(partial)
+		return err
+	}
+	if err := os.Rename(partial, target); err != nil {
+		_ = os.Remove(partial)
+		return err
+	}
+	return nil
+}
+
+// Verify checks that the file at path is an intact task-manager database
+// that this version can open, and returns its schema version.
+func Verify(ctx context.Context, path string) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, fmt.Errorf("verify %s: not a regular file", path)
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	var result string
+	if err := db.QueryRowContext(ctx, `PRAGMA integrity_check(1)`).Scan(&result); err != nil {
+		return 0, fmt.Errorf("verify %s: %w", path, err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("verify %s: integrity check failed: %s", path, result)
+	}
+	rows, err := db.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return 0, fmt.Errorf("verify %s: %w", path, err)
+	}
+	violations := rows.Next()
+	_ = rows.Close()
+	if violations {
+		return 0, fmt.Errorf("verify %s: foreign key check failed", path)
+	}
+
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("verify %s: not a task-manager database: %w", path, err)
+	}
+	if !version.Valid {
+		return 0, fmt.Errorf("verify %s: no migrations applied", path)
+	}
+	if latest := migrations.LatestVersion(); int(version.Int64) > latest {
+		return 0, fmt.Errorf("verify %s: schema version %d is newer than %d", path, version.Int64, latest)
+	}
+	return int(version.Int64), nil
+}
+
+// Restore replaces the database at dbPath with the verified snapshot. The
+// server must be stopped: a running one keeps the old database open. The
+// replaced database is kept next to it with a ".pre-restore" suffix. Unless
+// force is set, Restore refuses to run while SQLite's shared-memory file
+// shows that the database is open, or was not closed cleanly.
+func Restore(ctx context.Context, snapshot, dbPath string, force bool) error {
+	if _, err := Verify(ctx, snapshot); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dbPath + "-shm"); err == nil && !force {
+		return fmt.Errorf("restore %s: %w; stop the server first", dbPath, ErrDatabaseInUse)
+	}
+
+	restored := dbPath + ".restore"
+	if err := copyFile(snapshot, restored); err != nil {
+		_ = os.Remove(restored)
+		return err
+	}
+	if err := os.Rename(dbPath, dbPath+".pre-restore"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		_ = os.Remove(restored)
+		return err
+	}
+	// The journal files belong to the replaced database.
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(restored, dbPath)
+}
+
+func copyFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer func(in *os.File) {
+		_ = in.Close()
+	}(in)
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Manager keeps snapshots of the database in a directory and deletes all
+// but the newest ones. Without a directory it can still take one-off
+// snapshots.
+type Manager struct {
+	dbPath string
+	dir    string
+	keep   int
+	// mu serialises backups, so that retention never counts a backup that
+	// is still being written.
+	mu sync.Mutex
+}
+
+func NewManager(dbPath, dir string, keep int) *Manager {
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	return &Manager{dbPath: dbPath, dir: dir, keep: keep}
+}
+
+// Snapshot takes a one-off snapshot of the database to target.
+func (m *Manager) Snapshot(ctx context.Context, target string) error {
+	return Snapshot(ctx, m.dbPath, target)
+}
+
+// Create takes a snapshot into the backup directory and then deletes the
+// backups beyond the newest ones to keep.
+func (m *Manager) Create(ctx context.Context, now time.Time) (*Backup, error) {
+	if m.dir == "" {
+		return nil, ErrNoBackupDir
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return nil, err
+	}
+	name := namePrefix + now.UTC().Format(nameLayout) + nameSuffix
+	path := filepath.Join(m.dir, name)
+	if err := Snapshot(ctx, m.dbPath, path); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.prune(); err != nil {
+		return nil, err
+	}
+	return &Backup{Name: name, Size: info.Size(), CreatedAt: now.UTC().Truncate(time.Millisecond)}, nil
+}
+
+// CreateIfDue creates a backup unless the newest one is younger than
+// interval, and returns nil then. Periodic backups use it so that restarting
+// the server doesn't take a backup every time.
+func (m *Manager) CreateIfDue(ctx context.Context, now time.Time, interval time.Duration) (*Backup, error) {
+	backups, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) > 0 && now.Sub(backups[0].CreatedAt) < interval {
+		return nil, nil
+	}
+	return m.Create(ctx, now)
+}
+
+// List returns the backups in the directory, newest first.
+func (m *Manager) List() ([]*Backup, error) {
+	if m.dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []*Backup
+	for _, entry := range entries {
+		createdAt, ok := parseName(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, &Backup{Name: entry.Name(), Size: info.Size(), CreatedAt: createdAt})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Path returns the path of the named backup. Only names of backups in the
+// directory are accepted.
+func (m *Manager) Path(name string) (string, error) {
+	if _, ok := parseName(name); !ok || m.dir == "" {
+		return "", ErrBackupNotFound
+	}
+	path := filepath.Join(m.dir, name)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return "", ErrBackupNotFound
+	} else if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (m *Manager) prune() (int, error) {
+	backups, err := m.List()
+	if err != nil || len(backups) <= m.keep {
+		return 0, err
+	}
+	pruned := 0
+	for _, backup := range backups[m.keep:] {
+		if err := os.Remove(filepath.Join(m.dir, backup.Name)); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+func parseName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, namePrefix) || !strings.HasSuffix(name, nameSuffix) {
+		return time.Time{}, false
+	}
+	createdAt, err := time.Parse(nameLayout, strings.TrimSuffix(strings.TrimPrefix(name, namePrefix), nameSuffix))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
@@ -0,0 +1,279 @@
+package backup_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/backup"
+	"task-manager/internal/database"
+	"task-manager/internal/migrations"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+// newTestDB returns the path of a migrated database in WAL mode, with the
+// writer pool of the server still open on it.
+func newTestDB(t *testing.T) (string, *sql.DB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	writer, reader, err := database.OpenSQLite(path, database.DefaultSQLiteOptions())
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = reader.Close()
+		_ = writer.Close()
+	})
+	if err := migrations.Run(writer); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return path, writer
+}
+
+func createTask(t *testing.T, db *sql.DB, title string) uuid.UUID {
+	t.Helper()
+	task := &models.Task{ID: uuid.New(), Project: models.DefaultProject, Title: title, Status: models.TaskStatusNew}
+	if err := repository.NewSQLiteTaskRepository(db).CreateTask(context.Background(), task); err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	return task.ID
+}
+
+func countTasks(t *testing.T, path string) int {
+	t.Helper()
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tasks`).Scan(&count); err != nil {
+		t.Fatalf("count tasks: %v", err)
+	}
+	return count
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dbPath, db := newTestDB(t)
+	createTask(t, db, "Pay rent")
+
+	target := filepath.Join(t.TempDir(), "snapshot.db")
+	if err := backup.Snapshot(ctx, dbPath, target); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	// Snapshots are self-contained: the committed task is in the file even
+	// though the live database still has it in its WAL.
+	if _, err := os.Stat(target + "-wal"); err == nil {
+		t.Fatal("snapshot has a WAL file")
+	}
+	if got := countTasks(t, target); got != 1 {
+		t.Fatalf("snapshot has %d tasks, want 1", got)
+	}
+	version, err := backup.Verify(ctx, target)
+	if err != nil || version != migrations.LatestVersion() {
+		t.Fatalf("verify: version=%d err=%v", version, err)
+	}
+	if err := backup.Snapshot(ctx, dbPath, target); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("snapshot over an existing file: %v", err)
+	}
+}
+
+func TestVerifyRejectsBadFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte("not a database"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := backup.Verify(ctx, garbage); err == nil {
+		t.Fatal("verified a file that is not a database")
+	}
+
+	empty := filepath.Join(dir, "empty.db")
+	db, err := sql.Open("sqlite3", empty)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE notes (body TEXT)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	_ = db.Close()
+	if _, err := backup.Verify(ctx, empty); err == nil {
+		t.Fatal("verified a database without migrations")
+	}
+
+	dbPath, _ := newTestDB(t)
+	newer := filepath.Join(dir, "newer.db")
+	if err := backup.Snapshot(ctx, dbPath, newer); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	db, err = sql.Open("sqlite3", newer)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from_the_future', '2026-03-01T09:00:00Z')`, migrations.LatestVersion()+1); err != nil {
+		t.Fatalf("insert migration: %v", err)
+	}
+	_ = db.Close()
+	if _, err := backup.Verify(ctx, newer); err == nil {
+		t.Fatal("verified a database with a newer schema")
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.db")
+	if err := backup.Snapshot(ctx, dbPath, corrupt); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	data, err := os.ReadFile(corrupt)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	// Overwrite everything after the first page, which holds the schema.
+	for i := 4096; i < len(data); i++ {
+		data[i] = 0xff
+	}
+	if err := os.WriteFile(corrupt, data, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := backup.Verify(ctx, corrupt); err == nil {
+		t.Fatal("verified a corrupt database")
+	}
+}
+
+func TestManagerRetention(t *testing.T) {
+	ctx := context.Background()
+	dbPath, _ := newTestDB(t)
+	manager := backup.NewManager(dbPath, filepath.Join(t.TempDir(), "backups"), 2)
+
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	var names []string
+	for i := range 3 {
+		created, err := manager.Create(ctx, start.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		names = append(names, created.Name)
+	}
+
+	backups, err := manager.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Name != names[2] || backups[1].Name != names[1] {
+		t.Fatalf("got %+v, want the newest two of %v", backups, names)
+	}
+	if !backups[0].CreatedAt.Equal(start.Add(2 * time.Hour)) {
+		t.Fatalf("created at %s", backups[0].CreatedAt)
+	}
+	if _, err := manager.Path(names[0]); !errors.Is(err, backup.ErrBackupNotFound) {
+		t.Fatalf("pruned backup: %v", err)
+	}
+	if _, err := manager.Path("../tasks.db"); !errors.Is(err, backup.ErrBackupNotFound) {
+		t.Fatalf("path outside the directory: %v", err)
+	}
+
+	if created, err := manager.CreateIfDue(ctx, start.Add(150*time.Minute), time.Hour); err != nil || created != nil {
+		t.Fatalf("backup before it was due: %+v, %v", created, err)
+	}
+	if created, err := manager.CreateIfDue(ctx, start.Add(3*time.Hour), time.Hour); err != nil || created == nil {
+		t.Fatalf("due backup: %+v, %v", created, err)
+	}
+
+	if _, err := backup.NewManager(dbPath, "", 2).Create(ctx, start); !errors.Is(err, backup.ErrNoBackupDir) {
+		t.Fatalf("create without a directory: %v", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	dbPath, db := newTestDB(t)
+	createTask(t, db, "Pay rent")
+	snapshot := filepath.Join(t.TempDir(), "snapshot.db")
+	if err := backup.Snapshot(ctx, dbPath, snapshot); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	createTask(t, db, "Buy milk")
+
+	// The server still has the database open.
+	if err := backup.Restore(ctx, snapshot, dbPath, false); !errors.Is(err, backup.ErrDatabaseInUse) {
+		t.Fatalf("restore while in use: %v", err)
+	}
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	if err := os.WriteFile(target, []byte("old"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := backup.Restore(ctx, snapshot, target, false); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if got := countTasks(t, target); got != 1 {
+		t.Fatalf("restored %d tasks, want 1", got)
+	}
+	if data, err := os.ReadFile(target + ".pre-restore"); err != nil || string(data) != "old" {
+		t.Fatalf("previous database: %q, %v", data, err)
+	}
+
+	garbage := filepath.Join(t.TempDir(), "garbage.db")
+	if err := os.WriteFile(garbage, []byte("not a database"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := backup.Restore(ctx, garbage, target, false); err == nil {
+		t.Fatal("restored a file that is not a database")
+	}
+	if got := countTasks(t, target); got != 1 {
+		t.Fatalf("a failed restore changed the database: %d tasks", got)
+	}
+}
+
+func newMemoryDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestSaveAndLoadMemory(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tasks.json.db")
+	db := newMemoryDB(t)
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	id := createTask(t, db, "Kept across restarts")
+	if err := backup.SaveMemory(ctx, db, path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// Saving again replaces the file.
+	if err := backup.SaveMemory(ctx, db, path); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+
+	restarted := newMemoryDB(t)
+	if loaded, err := backup.LoadMemory(ctx, restarted, path+".missing"); loaded || err != nil {
+		t.Fatalf("missing file: loaded=%v err=%v", loaded, err)
+	}
+	if loaded, err := backup.LoadMemory(ctx, restarted, path); !loaded || err != nil {
+		t.Fatalf("load: loaded=%v err=%v", loaded, err)
+	}
+	if err := migrations.Run(restarted); err != nil {
+		t.Fatalf("migrate loaded database: %v", err)
+	}
+	task, err := repository.NewSQLiteTaskRepository(restarted).GetTask(ctx, id)
+	if err != nil || task.Title != "Kept across restarts" {
+		t.Fatalf("task after load: %v, %v", task, err)
+	}
+}
@@ -0,0 +1,76 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SaveMemory writes the in-memory database db to the file at path. The file
+// is replaced atomically, so a crash while saving leaves the previous copy
+// intact.
+func SaveMemory(ctx context.Context, db *sql.DB, path string) error {
+	partial := path + ".partial"
+	_ = os.Remove(partial)
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, partial); err != nil {
+		_ = os.Remove(partial)
+		return fmt.Errorf("save %s: %w", path, err)
+	}
+	if err := os.Rename(partial, path); err != nil {
+		_ = os.Remove(partial)
+		return err
+	}
+	return nil
+}
+
+// LoadMemory replaces the contents of the in-memory database db with the
+// database file at path and reports whether there was one. A missing file
+// loads nothing, so the first run starts empty. db must hold a single
+// connection, as every connection to ":memory:" opens a database of its own.
+func LoadMemory(ctx context.Context, db *sql.DB, path string) (bool, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	source, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return false, err
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(source)
+	sourceConn, err := source.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("load %s: %w", path, err)
+	}
+	defer func() { _ = sourceConn.Close() }()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	err = conn.Raw(func(destination any) error {
+		return sourceConn.Raw(func(source any) error {
+			backup, err := destination.(*sqlite3.SQLiteConn).Backup("main", source.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				_ = backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return false, fmt.Errorf("load %s: %w", path, err)
+	}
+	return true, nil
+}
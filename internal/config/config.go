@@ -3,7 +3,12 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"task-manager/internal/backup"
+	"task-manager/internal/database"
 )
 
 const (
@@ -12,12 +17,132 @@ const (
 	TaskManagerSqlitePath   = "TASK_MANAGER_SQLITE_PATH"
 	TaskManagerPollInterval = 15 * time.Second
 	TaskManagerSqliteDB     = "tasks.db"
+
+	TaskManagerDBDriver        = "TASK_MANAGER_DB_DRIVER"
+	TaskManagerDefaultDBDriver = "sqlite"
+	TaskManagerPostgresURL     = "TASK_MANAGER_POSTGRES_URL"
+	TaskManagerMemorySnapshot  = "TASK_MANAGER_MEMORY_SNAPSHOT"
+
+	TaskManagerSQLiteJournalMode = "TASK_MANAGER_SQLITE_JOURNAL_MODE"
+	TaskManagerSQLiteBusyTimeout = "TASK_MANAGER_SQLITE_BUSY_TIMEOUT"
+	TaskManagerSQLiteSynchronous = "TASK_MANAGER_SQLITE_SYNCHRONOUS"
+	TaskManagerSQLiteCacheSize   = "TASK_MANAGER_SQLITE_CACHE_SIZE"
+	TaskManagerSQLiteReadConns   = "TASK_MANAGER_SQLITE_READ_CONNS"
+
+	TaskManagerRecurrenceInterval        = "TASK_MANAGER_RECURRENCE_INTERVAL"
+	TaskManagerDefaultRecurrenceInterval = time.Minute
+
+	TaskManagerSchedulerPollInterval        = "TASK_MANAGER_SCHEDULER_POLL_INTERVAL"
+	TaskManagerDefaultSchedulerPollInterval = 5 * time.Second
+	TaskManagerReminderLeadTime             = "TASK_MANAGER_REMINDER_LEAD_TIME"
+	TaskManagerDefaultReminderLeadTime      = time.Hour
+	TaskManagerNotifiers                    = "TASK_MANAGER_NOTIFIERS"
+	TaskManagerDefaultNotifiers             = "log"
+
+	TaskManagerSMTPHost              = "TASK_MANAGER_SMTP_HOST"
+	TaskManagerSMTPPort              = "TASK_MANAGER_SMTP_PORT"
+	TaskManagerDefaultSMTPPort       = 587
+	TaskManagerSMTPUsername          = "TASK_MANAGER_SMTP_USERNAME"
+	TaskManagerSMTPPassword          = "TASK_MANAGER_SMTP_PASSWORD"
+	TaskManagerSMTPTLS               = "TASK_MANAGER_SMTP_TLS"
+	TaskManagerDefaultSMTPTLS        = "starttls"
+	TaskManagerSMTPFrom              = "TASK_MANAGER_SMTP_FROM"
+	TaskManagerDefaultSMTPFrom       = "task-manager@localhost"
+	TaskManagerMaildir               = "TASK_MANAGER_MAILDIR"
+	TaskManagerDigestInterval        = "TASK_MANAGER_DIGEST_INTERVAL"
+	TaskManagerDefaultDigestInterval = time.Minute
+
+	TaskManagerWebhookTimeout            = "TASK_MANAGER_WEBHOOK_TIMEOUT"
+	TaskManagerDefaultWebhookTimeout     = 10 * time.Second
+	TaskManagerWebhookMaxAttempts        = "TASK_MANAGER_WEBHOOK_MAX_ATTEMPTS"
+	TaskManagerDefaultWebhookMaxAttempts = 8
+
+	TaskManagerEventLogRetention        = "TASK_MANAGER_EVENT_LOG_RETENTION"
+	TaskManagerDefaultEventLogRetention = 7 * 24 * time.Hour
+
+	TaskManagerOutboxPollInterval        = "TASK_MANAGER_OUTBOX_POLL_INTERVAL"
+	TaskManagerDefaultOutboxPollInterval = 5 * time.Second
+	TaskManagerOutboxMaxAttempts         = "TASK_MANAGER_OUTBOX_MAX_ATTEMPTS"
+	TaskManagerDefaultOutboxMaxAttempts  = 10
+	TaskManagerOutboxRetention           = "TASK_MANAGER_OUTBOX_RETENTION"
+	TaskManagerDefaultOutboxRetention    = 24 * time.Hour
+
+	TaskManagerBackupDir      = "TASK_MANAGER_BACKUP_DIR"
+	TaskManagerBackupInterval = "TASK_MANAGER_BACKUP_INTERVAL"
+	TaskManagerBackupKeep     = "TASK_MANAGER_BACKUP_KEEP"
+	TaskManagerAdminToken     = "TASK_MANAGER_ADMIN_TOKEN"
+
+	TaskManagerEncryptionKeyFile = "TASK_MANAGER_ENCRYPTION_KEY_FILE"
+
+	TaskManagerGRPCAddr        = "TASK_MANAGER_GRPC_ADDR"
+	TaskManagerDefaultGRPCAddr = ":9090"
+
+	TaskManagerGraphQLMaxDepth             = "TASK_MANAGER_GRAPHQL_MAX_DEPTH"
+	TaskManagerDefaultGraphQLMaxDepth      = 10
+	TaskManagerGraphQLMaxComplexity        = "TASK_MANAGER_GRAPHQL_MAX_COMPLEXITY"
+	TaskManagerDefaultGraphQLMaxComplexity = 5000
 )
 
 type Config struct {
-	Addr        string
-	SQLitePath  string
-	ReadTimeout time.Duration
+	Addr               string
+	SQLitePath         string
+	ReadTimeout        time.Duration
+	RecurrenceInterval time.Duration
+
+	// DBDriver is "sqlite", "postgres" or "memory"; PostgresURL is the
+	// connection string used by postgres.
+	DBDriver    string
+	PostgresURL string
+	// MemorySnapshotPath is where the memory driver loads tasks from on
+	// startup and saves them to on shutdown; empty keeps them in memory only.
+	MemorySnapshotPath string
+
+	// SQLite tunes the connections of the sqlite driver.
+	SQLite database.SQLiteOptions
+
+	SchedulerPollInterval time.Duration
+	ReminderLeadTime      time.Duration
+	Notifiers             []string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPTLS      string
+	SMTPFrom     string
+	// Maildir switches email delivery to a local Maildir (test mode).
+	Maildir        string
+	DigestInterval time.Duration
+
+	WebhookTimeout     time.Duration
+	WebhookMaxAttempts int
+
+	EventLogRetention time.Duration
+
+	OutboxPollInterval time.Duration
+	OutboxMaxAttempts  int
+	OutboxRetention    time.Duration
+
+	// BackupDir is where scheduled backups of the sqlite driver are kept;
+	// empty disables them.
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+	// AdminToken is the bearer token of the /admin routes, which are only
+	// served when it is set.
+	AdminToken string
+
+	// EncryptionKeyFile is the key file for encrypting task descriptions
+	// and comments at rest; empty stores them in plaintext.
+	EncryptionKeyFile string
+
+	// GRPCAddr is where the gRPC API listens, next to the HTTP server.
+	GRPCAddr string
+
+	// GraphQLMaxDepth and GraphQLMaxComplexity bound the queries the
+	// GraphQL API runs.
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
 }
 
 func getenv(key, defaultValue string) string {
@@ -27,17 +152,115 @@ func getenv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getenvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("invalid %s=%q, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
+
+func getenvInt(key string, defaultValue int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("invalid %s=%q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+func getenvList(key, defaultValue string) []string {
+	var values []string
+	for _, value := range strings.Split(getenv(key, defaultValue), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func Load() Config {
 	addr := getenv(TaskManagerAddr, TaskManagerPort)
 	dbPath := getenv(TaskManagerSqlitePath, TaskManagerSqliteDB)
+	dbDriver := strings.ToLower(getenv(TaskManagerDBDriver, TaskManagerDefaultDBDriver))
+	if dbDriver != "sqlite" && dbDriver != "postgres" && dbDriver != "memory" {
+		log.Printf("invalid %s=%q, using default %s", TaskManagerDBDriver, dbDriver, TaskManagerDefaultDBDriver)
+		dbDriver = TaskManagerDefaultDBDriver
+	}
 
 	readTimeout := TaskManagerPollInterval
+	recurrenceInterval := getenvDuration(TaskManagerRecurrenceInterval, TaskManagerDefaultRecurrenceInterval)
+	schedulerPollInterval := getenvDuration(TaskManagerSchedulerPollInterval, TaskManagerDefaultSchedulerPollInterval)
+	reminderLeadTime := getenvDuration(TaskManagerReminderLeadTime, TaskManagerDefaultReminderLeadTime)
+	notifiers := getenvList(TaskManagerNotifiers, TaskManagerDefaultNotifiers)
+	digestInterval := getenvDuration(TaskManagerDigestInterval, TaskManagerDefaultDigestInterval)
 
-	log.Printf("using addr=%s sqlite_path=%s", addr, dbPath)
+	if dbDriver == "sqlite" {
+		log.Printf("using addr=%s sqlite_path=%s", addr, dbPath)
+	} else {
+		log.Printf("using addr=%s db_driver=%s", addr, dbDriver)
+	}
 
 	return Config{
-		Addr:        addr,
-		SQLitePath:  dbPath,
-		ReadTimeout: readTimeout,
+		Addr:               addr,
+		SQLitePath:         dbPath,
+		ReadTimeout:        readTimeout,
+		RecurrenceInterval: recurrenceInterval,
+
+		DBDriver:    dbDriver,
+		PostgresURL: getenv(TaskManagerPostgresURL, ""),
+
+		MemorySnapshotPath: getenv(TaskManagerMemorySnapshot, ""),
+
+		SQLite: database.SQLiteOptions{
+			JournalMode: strings.ToUpper(getenv(TaskManagerSQLiteJournalMode, database.DefaultSQLiteJournalMode)),
+			BusyTimeout: getenvDuration(TaskManagerSQLiteBusyTimeout, database.DefaultSQLiteBusyTimeout),
+			Synchronous: strings.ToUpper(getenv(TaskManagerSQLiteSynchronous, database.DefaultSQLiteSynchronous)),
+			CacheSize:   getenvInt(TaskManagerSQLiteCacheSize, database.DefaultSQLiteCacheSize),
+			ReadConns:   getenvInt(TaskManagerSQLiteReadConns, database.DefaultSQLiteReadConns),
+		},
+
+		SchedulerPollInterval: schedulerPollInterval,
+		ReminderLeadTime:      reminderLeadTime,
+		Notifiers:             notifiers,
+
+		SMTPHost:       getenv(TaskManagerSMTPHost, ""),
+		SMTPPort:       getenvInt(TaskManagerSMTPPort, TaskManagerDefaultSMTPPort),
+		SMTPUsername:   getenv(TaskManagerSMTPUsername, ""),
+		SMTPPassword:   getenv(TaskManagerSMTPPassword, ""),
+		SMTPTLS:        getenv(TaskManagerSMTPTLS, TaskManagerDefaultSMTPTLS),
+		SMTPFrom:       getenv(TaskManagerSMTPFrom, TaskManagerDefaultSMTPFrom),
+		Maildir:        getenv(TaskManagerMaildir, ""),
+		DigestInterval: digestInterval,
+
+		WebhookTimeout:     getenvDuration(TaskManagerWebhookTimeout, TaskManagerDefaultWebhookTimeout),
+		WebhookMaxAttempts: getenvInt(TaskManagerWebhookMaxAttempts, TaskManagerDefaultWebhookMaxAttempts),
+
+		EventLogRetention: getenvDuration(TaskManagerEventLogRetention, TaskManagerDefaultEventLogRetention),
+
+		OutboxPollInterval: getenvDuration(TaskManagerOutboxPollInterval, TaskManagerDefaultOutboxPollInterval),
+		OutboxMaxAttempts:  getenvInt(TaskManagerOutboxMaxAttempts, TaskManagerDefaultOutboxMaxAttempts),
+		OutboxRetention:    getenvDuration(TaskManagerOutboxRetention, TaskManagerDefaultOutboxRetention),
+
+		BackupDir:      getenv(TaskManagerBackupDir, ""),
+		BackupInterval: getenvDuration(TaskManagerBackupInterval, backup.DefaultInterval),
+		BackupKeep:     getenvInt(TaskManagerBackupKeep, backup.DefaultKeep),
+		AdminToken:     getenv(TaskManagerAdminToken, ""),
+
+		EncryptionKeyFile: getenv(TaskManagerEncryptionKeyFile, ""),
+
+		GRPCAddr: getenv(TaskManagerGRPCAddr, TaskManagerDefaultGRPCAddr),
+
+		GraphQLMaxDepth:      getenvInt(TaskManagerGraphQLMaxDepth, TaskManagerDefaultGraphQLMaxDepth),
+		GraphQLMaxComplexity: getenvInt(TaskManagerGraphQLMaxComplexity, TaskManagerDefaultGraphQLMaxComplexity),
 	}
 }
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	DefaultSQLiteJournalMode = "WAL"
+	DefaultSQLiteBusyTimeout = 5 * time.Second
+	DefaultSQLiteSynchronous = "NORMAL"
+	// DefaultSQLiteCacheSize is 20 MiB; negative cache sizes are in KiB.
+	DefaultSQLiteCacheSize = -20000
+	DefaultSQLiteReadConns = 4
+)
+
+type SQLiteOptions struct {
+	// JournalMode is the journal_mode pragma. In WAL mode readers don't
+	// block the writer and the writer doesn't block readers.
+	JournalMode string
+	// BusyTimeout is how long a connection waits for a lock held by another
+	// one, or another process, before failing with "database is locked".
+	BusyTimeout time.Duration
+	// Synchronous is the synchronous pragma. NORMAL is safe from corruption
+	// in WAL mode, but a power loss may roll back the last transactions.
+	Synchronous string
+	// CacheSize is the cache_size pragma of every connection: pages if
+	// positive, KiB if negative.
+	CacheSize int
+	// ReadConns is the size of the reader pool.
+	ReadConns int
+}
+
+func DefaultSQLiteOptions() SQLiteOptions {
+	return SQLiteOptions{
+		JournalMode: DefaultSQLiteJournalMode,
+		BusyTimeout: DefaultSQLiteBusyTimeout,
+		Synchronous: DefaultSQLiteSynchronous,
+		CacheSize:   DefaultSQLiteCacheSize,
+		ReadConns:   DefaultSQLiteReadConns,
+	}
+}
+
+// OpenSQLite opens the SQLite database file at path with two pools. The
+// writer pool has a single connection, so that writers queue for it instead
+// of failing on the database lock; it is the one to run migrations and
+// transactions on. The reader pool has ReadConns read-only connections for
+// queries outside of transactions.
+func OpenSQLite(path string, options SQLiteOptions) (writer, reader *sql.DB, err error) {
+	defaults := DefaultSQLiteOptions()
+	if options.JournalMode == "" {
+		options.JournalMode = defaults.JournalMode
+	}
+	if options.BusyTimeout <= 0 {
+		options.BusyTimeout = defaults.BusyTimeout
+	}
+	if options.Synchronous == "" {
+		options.Synchronous = defaults.Synchronous
+	}
+	if options.CacheSize == 0 {
+		options.CacheSize = defaults.CacheSize
+	}
+	if options.ReadConns <= 0 {
+		options.ReadConns = defaults.ReadConns
+	}
+
+	params := url.Values{}
+	params.Set("_busy_timeout", strconv.FormatInt(options.BusyTimeout.Milliseconds(), 10))
+	params.Set("_synchronous", options.Synchronous)
+	params.Set("_cache_size", strconv.Itoa(options.CacheSize))
+	params.Set("_foreign_keys", "on")
+
+	// Immediate transactions take the write lock up front, so concurrent
+	// read-modify-write transactions wait for each other instead of failing.
+	writerParams := cloneValues(params)
+	writerParams.Set("_journal_mode", options.JournalMode)
+	writerParams.Set("_txlock", "immediate")
+	writer, err = sql.Open(SQLite.DriverName(), path+"?"+writerParams.Encode())
+	if err != nil {
+		return nil, nil, err
+	}
+	writer.SetMaxOpenConns(1)
+	writer.SetMaxIdleConns(1)
+	writer.SetConnMaxLifetime(0)
+	// The journal mode is stored in the database file; connecting the writer
+	// sets it before any reader connects.
+	if err := writer.Ping(); err != nil {
+		_ = writer.Close()
+		return nil, nil, fmt.Errorf("open %s: %w", path, err)
+	}
+
+	readerParams := cloneValues(params)
+	readerParams.Set("_query_only", "true")
+	reader, err = sql.Open(SQLite.DriverName(), path+"?"+readerParams.Encode())
+	if err != nil {
+		_ = writer.Close()
+		return nil, nil, err
+	}
+	reader.SetMaxOpenConns(options.ReadConns)
+	reader.SetMaxIdleConns(options.ReadConns)
+	return writer, reader, nil
+}
+
+func cloneValues(values url.Values) url.Values {
+	cloned := make(url.Values, len(values))
+	for key, value := range values {
+		cloned[key] = append([]string(nil), value...)
+	}
+	return cloned
+}
@@ -0,0 +1,286 @@
+// Package encryption encrypts fields of rows at rest with envelope
+// encryption. Every value is sealed with AES-256-GCM under a fresh data key,
+// and the data key is sealed under a key encryption key from the key file.
+// The key file holds any number of key encryption keys by ID; new values
+// are sealed under the primary one, and the ID of the key a value was
+// sealed under is stored next to it, so keys can be rotated.
+//
+// The key file is JSON:
+//
+//	{
+//	  "primary": "2026-10",
+//	  "keys": {
+//	    "2026-04": "<base64 of 32 random bytes>",
+//	    "2026-10": "<base64 of 32 random bytes>"
+//	  }
+//	}
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	keySize   = 32
+	nonceSize = 12
+	// envelopeVersion is the first byte of every sealed value.
+	envelopeVersion byte = 1
+	// sealedKeySize is the size of a data key sealed under a key encryption
+	// key: nonce, key and GCM tag.
+	sealedKeySize = nonceSize + keySize + 16
+
+	// reloadInterval limits how often an unknown key ID makes the keyring
+	// reload the key file.
+	reloadInterval = 10 * time.Second
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown encryption key")
+	ErrDecrypt    = errors.New("decryption failed")
+)
+
+type keyFile struct {
+	Primary string            `json:"primary"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// Keyring holds the key encryption keys of a key file. It is safe for
+// concurrent use.
+type Keyring struct {
+	path string
+
+	mu         sync.RWMutex
+	primary    string
+	keys       map[string]cipher.AEAD
+	modTime    time.Time
+	lastReload time.Time
+}
+
+// LoadKeyring loads the key file at path.
+func LoadKeyring(path string) (*Keyring, error) {
+	k := &Keyring{path: path}
+	if _, err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// NewKeyring returns a keyring of the given keys, which are 32 bytes each,
+// that isn't backed by a file.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	aeads, err := newAEADs(primary, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &Keyring{primary: primary, keys: aeads}, nil
+}
+
+// Reload reloads the key file if it changed since it was last loaded, and
+// reports whether it did. On error the keyring keeps its keys.
+func (k *Keyring) Reload() (bool, error) {
+	if k.path == "" {
+		return false, nil
+	}
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return false, err
+	}
+	k.mu.RLock()
+	unchanged := info.ModTime().Equal(k.modTime) && k.keys != nil
+	k.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return false, err
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return false, fmt.Errorf("key file %s: %w", k.path, err)
+	}
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return false, fmt.Errorf("key file %s: key %q: %w", k.path, id, err)
+		}
+		keys[id] = key
+	}
+	aeads, err := newAEADs(file.Primary, keys)
+	if err != nil {
+		return false, fmt.Errorf("key file %s: %w", k.path, err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.primary = file.Primary
+	k.keys = aeads
+	k.modTime = info.ModTime()
+	return true, nil
+}
+
+func newAEADs(primary string, keys map[string][]byte) (map[string]cipher.AEAD, error) {
+	if _, ok := keys[primary]; !ok || primary == "" {
+		return nil, fmt.Errorf("primary key %q is not one of the keys", primary)
+	}
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		if id == "" {
+			return nil, errors.New("key IDs must not be empty")
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %q has %d bytes, want %d", id, len(key), keySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		aeads[id] = aead
+	}
+	return aeads, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PrimaryKeyID returns the ID of the key that new values are sealed under.
+func (k *Keyring) PrimaryKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// key returns the key encryption key with the given ID. A key that isn't
+// loaded makes the keyring reload the key file, at most every
+// reloadInterval, so that a process picks up keys that another one, such
+// as the rotate-keys command, has started to use.
+func (k *Keyring) key(keyID string) (cipher.AEAD, error) {
+	k.mu.RLock()
+	aead, ok := k.keys[keyID]
+	k.mu.RUnlock()
+	if ok {
+		return aead, nil
+	}
+
+	k.mu.Lock()
+	reload := k.path != "" && time.Since(k.lastReload) >= reloadInterval
+	if reload {
+		k.lastReload = time.Now()
+	}
+	k.mu.Unlock()
+	if reload {
+		if _, err := k.Reload(); err != nil {
+			return nil, err
+		}
+		k.mu.RLock()
+		aead, ok = k.keys[keyID]
+		k.mu.RUnlock()
+		if ok {
+			return aead, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+}
+
+// Encrypt seals plaintext under the primary key and returns the key's ID
+// and the sealed value. aad is authenticated but not stored; the same aad
+// must be passed to Decrypt. Binding a value to its row this way keeps
+// sealed values from being moved to other rows.
+func (k *Keyring) Encrypt(plaintext, aad string) (string, string, error) {
+	k.mu.RLock()
+	keyID, kek := k.primary, k.keys[k.primary]
+	k.mu.RUnlock()
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	envelope := make([]byte, 1, 1+sealedKeySize+nonceSize+len(plaintext)+data.Overhead())
+	envelope[0] = envelopeVersion
+	envelope, err = seal(envelope, kek, dataKey, []byte(keyID+"\x00"+aad))
+	if err != nil {
+		return "", "", err
+	}
+	envelope, err = seal(envelope, data, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", "", err
+	}
+	return keyID, base64.StdEncoding.EncodeToString(envelope), nil
+}
+
+// Decrypt opens a value that Encrypt sealed under the key keyID.
+func (k *Keyring) Decrypt(keyID, sealed, aad string) (string, error) {
+	kek, err := k.key(keyID)
+	if err != nil {
+		return "", err
+	}
+	envelope, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(envelope) < 1+sealedKeySize+nonceSize || envelope[0] != envelopeVersion {
+		return "", ErrDecrypt
+	}
+	dataKey, err := open(kek, envelope[1:1+sealedKeySize], []byte(keyID+"\x00"+aad))
+	if err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(data, envelope[1+sealedKeySize:], []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// seal appends a random nonce and plaintext sealed with it to dst.
+func seal(dst []byte, aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	dst = append(dst, nonce...)
+	return aead.Seal(dst, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < nonceSize {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// GenerateKey returns a new random key encryption key, base64 encoded for
+// the key file.
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
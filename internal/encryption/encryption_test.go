@@ -0,0 +1,152 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func writeKeyFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+func TestKeyringRoundTrip(t *testing.T) {
+	keyring, err := NewKeyring("a", map[string][]byte{"a": testKey(1), "b": testKey(2)})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	keyID, sealed, err := keyring.Encrypt("top secret", "tasks.description:1")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if keyID != "a" || strings.Contains(sealed, "top secret") {
+		t.Fatalf("sealed under %q as %q", keyID, sealed)
+	}
+	_, again, _ := keyring.Encrypt("top secret", "tasks.description:1")
+	if again == sealed {
+		t.Fatal("the same plaintext sealed to the same value twice")
+	}
+
+	plaintext, err := keyring.Decrypt(keyID, sealed, "tasks.description:1")
+	if err != nil || plaintext != "top secret" {
+		t.Fatalf("decrypt: %q, %v", plaintext, err)
+	}
+	if _, err := keyring.Decrypt(keyID, sealed, "tasks.description:2"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("other aad: %v, want ErrDecrypt", err)
+	}
+	if _, err := keyring.Decrypt("b", sealed, "tasks.description:1"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("other key: %v, want ErrDecrypt", err)
+	}
+	if _, err := keyring.Decrypt("c", sealed, "tasks.description:1"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("unknown key: %v, want ErrUnknownKey", err)
+	}
+
+	envelope, _ := base64.StdEncoding.DecodeString(sealed)
+	envelope[len(envelope)-1] ^= 1
+	if _, err := keyring.Decrypt(keyID, base64.StdEncoding.EncodeToString(envelope), "tasks.description:1"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("tampered: %v, want ErrDecrypt", err)
+	}
+	if _, err := keyring.Decrypt(keyID, "not base64!", "tasks.description:1"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("garbage: %v, want ErrDecrypt", err)
+	}
+}
+
+func TestNewKeyringRejectsBadKeys(t *testing.T) {
+	for name, keys := range map[string]map[string][]byte{
+		"missing primary": {"b": testKey(2)},
+		"short key":       {"a": testKey(1)[:16]},
+	} {
+		if _, err := NewKeyring("a", keys); err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+	}
+}
+
+func TestLoadKeyringReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	a := base64.StdEncoding.EncodeToString(testKey(1))
+	b := base64.StdEncoding.EncodeToString(testKey(2))
+	start := time.Now().Add(-time.Hour)
+	writeKeyFile(t, path, `{"primary": "a", "keys": {"a": "`+a+`"}}`, start)
+
+	keyring, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	_, sealed, err := keyring.Encrypt("note", "aad")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if reloaded, err := keyring.Reload(); reloaded || err != nil {
+		t.Fatalf("reload of an unchanged file: %v, %v", reloaded, err)
+	}
+
+	writeKeyFile(t, path, `{"primary": "b", "keys": {"a": "`+a+`", "b": "`+b+`"}}`, start.Add(time.Minute))
+	if reloaded, err := keyring.Reload(); !reloaded || err != nil {
+		t.Fatalf("reload: %v, %v", reloaded, err)
+	}
+	if primary := keyring.PrimaryKeyID(); primary != "b" {
+		t.Fatalf("primary %q, want b", primary)
+	}
+	if plaintext, err := keyring.Decrypt("a", sealed, "aad"); err != nil || plaintext != "note" {
+		t.Fatalf("decrypt under the old key: %q, %v", plaintext, err)
+	}
+
+	// A broken key file keeps the keys that were loaded.
+	writeKeyFile(t, path, `{"primary": "c", "keys": {"a": "`+a+`"}}`, start.Add(2*time.Minute))
+	if _, err := keyring.Reload(); err == nil {
+		t.Fatal("reload of a bad key file: no error")
+	}
+	if primary := keyring.PrimaryKeyID(); primary != "b" {
+		t.Fatalf("primary %q after a failed reload, want b", primary)
+	}
+}
+
+func TestKeyringReloadsForUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	a := base64.StdEncoding.EncodeToString(testKey(1))
+	b := base64.StdEncoding.EncodeToString(testKey(2))
+	writeKeyFile(t, path, `{"primary": "a", "keys": {"a": "`+a+`"}}`, time.Now().Add(-time.Hour))
+	keyring, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// Another process, such as rotate-keys, seals under a new key.
+	other, err := NewKeyring("b", map[string][]byte{"b": testKey(2)})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	keyID, sealed, _ := other.Encrypt("note", "aad")
+	writeKeyFile(t, path, `{"primary": "b", "keys": {"a": "`+a+`", "b": "`+b+`"}}`, time.Now())
+
+	if plaintext, err := keyring.Decrypt(keyID, sealed, "aad"); err != nil || plaintext != "note" {
+		t.Fatalf("decrypt under a key added to the file: %q, %v", plaintext, err)
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	encoded, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != keySize {
+		t.Fatalf("key %q: %d bytes, %v", encoded, len(key), err)
+	}
+}
@@ -0,0 +1,95 @@
+// Package events defines the task change events emitted by the service
+// layer and a simple in-process bus to fan them out to subscribers.
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+)
+
+type Type string
+
+const (
+	TaskCreated    Type = "task.created"
+	TaskUpdated    Type = "task.updated"
+	TaskDeleted    Type = "task.deleted"
+	TaskAssigned   Type = "task.assigned"
+	CommentCreated Type = "comment.created"
+)
+
+// Event describes a change to a task. Task is the state after the change
+// (the last known state for deletions) and Previous the state before an
+// update.
+type Event struct {
+	Type       Type            `json:"type"`
+	TaskID     uuid.UUID       `json:"task_id"`
+	Project    string          `json:"project"`
+	Task       *models.Task    `json:"task,omitempty"`
+	Previous   *models.Task    `json:"previous,omitempty"`
+	Comment    *models.Comment `json:"comment,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+func NewTaskEvent(eventType Type, task, previous *models.Task) Event {
+	return Event{
+		Type:       eventType,
+		TaskID:     task.ID,
+		Project:    task.Project,
+		Task:       task,
+		Previous:   previous,
+		OccurredAt: time.Now().UTC(),
+	}
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Subscriber receives events published on a Bus.
+type Subscriber func(ctx context.Context, event Event) error
+
+// Bus delivers every published event to all subscribers synchronously.
+// A failing subscriber is logged and doesn't affect the others or the
+// publisher.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []Subscriber
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(subscriber Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, subscriber)
+}
+
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	for _, subscriber := range subscribers {
+		if err := subscriber(ctx, event); err != nil {
+			log.Printf("events: %s for task %s: %v", event.Type, event.TaskID, err)
+		}
+	}
+	return nil
+}
+
+// Discard is a Publisher that drops every event.
+var Discard Publisher = discard{}
+
+type discard struct{}
+
+func (discard) Publish(ctx context.Context, event Event) error {
+	return nil
+}
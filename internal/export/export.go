@@ -0,0 +1,302 @@
+// Package export writes tasks as CSV, newline-delimited JSON or a JSON
+// array, one task at a time, so that exports of any size can be streamed.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	FormatJSON   Format = "json"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// bufferSize is how much of an export is buffered before it is written out.
+const bufferSize = 32 << 10
+
+// ParseFormat parses the name of a format, as used in the format query
+// parameter.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatCSV, FormatNDJSON, FormatJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownFormat, name)
+}
+
+// ContentType returns the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// Negotiate returns the format that an Accept header prefers. JSON is
+// returned when the header is empty or accepts anything.
+func Negotiate(accept string) (Format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return FormatJSON, true
+	}
+	var best Format
+	bestQ := 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(mediaRange, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if name, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && name == "q" {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		var format Format
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "text/csv", "text/*":
+			format = FormatCSV
+		case "application/x-ndjson", "application/ndjson":
+			format = FormatNDJSON
+		case "application/json", "application/*", "*/*":
+			format = FormatJSON
+		default:
+			continue
+		}
+		// The first of equally preferred types wins.
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best, best != ""
+}
+
+// column is a field of a task in an export. value returns false for null.
+type column struct {
+	name  string
+	value func(task *models.Task) (string, bool)
+	// text marks free text, which could be taken for a formula by
+	// spreadsheets.
+	text bool
+}
+
+func field(value func(task *models.Task) string) func(*models.Task) (string, bool) {
+	return func(task *models.Task) (string, bool) { return value(task), true }
+}
+
+func timestamp(t time.Time) (string, bool) {
+	return t.Format(time.RFC3339Nano), true
+}
+
+var columns = []column{
+	{name: "id", value: field(func(task *models.Task) string { return task.ID.String() })},
+	{name: "external_id", value: field(func(task *models.Task) string { return task.ExternalID }), text: true},
+	{name: "project", value: field(func(task *models.Task) string { return task.Project }), text: true},
+	{name: "title", value: field(func(task *models.Task) string { return task.Title }), text: true},
+	{name: "description", value: field(func(task *models.Task) string { return task.Description }), text: true},
+	{name: "status", value: field(func(task *models.Task) string { return string(task.Status) })},
+	{name: "assignee", value: field(func(task *models.Task) string { return task.Assignee }), text: true},
+	{name: "due_at", value: func(task *models.Task) (string, bool) {
+		if task.DueAt == nil {
+			return "", false
+		}
+		return timestamp(*task.DueAt)
+	}},
+	{name: "recurrence_id", value: func(task *models.Task) (string, bool) {
+		if task.RecurrenceID == nil {
+			return "", false
+		}
+		return task.RecurrenceID.String(), true
+	}},
+	{name: "created_at", value: func(task *models.Task) (string, bool) { return timestamp(task.CreatedAt) }},
+	{name: "updated_at", value: func(task *models.Task) (string, bool) { return timestamp(task.UpdatedAt) }},
+}
+
+// ColumnNames returns the names of all columns, which is also the default
+// selection.
+func ColumnNames() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// Columns is a selection of columns in the order they are written.
+type Columns []column
+
+// ParseColumns parses a comma separated list of column names. An empty
+// list selects all columns.
+func ParseColumns(list string) (Columns, error) {
+	if strings.TrimSpace(list) == "" {
+		return columns, nil
+	}
+	var selected Columns
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		i := indexOfColumn(name)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		selected = append(selected, columns[i])
+	}
+	if len(selected) == 0 {
+		return columns, nil
+	}
+	return selected, nil
+}
+
+func indexOfColumn(name string) int {
+	for i, c := range columns {
+		if c.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Writer writes tasks in a format. Close must be called to complete the
+// export; before that the output may be buffered or incomplete.
+type Writer interface {
+	Write(task *models.Task) error
+	Close() error
+}
+
+// NewWriter returns a Writer that writes the selected columns of tasks to
+// w in format.
+func NewWriter(w io.Writer, format Format, selected Columns) Writer {
+	buffered := bufio.NewWriterSize(w, bufferSize)
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(buffered), columns: selected}
+	case FormatNDJSON:
+		return &jsonWriter{w: buffered, columns: selected}
+	}
+	return &jsonWriter{w: buffered, columns: selected, array: true}
+}
+
+type csvWriter struct {
+	w           *csv.Writer
+	columns     Columns
+	wroteHeader bool
+	record      []string
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.wroteHeader {
+		return nil
+	}
+	c.wroteHeader = true
+	header := make([]string, len(c.columns))
+	for i, col := range c.columns {
+		header[i] = col.name
+	}
+	return c.w.Write(header)
+}
+
+func (c *csvWriter) Write(task *models.Task) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.record = c.record[:0]
+	for _, col := range c.columns {
+		value, _ := col.value(task)
+		if col.text {
+			value = escapeFormula(value)
+		}
+		c.record = append(c.record, value)
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormula keeps spreadsheets from evaluating text that starts like a
+// formula by prefixing it with a quote, as OWASP recommends for CSV files.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// jsonWriter writes one object per task with the keys in column order,
+// either as lines or as the elements of an array.
+type jsonWriter struct {
+	w       *bufio.Writer
+	columns Columns
+	array   bool
+	count   int
+	object  []byte
+}
+
+func (j *jsonWriter) Write(task *models.Task) error {
+	j.object = j.object[:0]
+	if j.array {
+		if j.count == 0 {
+			j.object = append(j.object, "[\n"...)
+		} else {
+			j.object = append(j.object, ",\n"...)
+		}
+	}
+	j.count++
+
+	j.object = append(j.object, '{')
+	for i, col := range j.columns {
+		if i > 0 {
+			j.object = append(j.object, ',')
+		}
+		j.object = strconv.AppendQuote(j.object, col.name)
+		j.object = append(j.object, ':')
+		value, ok := col.value(task)
+		if !ok {
+			j.object = append(j.object, "null"...)
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		j.object = append(j.object, encoded...)
+	}
+	j.object = append(j.object, '}')
+	if !j.array {
+		j.object = append(j.object, '\n')
+	}
+	_, err := j.w.Write(j.object)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	if j.array && j.count == 0 {
+		_, _ = j.w.WriteString("[]\n")
+	} else if j.array {
+		_, _ = j.w.WriteString("\n]\n")
+	}
+	return j.w.Flush()
+}
@@ -0,0 +1,124 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+)
+
+func exportTasks(t *testing.T, format Format, columnList string, tasks ...*models.Task) string {
+	t.Helper()
+	columns, err := ParseColumns(columnList)
+	if err != nil {
+		t.Fatalf("columns: %v", err)
+	}
+	var out bytes.Buffer
+	writer := NewWriter(&out, format, columns)
+	for _, task := range tasks {
+		if err := writer.Write(task); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return out.String()
+}
+
+func testTasks() []*models.Task {
+	created := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	dueAt := created.AddDate(0, 0, 7)
+	return []*models.Task{
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000001"), Project: "default", Title: "Pay rent", Description: "Line one\nline \"two\"", Status: models.TaskStatusNew, DueAt: &dueAt, CreatedAt: created, UpdatedAt: created},
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000002"), Project: "default", Title: "=SUM(A1:A9)", Status: models.TaskStatusDone, Assignee: "ann", CreatedAt: created, UpdatedAt: created},
+	}
+}
+
+func TestCSV(t *testing.T) {
+	records, err := csv.NewReader(strings.NewReader(exportTasks(t, FormatCSV, "", testTasks()...))).ReadAll()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(records) != 3 || strings.Join(records[0], ",") != strings.Join(ColumnNames(), ",") {
+		t.Fatalf("records %q", records)
+	}
+	if records[1][4] != "Line one\nline \"two\"" || records[1][7] != "2026-03-08T09:00:00Z" || records[1][8] != "" {
+		t.Fatalf("first row %q", records[1])
+	}
+	if records[2][3] != "'=SUM(A1:A9)" {
+		t.Fatalf("formula was not escaped: %q", records[2][3])
+	}
+
+	if got := exportTasks(t, FormatCSV, "title, status"); got != "title,status\n" {
+		t.Fatalf("empty export %q, want only the header", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var rows []map[string]any
+	if err := json.Unmarshal([]byte(exportTasks(t, FormatJSON, "status,id,due_at", testTasks()...)), &rows); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(rows) != 2 || len(rows[0]) != 3 || rows[0]["due_at"] != "2026-03-08T09:00:00Z" || rows[1]["due_at"] != nil {
+		t.Fatalf("rows %v", rows)
+	}
+	if got := exportTasks(t, FormatJSON, "id"); got != "[]\n" {
+		t.Fatalf("empty export %q", got)
+	}
+	if got := exportTasks(t, FormatJSON, "status,title", testTasks()[1]); got != "[\n{\"status\":\"done\",\"title\":\"=SUM(A1:A9)\"}\n]\n" {
+		t.Fatalf("export %q keeps neither the column order nor the text", got)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	lines := strings.Split(exportTasks(t, FormatNDJSON, "id,description", testTasks()...), "\n")
+	if len(lines) != 3 || lines[2] != "" {
+		t.Fatalf("lines %q", lines)
+	}
+	var row map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &row); err != nil || row["description"] != "Line one\nline \"two\"" {
+		t.Fatalf("first line %q: %v", lines[0], err)
+	}
+	if got := exportTasks(t, FormatNDJSON, ""); got != "" {
+		t.Fatalf("empty export %q", got)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	if _, err := ParseColumns("id,secret"); err == nil {
+		t.Fatal("unknown column: no error")
+	}
+	columns, err := ParseColumns(" ,")
+	if err != nil || len(columns) != len(ColumnNames()) {
+		t.Fatalf("blank list: %d columns, %v", len(columns), err)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   Format
+		ok     bool
+	}{
+		{"", FormatJSON, true},
+		{"*/*", FormatJSON, true},
+		{"text/csv", FormatCSV, true},
+		{"application/x-ndjson", FormatNDJSON, true},
+		{"text/html, application/json;q=0.9", FormatJSON, true},
+		{"application/json;q=0.5, text/csv", FormatCSV, true},
+		{"text/csv;q=0, application/x-ndjson;q=0.1", FormatNDJSON, true},
+		{"text/html", "", false},
+	} {
+		got, ok := Negotiate(tc.accept)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("Negotiate(%q) = %q, %v, want %q, %v", tc.accept, got, ok, tc.want, tc.ok)
+		}
+	}
+}
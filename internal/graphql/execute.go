@@ -0,0 +1,490 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Error is an error of a request as GraphQL responses report it. Path is
+// set for errors of fields.
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	Path      []any      `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	// QueryOnly refuses to run mutations, for requests that must be safe,
+	// like GET requests.
+	QueryOnly bool `json:"-"`
+}
+
+type Response struct {
+	Errors []*Error
+	Data   any
+	// Executed reports whether the operation ran. Responses of requests
+	// that couldn't be parsed or validated only have errors.
+	Executed bool
+}
+
+// MarshalJSON writes the response as GraphQL specifies: data is there,
+// and may be null, if the operation ran.
+func (r *Response) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString("{")
+	if len(r.Errors) > 0 {
+		errs, err := json.Marshal(r.Errors)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString(`"errors":`)
+		out.Write(errs)
+	}
+	if r.Executed {
+		if len(r.Errors) > 0 {
+			out.WriteString(",")
+		}
+		data, err := json.Marshal(r.Data)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString(`"data":`)
+		out.Write(data)
+	}
+	out.WriteString("}")
+	return out.Bytes(), nil
+}
+
+// orderedMap is a response object, whose fields are in the order they
+// were selected in.
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func (m *orderedMap) set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString("{")
+	for i, key := range m.keys {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		out.Write(name)
+		out.WriteString(":")
+		out.Write(value)
+	}
+	out.WriteString("}")
+	return out.Bytes(), nil
+}
+
+// nullValue marks values that are null because of an error, which was
+// already reported.
+type nullReason int
+
+const (
+	// failed is a null that a field resolved to because of an error.
+	failed nullReason = iota + 1
+	// propagated is a null in a non-null position, which makes the
+	// nearest nullable parent null.
+	propagated
+)
+
+// Execute parses, validates and runs the operation of req.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+	op, errs := s.selectOperation(doc, req)
+	if errs != nil {
+		return &Response{Errors: errs}
+	}
+	variables, errs := s.coerceVariables(op, req.Variables)
+	if errs != nil {
+		return &Response{Errors: errs}
+	}
+	if errs := s.validate(doc, op, variables); errs != nil {
+		return &Response{Errors: errs}
+	}
+
+	root := s.query
+	if op.kind == "mutation" {
+		root = s.mutation
+	}
+	e := &executor{fragments: doc.fragments, variables: variables, defined: make(map[string]bool)}
+	for _, definition := range op.variables {
+		e.defined[definition.name] = true
+	}
+	data := e.executeSelections(ctx, root, []any{nil}, [][]any{nil}, op.selections)[0]
+	if data == propagated {
+		data = nil
+	}
+	return &Response{Errors: e.errors, Data: data, Executed: true}
+}
+
+func toError(err error) *Error {
+	if gqlErr, ok := err.(*Error); ok {
+		return gqlErr
+	}
+	return &Error{Message: err.Error()}
+}
+
+func (s *Schema) selectOperation(doc *document, req Request) (*operation, []*Error) {
+	var op *operation
+	switch {
+	case req.OperationName != "":
+		for _, candidate := range doc.operations {
+			if candidate.name == req.OperationName {
+				op = candidate
+			}
+		}
+		if op == nil {
+			return nil, []*Error{{Message: fmt.Sprintf("Unknown operation named %q.", req.OperationName)}}
+		}
+	case len(doc.operations) == 1:
+		op = doc.operations[0]
+	case len(doc.operations) == 0:
+		return nil, []*Error{{Message: "Must provide an operation."}}
+	default:
+		return nil, []*Error{{Message: "Must provide operation name if query contains multiple operations."}}
+	}
+
+	switch {
+	case op.kind == "subscription":
+		return nil, []*Error{{Message: "Subscriptions are not supported.", Locations: []Location{op.loc}}}
+	case op.kind == "mutation" && s.mutation == nil:
+		return nil, []*Error{{Message: "Schema is not configured for mutations.", Locations: []Location{op.loc}}}
+	case op.kind == "mutation" && req.QueryOnly:
+		return nil, []*Error{{Message: "Mutations can only be sent with POST.", Locations: []Location{op.loc}}}
+	}
+	return op, nil
+}
+
+type executor struct {
+	fragments map[string]*fragment
+	variables map[string]any
+	// defined are the variables the operation defines, which may have been
+	// left out of variables.
+	defined map[string]bool
+	errors  []*Error
+}
+
+func (e *executor) addError(message string, path []any, loc Location) {
+	e.errors = append(e.errors, &Error{Message: message, Locations: []Location{loc}, Path: path})
+}
+
+// fieldGroup is the fields of a selection set with the same response key,
+// which are resolved together.
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+// collectFields returns the fields that selections select on t, grouped by
+// response key in order, leaving out skipped ones.
+func (e *executor) collectFields(t *Object, selections []selection, groups []*fieldGroup, visited map[string]bool) []*fieldGroup {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			if !e.included(sel.directives) {
+				continue
+			}
+			key := sel.responseKey()
+			found := false
+			for _, group := range groups {
+				if group.key == key {
+					group.fields = append(group.fields, sel)
+					found = true
+				}
+			}
+			if !found {
+				groups = append(groups, &fieldGroup{key: key, fields: []*field{sel}})
+			}
+		case *inlineFragment:
+			if e.included(sel.directives) && (sel.typeCondition == "" || sel.typeCondition == t.Name) {
+				groups = e.collectFields(t, sel.selections, groups, visited)
+			}
+		case *fragmentSpread:
+			frag := e.fragments[sel.name]
+			if visited[sel.name] || !e.included(sel.directives) || frag.typeCondition != t.Name {
+				continue
+			}
+			visited[sel.name] = true
+			groups = e.collectFields(t, frag.selections, groups, visited)
+		}
+	}
+	return groups
+}
+
+// included evaluates @skip and @include, which were validated.
+func (e *executor) included(directives []*directive) bool {
+	for _, d := range directives {
+		args, _ := coerceArguments(conditionArguments, d.arguments, e.variables, e.defined)
+		condition, _ := args["if"].(bool)
+		if (d.name == "skip" && condition) || (d.name == "include" && !condition) {
+			return false
+		}
+	}
+	return true
+}
+
+// executeSelections resolves selections on every source, which are objects
+// of type t at paths. Each field is resolved for all sources together, so
+// that batched resolvers see the whole level of the response at once. It
+// returns an *orderedMap per source, or propagated.
+func (e *executor) executeSelections(ctx context.Context, t *Object, sources []any, paths [][]any, selections []selection) []any {
+	results := make([]*orderedMap, len(sources))
+	for i := range results {
+		results[i] = &orderedMap{values: make(map[string]any)}
+	}
+	nulled := make([]bool, len(sources))
+
+	for _, group := range e.collectFields(t, selections, nil, make(map[string]bool)) {
+		first := group.fields[0]
+		fieldPaths := make([][]any, len(paths))
+		for i, path := range paths {
+			fieldPaths[i] = append(append([]any{}, path...), group.key)
+		}
+		if first.name == "__typename" {
+			for _, result := range results {
+				result.set(group.key, t.Name)
+			}
+			continue
+		}
+
+		definition := t.Field(first.name)
+		args, _ := coerceArguments(definition.Args, first.arguments, e.variables, e.defined)
+		values := e.resolve(ctx, definition, sources, fieldPaths, args, first.loc)
+		var subSelections []selection
+		for _, f := range group.fields {
+			subSelections = append(subSelections, f.selections...)
+		}
+		values = e.completeValues(ctx, definition.Type, values, fieldPaths, subSelections, first.loc)
+		for i, value := range values {
+			if value == propagated {
+				nulled[i] = true
+			}
+			results[i].set(group.key, value)
+		}
+	}
+
+	out := make([]any, len(sources))
+	for i, result := range results {
+		if nulled[i] {
+			out[i] = propagated
+		} else {
+			out[i] = result
+		}
+	}
+	return out
+}
+
+// resolve returns the values of a field of sources, and failed for those
+// whose resolver failed.
+func (e *executor) resolve(ctx context.Context, definition *Field, sources []any, paths [][]any, args map[string]any, loc Location) []any {
+	if definition.Batch != nil {
+		values, err := callBatch(ctx, definition, sources, args)
+		if err == nil && len(values) != len(sources) {
+			err = fmt.Errorf("batch resolver of %s returned %d values for %d objects", definition.Name, len(values), len(sources))
+		}
+		if err != nil {
+			values = make([]any, len(sources))
+			for i := range values {
+				e.addError(err.Error(), paths[i], loc)
+				values[i] = failed
+			}
+		}
+		return values
+	}
+
+	values := make([]any, len(sources))
+	for i, source := range sources {
+		value, err := callResolve(ctx, definition, source, args)
+		if err != nil {
+			e.addError(err.Error(), paths[i], loc)
+			value = failed
+		}
+		values[i] = value
+	}
+	return values
+}
+
+// callResolve and callBatch turn panics of resolvers into errors, so that
+// one broken field doesn't take the response down.
+func callResolve(ctx context.Context, definition *Field, source any, args map[string]any) (value any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("resolver of %s panicked: %v", definition.Name, r)
+		}
+	}()
+	return definition.Resolve(ctx, source, args)
+}
+
+func callBatch(ctx context.Context, definition *Field, sources []any, args map[string]any) (values []any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("resolver of %s panicked: %v", definition.Name, r)
+		}
+	}()
+	return definition.Batch(ctx, sources, args)
+}
+
+// completeValues turns resolved values of type t into response values.
+func (e *executor) completeValues(ctx context.Context, t Type, values []any, paths [][]any, selections []selection, loc Location) []any {
+	if nonNull, ok := t.(*NonNull); ok {
+		out := e.completeNullable(ctx, nonNull.Of, values, paths, selections, loc)
+		for i, value := range out {
+			switch value {
+			case nil:
+				e.addError(fmt.Sprintf("Cannot return null for non-nullable field %s.", pathString(paths[i])), paths[i], loc)
+				out[i] = propagated
+			case failed:
+				out[i] = propagated
+			}
+		}
+		return out
+	}
+	out := e.completeNullable(ctx, t, values, paths, selections, loc)
+	for i, value := range out {
+		if value == failed || value == propagated {
+			out[i] = nil
+		}
+	}
+	return out
+}
+
+func (e *executor) completeNullable(ctx context.Context, t Type, values []any, paths [][]any, selections []selection, loc Location) []any {
+	out := make([]any, len(values))
+	// present are the indexes of the values that aren't null.
+	var present []int
+	for i, value := range values {
+		if value == failed || value == propagated {
+			out[i] = value
+		} else if !isNull(value) {
+			present = append(present, i)
+		}
+	}
+
+	switch t := t.(type) {
+	case *Scalar:
+		for _, i := range present {
+			serialized, err := t.Serialize(indirect(values[i]))
+			if err != nil {
+				e.addError(err.Error(), paths[i], loc)
+				serialized = failed
+			}
+			out[i] = serialized
+		}
+	case *Enum:
+		for _, i := range present {
+			v := reflect.ValueOf(indirect(values[i]))
+			if v.Kind() != reflect.String || !t.has(v.String()) {
+				e.addError(fmt.Sprintf("Enum %q cannot represent value: %v", t.Name, values[i]), paths[i], loc)
+				out[i] = failed
+				continue
+			}
+			out[i] = v.String()
+		}
+	case *List:
+		// The items of all lists are completed together, and split up
+		// afterwards.
+		var items []any
+		var itemPaths [][]any
+		lengths := make(map[int]int, len(present))
+		for _, i := range present {
+			v := reflect.ValueOf(values[i])
+			if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+				e.addError(fmt.Sprintf("Expected a list for field %s.", pathString(paths[i])), paths[i], loc)
+				out[i] = failed
+				continue
+			}
+			lengths[i] = v.Len()
+			for j := 0; j < v.Len(); j++ {
+				items = append(items, v.Index(j).Interface())
+				itemPaths = append(itemPaths, append(append([]any{}, paths[i]...), j))
+			}
+		}
+		completed := e.completeValues(ctx, t.Of, items, itemPaths, selections, loc)
+		for _, i := range present {
+			n, ok := lengths[i]
+			if !ok {
+				continue
+			}
+			list := completed[:n:n]
+			completed = completed[n:]
+			out[i] = list
+			for _, item := range list {
+				if item == propagated {
+					out[i] = propagated
+					break
+				}
+			}
+		}
+	case *Object:
+		sources := make([]any, len(present))
+		sourcePaths := make([][]any, len(present))
+		for j, i := range present {
+			sources[j] = values[i]
+			sourcePaths[j] = paths[i]
+		}
+		if len(sources) > 0 {
+			for j, result := range e.executeSelections(ctx, t, sources, sourcePaths, selections) {
+				out[present[j]] = result
+			}
+		}
+	}
+	return out
+}
+
+// isNull reports whether a resolved value is nil, including nil pointers,
+// slices and maps in interfaces.
+func isNull(value any) bool {
+	if value == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+// indirect returns what a pointer to a leaf value points to, so that
+// resolvers can return optional values as pointers.
+func indirect(value any) any {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer {
+		return v.Elem().Interface()
+	}
+	return value
+}
+
+func pathString(path []any) string {
+	parts := make([]string, len(path))
+	for i, part := range path {
+		parts[i] = fmt.Sprint(part)
+	}
+	return strings.Join(parts, ".")
+}
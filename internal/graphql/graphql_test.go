@@ -0,0 +1,238 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testAuthor struct {
+	Name string
+}
+
+type testPost struct {
+	ID     string
+	Title  string
+	Author string
+}
+
+// newTestSchema returns a schema of posts whose authors are loaded in
+// batches, counting the batches in *batches.
+func newTestSchema(t *testing.T, batches *int, limits Limits) *Schema {
+	t.Helper()
+	posts := []*testPost{
+		{ID: "1", Title: "First", Author: "ann"},
+		{ID: "2", Title: "Second", Author: "bob"},
+		{ID: "3", Title: "Third", Author: "nobody"},
+	}
+	author := &Object{Name: "Author", Fields: []*Field{
+		{Name: "name", Type: NonNullOf(String), Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+			return source.(*testAuthor).Name, nil
+		}},
+	}}
+	status := &Enum{Name: "Status", Values: []EnumValue{{Name: "DRAFT"}, {Name: "PUBLISHED"}}}
+	post := &Object{Name: "Post", Fields: []*Field{
+		{Name: "id", Type: NonNullOf(ID), Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+			return source.(*testPost).ID, nil
+		}},
+		{Name: "title", Type: NonNullOf(String), Args: []*Argument{{Name: "upper", Type: Boolean, Default: false}},
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				if args["upper"].(bool) {
+					return strings.ToUpper(source.(*testPost).Title), nil
+				}
+				return source.(*testPost).Title, nil
+			}},
+		{Name: "status", Type: status, Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+			return "PUBLISHED", nil
+		}},
+		{Name: "author", Type: author, Batch: func(ctx context.Context, sources []any, args map[string]any) ([]any, error) {
+			*batches++
+			authors := make([]any, len(sources))
+			for i, source := range sources {
+				if name := source.(*testPost).Author; name != "nobody" {
+					authors[i] = &testAuthor{Name: name}
+				}
+			}
+			return authors, nil
+		}},
+		{Name: "requiredAuthor", Type: NonNullOf(author), Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+			if source.(*testPost).Author == "nobody" {
+				return nil, errors.New("no author")
+			}
+			return &testAuthor{Name: source.(*testPost).Author}, nil
+		}},
+	}}
+	query := &Object{Name: "Query", Fields: []*Field{
+		{Name: "posts", Type: NonNullOf(ListOf(NonNullOf(post))), Args: []*Argument{{Name: "first", Type: Int, Default: 10}},
+			Cost: func(args map[string]any) int { return args["first"].(int) },
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				return posts[:min(args["first"].(int), len(posts))], nil
+			}},
+		{Name: "post", Type: post, Args: []*Argument{{Name: "id", Type: NonNullOf(ID)}},
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				for _, p := range posts {
+					if p.ID == args["id"] {
+						return p, nil
+					}
+				}
+				return nil, nil
+			}},
+	}}
+	input := &InputObject{Name: "PostInput", Fields: []*Argument{
+		{Name: "title", Type: NonNullOf(String)},
+		{Name: "status", Type: status, Default: "DRAFT"},
+	}}
+	mutation := &Object{Name: "Mutation", Fields: []*Field{
+		{Name: "echo", Type: NonNullOf(String), Args: []*Argument{{Name: "input", Type: NonNullOf(input)}},
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				input := args["input"].(map[string]any)
+				return input["title"].(string) + "/" + input["status"].(string), nil
+			}},
+	}}
+	schema, err := NewSchema(query, mutation, limits)
+	if err != nil {
+		t.Fatalf("NewSchema: %v", err)
+	}
+	return schema
+}
+
+func execute(t *testing.T, schema *Schema, req Request) string {
+	t.Helper()
+	data, err := json.Marshal(schema.Execute(context.Background(), req))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(data)
+}
+
+func TestExecute(t *testing.T) {
+	var batches int
+	schema := newTestSchema(t, &batches, Limits{})
+
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			name: "fields in selection order with aliases",
+			req:  Request{Query: `{ posts(first: 2) { title loud: title(upper: true) id __typename } }`},
+			want: `{"data":{"posts":[{"title":"First","loud":"FIRST","id":"1","__typename":"Post"},{"title":"Second","loud":"SECOND","id":"2","__typename":"Post"}]}}`,
+		},
+		{
+			name: "variables, fragments and directives",
+			req: Request{
+				Query: `query Q($id: ID!, $withStatus: Boolean!) {
+					post(id: $id) { ...Fields status @include(if: $withStatus) title @skip(if: true) }
+				}
+				fragment Fields on Post { id ... on Post { author { name } } }`,
+				Variables: map[string]any{"id": "2", "withStatus": false},
+			},
+			want: `{"data":{"post":{"id":"2","author":{"name":"bob"}}}}`,
+		},
+		{
+			name: "arguments of variables that are left out get their defaults",
+			req:  Request{Query: `query($first: Int) { posts(first: $first) { id } }`},
+			want: `{"data":{"posts":[{"id":"1"},{"id":"2"},{"id":"3"}]}}`,
+		},
+		{
+			name: "input objects with defaults",
+			req:  Request{Query: `mutation { echo(input: {title: "Hi"}) }`},
+			want: `{"data":{"echo":"Hi/DRAFT"}}`,
+		},
+		{
+			name: "errors null the nearest nullable parent",
+			req:  Request{Query: `{ post(id: 3) { id requiredAuthor { name } } }`},
+			want: `{"errors":[{"message":"no author","locations":[{"line":1,"column":20}],"path":["post","requiredAuthor"]}],"data":{"post":null}}`,
+		},
+		{
+			name: "validation errors",
+			req:  Request{Query: `{ post(id: 1) { missing } }`},
+			want: `{"errors":[{"message":"Cannot query field \"missing\" on type \"Post\".","locations":[{"line":1,"column":17}]}]}`,
+		},
+		{
+			name: "syntax errors",
+			req:  Request{Query: `{ posts { id }`},
+			want: `{"errors":[{"message":"Syntax Error: Expected Name, found \u003cEOF\u003e.","locations":[{"line":1,"column":15}]}]}`,
+		},
+		{
+			name: "mutations in query-only requests",
+			req:  Request{Query: `mutation { echo(input: {title: "Hi"}) }`, QueryOnly: true},
+			want: `{"errors":[{"message":"Mutations can only be sent with POST.","locations":[{"line":1,"column":1}]}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := execute(t, schema, tt.req); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteBatches(t *testing.T) {
+	var batches int
+	schema := newTestSchema(t, &batches, Limits{})
+	got := execute(t, schema, Request{Query: `{ posts { author { name } again: author { name } } }`})
+	want := `{"data":{"posts":[{"author":{"name":"ann"},"again":{"name":"ann"}},{"author":{"name":"bob"},"again":{"name":"bob"}},{"author":null,"again":null}]}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if batches != 2 {
+		t.Errorf("resolved the authors of the list in %d batches, want one per field", batches)
+	}
+}
+
+func TestExecuteLimits(t *testing.T) {
+	var batches int
+	schema := newTestSchema(t, &batches, Limits{MaxDepth: 3, MaxComplexity: 25})
+
+	if got := execute(t, schema, Request{Query: `{ posts(first: 3) { id author { name } } }`}); strings.Contains(got, "errors") {
+		t.Errorf("query within the limits: %s", got)
+	}
+	if got := execute(t, schema, Request{Query: `{ posts(first: 30) { id } }`}); !strings.Contains(got, "complexity of 31, more than the limit of 25") {
+		t.Errorf("complex query: %s", got)
+	}
+	if got := execute(t, schema, Request{Query: `{ post(id: 1) { ... on Post { author { name { x } } } } }`}); !strings.Contains(got, "errors") {
+		t.Errorf("invalid deep query: %s", got)
+	}
+	deep := `{ post(id: 1) { author { name } } }`
+	if got := execute(t, newTestSchema(t, &batches, Limits{MaxDepth: 2}), Request{Query: deep}); !strings.Contains(got, "3 levels deep, more than the limit of 2") {
+		t.Errorf("deep query: %s", got)
+	}
+
+	// Each fragment spreads the next one twice, which doubles the work of
+	// walking them without a cache.
+	var fragments strings.Builder
+	fragments.WriteString(`{ ...F0 }`)
+	for i := range 64 {
+		fmt.Fprintf(&fragments, " fragment F%d on Query { ...F%d ...F%d }", i, i+1, i+1)
+	}
+	fragments.WriteString(" fragment F64 on Query { post(id: 1) { id } }")
+	if got := execute(t, schema, Request{Query: fragments.String()}); !strings.Contains(got, "more than the limit of 25") {
+		t.Errorf("nested fragments: %s", got)
+	}
+	// A fragment whose cost is known already is still checked where it is
+	// spread.
+	spread := `{ post(id: 1) { ...P author { ...P } } } fragment P on Post { id }`
+	if got := execute(t, schema, Request{Query: spread}); !strings.Contains(got, `objects of type \"Author\" can never be of type \"Post\"`) {
+		t.Errorf("fragment spread on another type: %s", got)
+	}
+}
+
+func TestSDL(t *testing.T) {
+	var batches int
+	sdl := newTestSchema(t, &batches, Limits{}).SDL()
+	for _, want := range []string{
+		"type Query {\n  posts(first: Int = 10): [Post!]!\n",
+		"input PostInput {\n  title: String!\n  status: Status = DRAFT\n}",
+		"enum Status {\n  DRAFT\n  PUBLISHED\n}",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("SDL is missing %q:\n%s", want, sdl)
+		}
+	}
+}
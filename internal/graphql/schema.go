@@ -0,0 +1,539 @@
+// Package graphql executes GraphQL queries and mutations against a schema
+// of Go resolvers. It implements the parts of the specification that an API
+// over a fixed set of object types needs: operations with variables,
+// fragments, @skip and @include, input objects, enums and custom scalars.
+// Interfaces, unions, subscriptions and introspection beyond __typename
+// aren't supported; Schema.SDL describes the schema for tooling instead.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is a *Scalar, *Enum, *Object, *InputObject, *List or *NonNull.
+type Type interface {
+	String() string
+}
+
+// Scalar is a leaf type.
+type Scalar struct {
+	Name        string
+	Description string
+	// Serialize turns what resolvers return into a JSON value.
+	Serialize func(value any) (any, error)
+	// Parse turns an input value into what resolvers get. Inputs are
+	// int64, float64, string or bool from literals, and json.Number, string
+	// or bool from variables.
+	Parse func(value any) (any, error)
+}
+
+// Enum is a leaf type whose values are names. Resolvers return, and
+// arguments are, their names as strings.
+type Enum struct {
+	Name        string
+	Description string
+	Values      []EnumValue
+}
+
+type EnumValue struct {
+	Name        string
+	Description string
+}
+
+// Object is a type with fields, whose values are what resolvers return for
+// them.
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+type Field struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*Argument
+	// Resolve returns the value of the field of source, which is what the
+	// field of the parent object resolved to, or nil for the fields of
+	// Query and Mutation.
+	Resolve func(ctx context.Context, source any, args map[string]any) (any, error)
+	// Batch resolves the field of many objects at once and returns their
+	// values in the same order. It is used instead of Resolve if set, so
+	// that a field of all objects of a list costs one query rather than one
+	// per object.
+	Batch func(ctx context.Context, sources []any, args map[string]any) ([]any, error)
+	// Cost is how many times the selections of the field count towards the
+	// complexity of a query, like the page size of a connection. Nil counts
+	// them once.
+	Cost func(args map[string]any) int
+}
+
+// Argument is an argument of a field. Arguments that are left out get their
+// Default unless it is nil, and are missing from args otherwise.
+type Argument struct {
+	Name        string
+	Description string
+	Type        Type
+	Default     any
+}
+
+// InputObject is the type of arguments that are objects, which resolvers
+// get as map[string]any.
+type InputObject struct {
+	Name        string
+	Description string
+	Fields      []*Argument
+}
+
+type List struct {
+	Of Type
+}
+
+type NonNull struct {
+	Of Type
+}
+
+func (t *Scalar) String() string      { return t.Name }
+func (t *Enum) String() string        { return t.Name }
+func (t *Object) String() string      { return t.Name }
+func (t *InputObject) String() string { return t.Name }
+func (t *List) String() string        { return "[" + t.Of.String() + "]" }
+func (t *NonNull) String() string     { return t.Of.String() + "!" }
+
+// ListOf returns the type of lists of of.
+func ListOf(of Type) *List {
+	return &List{Of: of}
+}
+
+// NonNullOf returns the type of values of of that can't be null.
+func NonNullOf(of Type) *NonNull {
+	return &NonNull{Of: of}
+}
+
+// Field returns the field called name, or nil.
+func (t *Object) Field(name string) *Field {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (t *InputObject) field(name string) *Argument {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (t *Enum) has(name string) bool {
+	for _, v := range t.Values {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func unwrap(t Type) Type {
+	for {
+		switch wrapper := t.(type) {
+		case *List:
+			t = wrapper.Of
+		case *NonNull:
+			t = wrapper.Of
+		default:
+			return t
+		}
+	}
+}
+
+func isLeaf(t Type) bool {
+	switch unwrap(t).(type) {
+	case *Scalar, *Enum:
+		return true
+	}
+	return false
+}
+
+func isInput(t Type) bool {
+	switch unwrap(t).(type) {
+	case *Scalar, *Enum, *InputObject:
+		return true
+	}
+	return false
+}
+
+// Built-in scalars.
+var (
+	Int = &Scalar{
+		Name:        "Int",
+		Description: "A signed 32-bit integer.",
+		Serialize: func(value any) (any, error) {
+			v := reflect.ValueOf(value)
+			switch v.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				if n := v.Int(); n >= math.MinInt32 && n <= math.MaxInt32 {
+					return n, nil
+				}
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				if n := v.Uint(); n <= math.MaxInt32 {
+					return int64(n), nil
+				}
+			}
+			return nil, fmt.Errorf("Int cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			var n int64
+			switch v := value.(type) {
+			case int64:
+				n = v
+			case json.Number:
+				parsed, err := strconv.ParseInt(string(v), 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("Int cannot represent non-integer value: %s", v)
+				}
+				n = parsed
+			default:
+				return nil, fmt.Errorf("Int cannot represent non-integer value: %s", inputString(value))
+			}
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("Int cannot represent non 32-bit signed integer value: %d", n)
+			}
+			return int(n), nil
+		},
+	}
+	Float = &Scalar{
+		Name:        "Float",
+		Description: "A double-precision floating-point number.",
+		Serialize: func(value any) (any, error) {
+			v := reflect.ValueOf(value)
+			switch v.Kind() {
+			case reflect.Float32, reflect.Float64:
+				return v.Float(), nil
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return float64(v.Int()), nil
+			}
+			return nil, fmt.Errorf("Float cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			switch v := value.(type) {
+			case int64:
+				return float64(v), nil
+			case float64:
+				return v, nil
+			case json.Number:
+				return v.Float64()
+			}
+			return nil, fmt.Errorf("Float cannot represent non numeric value: %s", inputString(value))
+		},
+	}
+	String = &Scalar{
+		Name:        "String",
+		Description: "UTF-8 text.",
+		Serialize: func(value any) (any, error) {
+			if v := reflect.ValueOf(value); v.Kind() == reflect.String {
+				return v.String(), nil
+			}
+			return nil, fmt.Errorf("String cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			if s, ok := value.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("String cannot represent a non string value: %s", inputString(value))
+		},
+	}
+	Boolean = &Scalar{
+		Name:        "Boolean",
+		Description: "true or false.",
+		Serialize: func(value any) (any, error) {
+			if v := reflect.ValueOf(value); v.Kind() == reflect.Bool {
+				return v.Bool(), nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			if b, ok := value.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent a non boolean value: %s", inputString(value))
+		},
+	}
+	ID = &Scalar{
+		Name:        "ID",
+		Description: "An opaque identifier, serialized as a string.",
+		Serialize: func(value any) (any, error) {
+			switch v := value.(type) {
+			case fmt.Stringer:
+				return v.String(), nil
+			case string:
+				return v, nil
+			}
+			if v := reflect.ValueOf(value); v.CanInt() {
+				return strconv.FormatInt(v.Int(), 10), nil
+			}
+			return nil, fmt.Errorf("ID cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			switch v := value.(type) {
+			case string:
+				return v, nil
+			case int64:
+				return strconv.FormatInt(v, 10), nil
+			case json.Number:
+				if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+					return string(v), nil
+				}
+			}
+			return nil, fmt.Errorf("ID cannot represent value: %s", inputString(value))
+		},
+	}
+)
+
+var builtinScalars = []*Scalar{Int, Float, String, Boolean, ID}
+
+// enumLiteral is an enum value written in a document, which only enums
+// accept.
+type enumLiteral string
+
+// inputString formats an input value for error messages.
+func inputString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case enumLiteral:
+		return string(v)
+	case nil:
+		return "null"
+	}
+	if data, err := json.Marshal(value); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// Limits bound the queries a schema runs; zero values don't limit them.
+type Limits struct {
+	// MaxDepth is how deeply fields may be nested.
+	MaxDepth int
+	// MaxComplexity is how many fields a query may resolve, counting the
+	// fields under lists by Field.Cost.
+	MaxComplexity int
+}
+
+type Schema struct {
+	query    *Object
+	mutation *Object
+	limits   Limits
+	types    map[string]Type
+}
+
+// NewSchema returns the schema with the root types query and mutation;
+// mutation may be nil. It fails if two types have the same name or a
+// field or argument has a type that it can't have.
+func NewSchema(query, mutation *Object, limits Limits) (*Schema, error) {
+	s := &Schema{query: query, mutation: mutation, limits: limits, types: make(map[string]Type)}
+	for _, scalar := range builtinScalars {
+		s.types[scalar.Name] = scalar
+	}
+	if err := s.addType(query); err != nil {
+		return nil, err
+	}
+	if mutation != nil {
+		if err := s.addType(mutation); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Schema) addType(t Type) error {
+	named := unwrap(t)
+	name := named.String()
+	if existing, ok := s.types[name]; ok {
+		if existing != named {
+			return fmt.Errorf("graphql: two types are called %s", name)
+		}
+		return nil
+	}
+	s.types[name] = named
+	switch named := named.(type) {
+	case *Object:
+		for _, f := range named.Fields {
+			if f.Resolve == nil && f.Batch == nil {
+				return fmt.Errorf("graphql: %s.%s has no resolver", name, f.Name)
+			}
+			if isInput(f.Type) && !isLeaf(f.Type) {
+				return fmt.Errorf("graphql: %s.%s has the input type %s", name, f.Name, f.Type)
+			}
+			if err := s.addType(f.Type); err != nil {
+				return err
+			}
+			if err := s.addArguments(name+"."+f.Name, f.Args); err != nil {
+				return err
+			}
+		}
+	case *InputObject:
+		if err := s.addArguments(name, named.Fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) addArguments(owner string, args []*Argument) error {
+	for _, arg := range args {
+		if !isInput(arg.Type) {
+			return fmt.Errorf("graphql: %s(%s) has the output type %s", owner, arg.Name, arg.Type)
+		}
+		if err := s.addType(arg.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typeOf returns the schema type of a type in a document, or nil if it
+// doesn't name one.
+func (s *Schema) typeOf(ref *typeRef) Type {
+	var t Type
+	if ref.elem != nil {
+		elem := s.typeOf(ref.elem)
+		if elem == nil {
+			return nil
+		}
+		t = ListOf(elem)
+	} else if t = s.types[ref.name]; t == nil {
+		return nil
+	}
+	if ref.nonNull {
+		t = NonNullOf(t)
+	}
+	return t
+}
+
+// SDL returns the schema in the schema definition language.
+func (s *Schema) SDL() string {
+	var names []string
+	for name, t := range s.types {
+		if scalar, ok := t.(*Scalar); ok && isBuiltin(scalar) {
+			continue
+		}
+		names = append(names, name)
+	}
+	// Query and Mutation come first, the other types by name.
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := s.rootRank(names[i]), s.rootRank(names[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+
+	var out strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		switch t := s.types[name].(type) {
+		case *Scalar:
+			writeDescription(&out, t.Description, "")
+			fmt.Fprintf(&out, "scalar %s\n", t.Name)
+		case *Enum:
+			writeDescription(&out, t.Description, "")
+			fmt.Fprintf(&out, "enum %s {\n", t.Name)
+			for _, v := range t.Values {
+				writeDescription(&out, v.Description, "  ")
+				fmt.Fprintf(&out, "  %s\n", v.Name)
+			}
+			out.WriteString("}\n")
+		case *Object:
+			writeDescription(&out, t.Description, "")
+			fmt.Fprintf(&out, "type %s {\n", t.Name)
+			for _, f := range t.Fields {
+				writeDescription(&out, f.Description, "  ")
+				fmt.Fprintf(&out, "  %s%s: %s\n", f.Name, sdlArguments(f.Args), f.Type)
+			}
+			out.WriteString("}\n")
+		case *InputObject:
+			writeDescription(&out, t.Description, "")
+			fmt.Fprintf(&out, "input %s {\n", t.Name)
+			for _, f := range t.Fields {
+				writeDescription(&out, f.Description, "  ")
+				fmt.Fprintf(&out, "  %s: %s%s\n", f.Name, f.Type, sdlDefault(f))
+			}
+			out.WriteString("}\n")
+		}
+	}
+	return out.String()
+}
+
+func (s *Schema) rootRank(name string) int {
+	switch {
+	case name == s.query.Name:
+		return 0
+	case s.mutation != nil && name == s.mutation.Name:
+		return 1
+	}
+	return 2
+}
+
+func isBuiltin(scalar *Scalar) bool {
+	for _, builtin := range builtinScalars {
+		if scalar == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+func writeDescription(out *strings.Builder, description, indent string) {
+	if description == "" {
+		return
+	}
+	if !strings.Contains(description, "\n") {
+		fmt.Fprintf(out, "%s%s\n", indent, strconv.Quote(description))
+		return
+	}
+	fmt.Fprintf(out, "%s\"\"\"\n", indent)
+	for _, line := range strings.Split(strings.ReplaceAll(description, `"""`, `\"""`), "\n") {
+		fmt.Fprintf(out, "%s%s\n", indent, line)
+	}
+	fmt.Fprintf(out, "%s\"\"\"\n", indent)
+}
+
+func sdlArguments(args []*Argument) string {
+	if len(args) == 0 {
+		return ""
+	}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.Name + ": " + arg.Type.String() + sdlDefault(arg)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func sdlDefault(arg *Argument) string {
+	if arg.Default == nil {
+		return ""
+	}
+	if _, ok := unwrap(arg.Type).(*Enum); ok {
+		return fmt.Sprintf(" = %v", arg.Default)
+	}
+	data, err := json.Marshal(arg.Default)
+	if err != nil {
+		return ""
+	}
+	return " = " + string(data)
+}
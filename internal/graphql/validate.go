@@ -0,0 +1,474 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// validator checks an operation against the schema before it runs, and
+// measures its depth and complexity.
+type validator struct {
+	schema    *Schema
+	doc       *document
+	variables map[string]any
+	defined   map[string]bool
+	errors    []*Error
+	// visiting holds the fragments being walked, to find cycles.
+	visiting map[string]bool
+	// fragments holds the fragments that were walked already, so that a
+	// fragment spread many times is walked once.
+	fragments map[string]fragmentCost
+}
+
+// fragmentCost is how many levels a fragment's selections go below the
+// level they are spread at, and their complexity.
+type fragmentCost struct {
+	depth, complexity int
+}
+
+// maxCost caps complexities, which nested fragments and field costs could
+// otherwise grow past the range of int.
+const maxCost = 1<<30 - 1
+
+func (v *validator) errorf(loc Location, format string, args ...any) {
+	v.errors = append(v.errors, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}})
+}
+
+// validate checks op with its coerced variables and returns the errors
+// that keep it from running.
+func (s *Schema) validate(doc *document, op *operation, variables map[string]any) []*Error {
+	v := &validator{schema: s, doc: doc, variables: variables, defined: make(map[string]bool), visiting: make(map[string]bool), fragments: make(map[string]fragmentCost)}
+	for _, definition := range op.variables {
+		v.defined[definition.name] = true
+	}
+	for _, frag := range doc.fragments {
+		if _, ok := s.types[frag.typeCondition].(*Object); !ok {
+			v.errorf(frag.loc, "Unknown type %q.", frag.typeCondition)
+		}
+	}
+	root := s.query
+	if op.kind == "mutation" {
+		root = s.mutation
+	}
+	v.directives(op.directives)
+	depth, complexity := v.selections(root, op.selections, 1)
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	if s.limits.MaxDepth > 0 && depth > s.limits.MaxDepth {
+		v.errorf(op.loc, "The query is %d levels deep, more than the limit of %d.", depth, s.limits.MaxDepth)
+	}
+	if s.limits.MaxComplexity > 0 && complexity > s.limits.MaxComplexity {
+		v.errorf(op.loc, "The query has a complexity of %d, more than the limit of %d.", complexity, s.limits.MaxComplexity)
+	}
+	return v.errors
+}
+
+// selections checks selections of fields of t at level depth, and returns
+// the depth they reach and their complexity.
+func (v *validator) selections(t *Object, selections []selection, depth int) (maxDepth, complexity int) {
+	maxDepth = depth
+	for _, sel := range selections {
+		var d, c int
+		switch sel := sel.(type) {
+		case *field:
+			d, c = v.field(t, sel, depth)
+		case *inlineFragment:
+			v.directives(sel.directives)
+			if sel.typeCondition != "" && sel.typeCondition != t.Name {
+				v.typeCondition(t, sel.typeCondition, sel.loc)
+				continue
+			}
+			d, c = v.selections(t, sel.selections, depth)
+		case *fragmentSpread:
+			v.directives(sel.directives)
+			frag, ok := v.doc.fragments[sel.name]
+			if !ok {
+				v.errorf(sel.loc, "Unknown fragment %q.", sel.name)
+				continue
+			}
+			// The cost is the same wherever the fragment is spread, but
+			// whether it may be spread there is not.
+			if frag.typeCondition != t.Name {
+				v.typeCondition(t, frag.typeCondition, sel.loc)
+				continue
+			}
+			if cost, ok := v.fragments[frag.name]; ok {
+				d, c = depth+cost.depth, cost.complexity
+				break
+			}
+			if v.visiting[frag.name] {
+				v.errorf(sel.loc, "Cannot spread fragment %q within itself.", frag.name)
+				continue
+			}
+			v.visiting[frag.name] = true
+			d, c = v.selections(t, frag.selections, depth)
+			delete(v.visiting, frag.name)
+			v.fragments[frag.name] = fragmentCost{depth: d - depth, complexity: c}
+		}
+		maxDepth = max(maxDepth, d)
+		complexity = min(complexity+c, maxCost)
+	}
+	return maxDepth, complexity
+}
+
+func (v *validator) typeCondition(t *Object, condition string, loc Location) {
+	if _, ok := v.schema.types[condition].(*Object); !ok {
+		v.errorf(loc, "Unknown type %q.", condition)
+		return
+	}
+	v.errorf(loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", t.Name, condition)
+}
+
+func (v *validator) field(t *Object, f *field, depth int) (int, int) {
+	v.directives(f.directives)
+	if f.name == "__typename" {
+		if len(f.arguments) > 0 || len(f.selections) > 0 {
+			v.errorf(f.loc, "Field \"__typename\" takes no arguments or selections.")
+		}
+		return depth, 1
+	}
+	definition := t.Field(f.name)
+	if definition == nil {
+		v.errorf(f.loc, "Cannot query field %q on type %q.", f.name, t.Name)
+		return depth, 0
+	}
+	args, err := coerceArguments(definition.Args, f.arguments, v.variables, v.defined)
+	if err != nil {
+		err.Locations = []Location{f.loc}
+		v.errors = append(v.errors, err)
+	}
+
+	object, isObject := unwrap(definition.Type).(*Object)
+	switch {
+	case !isObject && len(f.selections) > 0:
+		v.errorf(f.loc, "Field %q must not have a selection since type %q has no subfields.", f.name, definition.Type)
+		return depth, 1
+	case isObject && len(f.selections) == 0:
+		v.errorf(f.loc, "Field %q of type %q must have a selection of subfields.", f.name, definition.Type)
+		return depth, 1
+	case !isObject:
+		return depth, 1
+	}
+	d, c := v.selections(object, f.selections, depth+1)
+	if definition.Cost != nil && err == nil {
+		if cost := max(definition.Cost(args), 1); c > maxCost/cost {
+			c = maxCost
+		} else {
+			c *= cost
+		}
+	}
+	return d, min(1+c, maxCost)
+}
+
+func (v *validator) directives(directives []*directive) {
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			v.errorf(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		if _, err := coerceArguments(conditionArguments, d.arguments, v.variables, v.defined); err != nil {
+			err.Locations = []Location{d.loc}
+			v.errors = append(v.errors, err)
+		}
+	}
+}
+
+// conditionArguments are the arguments of @skip and @include.
+var conditionArguments = []*Argument{{Name: "if", Type: NonNullOf(Boolean)}}
+
+// coerceArguments returns the values of the arguments of a field, with
+// variables substituted and defaults for those that were left out.
+// defined are the variables of the operation, which may be missing from
+// variables if they have no value.
+func coerceArguments(definitions []*Argument, args []*argument, variables map[string]any, defined map[string]bool) (map[string]any, *Error) {
+	values := make(map[string]any, len(definitions))
+	for _, arg := range args {
+		if argumentDefinition(definitions, arg.name) == nil {
+			return nil, &Error{Message: fmt.Sprintf("Unknown argument %q.", arg.name), Locations: []Location{arg.loc}}
+		}
+	}
+	for _, definition := range definitions {
+		var arg *argument
+		for _, candidate := range args {
+			if candidate.name == definition.Name {
+				arg = candidate
+			}
+		}
+		present := arg != nil
+		if present && arg.value.kind == variableValue {
+			if !defined[arg.value.raw] {
+				return nil, &Error{Message: fmt.Sprintf("Variable \"$%s\" is not defined.", arg.value.raw), Locations: []Location{arg.loc}}
+			}
+			_, present = variables[arg.value.raw]
+		}
+		if !present {
+			if definition.Default != nil {
+				values[definition.Name] = definition.Default
+			} else if _, ok := definition.Type.(*NonNull); ok {
+				return nil, &Error{Message: fmt.Sprintf("Argument %q of required type %q was not provided.", definition.Name, definition.Type)}
+			}
+			continue
+		}
+		value, err := coerceLiteral(definition.Type, arg.value, variables, defined)
+		if err != nil {
+			return nil, &Error{Message: fmt.Sprintf("Argument %q has an invalid value: %v.", definition.Name, err), Locations: []Location{arg.loc}}
+		}
+		values[definition.Name] = value
+	}
+	return values, nil
+}
+
+func argumentDefinition(definitions []*Argument, name string) *Argument {
+	for _, definition := range definitions {
+		if definition.Name == name {
+			return definition
+		}
+	}
+	return nil
+}
+
+// coerceLiteral returns the value of v, written in a document, as type t.
+func coerceLiteral(t Type, v *value, variables map[string]any, defined map[string]bool) (any, error) {
+	if v.kind == variableValue {
+		if !defined[v.raw] {
+			return nil, fmt.Errorf("variable \"$%s\" is not defined", v.raw)
+		}
+		value := variables[v.raw]
+		if _, ok := t.(*NonNull); ok && value == nil {
+			return nil, fmt.Errorf("expected a value of type %s, but $%s is null", t, v.raw)
+		}
+		return value, nil
+	}
+	if nonNull, ok := t.(*NonNull); ok {
+		if v.kind == nullValue {
+			return nil, fmt.Errorf("expected a value of type %s, found null", t)
+		}
+		return coerceLiteral(nonNull.Of, v, variables, defined)
+	}
+	if v.kind == nullValue {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		if v.kind != listValue {
+			item, err := coerceLiteral(t.Of, v, variables, defined)
+			if err != nil {
+				return nil, err
+			}
+			return []any{item}, nil
+		}
+		items := make([]any, len(v.list))
+		for i, itemValue := range v.list {
+			item, err := coerceLiteral(t.Of, itemValue, variables, defined)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case *InputObject:
+		if v.kind != objectValue {
+			return nil, fmt.Errorf("expected an object of type %s", t.Name)
+		}
+		fields := make(map[string]*value, len(v.fields))
+		for _, f := range v.fields {
+			if t.field(f.name) == nil {
+				return nil, fmt.Errorf("field %q is not defined by type %s", f.name, t.Name)
+			}
+			fields[f.name] = f.value
+		}
+		object := make(map[string]any, len(t.Fields))
+		for _, definition := range t.Fields {
+			fieldValue, ok := fields[definition.Name]
+			if ok && fieldValue.kind == variableValue && defined[fieldValue.raw] {
+				_, ok = variables[fieldValue.raw]
+			}
+			if !ok {
+				if err := defaultInputField(object, definition, t); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			value, err := coerceLiteral(definition.Type, fieldValue, variables, defined)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.Name, definition.Name, err)
+			}
+			object[definition.Name] = value
+		}
+		return object, nil
+	case *Enum:
+		if v.kind != enumValue || !t.has(v.raw) {
+			return nil, fmt.Errorf("value %s does not exist in enum %s", literalString(v), t.Name)
+		}
+		return v.raw, nil
+	case *Scalar:
+		literal, err := literalValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return t.Parse(literal)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+// defaultInputField sets the default of a field of an input object that
+// was left out, if it has one.
+func defaultInputField(object map[string]any, definition *Argument, t *InputObject) error {
+	if definition.Default != nil {
+		object[definition.Name] = definition.Default
+		return nil
+	}
+	if _, ok := definition.Type.(*NonNull); ok {
+		return fmt.Errorf("field %s.%s of required type %s was not provided", t.Name, definition.Name, definition.Type)
+	}
+	return nil
+}
+
+// literalValue returns a scalar literal as the value Scalar.Parse takes.
+func literalValue(v *value) (any, error) {
+	switch v.kind {
+	case intValue:
+		n, err := strconv.ParseInt(v.raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is out of range", v.raw)
+		}
+		return n, nil
+	case floatValue:
+		return strconv.ParseFloat(v.raw, 64)
+	case stringValue:
+		return v.raw, nil
+	case booleanValue:
+		return v.raw == "true", nil
+	case enumValue:
+		return enumLiteral(v.raw), nil
+	}
+	return nil, fmt.Errorf("expected a scalar, found %s", literalString(v))
+}
+
+func literalString(v *value) string {
+	switch v.kind {
+	case stringValue:
+		return strconv.Quote(v.raw)
+	case listValue:
+		return "a list"
+	case objectValue:
+		return "an object"
+	case nullValue:
+		return "null"
+	case variableValue:
+		return "$" + v.raw
+	}
+	return v.raw
+}
+
+// coerceVariable returns the value of a variable, decoded from JSON with
+// numbers as json.Number, as type t.
+func coerceVariable(t Type, value any) (any, error) {
+	if nonNull, ok := t.(*NonNull); ok {
+		if value == nil {
+			return nil, fmt.Errorf("expected a value of type %s, found null", t)
+		}
+		return coerceVariable(nonNull.Of, value)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		list, ok := value.([]any)
+		if !ok {
+			item, err := coerceVariable(t.Of, value)
+			if err != nil {
+				return nil, err
+			}
+			return []any{item}, nil
+		}
+		items := make([]any, len(list))
+		for i, item := range list {
+			coerced, err := coerceVariable(t.Of, item)
+			if err != nil {
+				return nil, fmt.Errorf("at index %d: %w", i, err)
+			}
+			items[i] = coerced
+		}
+		return items, nil
+	case *InputObject:
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an object of type %s, found %s", t.Name, inputString(value))
+		}
+		for name := range fields {
+			if t.field(name) == nil {
+				return nil, fmt.Errorf("field %q is not defined by type %s", name, t.Name)
+			}
+		}
+		object := make(map[string]any, len(t.Fields))
+		for _, definition := range t.Fields {
+			fieldValue, ok := fields[definition.Name]
+			if !ok {
+				if err := defaultInputField(object, definition, t); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			coerced, err := coerceVariable(definition.Type, fieldValue)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.Name, definition.Name, err)
+			}
+			object[definition.Name] = coerced
+		}
+		return object, nil
+	case *Enum:
+		name, ok := value.(string)
+		if !ok || !t.has(name) {
+			return nil, fmt.Errorf("value %s does not exist in enum %s", inputString(value), t.Name)
+		}
+		return name, nil
+	case *Scalar:
+		// Callers that decode without UseNumber have float64s.
+		if f, ok := value.(float64); ok {
+			value = json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+		}
+		return t.Parse(value)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+// coerceVariables returns the values of the variables of op from the JSON
+// values of a request.
+func (s *Schema) coerceVariables(op *operation, raw map[string]any) (map[string]any, []*Error) {
+	values := make(map[string]any, len(op.variables))
+	var errs []*Error
+	for _, definition := range op.variables {
+		t := s.typeOf(definition.typ)
+		if t == nil || !isInput(t) {
+			errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" cannot be of type %q.", definition.name, definition.typ), Locations: []Location{definition.loc}})
+			continue
+		}
+		value, ok := raw[definition.name]
+		if !ok {
+			if definition.defaultValue != nil {
+				coerced, err := coerceLiteral(t, definition.defaultValue, nil, nil)
+				if err != nil {
+					errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" has an invalid default value: %v.", definition.name, err), Locations: []Location{definition.loc}})
+					continue
+				}
+				values[definition.name] = coerced
+			} else if _, ok := t.(*NonNull); ok {
+				errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" of required type %q was not provided.", definition.name, definition.typ), Locations: []Location{definition.loc}})
+			}
+			continue
+		}
+		coerced, err := coerceVariable(t, value)
+		if err != nil {
+			errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" got invalid value %s; %v.", definition.name, inputString(value), err), Locations: []Location{definition.loc}})
+			continue
+		}
+		values[definition.name] = coerced
+	}
+	return values, errs
+}
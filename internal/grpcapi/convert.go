@@ -0,0 +1,43 @@
+package grpcapi
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"task-manager/internal/grpcapi/taskspb"
+	"task-manager/internal/models"
+)
+
+func taskToProto(task *models.Task) *taskspb.Task {
+	if task == nil {
+		return nil
+	}
+	pb := &taskspb.Task{
+		Id:          task.ID.String(),
+		ExternalId:  task.ExternalID,
+		Project:     task.Project,
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      string(task.Status),
+		Assignee:    task.Assignee,
+		CreatedAt:   timestamppb.New(task.CreatedAt),
+		UpdatedAt:   timestamppb.New(task.UpdatedAt),
+	}
+	if task.DueAt != nil {
+		pb.DueAt = timestamppb.New(*task.DueAt)
+	}
+	if task.RecurrenceID != nil {
+		pb.RecurrenceId = task.RecurrenceID.String()
+	}
+	return pb
+}
+
+// timeFromProto returns nil for timestamps that aren't set.
+func timeFromProto(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
@@ -0,0 +1,322 @@
+// Package grpcapi serves the task service over gRPC, for backend services.
+// The messages and service are defined in proto/taskmanager/v1/tasks.proto
+// and generated into taskspb.
+package grpcapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"task-manager/internal/events"
+	"task-manager/internal/grpcapi/taskspb"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+	// watchReplayBatch is how many logged events WatchTasks reads at a time
+	// when it resumes.
+	watchReplayBatch = 500
+)
+
+// TaskServer implements taskspb.TaskServiceServer over the task service
+// and the event stream.
+type TaskServer struct {
+	taskspb.UnimplementedTaskServiceServer
+	tasks  service.TaskService
+	stream service.EventStreamService
+}
+
+func NewTaskServer(tasks service.TaskService, stream service.EventStreamService) *TaskServer {
+	return &TaskServer{tasks: tasks, stream: stream}
+}
+
+// NewServer returns a gRPC server that serves the task service.
+func NewServer(tasks service.TaskService, stream service.EventStreamService, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(opts...)
+	taskspb.RegisterTaskServiceServer(server, NewTaskServer(tasks, stream))
+	return server
+}
+
+func (s *TaskServer) CreateTask(ctx context.Context, req *taskspb.CreateTaskRequest) (*taskspb.Task, error) {
+	input := models.CreateTaskInput{
+		ExternalID:  req.GetExternalId(),
+		Project:     req.GetProject(),
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		Assignee:    req.GetAssignee(),
+		DueAt:       timeFromProto(req.GetDueAt()),
+	}
+	task, err := s.tasks.CreateTask(ctx, input)
+	if err != nil {
+		return nil, statusError("create task", err)
+	}
+	return taskToProto(task), nil
+}
+
+func (s *TaskServer) GetTask(ctx context.Context, req *taskspb.GetTaskRequest) (*taskspb.Task, error) {
+	taskID, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	task, err := s.tasks.GetTask(ctx, taskID)
+	if err != nil {
+		return nil, statusError("get task", err)
+	}
+	return taskToProto(task), nil
+}
+
+func (s *TaskServer) ListTasks(ctx context.Context, req *taskspb.ListTasksRequest) (*taskspb.ListTasksResponse, error) {
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case pageSize == 0:
+		pageSize = DefaultPageSize
+	case pageSize > MaxPageSize:
+		pageSize = MaxPageSize
+	}
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	filter := filterFromProto(req.GetFilter())
+	// One more task than the page tells whether there is a next page.
+	filter.Limit, filter.Offset = pageSize+1, offset
+	tasks, err := s.tasks.ListTasks(ctx, filter)
+	if err != nil {
+		return nil, statusError("list tasks", err)
+	}
+	response := &taskspb.ListTasksResponse{}
+	if len(tasks) > pageSize {
+		tasks = tasks[:pageSize]
+		response.NextPageToken = encodePageToken(offset + pageSize)
+	}
+	for _, task := range tasks {
+		response.Tasks = append(response.Tasks, taskToProto(task))
+	}
+	return response, nil
+}
+
+func (s *TaskServer) StreamTasks(req *taskspb.StreamTasksRequest, stream grpc.ServerStreamingServer[taskspb.Task]) error {
+	err := s.tasks.EachTask(stream.Context(), filterFromProto(req.GetFilter()), func(task *models.Task) error {
+		return stream.Send(taskToProto(task))
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			// Errors of Send already are statuses.
+			return err
+		}
+		return statusError("stream tasks", err)
+	}
+	return nil
+}
+
+func (s *TaskServer) UpdateTask(ctx context.Context, req *taskspb.UpdateTaskRequest) (*taskspb.Task, error) {
+	taskID, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	input := models.UpdateTaskInput{
+		Title:       req.Title,
+		Description: req.Description,
+		Assignee:    req.Assignee,
+		DueAt:       timeFromProto(req.GetDueAt()),
+	}
+	if req.Status != nil {
+		taskStatus := models.TaskStatus(req.GetStatus())
+		input.Status = &taskStatus
+	}
+	task, err := s.tasks.UpdateTask(ctx, taskID, input)
+	if err != nil {
+		return nil, statusError("update task", err)
+	}
+	return taskToProto(task), nil
+}
+
+func (s *TaskServer) DeleteTask(ctx context.Context, req *taskspb.DeleteTaskRequest) (*taskspb.DeleteTaskResponse, error) {
+	taskID, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tasks.DeleteTask(ctx, taskID); err != nil {
+		return nil, statusError("delete task", err)
+	}
+	return &taskspb.DeleteTaskResponse{}, nil
+}
+
+// WatchTasks sends task events like the /events route does: logged events
+// after after_event_id first, then events as they are published.
+func (s *TaskServer) WatchTasks(req *taskspb.WatchTasksRequest, stream grpc.ServerStreamingServer[taskspb.TaskEvent]) error {
+	ctx := stream.Context()
+	filter := service.EventStreamFilter{Project: req.GetProject()}
+	if req.GetStatus() != "" {
+		taskStatus := models.TaskStatus(req.GetStatus())
+		filter.Status = &taskStatus
+	}
+	lastID := req.GetAfterEventId()
+	if lastID < 0 {
+		return status.Error(codes.InvalidArgument, "after_event_id must not be negative")
+	}
+
+	// Subscribing before replaying guarantees that nothing published in
+	// between is lost; duplicates are skipped by ID.
+	subscription, err := s.stream.Subscribe(filter)
+	if err != nil {
+		return statusError("watch tasks", err)
+	}
+	defer s.stream.Unsubscribe(subscription)
+	// Headers tell clients that the watch is established, so that they
+	// can rely on it seeing the changes they make from now on.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	if lastID > 0 {
+		for first := true; ; first = false {
+			entries, reset, err := s.stream.Replay(ctx, lastID, filter, watchReplayBatch)
+			if err != nil {
+				return statusError("watch tasks", err)
+			}
+			if first && reset {
+				if err := stream.Send(&taskspb.TaskEvent{Type: taskspb.TaskEvent_TYPE_RESET}); err != nil {
+					return err
+				}
+			}
+			for _, entry := range entries {
+				if err := sendEntry(stream, entry); err != nil {
+					return err
+				}
+				lastID = entry.ID
+			}
+			if len(entries) < watchReplayBatch {
+				break
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case entry, ok := <-subscription.C:
+			if !ok {
+				return status.Errorf(codes.Unavailable, "the watch ended because it fell behind or the server is shutting down; resume it with after_event_id %d", lastID)
+			}
+			if entry.ID <= lastID {
+				continue
+			}
+			if err := sendEntry(stream, entry); err != nil {
+				return err
+			}
+			lastID = entry.ID
+		}
+	}
+}
+
+func sendEntry(stream grpc.ServerStreamingServer[taskspb.TaskEvent], entry *models.EventLogEntry) error {
+	event, err := eventToProto(entry)
+	if err != nil {
+		return statusError("watch tasks", err)
+	}
+	return stream.Send(event)
+}
+
+var eventTypes = map[string]taskspb.TaskEvent_Type{
+	string(events.TaskCreated): taskspb.TaskEvent_TYPE_CREATED,
+	string(events.TaskUpdated): taskspb.TaskEvent_TYPE_UPDATED,
+	string(events.TaskDeleted): taskspb.TaskEvent_TYPE_DELETED,
+}
+
+func eventToProto(entry *models.EventLogEntry) (*taskspb.TaskEvent, error) {
+	var event events.Event
+	if err := json.Unmarshal(entry.Payload, &event); err != nil {
+		return nil, fmt.Errorf("decode event log entry %d: %w", entry.ID, err)
+	}
+	return &taskspb.TaskEvent{
+		Id:         entry.ID,
+		Type:       eventTypes[entry.Type],
+		TaskId:     entry.TaskID.String(),
+		Task:       taskToProto(event.Task),
+		OccurredAt: timestamppb.New(entry.OccurredAt),
+	}, nil
+}
+
+// statusError maps errors of the services to gRPC statuses. Errors that
+// aren't the client's are logged and hidden from it.
+func statusError(operation string, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrTaskNotFound):
+		return status.Error(codes.NotFound, "task not found")
+	case errors.Is(err, service.ErrTitleTooShort), errors.Is(err, service.ErrInvalidStatus):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrInvalidTransition):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrExternalIDTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrEventStreamClosed):
+		return status.Error(codes.Unavailable, "the server is shutting down")
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	log.Printf("grpc %s: %v", operation, err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func parseID(id string) (uuid.UUID, error) {
+	taskID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.UUID{}, status.Error(codes.InvalidArgument, "id must be a valid uuid")
+	}
+	return taskID, nil
+}
+
+func filterFromProto(filter *taskspb.TaskFilter) repository.TaskFilter {
+	taskFilter := repository.TaskFilter{
+		Project:   filter.GetProject(),
+		Assignee:  filter.GetAssignee(),
+		DueAfter:  timeFromProto(filter.GetDueAfter()),
+		DueBefore: timeFromProto(filter.GetDueBefore()),
+	}
+	if filter.GetStatus() != "" {
+		taskStatus := models.TaskStatus(filter.GetStatus())
+		taskFilter.Status = &taskStatus
+	}
+	return taskFilter
+}
+
+// Page tokens are opaque to clients, but are offsets in the list.
+const pageTokenPrefix = "offset:"
+
+func encodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.Itoa(offset)))
+}
+
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil && strings.HasPrefix(string(data), pageTokenPrefix) {
+		if offset, err := strconv.Atoi(strings.TrimPrefix(string(data), pageTokenPrefix)); err == nil && offset >= 0 {
+			return offset, nil
+		}
+	}
+	return 0, status.Error(codes.InvalidArgument, "invalid page_token")
+}
@@ -0,0 +1,227 @@
+package grpcapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"task-manager/internal/events"
+	"task-manager/internal/grpcapi/taskspb"
+	"task-manager/internal/migrations"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+func newTestClient(t *testing.T) (taskspb.TaskServiceClient, service.EventStreamService) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	stream := service.NewEventStreamService(repository.NewSQLiteEventLogRepository(db), time.Hour)
+	bus := events.NewBus()
+	bus.Subscribe(stream.HandleEvent)
+	tasks := service.NewTaskService(repository.NewSQLiteTaskRepository(db), repository.NewSQLiteWorkflowRepository(db), bus, repository.NewSQLTransactor(db))
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer(tasks, stream)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return taskspb.NewTaskServiceClient(conn), stream
+}
+
+func TestTaskServer(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := t.Context()
+
+	var created []*taskspb.Task
+	for _, title := range []string{"Pay rent", "Water plants", "Call mum"} {
+		task, err := client.CreateTask(ctx, &taskspb.CreateTaskRequest{Project: "home", Title: title})
+		if err != nil {
+			t.Fatalf("CreateTask: %v", err)
+		}
+		created = append(created, task)
+	}
+	if created[0].GetStatus() != "new" || created[0].GetCreatedAt() == nil || created[0].GetDueAt() != nil {
+		t.Errorf("created task %v", created[0])
+	}
+
+	got, err := client.GetTask(ctx, &taskspb.GetTaskRequest{Id: created[0].GetId()})
+	if err != nil || !proto.Equal(got, created[0]) {
+		t.Errorf("GetTask: %v, %v", got, err)
+	}
+
+	first, err := client.ListTasks(ctx, &taskspb.ListTasksRequest{Filter: &taskspb.TaskFilter{Project: "home"}, PageSize: 2})
+	if err != nil || len(first.GetTasks()) != 2 || first.GetTasks()[0].GetTitle() != "Call mum" || first.GetNextPageToken() == "" {
+		t.Fatalf("first page: %v, %v", first, err)
+	}
+	second, err := client.ListTasks(ctx, &taskspb.ListTasksRequest{Filter: &taskspb.TaskFilter{Project: "home"}, PageSize: 2, PageToken: first.GetNextPageToken()})
+	if err != nil || len(second.GetTasks()) != 1 || second.GetTasks()[0].GetTitle() != "Pay rent" || second.GetNextPageToken() != "" {
+		t.Errorf("second page: %v, %v", second, err)
+	}
+
+	stream, err := client.StreamTasks(ctx, &taskspb.StreamTasksRequest{Filter: &taskspb.TaskFilter{Project: "home"}})
+	if err != nil {
+		t.Fatalf("StreamTasks: %v", err)
+	}
+	var streamed int
+	for {
+		if _, err := stream.Recv(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("StreamTasks: %v", err)
+		}
+		streamed++
+	}
+	if streamed != 3 {
+		t.Errorf("streamed %d tasks, want 3", streamed)
+	}
+
+	status := "done"
+	updated, err := client.UpdateTask(ctx, &taskspb.UpdateTaskRequest{Id: created[0].GetId(), Status: &status})
+	if err != nil || updated.GetStatus() != "done" || updated.GetTitle() != "Pay rent" {
+		t.Errorf("UpdateTask: %v, %v", updated, err)
+	}
+	if _, err := client.DeleteTask(ctx, &taskspb.DeleteTaskRequest{Id: created[1].GetId()}); err != nil {
+		t.Errorf("DeleteTask: %v", err)
+	}
+}
+
+func TestTaskServerStatusCodes(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := t.Context()
+	task, err := client.CreateTask(ctx, &taskspb.CreateTaskRequest{Title: "Pay rent", ExternalId: "rent"})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	unknown, done := "archived", "done"
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"unknown task", func() error {
+			_, err := client.GetTask(ctx, &taskspb.GetTaskRequest{Id: "00000000-0000-0000-0000-000000000000"})
+			return err
+		}, codes.NotFound},
+		{"invalid id", func() error {
+			_, err := client.DeleteTask(ctx, &taskspb.DeleteTaskRequest{Id: "nope"})
+			return err
+		}, codes.InvalidArgument},
+		{"short title", func() error {
+			_, err := client.CreateTask(ctx, &taskspb.CreateTaskRequest{Title: "x"})
+			return err
+		}, codes.InvalidArgument},
+		{"taken external id", func() error {
+			_, err := client.CreateTask(ctx, &taskspb.CreateTaskRequest{Title: "Pay rent again", ExternalId: "rent"})
+			return err
+		}, codes.AlreadyExists},
+		{"unknown status", func() error {
+			_, err := client.UpdateTask(ctx, &taskspb.UpdateTaskRequest{Id: task.GetId(), Status: &unknown})
+			return err
+		}, codes.InvalidArgument},
+		{"invalid page token", func() error {
+			_, err := client.ListTasks(ctx, &taskspb.ListTasksRequest{PageToken: "nope"})
+			return err
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call()); got != tt.want {
+				t.Errorf("code %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := client.UpdateTask(ctx, &taskspb.UpdateTaskRequest{Id: task.GetId(), Status: &done}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	reopened := "new"
+	if _, err := client.UpdateTask(ctx, &taskspb.UpdateTaskRequest{Id: task.GetId(), Status: &reopened}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("invalid transition: %v", err)
+	}
+}
+
+func TestWatchTasks(t *testing.T) {
+	client, stream := newTestClient(t)
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+
+	before, err := client.CreateTask(ctx, &taskspb.CreateTaskRequest{Project: "home", Title: "Pay rent"})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+
+	// A new watch gets events from now on; a resumed one first gets the
+	// logged events after its position.
+	watch, err := client.WatchTasks(ctx, &taskspb.WatchTasksRequest{Project: "home"})
+	if err != nil {
+		t.Fatalf("WatchTasks: %v", err)
+	}
+	resumed, err := client.WatchTasks(ctx, &taskspb.WatchTasksRequest{Project: "home", AfterEventId: 1})
+	if err != nil {
+		t.Fatalf("WatchTasks: %v", err)
+	}
+	for _, w := range []grpc.ServerStreamingClient[taskspb.TaskEvent]{watch, resumed} {
+		if _, err := w.Header(); err != nil {
+			t.Fatalf("watch headers: %v", err)
+		}
+	}
+
+	if _, err := client.CreateTask(ctx, &taskspb.CreateTaskRequest{Project: "work", Title: "Write report"}); err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if _, err := client.DeleteTask(ctx, &taskspb.DeleteTaskRequest{Id: before.GetId()}); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+
+	event, err := watch.Recv()
+	if err != nil || event.GetType() != taskspb.TaskEvent_TYPE_DELETED || event.GetTaskId() != before.GetId() || event.GetTask().GetTitle() != "Pay rent" {
+		t.Errorf("live event: %v, %v", event, err)
+	}
+	event, err = resumed.Recv()
+	if err != nil || event.GetType() != taskspb.TaskEvent_TYPE_DELETED || event.GetId() != 3 {
+		t.Errorf("event after the resumed position: %v, %v", event, err)
+	}
+
+	replay, err := client.WatchTasks(ctx, &taskspb.WatchTasksRequest{AfterEventId: 1})
+	if err != nil {
+		t.Fatalf("WatchTasks: %v", err)
+	}
+	for _, want := range []taskspb.TaskEvent_Type{taskspb.TaskEvent_TYPE_CREATED, taskspb.TaskEvent_TYPE_DELETED} {
+		if event, err := replay.Recv(); err != nil || event.GetType() != want {
+			t.Errorf("replayed event: %v, %v, want %v", event, err, want)
+		}
+	}
+
+	// Closing the event stream on shutdown ends watches.
+	stream.Close()
+	if _, err := replay.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("watch after the stream closed: %v", err)
+	}
+}
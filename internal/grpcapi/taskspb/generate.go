@@ -0,0 +1,6 @@
+// Package taskspb holds the messages and service of
+// proto/taskmanager/v1/tasks.proto, generated by protoc-gen-go and
+// protoc-gen-go-grpc.
+package taskspb
+
+//go:generate protoc -I ../../../proto --go_out=../../.. --go_opt=module=task-manager --go-grpc_out=../../.. --go-grpc_opt=module=task-manager taskmanager/v1/tasks.proto
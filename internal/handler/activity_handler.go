@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"task-manager/internal/atom"
+	"task-manager/internal/models"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgFailedToListActivity = "Failed to list activity due to an internal server error"
+)
+
+// DefaultActivityLimit is how many entries activity feeds have unless the
+// request asks for another number, up to MaxActivityLimit.
+const (
+	DefaultActivityLimit = 50
+	MaxActivityLimit     = 500
+)
+
+type ActivityHandler struct {
+	service service.ActivityService
+}
+
+func NewActivityHandler(service service.ActivityService) *ActivityHandler {
+	return &ActivityHandler{service: service}
+}
+
+func (h *ActivityHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /activity", h.handleListActivity)
+	mux.HandleFunc("GET /activity.atom", h.handleActivityFeed)
+}
+
+// parseActivityFilter parses the project, assignee, kind and limit query
+// parameters. It writes the error response and returns false if they are
+// invalid.
+func parseActivityFilter(w http.ResponseWriter, r *http.Request) (service.ActivityFilter, int, bool) {
+	queryParams := r.URL.Query()
+	limit := DefaultActivityLimit
+	if limitStr := queryParams.Get("limit"); limitStr != "" {
+		if limitValue, err := strconv.Atoi(limitStr); err == nil && limitValue > 0 {
+			limit = min(limitValue, MaxActivityLimit)
+		} else {
+			http.Error(w, ErrMsgInvalidLimit, http.StatusBadRequest)
+			return service.ActivityFilter{}, 0, false
+		}
+	}
+	return service.ActivityFilter{
+		Project:  queryParams.Get("project"),
+		Assignee: queryParams.Get("assignee"),
+		Kind:     models.ActivityKind(queryParams.Get("kind")),
+	}, limit, true
+}
+
+func (h *ActivityHandler) listActivity(w http.ResponseWriter, r *http.Request) (service.ActivityFilter, []*models.Activity, bool) {
+	filter, limit, ok := parseActivityFilter(w, r)
+	if !ok {
+		return filter, nil, false
+	}
+	activities, err := h.service.ListActivity(r.Context(), filter, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidActivityKind) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			log.Printf("list activity: %v", err)
+			http.Error(w, ErrMsgFailedToListActivity, http.StatusInternalServerError)
+		}
+		return filter, nil, false
+	}
+	return filter, activities, true
+}
+
+func (h *ActivityHandler) handleListActivity(w http.ResponseWriter, r *http.Request) {
+	_, activities, ok := h.listActivity(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(activities)
+}
+
+// handleActivityFeed serves activity as an Atom feed for feed readers.
+// Entry IDs are those of the event log entries, so they stay the same
+// whatever the filter, and readers show every change once.
+func (h *ActivityHandler) handleActivityFeed(w http.ResponseWriter, r *http.Request) {
+	filter, activities, ok := h.listActivity(w, r)
+	if !ok {
+		return
+	}
+
+	base := requestBaseURL(r)
+	query := url.Values{}
+	for name, value := range map[string]string{"project": filter.Project, "assignee": filter.Assignee, "kind": string(filter.Kind)} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	feed := atom.Feed{
+		ID:        "urn:task-manager:activity",
+		Title:     activityFeedTitle(filter),
+		Updated:   time.Unix(0, 0),
+		Self:      base + r.URL.RequestURI(),
+		Alternate: base + "/activity",
+		Author:    "task-manager",
+	}
+	if len(query) > 0 {
+		feed.ID += "?" + query.Encode()
+		feed.Alternate += "?" + query.Encode()
+	}
+	// An empty feed keeps the same updated time, and so its ETag.
+	if len(activities) > 0 {
+		feed.Updated = activities[0].OccurredAt
+	}
+	for _, activity := range activities {
+		entry := atom.Entry{
+			ID:         "urn:task-manager:activity:" + strconv.FormatInt(activity.ID, 10),
+			Title:      activityTitle(activity),
+			Updated:    activity.OccurredAt,
+			Published:  activity.OccurredAt,
+			Link:       base + "/tasks/" + activity.Task.ID.String(),
+			Summary:    activity.Task.Description,
+			Categories: []string{string(activity.Kind), activity.Task.Project},
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	var body bytes.Buffer
+	if err := atom.Write(&body, feed); err != nil {
+		log.Printf("write activity feed: %v", err)
+		http.Error(w, ErrMsgFailedToListActivity, http.StatusInternalServerError)
+		return
+	}
+	writeWithETag(w, r, atom.ContentType, &body)
+}
+
+func activityFeedTitle(filter service.ActivityFilter) string {
+	title := "Task activity"
+	if filter.Project != "" {
+		title += " in " + filter.Project
+	}
+	if filter.Assignee != "" {
+		title += " for " + filter.Assignee
+	}
+	return title
+}
+
+func activityTitle(activity *models.Activity) string {
+	switch activity.Kind {
+	case models.ActivityCreated:
+		return fmt.Sprintf("Created %q", activity.Task.Title)
+	case models.ActivityCompleted:
+		return fmt.Sprintf("Completed %q", activity.Task.Title)
+	default:
+		return fmt.Sprintf("Moved %q from %s to %s", activity.Task.Title, activity.From, activity.To)
+	}
+}
+
+// requestBaseURL returns the scheme and host that r was sent to, for
+// links that have to be absolute.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+type atomFeed struct {
+	ID      string `xml:"http://www.w3.org/2005/Atom id"`
+	Updated string `xml:"http://www.w3.org/2005/Atom updated"`
+	Entries []struct {
+		ID      string `xml:"id"`
+		Title   string `xml:"title"`
+		Updated string `xml:"updated"`
+		Link    struct {
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+	} `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+func TestActivityFeed(t *testing.T) {
+	db := newTestDB(t)
+	eventLogRepository := repository.NewSQLiteEventLogRepository(db)
+	workflowRepository := repository.NewSQLiteWorkflowRepository(db)
+	bus := events.NewBus()
+	bus.Subscribe(service.NewEventStreamService(eventLogRepository, time.Hour).HandleEvent)
+	tasks := service.NewTaskService(repository.NewSQLiteTaskRepository(db), workflowRepository, bus, repository.NewSQLTransactor(db))
+	mux := http.NewServeMux()
+	NewActivityHandler(service.NewActivityService(eventLogRepository, workflowRepository)).RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	task, err := tasks.CreateTask(ctx, models.CreateTaskInput{Project: "home", Title: "Pay rent"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	done := models.TaskStatusDone
+	if _, err := tasks.UpdateTask(ctx, task.ID, models.UpdateTaskInput{Status: &done}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := tasks.CreateTask(ctx, models.CreateTaskInput{Project: "work", Title: "Write report"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	readFeed := func(query string) atomFeed {
+		t.Helper()
+		response, body := getCalendar(t, server.URL+"/activity.atom"+query, "")
+		if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "application/atom+xml; charset=utf-8" {
+			t.Fatalf("GET %s: %d %s", query, response.StatusCode, body)
+		}
+		var feed atomFeed
+		if err := xml.Unmarshal([]byte(body), &feed); err != nil {
+			t.Fatalf("parse %s: %v\n%s", query, err, body)
+		}
+		return feed
+	}
+
+	feed := readFeed("?project=home")
+	if feed.ID != "urn:task-manager:activity?project=home" || len(feed.Entries) != 2 {
+		t.Fatalf("feed %+v", feed)
+	}
+	completed := feed.Entries[0]
+	if completed.Title != `Completed "Pay rent"` || completed.Updated != feed.Updated ||
+		completed.Link.Href != server.URL+"/tasks/"+task.ID.String() || feed.Entries[1].Title != `Created "Pay rent"` {
+		t.Errorf("entries %+v", feed.Entries)
+	}
+
+	// Entries keep their IDs in other feeds.
+	all := readFeed("")
+	if len(all.Entries) != 3 || all.Entries[1].ID != completed.ID {
+		t.Errorf("entries of the unfiltered feed %+v", all.Entries)
+	}
+
+	response, _ := getCalendar(t, server.URL+"/activity.atom?project=home", "")
+	etag := response.Header.Get("ETag")
+	if response, _ := getCalendar(t, server.URL+"/activity.atom?project=home", etag); response.StatusCode != http.StatusNotModified {
+		t.Errorf("GET with the ETag: %d", response.StatusCode)
+	}
+	if response, _ := getCalendar(t, server.URL+"/activity.atom?kind=deleted", ""); response.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET with an unknown kind: %d", response.StatusCode)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"task-manager/internal/backup"
+)
+
+const (
+	ErrMsgUnauthorized           = "Unauthorized! A valid admin token is required"
+	ErrMsgNoBackupDir            = "Backups are disabled! Set TASK_MANAGER_BACKUP_DIR to keep them"
+	ErrMsgFailedToCreateBackup   = "Failed to create backup due to an internal server error"
+	ErrMsgFailedToListBackups    = "Failed to list backups due to an internal server error"
+	ErrMsgFailedToCreateDownload = "Failed to create snapshot due to an internal server error"
+)
+
+// AdminHandler serves the /admin routes, which are authorised with a bearer
+// token.
+type AdminHandler struct {
+	backups *backup.Manager
+	token   string
+}
+
+func NewAdminHandler(backups *backup.Manager, token string) *AdminHandler {
+	return &AdminHandler{backups: backups, token: token}
+}
+
+func (h *AdminHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/backup", h.authorize(h.handleDownloadSnapshot))
+	mux.HandleFunc("GET /admin/backups", h.authorize(h.handleListBackups))
+	mux.HandleFunc("POST /admin/backups", h.authorize(h.handleCreateBackup))
+	mux.HandleFunc("GET /admin/backups/{name}", h.authorize(h.handleDownloadBackup))
+}
+
+func (h *AdminHandler) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, ErrMsgUnauthorized, http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleDownloadSnapshot streams a fresh snapshot of the database without
+// keeping it.
+func (h *AdminHandler) handleDownloadSnapshot(w http.ResponseWriter, r *http.Request) {
+	// Snapshots of large databases take longer to write and download than
+	// the server's write timeout.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	dir, err := os.MkdirTemp("", "task-manager-snapshot-")
+	if err != nil {
+		http.Error(w, ErrMsgFailedToCreateDownload, http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	now := time.Now().UTC()
+	path := filepath.Join(dir, "tasks.db")
+	if err := h.backups.Snapshot(r.Context(), path); err != nil {
+		log.Printf("snapshot: %v", err)
+		http.Error(w, ErrMsgFailedToCreateDownload, http.StatusInternalServerError)
+		return
+	}
+	serveBackup(w, r, path, "tasks-"+now.Format("20060102T150405Z")+".db", now)
+}
+
+func (h *AdminHandler) handleListBackups(w http.ResponseWriter, r *http.Request) {
+	backups, err := h.backups.List()
+	if err != nil {
+		http.Error(w, ErrMsgFailedToListBackups, http.StatusInternalServerError)
+		return
+	}
+	if backups == nil {
+		backups = []*backup.Backup{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(backups)
+}
+
+func (h *AdminHandler) handleCreateBackup(w http.ResponseWriter, r *http.Request) {
+	created, err := h.backups.Create(r.Context(), time.Now())
+	if err != nil {
+		if errors.Is(err, backup.ErrNoBackupDir) {
+			http.Error(w, ErrMsgNoBackupDir, http.StatusConflict)
+			return
+		}
+		log.Printf("backup: %v", err)
+		http.Error(w, ErrMsgFailedToCreateBackup, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(created)
+}
+
+func (h *AdminHandler) handleDownloadBackup(w http.ResponseWriter, r *http.Request) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	name := r.PathValue("name")
+	path, err := h.backups.Path(name)
+	if err != nil {
+		if errors.Is(err, backup.ErrBackupNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		return
+	}
+	serveBackup(w, r, path, name, info.ModTime())
+}
+
+func serveBackup(w http.ResponseWriter, r *http.Request, path, name string, modTime time.Time) {
+	file, err := os.Open(path)
+	if err != nil {
+		http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		return
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeContent(w, r, name, modTime, file)
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"task-manager/internal/backup"
+	"task-manager/internal/database"
+	"task-manager/internal/migrations"
+)
+
+func newAdminServer(t *testing.T, backupDir string) *httptest.Server {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "tasks.db")
+	writer, reader, err := database.OpenSQLite(dbPath, database.DefaultSQLiteOptions())
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = reader.Close()
+		_ = writer.Close()
+	})
+	if err := migrations.Run(writer); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	NewAdminHandler(backup.NewManager(dbPath, backupDir, 2), "secret").RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func adminRequest(t *testing.T, method, url, token string) *http.Response {
+	t.Helper()
+	request, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	t.Cleanup(func() { _ = response.Body.Close() })
+	return response
+}
+
+func TestAdminHandlerRequiresToken(t *testing.T) {
+	server := newAdminServer(t, "")
+	for _, token := range []string{"", "wrong"} {
+		response := adminRequest(t, http.MethodGet, server.URL+"/admin/backup", token)
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("token %q: status %d, want 401", token, response.StatusCode)
+		}
+	}
+}
+
+func TestAdminHandlerDownloadsSnapshot(t *testing.T) {
+	server := newAdminServer(t, "")
+	response := adminRequest(t, http.MethodGet, server.URL+"/admin/backup", "secret")
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want 200", response.StatusCode)
+	}
+	if disposition := response.Header.Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment;") {
+		t.Fatalf("Content-Disposition %q", disposition)
+	}
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "download.db")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := backup.Verify(t.Context(), path); err != nil {
+		t.Fatalf("downloaded snapshot: %v", err)
+	}
+
+	// Managed backups need a backup directory.
+	response = adminRequest(t, http.MethodPost, server.URL+"/admin/backups", "secret")
+	if response.StatusCode != http.StatusConflict {
+		t.Fatalf("create without a directory: status %d, want 409", response.StatusCode)
+	}
+}
+
+func TestAdminHandlerManagesBackups(t *testing.T) {
+	server := newAdminServer(t, filepath.Join(t.TempDir(), "backups"))
+	response := adminRequest(t, http.MethodPost, server.URL+"/admin/backups", "secret")
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("create: status %d, want 201", response.StatusCode)
+	}
+	var created backup.Backup
+	if err := json.NewDecoder(response.Body).Decode(&created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	response = adminRequest(t, http.MethodGet, server.URL+"/admin/backups", "secret")
+	var backups []backup.Backup
+	if err := json.NewDecoder(response.Body).Decode(&backups); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(backups) != 1 || backups[0].Name != created.Name {
+		t.Fatalf("got %+v, want %+v", backups, created)
+	}
+
+	response = adminRequest(t, http.MethodGet, server.URL+"/admin/backups/"+created.Name, "secret")
+	if response.StatusCode != http.StatusOK || response.ContentLength != created.Size {
+		t.Fatalf("download: status %d, %d bytes, want %d", response.StatusCode, response.ContentLength, created.Size)
+	}
+	response = adminRequest(t, http.MethodGet, server.URL+"/admin/backups/tasks.db", "secret")
+	if response.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown backup: status %d, want 404", response.StatusCode)
+	}
+}
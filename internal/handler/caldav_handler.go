@@ -0,0 +1,461 @@
+package handler
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgInvalidDAVRequest   = "Invalid request! The body must be a PROPFIND or REPORT request in XML"
+	ErrMsgUnsupportedReport   = "Unsupported report! Reports must be calendar-query, calendar-multiget or sync-collection"
+	ErrMsgInvalidDepth        = "Invalid depth! Depth must be 0 or 1"
+	ErrMsgInvalidCalendarData = "Invalid calendar data! The body must be an iCalendar object with a VTODO"
+	ErrMsgPreconditionFailed  = "Precondition failed! The calendar object was changed or doesn't exist"
+	ErrMsgFailedCalDAVRequest = "Failed to answer CalDAV request due to an internal server error"
+)
+
+// calDAVRoot is where CalDAV is served. It is the principal of the one
+// user there is, and calendars are under calDAVHome, one per project.
+const (
+	calDAVRoot = "/dav/"
+	calDAVHome = calDAVRoot + "calendars/"
+)
+
+// maxCalendarObjectSize limits the size of the objects that clients PUT.
+const maxCalendarObjectSize = 1 << 20
+
+// CalDAVHandler serves tasks as the VTODOs of CalDAV calendars, one per
+// project, for clients like Thunderbird, Apple Reminders or DAVx⁵.
+type CalDAVHandler struct {
+	service service.CalDAVService
+}
+
+func NewCalDAVHandler(service service.CalDAVService) *CalDAVHandler {
+	return &CalDAVHandler{service: service}
+}
+
+// RegisterRoutes registers CalDAV under /dav/ and the well-known URL that
+// clients discover it with. The methods of WebDAV can't be route patterns,
+// so they are dispatched in ServeHTTP.
+func (h *CalDAVHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.Handle(calDAVRoot, h)
+	mux.HandleFunc("/.well-known/caldav", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, calDAVRoot, http.StatusMovedPermanently)
+	})
+}
+
+// davPath is a parsed path under /dav/. Principal and home are the root and
+// the calendar home; otherwise project is set, and name is set for objects.
+type davPath struct {
+	principal bool
+	home      bool
+	project   string
+	name      string
+}
+
+// parseDAVPath parses the escaped path of a request, so that projects may
+// contain slashes.
+func parseDAVPath(r *http.Request) (davPath, bool) {
+	rest, ok := strings.CutPrefix(r.URL.EscapedPath(), calDAVRoot)
+	if !ok {
+		return davPath{}, false
+	}
+	if rest == "" {
+		return davPath{principal: true}, true
+	}
+	rest, ok = strings.CutPrefix(rest, "calendars")
+	if !ok {
+		return davPath{}, false
+	}
+	if rest == "" || rest == "/" {
+		return davPath{home: true}, true
+	}
+	segments := strings.Split(strings.TrimPrefix(rest, "/"), "/")
+	if len(segments) > 2 || segments[0] == "" {
+		return davPath{}, false
+	}
+	project, err := url.PathUnescape(segments[0])
+	if err != nil {
+		return davPath{}, false
+	}
+	path := davPath{project: project}
+	if len(segments) == 2 && segments[1] != "" {
+		file, err := url.PathUnescape(segments[1])
+		if err != nil {
+			return davPath{}, false
+		}
+		path.name, ok = strings.CutSuffix(file, ".ics")
+		if !ok || path.name == "" {
+			return davPath{}, false
+		}
+	}
+	return path, true
+}
+
+func calendarHref(project string) string {
+	return calDAVHome + url.PathEscape(project) + "/"
+}
+
+func objectHref(project, name string) string {
+	return calendarHref(project) + url.PathEscape(name) + ".ics"
+}
+
+func (h *CalDAVHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(r.Body)
+
+	path, ok := parseDAVPath(r)
+	if !ok {
+		http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1, 3, calendar-access")
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, REPORT, GET, HEAD, PUT, DELETE")
+	case "PROPFIND":
+		h.handlePropfind(w, r, path)
+	case "REPORT":
+		h.handleReport(w, r, path)
+	case http.MethodGet, http.MethodHead:
+		h.handleGetObject(w, r, path)
+	case http.MethodPut:
+		h.handlePutObject(w, r, path)
+	case http.MethodDelete:
+		h.handleDeleteObject(w, r, path)
+	default:
+		http.Error(w, ErrMsgMethodNotAllowed, http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *CalDAVHandler) handlePropfind(w http.ResponseWriter, r *http.Request, path davPath) {
+	depth := r.Header.Get("Depth")
+	if depth != "0" && depth != "1" {
+		// Depth infinity isn't supported, which RFC 4918 allows.
+		http.Error(w, ErrMsgInvalidDepth, http.StatusForbidden)
+		return
+	}
+	request, err := readDAVRequest(w, r)
+	if err != nil {
+		writeDAVRequestError(w, err)
+		return
+	}
+	if request.XMLName != (xml.Name{Space: nsDAV, Local: "propfind"}) {
+		http.Error(w, ErrMsgInvalidDAVRequest, http.StatusBadRequest)
+		return
+	}
+	names, nameOnly := request.props(), request.PropName != nil
+
+	var responses []davResponse
+	switch {
+	case path.principal:
+		responses = append(responses, newDAVResponse(calDAVRoot, principalProps(), names, nameOnly))
+		if depth == "1" {
+			responses = append(responses, newDAVResponse(calDAVHome, homeProps(), names, nameOnly))
+		}
+	case path.home:
+		responses = append(responses, newDAVResponse(calDAVHome, homeProps(), names, nameOnly))
+		if depth == "1" {
+			projects, err := h.service.Projects(r.Context())
+			if err != nil {
+				h.internalError(w, "list projects", err)
+				return
+			}
+			for _, project := range projects {
+				props, err := h.calendarProps(r, project)
+				if err != nil {
+					h.internalError(w, "get calendar", err)
+					return
+				}
+				responses = append(responses, newDAVResponse(calendarHref(project), props, names, nameOnly))
+			}
+		}
+	case path.name == "":
+		props, err := h.calendarProps(r, path.project)
+		if err != nil {
+			h.internalError(w, "get calendar", err)
+			return
+		}
+		responses = append(responses, newDAVResponse(calendarHref(path.project), props, names, nameOnly))
+		if depth == "1" {
+			objects, err := h.service.ListObjects(r.Context(), path.project)
+			if err != nil {
+				h.internalError(w, "list objects", err)
+				return
+			}
+			for _, object := range objects {
+				responses = append(responses, newDAVResponse(objectHref(path.project, object.Name), objectProps(object), names, nameOnly))
+			}
+		}
+	default:
+		object, err := h.service.GetObject(r.Context(), path.project, path.name)
+		if err != nil {
+			h.objectError(w, "get object", err)
+			return
+		}
+		responses = append(responses, newDAVResponse(objectHref(path.project, path.name), objectProps(object), names, nameOnly))
+	}
+	writeMultistatus(w, responses, "")
+}
+
+// handleReport answers the reports that clients sync calendars with:
+// calendar-query for all to-dos, calendar-multiget for some of them and
+// sync-collection for those that changed since a sync token. Other filters
+// than those for components aren't applied; clients filter the results
+// themselves.
+func (h *CalDAVHandler) handleReport(w http.ResponseWriter, r *http.Request, path davPath) {
+	if path.project == "" || path.name != "" {
+		http.Error(w, ErrMsgUnsupportedReport, http.StatusForbidden)
+		return
+	}
+	request, err := readDAVRequest(w, r)
+	if err != nil {
+		writeDAVRequestError(w, err)
+		return
+	}
+	names := request.props()
+
+	var responses []davResponse
+	switch request.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		if !request.wantsTodos() {
+			break
+		}
+		objects, err := h.service.ListObjects(r.Context(), path.project)
+		if err != nil {
+			h.internalError(w, "list objects", err)
+			return
+		}
+		for _, object := range objects {
+			responses = append(responses, newDAVResponse(objectHref(path.project, object.Name), objectProps(object), names, false))
+		}
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		for _, href := range request.Hrefs {
+			name, ok := objectName(href, path.project)
+			if !ok {
+				responses = append(responses, davResponse{href: href, status: http.StatusNotFound})
+				continue
+			}
+			object, err := h.service.GetObject(r.Context(), path.project, name)
+			if errors.Is(err, repository.ErrTaskNotFound) {
+				responses = append(responses, davResponse{href: href, status: http.StatusNotFound})
+				continue
+			}
+			if err != nil {
+				h.internalError(w, "get object", err)
+				return
+			}
+			responses = append(responses, newDAVResponse(href, objectProps(object), names, false))
+		}
+	case xml.Name{Space: nsDAV, Local: "sync-collection"}:
+		h.handleSyncCollection(w, r, path.project, request)
+		return
+	default:
+		http.Error(w, ErrMsgUnsupportedReport, http.StatusForbidden)
+		return
+	}
+	writeMultistatus(w, responses, "")
+}
+
+// handleSyncCollection answers a sync-collection report. Without a token,
+// all objects are reported; with one, those that changed or were removed
+// since.
+func (h *CalDAVHandler) handleSyncCollection(w http.ResponseWriter, r *http.Request, project string, request *davRequest) {
+	names := request.props()
+	var responses []davResponse
+	var token string
+	if request.SyncToken == "" {
+		// The token is taken first, so that changes made while the objects
+		// are listed are reported again rather than missed.
+		var err error
+		if token, err = h.service.SyncToken(r.Context()); err != nil {
+			h.internalError(w, "get sync token", err)
+			return
+		}
+		objects, err := h.service.ListObjects(r.Context(), project)
+		if err != nil {
+			h.internalError(w, "list objects", err)
+			return
+		}
+		for _, object := range objects {
+			responses = append(responses, newDAVResponse(objectHref(project, object.Name), objectProps(object), names, false))
+		}
+	} else {
+		changed, removed, next, err := h.service.Changes(r.Context(), project, request.SyncToken)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidSyncToken) {
+				writeDAVError(w, http.StatusForbidden, xml.Name{Space: nsDAV, Local: "valid-sync-token"})
+			} else {
+				h.internalError(w, "list changes", err)
+			}
+			return
+		}
+		for _, object := range changed {
+			responses = append(responses, newDAVResponse(objectHref(project, object.Name), objectProps(object), names, false))
+		}
+		for _, name := range removed {
+			responses = append(responses, davResponse{href: objectHref(project, name), status: http.StatusNotFound})
+		}
+		token = next
+	}
+	writeMultistatus(w, responses, token)
+}
+
+// objectName returns the name of the object in project that href refers
+// to. Hrefs may be paths or full URLs.
+func objectName(href, project string) (string, bool) {
+	parsed, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	request := &http.Request{URL: parsed}
+	path, ok := parseDAVPath(request)
+	if !ok || path.project != project || path.name == "" {
+		return "", false
+	}
+	return path.name, true
+}
+
+func (h *CalDAVHandler) handleGetObject(w http.ResponseWriter, r *http.Request, path davPath) {
+	if path.name == "" {
+		http.Error(w, ErrMsgMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+	object, err := h.service.GetObject(r.Context(), path.project, path.name)
+	if err != nil {
+		h.objectError(w, "get object", err)
+		return
+	}
+	w.Header().Set("ETag", object.ETag)
+	if r.Header.Get("If-None-Match") == object.ETag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(object.Data)
+	}
+}
+
+// handlePutObject creates or updates the task of an object. The task is
+// stored rather than the object, so the response has no ETag and clients
+// GET the object again, as RFC 4791 asks.
+func (h *CalDAVHandler) handlePutObject(w http.ResponseWriter, r *http.Request, path davPath) {
+	if path.name == "" {
+		http.Error(w, ErrMsgMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCalendarObjectSize))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidCalendarData, http.StatusRequestEntityTooLarge)
+		return
+	}
+	_, created, err := h.service.PutObject(r.Context(), path.project, path.name, data, r.Header.Get("If-Match"), r.Header.Get("If-None-Match"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCalendarData):
+			writeDAVError(w, http.StatusBadRequest, xml.Name{Space: nsCalDAV, Local: "valid-calendar-data"})
+		case errors.Is(err, service.ErrTitleTooShort):
+			http.Error(w, ErrMsgTitleTooShort, http.StatusBadRequest)
+		case errors.Is(err, service.ErrInvalidTransition):
+			http.Error(w, ErrMsgInvalidTransition, http.StatusConflict)
+		case errors.Is(err, service.ErrExternalIDTaken):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			h.objectError(w, "put object", err)
+		}
+		return
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *CalDAVHandler) handleDeleteObject(w http.ResponseWriter, r *http.Request, path davPath) {
+	if path.name == "" {
+		http.Error(w, ErrMsgMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+	if err := h.service.DeleteObject(r.Context(), path.project, path.name, r.Header.Get("If-Match")); err != nil {
+		h.objectError(w, "delete object", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// objectError writes the error of an operation on an object.
+func (h *CalDAVHandler) objectError(w http.ResponseWriter, operation string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrTaskNotFound):
+		http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+	case errors.Is(err, service.ErrPreconditionFailed):
+		http.Error(w, ErrMsgPreconditionFailed, http.StatusPreconditionFailed)
+	default:
+		h.internalError(w, operation, err)
+	}
+}
+
+func (h *CalDAVHandler) internalError(w http.ResponseWriter, operation string, err error) {
+	log.Printf("caldav %s: %v", operation, err)
+	http.Error(w, ErrMsgFailedCalDAVRequest, http.StatusInternalServerError)
+}
+
+func principalProps() []davProperty {
+	return []davProperty{
+		{xml.Name{Space: nsDAV, Local: "resourcetype"}, "<D:collection/><D:principal/>"},
+		{xml.Name{Space: nsDAV, Local: "displayname"}, "task-manager"},
+		{xml.Name{Space: nsDAV, Local: "current-user-principal"}, hrefValue(calDAVRoot)},
+		{xml.Name{Space: nsDAV, Local: "principal-URL"}, hrefValue(calDAVRoot)},
+		{xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}, hrefValue(calDAVHome)},
+	}
+}
+
+func homeProps() []davProperty {
+	return []davProperty{
+		{xml.Name{Space: nsDAV, Local: "resourcetype"}, "<D:collection/>"},
+		{xml.Name{Space: nsDAV, Local: "displayname"}, "Projects"},
+		{xml.Name{Space: nsDAV, Local: "current-user-principal"}, hrefValue(calDAVRoot)},
+	}
+}
+
+// calendarProps returns the properties of the calendar of project. Its
+// ctag is the sync token, which changes whenever a task changes.
+func (h *CalDAVHandler) calendarProps(r *http.Request, project string) ([]davProperty, error) {
+	token, err := h.service.SyncToken(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	return []davProperty{
+		{xml.Name{Space: nsDAV, Local: "resourcetype"}, "<D:collection/><C:calendar/>"},
+		{xml.Name{Space: nsDAV, Local: "displayname"}, escapeXML(project)},
+		{xml.Name{Space: nsDAV, Local: "current-user-principal"}, hrefValue(calDAVRoot)},
+		{xml.Name{Space: nsDAV, Local: "current-user-privilege-set"}, "<D:privilege><D:read/></D:privilege><D:privilege><D:write/></D:privilege>"},
+		{xml.Name{Space: nsDAV, Local: "supported-report-set"}, "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report>" +
+			"<D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>" +
+			"<D:supported-report><D:report><D:sync-collection/></D:report></D:supported-report>"},
+		{xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}, `<C:comp name="VTODO"/>`},
+		{xml.Name{Space: nsDAV, Local: "sync-token"}, escapeXML(token)},
+		{xml.Name{Space: nsCS, Local: "getctag"}, escapeXML(token)},
+	}, nil
+}
+
+func objectProps(object *service.CalDAVObject) []davProperty {
+	return []davProperty{
+		{xml.Name{Space: nsDAV, Local: "resourcetype"}, ""},
+		{xml.Name{Space: nsDAV, Local: "getetag"}, escapeXML(object.ETag)},
+		{xml.Name{Space: nsDAV, Local: "getcontenttype"}, "text/calendar; charset=utf-8; component=VTODO"},
+		{xml.Name{Space: nsDAV, Local: "getlastmodified"}, object.Task.UpdatedAt.UTC().Format(http.TimeFormat)},
+		{xml.Name{Space: nsCalDAV, Local: "calendar-data"}, escapeXML(string(object.Data))},
+	}
+}
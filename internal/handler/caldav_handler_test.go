@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+func sendDAV(t *testing.T, method, url, body string, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer func() { _ = response.Body.Close() }()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return response, string(data)
+}
+
+const syncCollection = `<?xml version="1.0"?>
+<D:sync-collection xmlns:D="DAV:"><D:sync-token>%s</D:sync-token><D:sync-level>1</D:sync-level>
+<D:prop><D:getetag/></D:prop></D:sync-collection>`
+
+var syncTokenPattern = regexp.MustCompile(`<D:sync-token>([^<]*)</D:sync-token></D:multistatus>`)
+
+func TestCalDAV(t *testing.T) {
+	db := newTestDB(t)
+	taskRepository := repository.NewSQLiteTaskRepository(db)
+	workflowRepository := repository.NewSQLiteWorkflowRepository(db)
+	eventLogRepository := repository.NewSQLiteEventLogRepository(db)
+	transactor := repository.NewSQLTransactor(db)
+	// Events go to the event log right away rather than through the outbox.
+	bus := events.NewBus()
+	bus.Subscribe(service.NewEventStreamService(eventLogRepository, time.Hour).HandleEvent)
+	tasks := service.NewTaskService(taskRepository, workflowRepository, bus, transactor)
+	mux := http.NewServeMux()
+	NewCalDAVHandler(service.NewCalDAVService(tasks, taskRepository, workflowRepository, eventLogRepository, transactor)).RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	existing, err := tasks.CreateTask(ctx, models.CreateTaskInput{Project: "home", Title: "Pay rent"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := tasks.CreateTask(ctx, models.CreateTaskInput{Project: "work", Title: "Write report"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	response, body := sendDAV(t, "PROPFIND", server.URL+"/dav/calendars/", `<?xml version="1.0"?>
+<D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:resourcetype/><C:supported-calendar-component-set/><D:owner/></D:prop></D:propfind>`,
+		map[string]string{"Depth": "1"})
+	if response.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND home: %d %s", response.StatusCode, body)
+	}
+	for _, want := range []string{
+		"<D:href>/dav/calendars/home/</D:href>",
+		"<D:href>/dav/calendars/work/</D:href>",
+		"<D:href>/dav/calendars/default/</D:href>",
+		"<D:resourcetype><D:collection/><C:calendar/></D:resourcetype>",
+		`<C:supported-calendar-component-set><C:comp name="VTODO"/></C:supported-calendar-component-set>`,
+		"<D:prop><D:owner/></D:prop><D:status>HTTP/1.1 404 Not Found</D:status>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("PROPFIND home lacks %s:\n%s", want, body)
+		}
+	}
+
+	response, body = sendDAV(t, "REPORT", server.URL+"/dav/calendars/home/", strings.Replace(syncCollection, "%s", "", 1), nil)
+	match := syncTokenPattern.FindStringSubmatch(body)
+	if response.StatusCode != http.StatusMultiStatus || match == nil || !strings.Contains(body, existing.ID.String()+".ics") {
+		t.Fatalf("initial sync: %d %s", response.StatusCode, body)
+	}
+	token := match[1]
+
+	// A client creates a to-do, which becomes a task of the project.
+	object := "/dav/calendars/home/client-1.ics"
+	todo := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VTODO\r\nUID:client-1\r\nSUMMARY:Water plants\r\n" +
+		"DUE;VALUE=DATE:20300101\r\nSTATUS:IN-PROCESS\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+	response, body = sendDAV(t, http.MethodPut, server.URL+object, todo, map[string]string{"If-None-Match": "*"})
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: %d %s", response.StatusCode, body)
+	}
+	created, err := taskRepository.GetTaskByExternalID(ctx, "caldav:client-1")
+	if err != nil || created.Project != "home" || created.Title != "Water plants" || created.Status != models.TaskStatusInProgress ||
+		!created.DueAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("created task %+v, %v", created, err)
+	}
+	response, _ = sendDAV(t, http.MethodPut, server.URL+object, todo, map[string]string{"If-None-Match": "*"})
+	if response.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("PUT over an existing object: %d", response.StatusCode)
+	}
+
+	response, body = sendDAV(t, http.MethodGet, server.URL+object, "", nil)
+	etag := response.Header.Get("ETag")
+	if response.StatusCode != http.StatusOK || etag == "" || !strings.Contains(body, "UID:client-1\r\n") || !strings.Contains(body, "STATUS:IN-PROCESS\r\n") {
+		t.Fatalf("GET: %d %s", response.StatusCode, body)
+	}
+
+	// Updates must match the current ETag.
+	done := strings.Replace(todo, "IN-PROCESS", "COMPLETED", 1)
+	response, _ = sendDAV(t, http.MethodPut, server.URL+object, done, map[string]string{"If-Match": `"1"`})
+	if response.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("PUT with a stale ETag: %d", response.StatusCode)
+	}
+	response, body = sendDAV(t, http.MethodPut, server.URL+object, done, map[string]string{"If-Match": etag})
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("PUT with the ETag: %d %s", response.StatusCode, body)
+	}
+	if updated, _ := tasks.GetTask(ctx, created.ID); updated.Status != models.TaskStatusDone {
+		t.Errorf("status after COMPLETED is %s", updated.Status)
+	}
+	// The ETag counts updates, so it is stale after one however quick.
+	response, _ = sendDAV(t, http.MethodPut, server.URL+object, done, map[string]string{"If-Match": etag})
+	if response.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("PUT with the ETag from before the update: %d", response.StatusCode)
+	}
+	response, _ = sendDAV(t, http.MethodGet, server.URL+object, "", nil)
+	if updated := response.Header.Get("ETag"); updated == etag || !strings.HasPrefix(updated, `"`+created.ID.String()+"-") {
+		t.Errorf("ETag after an update is %s, before it %s", updated, etag)
+	}
+	response, _ = sendDAV(t, http.MethodPut, server.URL+object, "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", nil)
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT without a VTODO: %d", response.StatusCode)
+	}
+	// Removing DUE clears the due date.
+	undated := strings.Replace(done, "DUE;VALUE=DATE:20300101\r\n", "", 1)
+	if response, body = sendDAV(t, http.MethodPut, server.URL+object, undated, nil); response.StatusCode != http.StatusNoContent {
+		t.Fatalf("PUT without DUE: %d %s", response.StatusCode, body)
+	}
+	if updated, _ := tasks.GetTask(ctx, created.ID); updated.DueAt != nil {
+		t.Errorf("due date after removing DUE is %v", updated.DueAt)
+	}
+	large := `<?xml version="1.0"?><D:propfind xmlns:D="DAV:"><!--` + strings.Repeat(" ", maxDAVRequestSize) + `--><D:allprop/></D:propfind>`
+	if response, _ = sendDAV(t, "PROPFIND", server.URL+"/dav/calendars/home/", large, map[string]string{"Depth": "0"}); response.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("PROPFIND of %d bytes: %d", len(large), response.StatusCode)
+	}
+
+	if err := tasks.DeleteTask(ctx, existing.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := tasks.CreateTask(ctx, models.CreateTaskInput{Project: "work", Title: "Book flights"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// The changes since the first sync are the new object and the deleted
+	// task, but not the task of another project.
+	response, body = sendDAV(t, "REPORT", server.URL+"/dav/calendars/home/", strings.Replace(syncCollection, "%s", token, 1), nil)
+	if response.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("sync: %d %s", response.StatusCode, body)
+	}
+	if got := strings.Count(body, "<D:response>"); got != 2 {
+		t.Errorf("sync has %d responses:\n%s", got, body)
+	}
+	for _, want := range []string{
+		"<D:href>/dav/calendars/home/client-1.ics</D:href><D:propstat><D:prop><D:getetag>",
+		"<D:href>/dav/calendars/home/" + existing.ID.String() + ".ics</D:href><D:status>HTTP/1.1 404 Not Found</D:status>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("sync lacks %s:\n%s", want, body)
+		}
+	}
+	next := syncTokenPattern.FindStringSubmatch(body)
+	if next == nil || next[1] == token {
+		t.Fatalf("sync token %v after %s", next, token)
+	}
+	_, body = sendDAV(t, "REPORT", server.URL+"/dav/calendars/home/", strings.Replace(syncCollection, "%s", next[1], 1), nil)
+	if strings.Contains(body, "<D:response>") {
+		t.Errorf("sync without changes:\n%s", body)
+	}
+	response, body = sendDAV(t, "REPORT", server.URL+"/dav/calendars/home/", strings.Replace(syncCollection, "%s", "urn:other:1", 1), nil)
+	if response.StatusCode != http.StatusForbidden || !strings.Contains(body, "<D:valid-sync-token/>") {
+		t.Errorf("sync with a foreign token: %d %s", response.StatusCode, body)
+	}
+
+	response, body = sendDAV(t, "REPORT", server.URL+"/dav/calendars/home/", `<?xml version="1.0"?>
+<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:getetag/><C:calendar-data/></D:prop>
+<D:href>/dav/calendars/home/client-1.ics</D:href><D:href>/dav/calendars/home/missing.ics</D:href></C:calendar-multiget>`, nil)
+	if response.StatusCode != http.StatusMultiStatus || !strings.Contains(body, "SUMMARY:Water plants") ||
+		!strings.Contains(body, "<D:href>/dav/calendars/home/missing.ics</D:href><D:status>HTTP/1.1 404 Not Found</D:status>") {
+		t.Errorf("multiget: %d %s", response.StatusCode, body)
+	}
+
+	// Objects of one calendar can't be reached through another.
+	response, _ = sendDAV(t, http.MethodDelete, server.URL+"/dav/calendars/work/client-1.ics", "", nil)
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("DELETE in another calendar: %d", response.StatusCode)
+	}
+	response, _ = sendDAV(t, http.MethodDelete, server.URL+object, "", nil)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE: %d", response.StatusCode)
+	}
+	if _, err := tasks.GetTask(ctx, created.ID); err == nil {
+		t.Errorf("task of the deleted object still exists")
+	}
+}
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Namespaces of the WebDAV, CalDAV and Calendar Server properties.
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsCS     = "http://calendarserver.org/ns/"
+)
+
+// davPrefixes are the prefixes that multistatus responses declare.
+var davPrefixes = map[string]string{nsDAV: "D", nsCalDAV: "C", nsCS: "CS"}
+
+// davRequest is the body of a PROPFIND or REPORT request. Its XMLName tells
+// which one it is.
+type davRequest struct {
+	XMLName   xml.Name
+	AllProp   *struct{}     `xml:"DAV: allprop"`
+	PropName  *struct{}     `xml:"DAV: propname"`
+	Prop      *davPropNames `xml:"DAV: prop"`
+	Hrefs     []string      `xml:"DAV: href"`
+	SyncToken string        `xml:"DAV: sync-token"`
+	Filter    *davFilter    `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+type davFilter struct {
+	Comp *davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type davPropNames struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+type davCompFilter struct {
+	Name    string          `xml:"name,attr"`
+	Filters []davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// maxDAVRequestSize limits the size of PROPFIND and REPORT bodies.
+const maxDAVRequestSize = 1 << 20
+
+// readDAVRequest decodes the body of r. An empty body is an allprop
+// PROPFIND, as RFC 4918 asks.
+func readDAVRequest(w http.ResponseWriter, r *http.Request) (*davRequest, error) {
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxDAVRequestSize))
+	if _, err := body.Peek(1); errors.Is(err, io.EOF) {
+		return &davRequest{XMLName: xml.Name{Space: nsDAV, Local: "propfind"}, AllProp: &struct{}{}}, nil
+	}
+	var request davRequest
+	if err := xml.NewDecoder(body).Decode(&request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// writeDAVRequestError responds to a body that readDAVRequest couldn't
+// decode.
+func writeDAVRequestError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, ErrMsgInvalidDAVRequest, http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, ErrMsgInvalidDAVRequest, http.StatusBadRequest)
+}
+
+// props returns the properties the request asks for, or nil for all of
+// them.
+func (req *davRequest) props() []xml.Name {
+	if req.Prop == nil {
+		return nil
+	}
+	names := make([]xml.Name, 0, len(req.Prop.Names))
+	for _, name := range req.Prop.Names {
+		names = append(names, name.XMLName)
+	}
+	return names
+}
+
+// wantsTodos reports whether the filter of a calendar-query matches
+// VTODOs, the only component the calendars have.
+func (req *davRequest) wantsTodos() bool {
+	if req.Filter == nil || req.Filter.Comp == nil || len(req.Filter.Comp.Filters) == 0 {
+		return true
+	}
+	for _, filter := range req.Filter.Comp.Filters {
+		if strings.EqualFold(filter.Name, "VTODO") {
+			return true
+		}
+	}
+	return false
+}
+
+// davProperty is a property of a resource with its value as XML.
+type davProperty struct {
+	name  xml.Name
+	value string
+}
+
+// davResponse is a response element of a multistatus. Responses for
+// resources that are gone only have a status.
+type davResponse struct {
+	href    string
+	status  int
+	found   []davProperty
+	missing []xml.Name
+}
+
+// newDAVResponse returns the response for href with the properties of
+// props that names asks for, all of them if names is nil, or only their
+// names if nameOnly is set.
+func newDAVResponse(href string, props []davProperty, names []xml.Name, nameOnly bool) davResponse {
+	response := davResponse{href: href}
+	if names == nil {
+		for _, prop := range props {
+			if nameOnly {
+				prop.value = ""
+			}
+			response.found = append(response.found, prop)
+		}
+		return response
+	}
+	for _, name := range names {
+		i := indexOfProperty(props, name)
+		if i < 0 {
+			response.missing = append(response.missing, name)
+			continue
+		}
+		response.found = append(response.found, props[i])
+	}
+	return response
+}
+
+func indexOfProperty(props []davProperty, name xml.Name) int {
+	for i, prop := range props {
+		if prop.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeMultistatus writes a 207 Multi-Status with responses, and the sync
+// token of a sync-collection report if there is one.
+func writeMultistatus(w http.ResponseWriter, responses []davResponse, syncToken string) {
+	var out strings.Builder
+	out.WriteString(xml.Header)
+	out.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:CS="http://calendarserver.org/ns/">`)
+	for _, response := range responses {
+		out.WriteString("<D:response><D:href>")
+		out.WriteString(escapeXML(response.href))
+		out.WriteString("</D:href>")
+		if response.status != 0 {
+			writeStatus(&out, response.status)
+		}
+		if len(response.found) > 0 {
+			out.WriteString("<D:propstat><D:prop>")
+			for _, prop := range response.found {
+				writeElement(&out, prop.name, prop.value)
+			}
+			out.WriteString("</D:prop>")
+			writeStatus(&out, http.StatusOK)
+			out.WriteString("</D:propstat>")
+		}
+		if len(response.missing) > 0 {
+			out.WriteString("<D:propstat><D:prop>")
+			for _, name := range response.missing {
+				writeElement(&out, name, "")
+			}
+			out.WriteString("</D:prop>")
+			writeStatus(&out, http.StatusNotFound)
+			out.WriteString("</D:propstat>")
+		}
+		out.WriteString("</D:response>")
+	}
+	if syncToken != "" {
+		out.WriteString("<D:sync-token>" + escapeXML(syncToken) + "</D:sync-token>")
+	}
+	out.WriteString("</D:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = io.WriteString(w, out.String())
+}
+
+// writeDAVError writes an error with the precondition that failed, like
+// valid-sync-token.
+func writeDAVError(w http.ResponseWriter, status int, precondition xml.Name) {
+	var out strings.Builder
+	out.WriteString(xml.Header)
+	out.WriteString(`<D:error xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`)
+	writeElement(&out, precondition, "")
+	out.WriteString("</D:error>")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, out.String())
+}
+
+func writeStatus(out *strings.Builder, status int) {
+	out.WriteString("<D:status>HTTP/1.1 " + strconv.Itoa(status) + " " + http.StatusText(status) + "</D:status>")
+}
+
+// writeElement writes an element with value as its content. Elements of
+// namespaces without a prefix declare theirs as the default.
+func writeElement(out *strings.Builder, name xml.Name, value string) {
+	tag := name.Local
+	open := tag
+	if prefix, ok := davPrefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+		open = tag
+	} else if name.Space != "" {
+		open = tag + ` xmlns="` + escapeXML(name.Space) + `"`
+	}
+	if value == "" {
+		out.WriteString("<" + open + "/>")
+		return
+	}
+	out.WriteString("<" + open + ">" + value + "</" + tag + ">")
+}
+
+func escapeXML(s string) string {
+	var out strings.Builder
+	_ = xml.EscapeText(&out, []byte(s))
+	return out.String()
+}
+
+// hrefValue returns the value of a property that is a URL, like
+// current-user-principal.
+func hrefValue(href string) string {
+	return "<D:href>" + escapeXML(href) + "</D:href>"
+}
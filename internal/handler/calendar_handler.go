@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/ical"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgInvalidComponent           = "Invalid component! Component must be event or todo"
+	ErrMsgFailedToListCalendarFeeds  = "Failed to list calendar feeds due to an internal server error"
+	ErrMsgFailedToCreateCalendarFeed = "Failed to create calendar feed due to an internal server error"
+	ErrMsgFailedToDeleteCalendarFeed = "Failed to delete calendar feed due to an internal server error"
+	ErrMsgFailedToGetCalendar        = "Failed to get calendar due to an internal server error"
+)
+
+type CalendarHandler struct {
+	service service.CalendarService
+}
+
+func NewCalendarHandler(service service.CalendarService) *CalendarHandler {
+	return &CalendarHandler{service: service}
+}
+
+func (h *CalendarHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /calendar-feeds", h.handleCreateFeed)
+	mux.HandleFunc("GET /calendar-feeds", h.handleListFeeds)
+	mux.HandleFunc("DELETE /calendar-feeds/{id}", h.handleDeleteFeed)
+	mux.HandleFunc("GET /ical/{file}", h.handleGetCalendar)
+}
+
+func (h *CalendarHandler) handleCreateFeed(w http.ResponseWriter, r *http.Request) {
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(r.Body)
+
+	var createInput models.CreateCalendarFeedInput
+	if err := json.NewDecoder(r.Body).Decode(&createInput); err != nil {
+		http.Error(w, ErrMsgInvalidJSON, http.StatusBadRequest)
+		return
+	}
+	feed, err := h.service.CreateFeed(r.Context(), createInput)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCalendarFeed) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, ErrMsgFailedToCreateCalendarFeed, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(feed)
+}
+
+func (h *CalendarHandler) handleListFeeds(w http.ResponseWriter, r *http.Request) {
+	feeds, err := h.service.ListFeeds(r.Context())
+	if err != nil {
+		http.Error(w, ErrMsgFailedToListCalendarFeeds, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(feeds)
+}
+
+func (h *CalendarHandler) handleDeleteFeed(w http.ResponseWriter, r *http.Request) {
+	feedID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+	if err := h.service.DeleteFeed(r.Context(), feedID); err != nil {
+		if errors.Is(err, repository.ErrCalendarFeedNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			http.Error(w, ErrMsgFailedToDeleteCalendarFeed, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetCalendar serves the feed whose token is the name of the .ics
+// file: tasks as events, or with component=todo as to-dos. Calendar clients
+// poll feeds, so responses have an ETag and unchanged feeds are answered
+// with 304 Not Modified.
+func (h *CalendarHandler) handleGetCalendar(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+	if !ok {
+		http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		return
+	}
+	component := ical.Event
+	switch r.URL.Query().Get("component") {
+	case "", "event":
+	case "todo":
+		component = ical.Todo
+	default:
+		http.Error(w, ErrMsgInvalidComponent, http.StatusBadRequest)
+		return
+	}
+
+	var calendar bytes.Buffer
+	if err := h.service.WriteFeed(r.Context(), token, component, &calendar); err != nil {
+		if errors.Is(err, repository.ErrCalendarFeedNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			log.Printf("get calendar: %v", err)
+			http.Error(w, ErrMsgFailedToGetCalendar, http.StatusInternalServerError)
+		}
+		return
+	}
+	writeWithETag(w, r, "text/calendar; charset=utf-8", &calendar)
+}
+
+// writeWithETag writes the body of a feed that clients poll, with an ETag
+// that is the hash of the body. Clients that have the body already get 304
+// Not Modified.
+func writeWithETag(w http.ResponseWriter, r *http.Request, contentType string, body *bytes.Buffer) {
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = body.WriteTo(w)
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+func getCalendar(t *testing.T, url, etag string) (*http.Response, string) {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer func() { _ = response.Body.Close() }()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return response, string(body)
+}
+
+func TestCalendarFeed(t *testing.T) {
+	db := newTestDB(t)
+	taskRepository := repository.NewSQLiteTaskRepository(db)
+	workflowRepository := repository.NewSQLiteWorkflowRepository(db)
+	tasks := service.NewTaskService(taskRepository, workflowRepository, events.Discard, repository.NewSQLTransactor(db))
+	mux := http.NewServeMux()
+	NewCalendarHandler(service.NewCalendarService(repository.NewSQLiteCalendarFeedRepository(db), taskRepository, workflowRepository)).RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	next := time.Now().In(berlin).AddDate(0, 0, 7)
+	midnight := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, berlin)
+	morning := midnight.Add(9*time.Hour + 30*time.Minute)
+	for _, input := range []models.CreateTaskInput{
+		{Project: "home", Title: "Pay rent", Description: "Rent, water", DueAt: &midnight},
+		{Project: "home", Title: "Call the landlord", DueAt: &morning},
+		{Project: "home", Title: "Water plants"},
+		{Project: "work", Title: "Write report", DueAt: &morning},
+	} {
+		if _, err := tasks.CreateTask(ctx, input); err != nil {
+			t.Fatalf("create %q: %v", input.Title, err)
+		}
+	}
+
+	for _, body := range []string{`{}`, `{"project": "home", "timezone": "Mars/Olympus"}`, `{"project": "home", "timezone": "Local"}`} {
+		response, err := http.Post(server.URL+"/calendar-feeds", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("create feed: %v", err)
+		}
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("create feed %s: status %d, want 400", body, response.StatusCode)
+		}
+	}
+	response, err := http.Post(server.URL+"/calendar-feeds", "application/json", strings.NewReader(`{"project": "home", "timezone": "Europe/Berlin"}`))
+	if err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	var feed models.CalendarFeed
+	err = json.NewDecoder(response.Body).Decode(&feed)
+	_ = response.Body.Close()
+	if err != nil || response.StatusCode != http.StatusCreated || feed.Token == "" || feed.Path != "/ical/"+feed.Token+".ics" || feed.Name != "home" {
+		t.Fatalf("create feed: status %d, feed %+v, %v", response.StatusCode, feed, err)
+	}
+
+	response, calendar := getCalendar(t, server.URL+feed.Path, "")
+	if response.StatusCode != http.StatusOK || !strings.HasPrefix(response.Header.Get("Content-Type"), "text/calendar") {
+		t.Fatalf("get calendar: status %d, Content-Type %q", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-TIMEZONE:Europe/Berlin\r\n",
+		"SUMMARY:Pay rent\r\nDESCRIPTION:Rent\\, water\r\n",
+		"DTSTART;VALUE=DATE:" + midnight.Format("20060102") + "\r\nDTEND;VALUE=DATE:" + midnight.AddDate(0, 0, 1).Format("20060102") + "\r\n",
+		"DTSTART:" + morning.UTC().Format("20060102T150405Z") + "\r\n",
+		"SEQUENCE:0\r\n",
+		"LAST-MODIFIED:",
+	} {
+		if !strings.Contains(calendar, want) {
+			t.Errorf("calendar has no %q:\n%s", want, calendar)
+		}
+	}
+	if count := strings.Count(calendar, "BEGIN:VEVENT"); count != 2 {
+		t.Errorf("calendar has %d events, want 2:\n%s", count, calendar)
+	}
+
+	etag := response.Header.Get("ETag")
+	if response, _ := getCalendar(t, server.URL+feed.Path, etag); response.StatusCode != http.StatusNotModified {
+		t.Fatalf("get unchanged calendar: status %d, want 304", response.StatusCode)
+	}
+	listed, err := tasks.ListTasks(ctx, repository.TaskFilter{Project: "home"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	done := models.TaskStatusDone
+	for _, task := range listed {
+		if task.Title == "Pay rent" {
+			if _, err := tasks.UpdateTask(ctx, task.ID, models.UpdateTaskInput{Status: &done}); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+		}
+	}
+	response, calendar = getCalendar(t, server.URL+feed.Path+"?component=todo", etag)
+	if response.StatusCode != http.StatusOK || response.Header.Get("ETag") == etag {
+		t.Fatalf("get changed calendar: status %d, ETag %q", response.StatusCode, response.Header.Get("ETag"))
+	}
+	for _, want := range []string{"BEGIN:VTODO", "DUE;VALUE=DATE:", "STATUS:COMPLETED", "STATUS:NEEDS-ACTION"} {
+		if !strings.Contains(calendar, want) {
+			t.Errorf("to-dos have no %q:\n%s", want, calendar)
+		}
+	}
+
+	if response, _ := getCalendar(t, server.URL+feed.Path+"?component=journal", ""); response.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid component: status %d, want 400", response.StatusCode)
+	}
+	if response, _ := getCalendar(t, server.URL+"/ical/unknown.ics", ""); response.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown token: status %d, want 404", response.StatusCode)
+	}
+	request, _ := http.NewRequest(http.MethodDelete, server.URL+"/calendar-feeds/"+feed.ID.String(), nil)
+	if response, err := http.DefaultClient.Do(request); err != nil || response.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete feed: %v, %v", response, err)
+	}
+	if response, _ := getCalendar(t, server.URL+feed.Path, ""); response.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted feed: status %d, want 404", response.StatusCode)
+	}
+}
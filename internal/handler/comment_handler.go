@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgFailedToListComments = "Failed to list comments due to an internal server error"
+)
+
+type CommentHandler struct {
+	service service.CommentService
+}
+
+func NewCommentHandler(service service.CommentService) *CommentHandler {
+	return &CommentHandler{service: service}
+}
+
+func (h *CommentHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /tasks/{id}/comments", h.handleCreateComment)
+	mux.HandleFunc("GET /tasks/{id}/comments", h.handleListComments)
+}
+
+func (h *CommentHandler) handleCreateComment(w http.ResponseWriter, r *http.Request) {
+	taskID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(r.Body)
+
+	var createInput models.CreateCommentInput
+	if err := json.NewDecoder(r.Body).Decode(&createInput); err != nil {
+		http.Error(w, ErrMsgInvalidJSON, http.StatusBadRequest)
+		return
+	}
+	comment, err := h.service.CreateComment(r.Context(), taskID, createInput)
+	if err != nil {
+		if errors.Is(err, repository.ErrTaskNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(comment)
+}
+
+func (h *CommentHandler) handleListComments(w http.ResponseWriter, r *http.Request) {
+	taskID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+	comments, err := h.service.ListComments(r.Context(), taskID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTaskNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			http.Error(w, ErrMsgFailedToListComments, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(comments)
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"task-manager/internal/models"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgInvalidLastEventID = "Invalid Last-Event-ID! It must be a non-negative integer"
+	ErrMsgEventStreamClosed  = "The event stream is shutting down"
+
+	// eventStreamHeartbeat is how often an idle stream sends a comment line
+	// so that clients and proxies notice dead connections.
+	eventStreamHeartbeat = 15 * time.Second
+	// eventStreamWriteTimeout bounds every single write to a stream. It
+	// replaces the server's WriteTimeout, which would otherwise end every
+	// stream after a few seconds.
+	eventStreamWriteTimeout = 10 * time.Second
+	eventStreamReplayBatch  = 500
+	eventStreamRetry        = 3 * time.Second
+)
+
+type EventHandler struct {
+	service service.EventStreamService
+}
+
+func NewEventHandler(service service.EventStreamService) *EventHandler {
+	return &EventHandler{service: service}
+}
+
+func (h *EventHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /events", h.handleEvents)
+}
+
+// handleEvents streams task events as Server-Sent Events. Clients that send
+// Last-Event-ID (or the last_event_id query parameter) first receive the
+// logged events they missed.
+func (h *EventHandler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	filter := service.EventStreamFilter{Project: queryParams.Get("project")}
+	if statusStr := queryParams.Get("status"); statusStr != "" {
+		status := models.TaskStatus(statusStr)
+		filter.Status = &status
+	}
+	lastIDStr := r.Header.Get("Last-Event-ID")
+	if lastIDStr == "" {
+		lastIDStr = queryParams.Get("last_event_id")
+	}
+	var lastID int64
+	if lastIDStr != "" {
+		var err error
+		if lastID, err = strconv.ParseInt(lastIDStr, 10, 64); err != nil || lastID < 0 {
+			http.Error(w, ErrMsgInvalidLastEventID, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Subscribing before replaying guarantees that nothing published in
+	// between is lost; duplicates are skipped by ID.
+	subscription, err := h.service.Subscribe(filter)
+	if err != nil {
+		if errors.Is(err, service.ErrEventStreamClosed) {
+			http.Error(w, ErrMsgEventStreamClosed, http.StatusServiceUnavailable)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer h.service.Unsubscribe(subscription)
+
+	stream := &eventStream{w: w, rc: http.NewResponseController(w)}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := stream.write("retry: %d\n\n", eventStreamRetry.Milliseconds()); err != nil {
+		return
+	}
+
+	if lastID > 0 {
+		for first := true; ; first = false {
+			entries, reset, err := h.service.Replay(r.Context(), lastID, filter, eventStreamReplayBatch)
+			if err != nil {
+				return
+			}
+			if first && reset {
+				if err := stream.write("event: reset\ndata: {}\n\n"); err != nil {
+					return
+				}
+			}
+			for _, entry := range entries {
+				if err := stream.writeEntry(entry); err != nil {
+					return
+				}
+				lastID = entry.ID
+			}
+			if len(entries) < eventStreamReplayBatch {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case entry, ok := <-subscription.C:
+			if !ok {
+				return
+			}
+			if entry.ID <= lastID {
+				continue
+			}
+			if err := stream.writeEntry(entry); err != nil {
+				return
+			}
+			lastID = entry.ID
+		case <-heartbeat.C:
+			if err := stream.write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+type eventStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (s *eventStream) write(format string, args ...any) error {
+	// Not every ResponseWriter supports deadlines; those just don't get one.
+	_ = s.rc.SetWriteDeadline(time.Now().Add(eventStreamWriteTimeout))
+	if _, err := fmt.Fprintf(s.w, format, args...); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *eventStream) writeEntry(entry *models.EventLogEntry) error {
+	return s.write("id: %d\nevent: %s\ndata: %s\n\n", entry.ID, entry.Type, entry.Payload)
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"task-manager/internal/graphql"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgInvalidGraphQLRequest = "Invalid GraphQL request! Send a JSON object with a query"
+	ErrMsgInvalidVariables      = "Invalid variables! Variables must be a JSON object"
+)
+
+// maxGraphQLRequestSize bounds the bodies of GraphQL requests, which are
+// queries rather than data.
+const maxGraphQLRequestSize = 1 << 20
+
+// GraphQLHandler serves the GraphQL API, which reads tasks with their
+// workflow statuses and comments in one request.
+type GraphQLHandler struct {
+	schema *graphql.Schema
+}
+
+func NewGraphQLHandler(tasks service.TaskService, comments service.CommentService, workflows service.WorkflowService, limits graphql.Limits) *GraphQLHandler {
+	schema, err := newGraphQLSchema(tasks, comments, workflows, limits)
+	if err != nil {
+		// The schema is fixed, so this is a bug rather than bad input.
+		panic(fmt.Sprintf("graphql schema: %v", err))
+	}
+	return &GraphQLHandler{schema: schema}
+}
+
+func (h *GraphQLHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /graphql", h.handlePost)
+	mux.HandleFunc("GET /graphql", h.handleGet)
+	mux.HandleFunc("GET /graphql/schema.graphql", h.handleSchema)
+}
+
+func (h *GraphQLHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(r.Body)
+
+	var req graphql.Request
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLRequestSize))
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, ErrMsgInvalidGraphQLRequest, http.StatusBadRequest)
+		return
+	}
+	h.execute(w, r, req)
+}
+
+// handleGet runs queries sent as query parameters. It doesn't run
+// mutations, as GET requests must not change anything.
+func (h *GraphQLHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	req := graphql.Request{
+		Query:         queryParams.Get("query"),
+		OperationName: queryParams.Get("operationName"),
+		QueryOnly:     true,
+	}
+	if variables := queryParams.Get("variables"); variables != "" {
+		decoder := json.NewDecoder(bytes.NewReader([]byte(variables)))
+		decoder.UseNumber()
+		if err := decoder.Decode(&req.Variables); err != nil {
+			http.Error(w, ErrMsgInvalidVariables, http.StatusBadRequest)
+			return
+		}
+	}
+	h.execute(w, r, req)
+}
+
+// execute responds 200 to requests whose operation ran, even if fields
+// failed, and 400 to requests that couldn't run.
+func (h *GraphQLHandler) execute(w http.ResponseWriter, r *http.Request, req graphql.Request) {
+	response := h.schema.Execute(r.Context(), req)
+	body, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("graphql response: %v", err)
+		http.Error(w, ErrMsgGraphQLInternal, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !response.Executed {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	_, _ = w.Write(body)
+}
+
+func (h *GraphQLHandler) handleSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = io.WriteString(w, h.schema.SDL())
+}
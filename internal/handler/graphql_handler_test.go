@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/events"
+	"task-manager/internal/graphql"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+// countingCommentService counts the batched comment lookups.
+type countingCommentService struct {
+	service.CommentService
+	batches int
+}
+
+func (s *countingCommentService) ListCommentsForTasks(ctx context.Context, taskIDs []uuid.UUID) (map[uuid.UUID][]*models.Comment, error) {
+	s.batches++
+	return s.CommentService.ListCommentsForTasks(ctx, taskIDs)
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func TestGraphQL(t *testing.T) {
+	db := newTestDB(t)
+	taskRepository := repository.NewSQLiteTaskRepository(db)
+	workflowRepository := repository.NewSQLiteWorkflowRepository(db)
+	transactor := repository.NewSQLTransactor(db)
+	bus := events.NewBus()
+	tasks := service.NewTaskService(taskRepository, workflowRepository, bus, transactor)
+	comments := &countingCommentService{CommentService: service.NewCommentService(repository.NewSQLiteCommentRepository(db), taskRepository, bus, transactor)}
+	mux := http.NewServeMux()
+	NewGraphQLHandler(tasks, comments, service.NewWorkflowService(workflowRepository, taskRepository, transactor), graphql.Limits{MaxDepth: 6, MaxComplexity: 500}).RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	post := func(query string, variables map[string]any) (int, graphQLResponse) {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{"query": query, "variables": variables})
+		response, err := http.Post(server.URL+"/graphql", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		defer response.Body.Close()
+		var result graphQLResponse
+		if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return response.StatusCode, result
+	}
+
+	var created struct {
+		CreateTask struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"createTask"`
+	}
+	for _, title := range []string{"Pay rent", "Water plants", "Call mum"} {
+		status, result := post(`mutation($input: CreateTaskInput!) { createTask(input: $input) { id status } }`,
+			map[string]any{"input": map[string]any{"project": "home", "title": title, "dueAt": "2026-11-01T09:00:00Z"}})
+		if status != http.StatusOK || len(result.Errors) > 0 {
+			t.Fatalf("createTask: %d %+v", status, result.Errors)
+		}
+		if err := json.Unmarshal(result.Data, &created); err != nil || created.CreateTask.Status != "new" {
+			t.Fatalf("createTask: %s", result.Data)
+		}
+		for _, body := range []string{"first", "second"} {
+			_, result := post(`mutation($id: ID!, $body: String!) { addComment(taskId: $id, input: {body: $body}) { id } }`,
+				map[string]any{"id": created.CreateTask.ID, "body": title + " " + body})
+			if len(result.Errors) > 0 {
+				t.Fatalf("addComment: %+v", result.Errors)
+			}
+		}
+	}
+
+	query := `query($after: String) {
+		tasks(project: "home", first: 2, after: $after) {
+			edges { cursor node { title workflowStatus { name category } comments(first: 1) { nodes { body } pageInfo { hasNextPage } } } }
+			pageInfo { hasNextPage hasPreviousPage endCursor }
+		}
+	}`
+	var page struct {
+		Tasks struct {
+			Edges []struct {
+				Node struct {
+					Title          string `json:"title"`
+					WorkflowStatus struct {
+						Name     string `json:"name"`
+						Category string `json:"category"`
+					} `json:"workflowStatus"`
+					Comments struct {
+						Nodes []struct {
+							Body string `json:"body"`
+						} `json:"nodes"`
+						PageInfo struct {
+							HasNextPage bool `json:"hasNextPage"`
+						} `json:"pageInfo"`
+					} `json:"comments"`
+				} `json:"node"`
+			} `json:"edges"`
+			PageInfo struct {
+				HasNextPage     bool   `json:"hasNextPage"`
+				HasPreviousPage bool   `json:"hasPreviousPage"`
+				EndCursor       string `json:"endCursor"`
+			} `json:"pageInfo"`
+		} `json:"tasks"`
+	}
+	comments.batches = 0
+	_, result := post(query, nil)
+	if err := json.Unmarshal(result.Data, &page); err != nil || len(result.Errors) > 0 {
+		t.Fatalf("tasks: %s %+v", result.Data, result.Errors)
+	}
+	edges := page.Tasks.Edges
+	if len(edges) != 2 || edges[0].Node.Title != "Call mum" || edges[0].Node.WorkflowStatus.Name != "New" ||
+		edges[0].Node.WorkflowStatus.Category != "todo" || len(edges[0].Node.Comments.Nodes) != 1 ||
+		edges[0].Node.Comments.Nodes[0].Body != "Call mum first" || !edges[0].Node.Comments.PageInfo.HasNextPage {
+		t.Errorf("first page %s", result.Data)
+	}
+	if !page.Tasks.PageInfo.HasNextPage || page.Tasks.PageInfo.HasPreviousPage {
+		t.Errorf("page info of the first page %+v", page.Tasks.PageInfo)
+	}
+	if comments.batches != 1 {
+		t.Errorf("loaded comments in %d queries, want 1", comments.batches)
+	}
+
+	_, result = post(query, map[string]any{"after": page.Tasks.PageInfo.EndCursor})
+	if err := json.Unmarshal(result.Data, &page); err != nil || len(result.Errors) > 0 {
+		t.Fatalf("second page: %s %+v", result.Data, result.Errors)
+	}
+	if len(page.Tasks.Edges) != 1 || page.Tasks.Edges[0].Node.Title != "Pay rent" || page.Tasks.PageInfo.HasNextPage || !page.Tasks.PageInfo.HasPreviousPage {
+		t.Errorf("second page %s", result.Data)
+	}
+
+	// Service errors are reported on the field.
+	_, result = post(`mutation($id: ID!) { updateTask(id: $id, input: {status: "archived"}) { id } }`, map[string]any{"id": created.CreateTask.ID})
+	if len(result.Errors) != 1 || result.Errors[0].Message != ErrMsgInvalidStatus || string(result.Data) != "null" {
+		t.Errorf("updateTask with an unknown status: %s %+v", result.Data, result.Errors)
+	}
+	_, result = post(`{ task(id: "`+uuid.NewString()+`") { id } }`, nil)
+	if len(result.Errors) > 0 || string(result.Data) != `{"task":null}` {
+		t.Errorf("unknown task: %s %+v", result.Data, result.Errors)
+	}
+
+	// Queries beyond the limits don't run.
+	status, result := post(`{ tasks(first: 100) { nodes { comments(first: 100) { nodes { id } } } } }`, nil)
+	if status != http.StatusBadRequest || len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "complexity") {
+		t.Errorf("complex query: %d %+v", status, result.Errors)
+	}
+
+	response, err := http.Get(server.URL + "/graphql?query=" + url.QueryEscape(`mutation { deleteTask(id: "`+created.CreateTask.ID+`") }`))
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("mutation with GET: %d", response.StatusCode)
+	}
+	if _, err := tasks.GetTask(context.Background(), uuid.MustParse(created.CreateTask.ID)); err != nil {
+		t.Errorf("mutation with GET deleted the task: %v", err)
+	}
+
+	response, err = http.Get(server.URL + "/graphql/schema.graphql")
+	if err != nil {
+		t.Fatalf("GET schema: %v", err)
+	}
+	sdl, _ := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if !strings.Contains(string(sdl), "comments(first: Int = 20, after: String): CommentConnection!") {
+		t.Errorf("schema:\n%s", sdl)
+	}
+}
@@ -0,0 +1,593 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/graphql"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgGraphQLInternal = "Internal server error"
+	ErrMsgInvalidCursor   = "Invalid cursor! Use the cursors of a previous page"
+	ErrMsgInvalidFirst    = "Invalid first! It must be between 0 and 100"
+)
+
+const (
+	// DefaultPageSize and MaxPageSize bound the first argument of
+	// connections.
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// taskConnection is a page of tasks; offset is the position of the first
+// one in the whole list.
+type taskConnection struct {
+	tasks       []*models.Task
+	offset      int
+	hasNextPage bool
+}
+
+type commentConnection struct {
+	comments    []*models.Comment
+	offset      int
+	hasNextPage bool
+}
+
+type edge struct {
+	cursor string
+	node   any
+}
+
+type pageInfo struct {
+	hasNextPage     bool
+	hasPreviousPage bool
+	startCursor     *string
+	endCursor       *string
+}
+
+// graphQLResolvers holds the services the resolvers of the schema call.
+type graphQLResolvers struct {
+	tasks     service.TaskService
+	comments  service.CommentService
+	workflows service.WorkflowService
+}
+
+// newGraphQLSchema returns the schema of the GraphQL API over the task,
+// comment and workflow services.
+func newGraphQLSchema(tasks service.TaskService, comments service.CommentService, workflows service.WorkflowService, limits graphql.Limits) (*graphql.Schema, error) {
+	r := &graphQLResolvers{tasks: tasks, comments: comments, workflows: workflows}
+
+	dateTime := &graphql.Scalar{
+		Name:        "DateTime",
+		Description: "An RFC 3339 timestamp.",
+		Serialize: func(value any) (any, error) {
+			if t, ok := value.(time.Time); ok {
+				return t.Format(time.RFC3339Nano), nil
+			}
+			return nil, fmt.Errorf("DateTime cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("DateTime cannot represent a non string value")
+			}
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, fmt.Errorf("DateTime cannot represent %q: it must be an RFC 3339 timestamp", s)
+			}
+			return t, nil
+		},
+	}
+	statusCategory := &graphql.Enum{
+		Name:        "StatusCategory",
+		Description: "What a workflow status means for features that don't know every status.",
+		Values: []graphql.EnumValue{
+			{Name: string(models.StatusCategoryTodo)},
+			{Name: string(models.StatusCategoryActive)},
+			{Name: string(models.StatusCategoryDone)},
+		},
+	}
+	workflowStatus := &graphql.Object{
+		Name:        "WorkflowStatus",
+		Description: "A status of a project's workflow.",
+		Fields: []*graphql.Field{
+			property("key", graphql.NonNullOf(graphql.String), func(s models.WorkflowStatus) any { return s.Key }),
+			property("name", graphql.NonNullOf(graphql.String), func(s models.WorkflowStatus) any { return s.Name }),
+			property("category", graphql.NonNullOf(statusCategory), func(s models.WorkflowStatus) any { return s.Category }),
+		},
+	}
+	pageInfoType := &graphql.Object{
+		Name: "PageInfo",
+		Fields: []*graphql.Field{
+			property("hasNextPage", graphql.NonNullOf(graphql.Boolean), func(p pageInfo) any { return p.hasNextPage }),
+			property("hasPreviousPage", graphql.NonNullOf(graphql.Boolean), func(p pageInfo) any { return p.hasPreviousPage }),
+			property("startCursor", graphql.String, func(p pageInfo) any { return p.startCursor }),
+			property("endCursor", graphql.String, func(p pageInfo) any { return p.endCursor }),
+		},
+	}
+	comment := &graphql.Object{
+		Name: "Comment",
+		Fields: []*graphql.Field{
+			property("id", graphql.NonNullOf(graphql.ID), func(c *models.Comment) any { return c.ID }),
+			property("taskId", graphql.NonNullOf(graphql.ID), func(c *models.Comment) any { return c.TaskID }),
+			property("author", graphql.NonNullOf(graphql.String), func(c *models.Comment) any { return c.Author }),
+			property("body", graphql.NonNullOf(graphql.String), func(c *models.Comment) any { return c.Body }),
+			property("createdAt", graphql.NonNullOf(dateTime), func(c *models.Comment) any { return c.CreatedAt }),
+		},
+	}
+	commentConnectionType := connectionType("Comment", comment, pageInfoType, func(source any) ([]any, int, bool) {
+		c := source.(*commentConnection)
+		nodes := make([]any, len(c.comments))
+		for i, comment := range c.comments {
+			nodes[i] = comment
+		}
+		return nodes, c.offset, c.hasNextPage
+	})
+	task := &graphql.Object{
+		Name: "Task",
+		Fields: []*graphql.Field{
+			property("id", graphql.NonNullOf(graphql.ID), func(t *models.Task) any { return t.ID }),
+			property("externalId", graphql.String, func(t *models.Task) any { return optionalString(t.ExternalID) }),
+			property("project", graphql.NonNullOf(graphql.String), func(t *models.Task) any { return t.Project }),
+			property("title", graphql.NonNullOf(graphql.String), func(t *models.Task) any { return t.Title }),
+			property("description", graphql.NonNullOf(graphql.String), func(t *models.Task) any { return t.Description }),
+			{
+				Name:        "status",
+				Description: "The key of the task's status in its project's workflow.",
+				Type:        graphql.NonNullOf(graphql.String),
+				Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+					return source.(*models.Task).Status, nil
+				},
+			},
+			{
+				Name:        "workflowStatus",
+				Description: "The task's status as its project's workflow defines it, or null if the workflow no longer has it.",
+				Type:        workflowStatus,
+				Batch:       r.batchWorkflowStatuses,
+			},
+			property("assignee", graphql.String, func(t *models.Task) any { return optionalString(t.Assignee) }),
+			property("dueAt", dateTime, func(t *models.Task) any { return t.DueAt }),
+			property("recurrenceId", graphql.ID, func(t *models.Task) any { return t.RecurrenceID }),
+			property("createdAt", graphql.NonNullOf(dateTime), func(t *models.Task) any { return t.CreatedAt }),
+			property("updatedAt", graphql.NonNullOf(dateTime), func(t *models.Task) any { return t.UpdatedAt }),
+			{
+				Name:        "comments",
+				Description: "The task's comments, oldest first.",
+				Type:        graphql.NonNullOf(commentConnectionType),
+				Args:        pageArguments(),
+				Cost:        pageCost,
+				Batch:       r.batchComments,
+			},
+		},
+	}
+	taskConnectionType := connectionType("Task", task, pageInfoType, func(source any) ([]any, int, bool) {
+		c := source.(*taskConnection)
+		nodes := make([]any, len(c.tasks))
+		for i, task := range c.tasks {
+			nodes[i] = task
+		}
+		return nodes, c.offset, c.hasNextPage
+	})
+
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: []*graphql.Field{
+			{
+				Name:        "task",
+				Description: "The task with the ID, or null if there is none.",
+				Type:        task,
+				Args:        []*graphql.Argument{{Name: "id", Type: graphql.NonNullOf(graphql.ID)}},
+				Resolve:     r.resolveTask,
+			},
+			{
+				Name:        "tasks",
+				Description: "Tasks matching the filters, newest first.",
+				Type:        graphql.NonNullOf(taskConnectionType),
+				Args: append([]*graphql.Argument{
+					{Name: "project", Type: graphql.String},
+					{Name: "status", Type: graphql.String},
+					{Name: "assignee", Type: graphql.String},
+					{Name: "dueAfter", Type: dateTime},
+					{Name: "dueBefore", Type: dateTime},
+				}, pageArguments()...),
+				Cost:    pageCost,
+				Resolve: r.resolveTasks,
+			},
+		},
+	}
+
+	createTaskInput := &graphql.InputObject{
+		Name: "CreateTaskInput",
+		Fields: []*graphql.Argument{
+			{Name: "externalId", Type: graphql.String},
+			{Name: "project", Type: graphql.String},
+			{Name: "title", Type: graphql.NonNullOf(graphql.String)},
+			{Name: "description", Type: graphql.String},
+			{Name: "assignee", Type: graphql.String},
+			{Name: "dueAt", Type: dateTime},
+		},
+	}
+	updateTaskInput := &graphql.InputObject{
+		Name:        "UpdateTaskInput",
+		Description: "Changes to a task; fields that are left out or null stay as they are.",
+		Fields: []*graphql.Argument{
+			{Name: "title", Type: graphql.String},
+			{Name: "description", Type: graphql.String},
+			{Name: "status", Type: graphql.String},
+			{Name: "assignee", Type: graphql.String},
+			{Name: "dueAt", Type: dateTime},
+		},
+	}
+	commentInput := &graphql.InputObject{
+		Name: "CommentInput",
+		Fields: []*graphql.Argument{
+			{Name: "author", Type: graphql.String},
+			{Name: "body", Type: graphql.NonNullOf(graphql.String)},
+		},
+	}
+	mutation := &graphql.Object{
+		Name: "Mutation",
+		Fields: []*graphql.Field{
+			{
+				Name:    "createTask",
+				Type:    graphql.NonNullOf(task),
+				Args:    []*graphql.Argument{{Name: "input", Type: graphql.NonNullOf(createTaskInput)}},
+				Resolve: r.createTask,
+			},
+			{
+				Name: "updateTask",
+				Type: graphql.NonNullOf(task),
+				Args: []*graphql.Argument{
+					{Name: "id", Type: graphql.NonNullOf(graphql.ID)},
+					{Name: "input", Type: graphql.NonNullOf(updateTaskInput)},
+				},
+				Resolve: r.updateTask,
+			},
+			{
+				Name:        "deleteTask",
+				Description: "Deletes the task and returns true.",
+				Type:        graphql.NonNullOf(graphql.Boolean),
+				Args:        []*graphql.Argument{{Name: "id", Type: graphql.NonNullOf(graphql.ID)}},
+				Resolve:     r.deleteTask,
+			},
+			{
+				Name: "addComment",
+				Type: graphql.NonNullOf(comment),
+				Args: []*graphql.Argument{
+					{Name: "taskId", Type: graphql.NonNullOf(graphql.ID)},
+					{Name: "input", Type: graphql.NonNullOf(commentInput)},
+				},
+				Resolve: r.addComment,
+			},
+		},
+	}
+	return graphql.NewSchema(query, mutation, limits)
+}
+
+// property returns a field whose value is a property of sources of type T.
+func property[T any](name string, t graphql.Type, get func(T) any) *graphql.Field {
+	return &graphql.Field{
+		Name: name,
+		Type: t,
+		Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+			return get(source.(T)), nil
+		},
+	}
+}
+
+// connectionType returns the <name>Connection and <name>Edge types of
+// pages of node, which page splits into their nodes, the offset of the
+// first one and whether there are more.
+func connectionType(name string, node *graphql.Object, pageInfoType *graphql.Object, page func(source any) ([]any, int, bool)) *graphql.Object {
+	edgeType := &graphql.Object{
+		Name: name + "Edge",
+		Fields: []*graphql.Field{
+			property("cursor", graphql.NonNullOf(graphql.String), func(e edge) any { return e.cursor }),
+			property("node", graphql.NonNullOf(node), func(e edge) any { return e.node }),
+		},
+	}
+	return &graphql.Object{
+		Name: name + "Connection",
+		Fields: []*graphql.Field{
+			{
+				Name: "edges",
+				Type: graphql.NonNullOf(graphql.ListOf(graphql.NonNullOf(edgeType))),
+				Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+					nodes, offset, _ := page(source)
+					edges := make([]edge, len(nodes))
+					for i, node := range nodes {
+						edges[i] = edge{cursor: encodeCursor(offset + i), node: node}
+					}
+					return edges, nil
+				},
+			},
+			{
+				Name: "nodes",
+				Type: graphql.NonNullOf(graphql.ListOf(graphql.NonNullOf(node))),
+				Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+					nodes, _, _ := page(source)
+					return nodes, nil
+				},
+			},
+			{
+				Name: "pageInfo",
+				Type: graphql.NonNullOf(pageInfoType),
+				Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+					nodes, offset, hasNextPage := page(source)
+					info := pageInfo{hasNextPage: hasNextPage, hasPreviousPage: offset > 0}
+					if len(nodes) > 0 {
+						start, end := encodeCursor(offset), encodeCursor(offset+len(nodes)-1)
+						info.startCursor, info.endCursor = &start, &end
+					}
+					return info, nil
+				},
+			},
+		},
+	}
+}
+
+func pageArguments() []*graphql.Argument {
+	return []*graphql.Argument{
+		{Name: "first", Description: "How many items to return, at most 100.", Type: graphql.Int, Default: DefaultPageSize},
+		{Name: "after", Description: "The cursor of the item to start after.", Type: graphql.String},
+	}
+}
+
+// pageCost counts the selections of a connection once per item of the
+// page.
+func pageCost(args map[string]any) int {
+	if first, ok := args["first"].(int); ok {
+		return first
+	}
+	return DefaultPageSize
+}
+
+// pageArgs returns the offset and size of the page that the first and
+// after arguments select.
+func pageArgs(args map[string]any) (offset, first int, err error) {
+	first = DefaultPageSize
+	if value, ok := args["first"].(int); ok {
+		first = value
+	}
+	if first < 0 || first > MaxPageSize {
+		return 0, 0, errors.New(ErrMsgInvalidFirst)
+	}
+	if after, ok := args["after"].(string); ok {
+		position, err := decodeCursor(after)
+		if err != nil {
+			return 0, 0, err
+		}
+		offset = position + 1
+	}
+	return offset, first, nil
+}
+
+// Cursors are opaque to clients, but are positions in the list.
+const cursorPrefix = "cursor:"
+
+func encodeCursor(position int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(position)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(data), cursorPrefix) {
+		return 0, errors.New(ErrMsgInvalidCursor)
+	}
+	position, err := strconv.Atoi(strings.TrimPrefix(string(data), cursorPrefix))
+	if err != nil || position < 0 {
+		return 0, errors.New(ErrMsgInvalidCursor)
+	}
+	return position, nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func (r *graphQLResolvers) resolveTask(ctx context.Context, source any, args map[string]any) (any, error) {
+	taskID, err := uuid.Parse(args["id"].(string))
+	if err != nil {
+		return nil, errors.New(ErrMsgInvalidID)
+	}
+	task, err := r.tasks.GetTask(ctx, taskID)
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, queryError("get task", err)
+	}
+	return task, nil
+}
+
+func (r *graphQLResolvers) resolveTasks(ctx context.Context, source any, args map[string]any) (any, error) {
+	offset, first, err := pageArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	// One more task than the page tells whether there is a next page.
+	filter := repository.TaskFilter{Offset: offset, Limit: first + 1}
+	filter.Project, _ = args["project"].(string)
+	filter.Assignee, _ = args["assignee"].(string)
+	if status, ok := args["status"].(string); ok {
+		taskStatus := models.TaskStatus(status)
+		filter.Status = &taskStatus
+	}
+	if dueAfter, ok := args["dueAfter"].(time.Time); ok {
+		filter.DueAfter = &dueAfter
+	}
+	if dueBefore, ok := args["dueBefore"].(time.Time); ok {
+		filter.DueBefore = &dueBefore
+	}
+	tasks, err := r.tasks.ListTasks(ctx, filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidStatus) {
+			return nil, errors.New(ErrMsgInvalidStatus)
+		}
+		return nil, queryError("list tasks", err)
+	}
+	connection := &taskConnection{tasks: tasks, offset: offset}
+	if len(tasks) > first {
+		connection.tasks, connection.hasNextPage = tasks[:first], true
+	}
+	return connection, nil
+}
+
+// batchWorkflowStatuses looks up the statuses of tasks, loading the
+// workflow of each of their projects once.
+func (r *graphQLResolvers) batchWorkflowStatuses(ctx context.Context, sources []any, args map[string]any) ([]any, error) {
+	workflows := make(map[string]*models.Workflow)
+	statuses := make([]any, len(sources))
+	for i, source := range sources {
+		task := source.(*models.Task)
+		workflow, ok := workflows[task.Project]
+		if !ok {
+			var err error
+			workflow, err = r.workflows.GetWorkflow(ctx, task.Project)
+			if err != nil {
+				return nil, queryError("get workflow", err)
+			}
+			workflows[task.Project] = workflow
+		}
+		if status, ok := workflow.Status(task.Status); ok {
+			statuses[i] = status
+		}
+	}
+	return statuses, nil
+}
+
+// batchComments loads the comments of tasks with one query.
+func (r *graphQLResolvers) batchComments(ctx context.Context, sources []any, args map[string]any) ([]any, error) {
+	offset, first, err := pageArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	taskIDs := make([]uuid.UUID, len(sources))
+	for i, source := range sources {
+		taskIDs[i] = source.(*models.Task).ID
+	}
+	comments, err := r.comments.ListCommentsForTasks(ctx, taskIDs)
+	if err != nil {
+		return nil, queryError("list comments", err)
+	}
+	connections := make([]any, len(sources))
+	for i, taskID := range taskIDs {
+		all := comments[taskID]
+		start := min(offset, len(all))
+		end := min(start+first, len(all))
+		connections[i] = &commentConnection{comments: all[start:end], offset: offset, hasNextPage: end < len(all)}
+	}
+	return connections, nil
+}
+
+func (r *graphQLResolvers) createTask(ctx context.Context, source any, args map[string]any) (any, error) {
+	input := args["input"].(map[string]any)
+	createInput := models.CreateTaskInput{Title: input["title"].(string)}
+	createInput.ExternalID, _ = input["externalId"].(string)
+	createInput.Project, _ = input["project"].(string)
+	createInput.Description, _ = input["description"].(string)
+	createInput.Assignee, _ = input["assignee"].(string)
+	if dueAt, ok := input["dueAt"].(time.Time); ok {
+		createInput.DueAt = &dueAt
+	}
+	task, err := r.tasks.CreateTask(ctx, createInput)
+	if err != nil {
+		return nil, mutationError(err)
+	}
+	return task, nil
+}
+
+func (r *graphQLResolvers) updateTask(ctx context.Context, source any, args map[string]any) (any, error) {
+	taskID, err := uuid.Parse(args["id"].(string))
+	if err != nil {
+		return nil, errors.New(ErrMsgInvalidID)
+	}
+	input := args["input"].(map[string]any)
+	var updateInput models.UpdateTaskInput
+	if title, ok := input["title"].(string); ok {
+		updateInput.Title = &title
+	}
+	if description, ok := input["description"].(string); ok {
+		updateInput.Description = &description
+	}
+	if status, ok := input["status"].(string); ok {
+		taskStatus := models.TaskStatus(status)
+		updateInput.Status = &taskStatus
+	}
+	if assignee, ok := input["assignee"].(string); ok {
+		updateInput.Assignee = &assignee
+	}
+	if dueAt, ok := input["dueAt"].(time.Time); ok {
+		updateInput.DueAt = &dueAt
+	}
+	task, err := r.tasks.UpdateTask(ctx, taskID, updateInput)
+	if err != nil {
+		return nil, mutationError(err)
+	}
+	return task, nil
+}
+
+func (r *graphQLResolvers) deleteTask(ctx context.Context, source any, args map[string]any) (any, error) {
+	taskID, err := uuid.Parse(args["id"].(string))
+	if err != nil {
+		return nil, errors.New(ErrMsgInvalidID)
+	}
+	if err := r.tasks.DeleteTask(ctx, taskID); err != nil {
+		if errors.Is(err, repository.ErrTaskNotFound) {
+			return nil, errors.New(ErrMsgNotFound)
+		}
+		return nil, queryError("delete task", err)
+	}
+	return true, nil
+}
+
+func (r *graphQLResolvers) addComment(ctx context.Context, source any, args map[string]any) (any, error) {
+	taskID, err := uuid.Parse(args["taskId"].(string))
+	if err != nil {
+		return nil, errors.New(ErrMsgInvalidID)
+	}
+	input := args["input"].(map[string]any)
+	commentInput := models.CreateCommentInput{Body: input["body"].(string)}
+	commentInput.Author, _ = input["author"].(string)
+	comment, err := r.comments.CreateComment(ctx, taskID, commentInput)
+	if err != nil {
+		return nil, mutationError(err)
+	}
+	return comment, nil
+}
+
+// queryError logs err and hides it from clients, like the REST handlers
+// do with internal errors.
+func queryError(operation string, err error) error {
+	log.Printf("graphql %s: %v", operation, err)
+	return errors.New(ErrMsgGraphQLInternal)
+}
+
+// mutationError returns the message the REST handlers respond to a failed
+// change with.
+func mutationError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrTaskNotFound):
+		return errors.New(ErrMsgNotFound)
+	case errors.Is(err, service.ErrInvalidStatus):
+		return errors.New(ErrMsgInvalidStatus)
+	case errors.Is(err, service.ErrInvalidTransition):
+		return errors.New(ErrMsgInvalidTransition)
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgInvalidJobStatus = "Invalid status! Status can be only: `pending`, `running`, `done` or `failed`"
+	ErrMsgFailedToListJobs = "Failed to list jobs due to an internal server error"
+)
+
+type JobHandler struct {
+	service service.JobService
+}
+
+func NewJobHandler(service service.JobService) *JobHandler {
+	return &JobHandler{service: service}
+}
+
+func (h *JobHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /jobs", h.handleListJobs)
+}
+
+func (h *JobHandler) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	filter := repository.JobFilter{
+		Kind:   queryParams.Get("kind"),
+		Limit:  DefaultLimit,
+		Offset: DefaultOffset,
+	}
+	if statusStr := queryParams.Get("status"); statusStr != "" {
+		status := models.JobStatus(statusStr)
+		switch status {
+		case models.JobStatusPending, models.JobStatusRunning, models.JobStatusDone, models.JobStatusFailed:
+			filter.Status = &status
+		default:
+			http.Error(w, ErrMsgInvalidJobStatus, http.StatusBadRequest)
+			return
+		}
+	}
+	if limitStr := queryParams.Get("limit"); limitStr != "" {
+		if limitValue, err := strconv.Atoi(limitStr); err == nil && limitValue > 0 {
+			filter.Limit = limitValue
+		} else {
+			http.Error(w, ErrMsgInvalidLimit, http.StatusBadRequest)
+			return
+		}
+	}
+	if offsetStr := queryParams.Get("offset"); offsetStr != "" {
+		if offsetValue, err := strconv.Atoi(offsetStr); err == nil && offsetValue >= 0 {
+			filter.Offset = offsetValue
+		} else {
+			http.Error(w, ErrMsgInvalidOffset, http.StatusBadRequest)
+			return
+		}
+	}
+
+	jobs, err := h.service.ListJobs(r.Context(), filter)
+	if err != nil {
+		http.Error(w, ErrMsgFailedToListJobs, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(jobs)
+}
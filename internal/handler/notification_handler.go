@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"task-manager/internal/models"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgFailedToGetPreferences    = "Failed to get notification preferences due to an internal server error"
+	ErrMsgFailedToUpdatePreferences = "Failed to update notification preferences due to an internal server error"
+)
+
+type NotificationHandler struct {
+	service service.NotificationService
+}
+
+func NewNotificationHandler(service service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{service: service}
+}
+
+func (h *NotificationHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /users/{user}/notification-preferences", h.handleGetPreferences)
+	mux.HandleFunc("PUT /users/{user}/notification-preferences", h.handleUpdatePreferences)
+}
+
+func (h *NotificationHandler) handleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	preferences, err := h.service.GetPreferences(r.Context(), r.PathValue("user"))
+	if err != nil {
+		http.Error(w, ErrMsgFailedToGetPreferences, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(preferences)
+}
+
+func (h *NotificationHandler) handleUpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(r.Body)
+
+	var updateInput models.UpdateNotificationPreferencesInput
+	if err := json.NewDecoder(r.Body).Decode(&updateInput); err != nil {
+		http.Error(w, ErrMsgInvalidJSON, http.StatusBadRequest)
+		return
+	}
+	preferences, err := h.service.UpdatePreferences(r.Context(), r.PathValue("user"), updateInput)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPreferences) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, ErrMsgFailedToUpdatePreferences, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(preferences)
+}
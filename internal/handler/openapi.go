@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"task-manager/internal/export"
+	"task-manager/internal/models"
+	"task-manager/internal/openapi"
+	"task-manager/internal/service"
+)
+
+// apiVersion is the version of the REST API in its OpenAPI document.
+const apiVersion = "1.0.0"
+
+// openAPIJSON is the encoded document, built on first use.
+var openAPIJSON = sync.OnceValue(func() []byte {
+	data, err := json.MarshalIndent(OpenAPIDocument(), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return data
+})
+
+func (h *TaskHandler) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPIJSON())
+}
+
+// OpenAPIDocument describes the routes of TaskHandler. Schemas of bodies
+// are derived from the models types; errors are the plain text messages
+// of http.Error.
+func OpenAPIDocument() *openapi.Document {
+	components := openapi.NewComponents()
+	task := components.Schema(reflect.TypeFor[models.Task]())
+	taskList := components.Schema(reflect.TypeFor[[]*models.Task]())
+	createInput := components.Schema(reflect.TypeFor[models.CreateTaskInput]())
+	updateInput := components.Schema(reflect.TypeFor[models.UpdateTaskInput]())
+	// The decoder leaves out missing fields, so only the service requires
+	// any.
+	components.Schemas["CreateTaskInput"].Required = []string{"title"}
+	components.Schemas["UpdateTaskInput"].Required = nil
+
+	minTitle := 3
+	components.Schemas["CreateTaskInput"].Properties["title"].MinLength = &minTitle
+	components.Schemas["UpdateTaskInput"].Properties["title"].MinLength = &minTitle
+
+	idParam := &openapi.Parameter{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string", Format: "uuid"}}
+	filterParams := []*openapi.Parameter{
+		queryParam("project", "Only tasks of the project.", stringSchema()),
+		queryParam("status", "Only tasks with the status, a status key of the project's workflow.", stringSchema()),
+		queryParam("assignee", "Only tasks assigned to the person.", stringSchema()),
+		queryParam("limit", "How many tasks to return.", &openapi.Schema{Type: "integer", Minimum: float(1)}),
+		queryParam("offset", "How many tasks to skip.", &openapi.Schema{Type: "integer", Minimum: float(0), Default: DefaultOffset}),
+	}
+	listParams := append([]*openapi.Parameter{}, filterParams...)
+	listParams[3] = queryParam("limit", "How many tasks to return.", &openapi.Schema{Type: "integer", Minimum: float(1), Default: DefaultLimit})
+
+	exportParams := append(append([]*openapi.Parameter{}, filterParams...),
+		queryParam("format", "The format of the export; without it, the Accept header chooses.",
+			&openapi.Schema{Type: "string", Enum: []any{string(export.FormatCSV), string(export.FormatNDJSON), string(export.FormatJSON)}}),
+		queryParam("columns", "Comma separated columns of the export, in order, out of "+strings.Join(export.ColumnNames(), ", ")+".", stringSchema()),
+	)
+
+	paths := map[string]*openapi.PathItem{}
+	add := func(method, path string, op *openapi.Operation) {
+		if paths[path] == nil {
+			paths[path] = &openapi.PathItem{}
+		}
+		op.Tags = []string{"tasks"}
+		paths[path].SetOperation(method, op)
+	}
+
+	add("GET", "/health", &openapi.Operation{
+		OperationID: "health",
+		Summary:     "Check that the server can reach its database",
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The server is healthy.", Content: openapi.JSON(&openapi.Schema{
+				Type:       "object",
+				Properties: map[string]*openapi.Schema{"status": {Type: "string", Enum: []any{"ok"}}},
+				Required:   []string{"status"},
+			})},
+			"503": errorResponse(ErrMsgUnhealthy),
+		},
+	})
+	add("POST", "/tasks", &openapi.Operation{
+		OperationID: "createTask",
+		Summary:     "Create a task",
+		Description: "Tasks start in the initial status of their project's workflow. Projects default to \"" + models.DefaultProject + "\".",
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(createInput)},
+		Responses: map[string]*openapi.Response{
+			"201": {Description: "The created task.", Content: openapi.JSON(task)},
+			"400": withErrorCodes(errorResponse(ErrMsgInvalidJSON, ErrMsgTitleTooShort), ErrCodeTitleTooShort),
+			"409": withErrorCodes(errorResponse(service.ErrExternalIDTaken.Error()), ErrCodeExternalIDTaken),
+			"500": errorResponse(ErrMsgFailedToCreate),
+		},
+	})
+	add("GET", "/tasks", &openapi.Operation{
+		OperationID: "listTasks",
+		Summary:     "List tasks, newest first",
+		Parameters:  listParams,
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The tasks; null if there are none.", Content: openapi.JSON(taskList)},
+			"400": withErrorCodes(errorResponse(ErrMsgInvalidLimit, ErrMsgInvalidOffset, ErrMsgInvalidStatus), ErrCodeInvalidStatus),
+			"500": errorResponse(ErrMsgFailedToList),
+		},
+	})
+	add("GET", "/tasks/export", &openapi.Operation{
+		OperationID: "exportTasks",
+		Summary:     "Export tasks as CSV, NDJSON or JSON",
+		Description: "Streams all tasks matching the filters; unlike listing there is no default limit.",
+		Parameters:  exportParams,
+		Responses: map[string]*openapi.Response{
+			"200": {
+				Description: "The export, as an attachment.",
+				Content: map[string]*openapi.MediaType{
+					export.FormatCSV.ContentType():    {Schema: stringSchema()},
+					export.FormatNDJSON.ContentType(): {Schema: stringSchema()},
+					export.FormatJSON.ContentType():   {Schema: &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "object"}}},
+				},
+			},
+			"400": withErrorCodes(errorResponse(ErrMsgInvalidLimit, ErrMsgInvalidOffset, ErrMsgInvalidFormat, ErrMsgInvalidColumns+" "+strings.Join(export.ColumnNames(), ", "), ErrMsgInvalidStatus), ErrCodeInvalidStatus),
+			"406": errorResponse(ErrMsgNotAcceptable),
+			"500": errorResponse(ErrMsgFailedToExport),
+		},
+	})
+	add("GET", "/tasks/{id}", &openapi.Operation{
+		OperationID: "getTask",
+		Summary:     "Get a task",
+		Parameters:  []*openapi.Parameter{idParam},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The task.", Content: openapi.JSON(task)},
+			"400": errorResponse(ErrMsgInvalidID),
+			"404": errorResponse(ErrMsgNotFound),
+			"500": errorResponse(ErrMsgFailedToGet),
+		},
+	})
+	add("PUT", "/tasks/{id}", &openapi.Operation{
+		OperationID: "updateTask",
+		Summary:     "Update a task",
+		Description: "Changes the fields that are in the body; the others stay as they are.",
+		Parameters:  []*openapi.Parameter{idParam},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(updateInput)},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The updated task.", Content: openapi.JSON(task)},
+			"400": withErrorCodes(errorResponse(ErrMsgInvalidID, ErrMsgInvalidJSON, ErrMsgTitleTooShort, ErrMsgInvalidStatus), ErrCodeTitleTooShort, ErrCodeInvalidStatus),
+			"404": errorResponse(ErrMsgNotFound),
+			"409": withErrorCodes(errorResponse(ErrMsgInvalidTransition), ErrCodeInvalidTransition),
+			"500": errorResponse(ErrMsgFailedToUpdate),
+		},
+	})
+	add("DELETE", "/tasks/{id}", &openapi.Operation{
+		OperationID: "deleteTask",
+		Summary:     "Delete a task",
+		Parameters:  []*openapi.Parameter{idParam},
+		Responses: map[string]*openapi.Response{
+			"204": {Description: "The task was deleted."},
+			"400": errorResponse(ErrMsgInvalidID),
+			"404": errorResponse(ErrMsgNotFound),
+			"500": errorResponse(ErrMsgFailedToDelete),
+		},
+	})
+	add("GET", "/openapi.json", &openapi.Operation{
+		OperationID: "openAPI",
+		Summary:     "This document",
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The OpenAPI document of the task routes.", Content: openapi.JSON(&openapi.Schema{Type: "object"})},
+		},
+	})
+
+	return &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Task Manager",
+			Description: "Tasks with per-project workflows.",
+			Version:     apiVersion,
+		},
+		Paths:      paths,
+		Components: components,
+	}
+}
+
+func queryParam(name, description string, schema *openapi.Schema) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func stringSchema() *openapi.Schema {
+	return &openapi.Schema{Type: "string"}
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+// errorResponse describes an error response of http.Error with one of
+// messages.
+func errorResponse(messages ...string) *openapi.Response {
+	return &openapi.Response{
+		Description: strings.Join(messages, "; "),
+		Content:     map[string]*openapi.MediaType{"text/plain": {Schema: stringSchema()}},
+	}
+}
+
+// withErrorCodes adds the ErrorCodeHeader with one of codes to an error
+// response.
+func withErrorCodes(response *openapi.Response, codes ...string) *openapi.Response {
+	enum := make([]any, len(codes))
+	for i, code := range codes {
+		enum[i] = code
+	}
+	response.Headers = map[string]*openapi.Header{ErrorCodeHeader: {
+		Description: "Which of the errors it is, for errors that clients tell apart.",
+		Schema:      &openapi.Schema{Type: "string", Enum: enum},
+	}}
+	return response
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// registeredRoutes returns the patterns that TaskHandler.RegisterRoutes
+// registers, read from its source so that new routes can't be missed.
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "task_handler.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var routes []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "RegisterRoutes" || fn.Recv == nil {
+			continue
+		}
+		ast.Inspect(fn.Body, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			if selector, ok := call.Fun.(*ast.SelectorExpr); !ok || (selector.Sel.Name != "HandleFunc" && selector.Sel.Name != "Handle") {
+				return true
+			}
+			literal, ok := call.Args[0].(*ast.BasicLit)
+			if !ok {
+				t.Fatalf("route pattern %v isn't a literal", call.Args[0])
+			}
+			pattern, err := strconv.Unquote(literal.Value)
+			if err != nil {
+				t.Fatalf("unquote %s: %v", literal.Value, err)
+			}
+			routes = append(routes, pattern)
+			return true
+		})
+	}
+	if len(routes) == 0 {
+		t.Fatal("found no routes in TaskHandler.RegisterRoutes")
+	}
+	slices.Sort(routes)
+	return routes
+}
+
+var pathParameter = regexp.MustCompile(`\{(\w+)\}`)
+
+func TestOpenAPIDocumentMatchesRoutes(t *testing.T) {
+	document := OpenAPIDocument()
+	var documented []string
+	for path, item := range document.Paths {
+		for _, method := range []string{"GET", "PUT", "POST", "DELETE", "PATCH"} {
+			operation := item.Operation(method)
+			if operation == nil {
+				continue
+			}
+			documented = append(documented, method+" "+path)
+
+			var inPath []string
+			for _, parameter := range operation.Parameters {
+				if parameter.In == "path" {
+					inPath = append(inPath, parameter.Name)
+				}
+			}
+			for _, match := range pathParameter.FindAllStringSubmatch(path, -1) {
+				if !slices.Contains(inPath, match[1]) {
+					t.Errorf("%s %s: path parameter %s isn't documented", method, path, match[1])
+				}
+			}
+			if len(operation.Responses) == 0 {
+				t.Errorf("%s %s: no responses", method, path)
+			}
+		}
+	}
+	slices.Sort(documented)
+
+	routes := registeredRoutes(t)
+	for _, route := range routes {
+		if !slices.Contains(documented, route) {
+			t.Errorf("route %q is missing from the OpenAPI document", route)
+		}
+	}
+	for _, operation := range documented {
+		if !slices.Contains(routes, operation) {
+			t.Errorf("documented operation %q isn't a route", operation)
+		}
+	}
+}
+
+func TestOpenAPIDocumentReferences(t *testing.T) {
+	document := OpenAPIDocument()
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, match := range regexp.MustCompile(`"\$ref":"#/components/schemas/(\w+)"`).FindAllStringSubmatch(string(data), -1) {
+		if _, ok := document.Components.Schemas[match[1]]; !ok {
+			t.Errorf("reference to missing schema %s", match[1])
+		}
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	server, _ := newTaskServer(t)
+	response, err := http.Get(server.URL + "/openapi.json")
+	if err != nil {
+		t.Fatalf("GET /openapi.json: %v", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(response.Body)
+	if response.StatusCode != http.StatusOK || !strings.HasPrefix(response.Header.Get("Content-Type"), "application/json") {
+		t.Fatalf("status %d, content type %q", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+	var document struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if document.OpenAPI != "3.1.0" {
+		t.Errorf("openapi %q", document.OpenAPI)
+	}
+	if _, ok := document.Paths["/tasks/{id}"]["put"]; !ok {
+		t.Errorf("PUT /tasks/{id} is missing: %v", document.Paths)
+	}
+}
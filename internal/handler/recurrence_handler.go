@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgFailedToCreateRecurrence = "Failed to create recurrence due to an internal server error"
+	ErrMsgFailedToListRecurrences  = "Failed to list recurrences due to an internal server error"
+	ErrMsgFailedToGetRecurrence    = "Failed to get recurrence due to an internal server error"
+	ErrMsgFailedToStopRecurrence   = "Failed to stop recurrence due to an internal server error"
+)
+
+type RecurrenceHandler struct {
+	service service.RecurrenceService
+}
+
+func NewRecurrenceHandler(service service.RecurrenceService) *RecurrenceHandler {
+	return &RecurrenceHandler{service: service}
+}
+
+func (h *RecurrenceHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /recurrences", h.handleCreateRecurrence)
+	mux.HandleFunc("GET /recurrences", h.handleListRecurrences)
+	mux.HandleFunc("GET /recurrences/{id}", h.handleGetRecurrence)
+	mux.HandleFunc("DELETE /recurrences/{id}", h.handleStopRecurrence)
+}
+
+func (h *RecurrenceHandler) handleCreateRecurrence(w http.ResponseWriter, r *http.Request) {
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(r.Body)
+
+	var createInput models.CreateRecurrenceInput
+	if err := json.NewDecoder(r.Body).Decode(&createInput); err != nil {
+		http.Error(w, ErrMsgInvalidJSON, http.StatusBadRequest)
+		return
+	}
+	recurrence, err := h.service.CreateRecurrence(r.Context(), createInput)
+	if err != nil {
+		if errors.Is(err, service.ErrTitleTooShort) || errors.Is(err, service.ErrInvalidRecurrence) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			log.Printf("create recurrence: %v", err)
+			http.Error(w, ErrMsgFailedToCreateRecurrence, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(recurrence)
+}
+
+func (h *RecurrenceHandler) handleListRecurrences(w http.ResponseWriter, r *http.Request) {
+	recurrences, err := h.service.ListRecurrences(r.Context())
+	if err != nil {
+		http.Error(w, ErrMsgFailedToListRecurrences, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(recurrences)
+}
+
+func (h *RecurrenceHandler) handleGetRecurrence(w http.ResponseWriter, r *http.Request) {
+	recurrenceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+	recurrence, err := h.service.GetRecurrence(r.Context(), recurrenceID)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecurrenceNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			http.Error(w, ErrMsgFailedToGetRecurrence, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(recurrence)
+}
+
+func (h *RecurrenceHandler) handleStopRecurrence(w http.ResponseWriter, r *http.Request) {
+	recurrenceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+	if err := h.service.StopRecurrence(r.Context(), recurrenceID); err != nil {
+		if errors.Is(err, repository.ErrRecurrenceNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			http.Error(w, ErrMsgFailedToStopRecurrence, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"task-manager/internal/export"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgInvalidFormat  = "Invalid format! Format must be one of csv, ndjson or json"
+	ErrMsgNotAcceptable  = "Not acceptable! Exports are available as text/csv, application/x-ndjson and application/json"
+	ErrMsgInvalidColumns = "Invalid columns! Columns must be a comma separated list of"
+	ErrMsgFailedToExport = "Failed to export tasks due to an internal server error"
+
+	// exportWriteTimeout bounds every single write of an export. It replaces
+	// the server's WriteTimeout, which would end large exports.
+	exportWriteTimeout = 10 * time.Second
+)
+
+// handleExportTasks streams the tasks matching the filters of GET /tasks as
+// CSV, NDJSON or a JSON array, chosen by the format query parameter or the
+// Accept header. Unlike GET /tasks there is no default limit. The columns
+// query parameter selects and orders the fields.
+func (h *TaskHandler) handleExportTasks(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseTaskFilter(w, r, 0)
+	if !ok {
+		return
+	}
+	queryParams := r.URL.Query()
+	var format export.Format
+	if formatStr := queryParams.Get("format"); formatStr != "" {
+		var err error
+		if format, err = export.ParseFormat(formatStr); err != nil {
+			http.Error(w, ErrMsgInvalidFormat, http.StatusBadRequest)
+			return
+		}
+	} else if format, ok = export.Negotiate(r.Header.Get("Accept")); !ok {
+		http.Error(w, ErrMsgNotAcceptable, http.StatusNotAcceptable)
+		return
+	}
+	columns, err := export.ParseColumns(queryParams.Get("columns"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidColumns+" "+strings.Join(export.ColumnNames(), ", "), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="tasks.`+string(format)+`"`)
+	w.Header().Add("Vary", "Accept")
+	out := &exportWriter{w: w, rc: http.NewResponseController(w)}
+	writer := export.NewWriter(out, format, columns)
+	err = h.service.EachTask(r.Context(), filter, writer.Write)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	if !out.wrote {
+		w.Header().Del("Content-Disposition")
+		if errors.Is(err, service.ErrInvalidStatus) {
+			writeTaskError(w, err, ErrMsgInvalidStatus, http.StatusBadRequest)
+		} else {
+			log.Printf("export tasks: %v", err)
+			http.Error(w, ErrMsgFailedToExport, http.StatusInternalServerError)
+		}
+		return
+	}
+	if r.Context().Err() == nil {
+		log.Printf("export tasks: %v", err)
+	}
+	// The status was sent with the first rows. Aborting the response keeps
+	// clients from taking a truncated export for a complete one.
+	panic(http.ErrAbortHandler)
+}
+
+// exportWriter writes an export to the response, with a deadline for every
+// write, and records whether anything was written.
+type exportWriter struct {
+	w     http.ResponseWriter
+	rc    *http.ResponseController
+	wrote bool
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	e.wrote = true
+	// Not every ResponseWriter supports deadlines; those just don't get one.
+	_ = e.rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	return e.w.Write(p)
+}
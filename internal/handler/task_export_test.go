@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"task-manager/internal/events"
+	"task-manager/internal/migrations"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+// newTestDB returns a migrated in-memory SQLite database.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func newTaskServer(t *testing.T) (*httptest.Server, service.TaskService) {
+	t.Helper()
+	db := newTestDB(t)
+	taskRepository := repository.NewSQLiteTaskRepository(db)
+	workflowRepository := repository.NewSQLiteWorkflowRepository(db)
+	transactor := repository.NewSQLTransactor(db)
+	tasks := service.NewTaskService(taskRepository, workflowRepository, events.NewBus(), transactor)
+	imports := service.NewImportService(taskRepository, repository.NewSQLiteCommentRepository(db), workflowRepository, events.NewBus(), transactor)
+
+	mux := http.NewServeMux()
+	NewTaskHandler(tasks).RegisterRoutes(mux)
+	NewImportHandler(imports).RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, tasks
+}
+
+func getExport(t *testing.T, url, accept string) (*http.Response, string) {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(response.Body)
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return response, string(body)
+}
+
+func TestExportTasks(t *testing.T) {
+	server, tasks := newTaskServer(t)
+	// More tasks than GET /tasks returns by default.
+	for i := 0; i < DefaultLimit+10; i++ {
+		input := models.CreateTaskInput{Title: fmt.Sprintf("Task %d", i)}
+		if i%2 == 0 {
+			input.Assignee = "ann"
+		}
+		if _, err := tasks.CreateTask(context.Background(), input); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	response, body := getExport(t, server.URL+"/tasks/export?assignee=ann&columns=title,assignee", "text/csv")
+	if response.StatusCode != http.StatusOK || !strings.HasPrefix(response.Header.Get("Content-Type"), "text/csv") {
+		t.Fatalf("status %d, Content-Type %q", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	if len(records) != 1+(DefaultLimit+10)/2 || strings.Join(records[0], ",") != "title,assignee" || records[1][1] != "ann" {
+		t.Fatalf("%d records, first %q", len(records), records[:2])
+	}
+
+	// The format parameter wins over Accept.
+	response, body = getExport(t, server.URL+"/tasks/export?format=ndjson&limit=3&columns=id", "text/csv")
+	if response.Header.Get("Content-Type") != "application/x-ndjson" || strings.Count(body, "\n") != 3 {
+		t.Fatalf("Content-Type %q, body %q", response.Header.Get("Content-Type"), body)
+	}
+	response, body = getExport(t, server.URL+"/tasks/export?offset=59", "")
+	if response.Header.Get("Content-Type") != "application/json" || !strings.HasPrefix(body, "[\n{\"id\":") || strings.Count(body, "\"id\"") != 1 {
+		t.Fatalf("Content-Type %q, body %q", response.Header.Get("Content-Type"), body)
+	}
+
+	for _, tc := range []struct {
+		query, accept string
+		status        int
+	}{
+		{"?format=xml", "", http.StatusBadRequest},
+		{"?columns=id,password", "", http.StatusBadRequest},
+		{"?limit=0", "", http.StatusBadRequest},
+		{"?project=default&status=archived", "", http.StatusBadRequest},
+		{"", "text/html", http.StatusNotAcceptable},
+	} {
+		if response, body := getExport(t, server.URL+"/tasks/export"+tc.query, tc.accept); response.StatusCode != tc.status {
+			t.Errorf("%s %q: status %d (%s), want %d", tc.query, tc.accept, response.StatusCode, body, tc.status)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -15,19 +16,35 @@ import (
 )
 
 const (
-	ErrMsgUnhealthy        = "Server is not available"
-	ErrMsgMethodNotAllowed = "Method not allowed. please use appropriate method for this operation"
-	ErrMsgInvalidJSON      = "Invalid JSON! can't parse incoming model please check the input"
-	ErrMsgInvalidStatus    = "Invalid status! Status can be only: `new`, `in_progress` or `done`"
-	ErrMsgInvalidLimit     = "Invalid limit! Limit value must be greater than zero"
-	ErrMsgInvalidOffset    = "Invalid offset! Offset value must be greater than zero"
-	ErrMsgInvalidID        = "Invalid id! Id must be a valid uuid"
-	ErrMsgNotFound         = "Not found!"
-	ErrMsgFailedToList     = "Failed to list tasks due to an internal server error"
-	ErrMsgFailedToGet      = "Failed to get task due to an internal server error"
-	ErrMsgFailedToDelete   = "Failed to delete task due to an internal server error"
-	ErrMsgTitleTooShort    = "Title must be at least 3 characters. Please check the input and try again"
+	ErrMsgUnhealthy         = "Server is not available"
+	ErrMsgMethodNotAllowed  = "Method not allowed. please use appropriate method for this operation"
+	ErrMsgInvalidJSON       = "Invalid JSON! can't parse incoming model please check the input"
+	ErrMsgInvalidStatus     = "Invalid status! Status must be one of the statuses defined in the project's workflow"
+	ErrMsgInvalidTransition = "Invalid status transition! The project's workflow doesn't allow this change"
+	ErrMsgInvalidLimit      = "Invalid limit! Limit value must be greater than zero"
+	ErrMsgInvalidOffset     = "Invalid offset! Offset value must be greater than zero"
+	ErrMsgInvalidID         = "Invalid id! Id must be a valid uuid"
+	ErrMsgNotFound          = "Not found!"
+	ErrMsgFailedToList      = "Failed to list tasks due to an internal server error"
+	ErrMsgFailedToCreate    = "Failed to create task due to an internal server error"
+	ErrMsgFailedToGet       = "Failed to get task due to an internal server error"
+	ErrMsgFailedToUpdate    = "Failed to update task due to an internal server error"
+	ErrMsgFailedToDelete    = "Failed to delete task due to an internal server error"
+	ErrMsgTitleTooShort     = "Title must be at least 3 characters. Please check the input and try again"
 )
+
+// ErrorCodeHeader is the response header with the code of an error that
+// clients tell apart from others of the same status. Codes are stable,
+// unlike the messages, which are meant for people.
+const ErrorCodeHeader = "X-Error-Code"
+
+const (
+	ErrCodeTitleTooShort     = "title_too_short"
+	ErrCodeExternalIDTaken   = "external_id_taken"
+	ErrCodeInvalidStatus     = "invalid_status"
+	ErrCodeInvalidTransition = "invalid_transition"
+)
+
 const (
 	DefaultLimit  = 50
 	DefaultOffset = 0
@@ -45,9 +62,11 @@ func (h *TaskHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /health", h.handleHealth)
 	mux.HandleFunc("POST /tasks", h.handleCreateTask)
 	mux.HandleFunc("GET /tasks", h.handleListTasks)
+	mux.HandleFunc("GET /tasks/export", h.handleExportTasks)
 	mux.HandleFunc("GET /tasks/{id}", h.handleGetTask)
 	mux.HandleFunc("PUT /tasks/{id}", h.handleUpdateTask)
 	mux.HandleFunc("DELETE /tasks/{id}", h.handleDeleteTask)
+	mux.HandleFunc("GET /openapi.json", h.handleOpenAPI)
 }
 
 func (h *TaskHandler) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -80,12 +99,8 @@ func (h *TaskHandler) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 	}
 	task, err := h.service.CreateTask(r.Context(), createInput)
 	if err != nil {
-		errMsg := err.Error()
-		if errMsg == ErrMsgTitleTooShort {
-			http.Error(w, ErrMsgTitleTooShort, http.StatusBadRequest)
-		} else {
-			http.Error(w, errMsg, http.StatusBadRequest)
-		}
+		status, message := taskWriteError("create task", err, ErrMsgFailedToCreate)
+		writeTaskError(w, err, message, status)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -98,26 +113,40 @@ func (h *TaskHandler) handleListTasks(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, ErrMsgMethodNotAllowed, http.StatusMethodNotAllowed)
 		return
 	}
+	filter, ok := parseTaskFilter(w, r, DefaultLimit)
+	if !ok {
+		return
+	}
+	tasks, err := h.service.ListTasks(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidStatus) {
+			writeTaskError(w, err, ErrMsgInvalidStatus, http.StatusBadRequest)
+		} else {
+			http.Error(w, ErrMsgFailedToList, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tasks)
+}
+
+// parseTaskFilter parses the filter query parameters of task lists. It
+// writes the error response and returns false if they are invalid.
+func parseTaskFilter(w http.ResponseWriter, r *http.Request, defaultLimit int) (repository.TaskFilter, bool) {
 	queryParams := r.URL.Query()
 	var taskStatus *models.TaskStatus
 	if statusStr := queryParams.Get("status"); statusStr != "" {
 		parsedStatus := models.TaskStatus(statusStr)
-		switch parsedStatus {
-		case models.TaskStatusNew, models.TaskStatusInProgress, models.TaskStatusDone:
-			taskStatus = &parsedStatus
-		default:
-			http.Error(w, ErrMsgInvalidStatus, http.StatusBadRequest)
-			return
-		}
+		taskStatus = &parsedStatus
 	}
-	limit := DefaultLimit
+	limit := defaultLimit
 	offset := DefaultOffset
 	if limitStr := queryParams.Get("limit"); limitStr != "" {
 		if limitValue, err := strconv.Atoi(limitStr); err == nil && limitValue > 0 {
 			limit = limitValue
 		} else {
 			http.Error(w, ErrMsgInvalidLimit, http.StatusBadRequest)
-			return
+			return repository.TaskFilter{}, false
 		}
 	}
 	if offsetStr := queryParams.Get("offset"); offsetStr != "" {
@@ -125,17 +154,16 @@ func (h *TaskHandler) handleListTasks(w http.ResponseWriter, r *http.Request) {
 			offset = offsetValue
 		} else {
 			http.Error(w, ErrMsgInvalidOffset, http.StatusBadRequest)
-			return
+			return repository.TaskFilter{}, false
 		}
 	}
-
-	tasks, err := h.service.ListTasks(r.Context(), taskStatus, limit, offset)
-	if err != nil {
-		http.Error(w, ErrMsgFailedToList, http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(tasks)
+	return repository.TaskFilter{
+		Project:  queryParams.Get("project"),
+		Status:   taskStatus,
+		Assignee: queryParams.Get("assignee"),
+		Limit:    limit,
+		Offset:   offset,
+	}, true
 }
 
 func (h *TaskHandler) handleGetTask(w http.ResponseWriter, r *http.Request) {
@@ -188,18 +216,8 @@ func (h *TaskHandler) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 	}
 	task, err := h.service.UpdateTask(r.Context(), taskID, updateInput)
 	if err != nil {
-		if errors.Is(err, repository.ErrTaskNotFound) {
-			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
-		} else {
-			errMsg := err.Error()
-			if errMsg == ErrMsgTitleTooShort {
-				http.Error(w, ErrMsgTitleTooShort, http.StatusBadRequest)
-			} else if errMsg == ErrMsgInvalidStatus {
-				http.Error(w, ErrMsgInvalidStatus, http.StatusBadRequest)
-			} else {
-				http.Error(w, errMsg, http.StatusBadRequest)
-			}
-		}
+		status, message := taskWriteError("update task", err, ErrMsgFailedToUpdate)
+		writeTaskError(w, err, message, status)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -228,3 +246,43 @@ func (h *TaskHandler) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// taskWriteError maps an error of creating or updating a task to the status
+// and message of the response. Errors that aren't the client's are logged
+// and answered with internal, the operation's internal error message.
+func taskWriteError(operation string, err error, internal string) (int, string) {
+	switch {
+	case errors.Is(err, repository.ErrTaskNotFound):
+		return http.StatusNotFound, ErrMsgNotFound
+	case errors.Is(err, service.ErrTitleTooShort):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, service.ErrInvalidStatus):
+		return http.StatusBadRequest, ErrMsgInvalidStatus
+	case errors.Is(err, service.ErrInvalidTransition):
+		return http.StatusConflict, ErrMsgInvalidTransition
+	case errors.Is(err, service.ErrExternalIDTaken):
+		return http.StatusConflict, err.Error()
+	}
+	log.Printf("%s: %v", operation, err)
+	return http.StatusInternalServerError, internal
+}
+
+// writeTaskError is http.Error for an error of the task service, with its
+// code in the ErrorCodeHeader if it has one.
+func writeTaskError(w http.ResponseWriter, err error, message string, status int) {
+	var code string
+	switch {
+	case errors.Is(err, service.ErrTitleTooShort):
+		code = ErrCodeTitleTooShort
+	case errors.Is(err, service.ErrExternalIDTaken):
+		code = ErrCodeExternalIDTaken
+	case errors.Is(err, service.ErrInvalidStatus):
+		code = ErrCodeInvalidStatus
+	case errors.Is(err, service.ErrInvalidTransition):
+		code = ErrCodeInvalidTransition
+	}
+	if code != "" {
+		w.Header().Set(ErrorCodeHeader, code)
+	}
+	http.Error(w, message, status)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"task-manager/internal/importer"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgUnsupportedImport = "Unsupported import! Send text/csv or application/x-ndjson, or set format to csv, ndjson, trello, jira or todotxt"
+	ErrMsgInvalidDryRun     = "Invalid dry_run! It must be true or false"
+	ErrMsgInvalidMapping    = "Invalid mapping! It must be a JSON object with project, statuses, projects, assignees and archived"
+	ErrMsgImportTooLarge    = "Import too large! Split it into several imports"
+	ErrMsgFailedToImport    = "Failed to import tasks due to an internal server error"
+
+	// maxImportBytes limits the size of an import body.
+	maxImportBytes = 32 << 20
+)
+
+type ImportHandler struct {
+	service service.ImportService
+}
+
+func NewImportHandler(service service.ImportService) *ImportHandler {
+	return &ImportHandler{service: service}
+}
+
+func (h *ImportHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /tasks/import", h.handleImportTasks)
+}
+
+// handleImportTasks imports the tasks of a CSV or NDJSON body, or of a
+// Trello, Jira or todo.txt export, chosen by the format query parameter or
+// the Content-Type. The mapping query parameter holds the mapping rules for
+// exports as JSON. With dry_run=true the rows are only validated. The
+// response is the import report: 200 if the rows are valid, 422 if any row
+// has errors, in which case nothing was imported.
+func (h *ImportHandler) handleImportTasks(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	format, ok := importer.FormatOfMediaType(r.Header.Get("Content-Type"))
+	if formatStr := queryParams.Get("format"); formatStr != "" {
+		var err error
+		format, err = importer.ParseFormat(formatStr)
+		ok = err == nil
+	}
+	if !ok {
+		http.Error(w, ErrMsgUnsupportedImport, http.StatusUnsupportedMediaType)
+		return
+	}
+	dryRun := false
+	if dryRunStr := queryParams.Get("dry_run"); dryRunStr != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(dryRunStr); err != nil {
+			http.Error(w, ErrMsgInvalidDryRun, http.StatusBadRequest)
+			return
+		}
+	}
+	var mapping importer.Mapping
+	if mappingStr := queryParams.Get("mapping"); mappingStr != "" {
+		var err error
+		if mapping, err = importer.ReadMapping(strings.NewReader(mappingStr)); err != nil {
+			http.Error(w, ErrMsgInvalidMapping, http.StatusBadRequest)
+			return
+		}
+	}
+
+	rows, err := importer.Read(http.MaxBytesReader(w, r.Body, maxImportBytes), format, mapping)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || errors.Is(err, importer.ErrTooManyRows) {
+			http.Error(w, ErrMsgImportTooLarge, http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	report, err := h.service.ImportTasks(r.Context(), rows, dryRun)
+	if err != nil {
+		log.Printf("import tasks: %v", err)
+		http.Error(w, ErrMsgFailedToImport, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if len(report.Errors) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+func postImport(t *testing.T, url, contentType, body string) (*http.Response, models.ImportReport) {
+	t.Helper()
+	response, err := http.Post(url, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	defer func() { _ = response.Body.Close() }()
+	var report models.ImportReport
+	if strings.HasPrefix(response.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(response.Body).Decode(&report); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return response, report
+}
+
+func TestImportTasks(t *testing.T) {
+	server, tasks := newTaskServer(t)
+	const csv = "external_id,title,assignee\nS-1,Pay rent,ann\nS-2,Buy milk,\n"
+
+	response, report := postImport(t, server.URL+"/tasks/import?dry_run=true", "text/csv", csv)
+	if response.StatusCode != http.StatusOK || !report.DryRun || report.Imported || report.Created != 2 {
+		t.Fatalf("dry run: status %d, report %+v", response.StatusCode, report)
+	}
+	response, report = postImport(t, server.URL+"/tasks/import", "text/csv; charset=utf-8", csv)
+	if response.StatusCode != http.StatusOK || !report.Imported || report.Created != 2 {
+		t.Fatalf("import: status %d, report %+v", response.StatusCode, report)
+	}
+
+	// Importing again updates the tasks by their external IDs.
+	const ndjson = `{"external_id": "S-1", "title": "Pay rent", "status": "done"}
+{"external_id": "S-3", "title": "x"}
+`
+	response, report = postImport(t, server.URL+"/tasks/import?format=ndjson", "application/octet-stream", ndjson)
+	if response.StatusCode != http.StatusUnprocessableEntity || report.Imported || len(report.Errors) != 1 || report.Errors[0].Line != 2 {
+		t.Fatalf("import with errors: status %d, report %+v", response.StatusCode, report)
+	}
+	response, report = postImport(t, server.URL+"/tasks/import", "application/x-ndjson", strings.Split(ndjson, "\n")[0])
+	if response.StatusCode != http.StatusOK || report.Updated != 1 {
+		t.Fatalf("update: status %d, report %+v", response.StatusCode, report)
+	}
+	listed, err := tasks.ListTasks(context.Background(), repository.TaskFilter{})
+	if err != nil || len(listed) != 2 {
+		t.Fatalf("list: %d tasks, %v", len(listed), err)
+	}
+	for _, task := range listed {
+		if task.ExternalID == "S-1" && task.Status != models.TaskStatusDone {
+			t.Fatalf("task S-1 was not updated: %+v", task)
+		}
+	}
+
+	for _, tc := range []struct {
+		query, contentType, body string
+		status                   int
+	}{
+		{"", "application/json", csv, http.StatusUnsupportedMediaType},
+		{"?format=xlsx", "text/csv", csv, http.StatusUnsupportedMediaType},
+		{"?dry_run=maybe", "text/csv", csv, http.StatusBadRequest},
+		{"", "text/csv", "name\nPay rent\n", http.StatusBadRequest},
+	} {
+		if response, _ := postImport(t, server.URL+"/tasks/import"+tc.query, tc.contentType, tc.body); response.StatusCode != tc.status {
+			t.Errorf("%s %s: status %d, want %d", tc.query, tc.contentType, response.StatusCode, tc.status)
+		}
+	}
+
+	// A new task can't take an external ID.
+	response, err = http.Post(server.URL+"/tasks", "application/json", strings.NewReader(`{"external_id": "S-2", "title": "Buy milk"}`))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusConflict {
+		t.Fatalf("create with a taken external ID: status %d, want 409", response.StatusCode)
+	}
+}
+
+func TestImportTrelloTwice(t *testing.T) {
+	server, tasks := newTaskServer(t)
+	const board = `{
+  "lists": [{"id": "l1", "name": "Doing"}],
+  "cards": [{"id": "c1", "name": "Pay rent", "idList": "l1", "labels": [{"name": "Bills"}]}],
+  "actions": [{"type": "commentCard", "date": "2026-02-01T10:00:00Z", "data": {"text": "Rent went up", "card": {"id": "c1"}},
+               "memberCreator": {"username": "sam"}}]
+}`
+	mapping := url.QueryEscape(`{"projects": {"bills": "finance"}}`)
+
+	for i, want := range []models.ImportReport{{Imported: true, Rows: 1, Created: 1, Comments: 1}, {Imported: true, Rows: 1, Updated: 1}} {
+		response, report := postImport(t, server.URL+"/tasks/import?format=trello&mapping="+mapping, "application/json", board)
+		want.Errors = []models.ImportError{}
+		if response.StatusCode != http.StatusOK || !reflect.DeepEqual(report, want) {
+			t.Fatalf("import %d: status %d, report %+v, want %+v", i+1, response.StatusCode, report, want)
+		}
+	}
+	listed, err := tasks.ListTasks(context.Background(), repository.TaskFilter{})
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("list: %d tasks, %v", len(listed), err)
+	}
+	if task := listed[0]; task.ExternalID != "trello:c1" || task.Project != "finance" || task.Status != models.TaskStatusInProgress {
+		t.Fatalf("task %+v", task)
+	}
+
+	if response, _ := postImport(t, server.URL+"/tasks/import?format=trello&mapping=%7B", "application/json", board); response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid mapping: status %d, want 400", response.StatusCode)
+	}
+}
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgFailedToListWebhooks      = "Failed to list webhooks due to an internal server error"
+	ErrMsgFailedToGetWebhook        = "Failed to get webhook due to an internal server error"
+	ErrMsgFailedToCreateWebhook     = "Failed to create webhook due to an internal server error"
+	ErrMsgFailedToUpdateWebhook     = "Failed to update webhook due to an internal server error"
+	ErrMsgFailedToDeleteWebhook     = "Failed to delete webhook due to an internal server error"
+	ErrMsgFailedToListDeliveries    = "Failed to list webhook deliveries due to an internal server error"
+	ErrMsgFailedToGetDelivery       = "Failed to get webhook delivery due to an internal server error"
+	ErrMsgFailedToRedeliverDelivery = "Failed to redeliver webhook delivery due to an internal server error"
+)
+
+type WebhookHandler struct {
+	service service.WebhookService
+}
+
+func NewWebhookHandler(service service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+func (h *WebhookHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /webhooks", h.handleCreateWebhook)
+	mux.HandleFunc("GET /webhooks", h.handleListWebhooks)
+	mux.HandleFunc("GET /webhooks/{id}", h.handleGetWebhook)
+	mux.HandleFunc("PUT /webhooks/{id}", h.handleUpdateWebhook)
+	mux.HandleFunc("DELETE /webhooks/{id}", h.handleDeleteWebhook)
+	mux.HandleFunc("GET /webhooks/{id}/deliveries", h.handleListDeliveries)
+	mux.HandleFunc("GET /webhooks/{id}/deliveries/{delivery}", h.handleGetDelivery)
+	mux.HandleFunc("POST /webhooks/{id}/deliveries/{delivery}/redeliver", h.handleRedeliver)
+}
+
+func (h *WebhookHandler) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(r.Body)
+
+	var createInput models.CreateWebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&createInput); err != nil {
+		http.Error(w, ErrMsgInvalidJSON, http.StatusBadRequest)
+		return
+	}
+	hook, err := h.service.CreateWebhook(r.Context(), createInput)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWebhook) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, ErrMsgFailedToCreateWebhook, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(hook)
+}
+
+func (h *WebhookHandler) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.service.ListWebhooks(r.Context())
+	if err != nil {
+		http.Error(w, ErrMsgFailedToListWebhooks, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(hooks)
+}
+
+func (h *WebhookHandler) handleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+	hook, err := h.service.GetWebhook(r.Context(), webhookID)
+	if err != nil {
+		if errors.Is(err, repository.ErrWebhookNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			http.Error(w, ErrMsgFailedToGetWebhook, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(hook)
+}
+
+func (h *WebhookHandler) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(r.Body)
+
+	var updateInput models.UpdateWebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&updateInput); err != nil {
+		http.Error(w, ErrMsgInvalidJSON, http.StatusBadRequest)
+		return
+	}
+	hook, err := h.service.UpdateWebhook(r.Context(), webhookID, updateInput)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrWebhookNotFound):
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		case errors.Is(err, service.ErrInvalidWebhook):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, ErrMsgFailedToUpdateWebhook, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(hook)
+}
+
+func (h *WebhookHandler) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+	if err := h.service.DeleteWebhook(r.Context(), webhookID); err != nil {
+		if errors.Is(err, repository.ErrWebhookNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			http.Error(w, ErrMsgFailedToDeleteWebhook, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) handleListDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+	queryParams := r.URL.Query()
+	limit := DefaultLimit
+	offset := DefaultOffset
+	if limitStr := queryParams.Get("limit"); limitStr != "" {
+		if limitValue, err := strconv.Atoi(limitStr); err == nil && limitValue > 0 {
+			limit = limitValue
+		} else {
+			http.Error(w, ErrMsgInvalidLimit, http.StatusBadRequest)
+			return
+		}
+	}
+	if offsetStr := queryParams.Get("offset"); offsetStr != "" {
+		if offsetValue, err := strconv.Atoi(offsetStr); err == nil && offsetValue >= 0 {
+			offset = offsetValue
+		} else {
+			http.Error(w, ErrMsgInvalidOffset, http.StatusBadRequest)
+			return
+		}
+	}
+
+	deliveries, err := h.service.ListDeliveries(r.Context(), webhookID, limit, offset)
+	if err != nil {
+		if errors.Is(err, repository.ErrWebhookNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			http.Error(w, ErrMsgFailedToListDeliveries, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(deliveries)
+}
+
+func (h *WebhookHandler) handleGetDelivery(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("delivery"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+	delivery, err := h.service.GetDelivery(r.Context(), webhookID, deliveryID)
+	if err != nil {
+		if errors.Is(err, repository.ErrWebhookDeliveryNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			http.Error(w, ErrMsgFailedToGetDelivery, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(delivery)
+}
+
+func (h *WebhookHandler) handleRedeliver(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("delivery"))
+	if err != nil {
+		http.Error(w, ErrMsgInvalidID, http.StatusBadRequest)
+		return
+	}
+	delivery, err := h.service.Redeliver(r.Context(), webhookID, deliveryID)
+	if err != nil {
+		if errors.Is(err, repository.ErrWebhookDeliveryNotFound) {
+			http.Error(w, ErrMsgNotFound, http.StatusNotFound)
+		} else {
+			http.Error(w, ErrMsgFailedToRedeliverDelivery, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(delivery)
+}
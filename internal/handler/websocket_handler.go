@@ -0,0 +1,478 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgUnknownFrameType     = "Unknown frame type"
+	ErrMsgEmptySubscribe       = "Subscribe needs at least one task_id or project"
+	ErrMsgFailedToReplayEvents = "Failed to replay events due to an internal server error"
+
+	websocketReadLimit    = 64 << 10
+	websocketPongWait     = 60 * time.Second
+	websocketPingInterval = 30 * time.Second
+	websocketWriteWait    = 10 * time.Second
+	websocketSendBuffer   = 64
+)
+
+// Frame types of the WebSocket protocol. Clients send subscribe,
+// unsubscribe, create, update and delete frames; the server answers every
+// client frame with an ack or an error frame carrying the same id, and
+// pushes event frames for subscribed tasks and projects.
+const (
+	FrameSubscribe   = "subscribe"
+	FrameUnsubscribe = "unsubscribe"
+	FrameCreate      = "create"
+	FrameUpdate      = "update"
+	FrameDelete      = "delete"
+	FrameAck         = "ack"
+	FrameError       = "error"
+	FrameEvent       = "event"
+)
+
+// ClientFrame is a message sent by a WebSocket client. Which fields are used
+// depends on Type.
+type ClientFrame struct {
+	ID          string                  `json:"id"`
+	Type        string                  `json:"type"`
+	TaskIDs     []uuid.UUID             `json:"task_ids,omitempty"`
+	Projects    []string                `json:"projects,omitempty"`
+	LastEventID int64                   `json:"last_event_id,omitempty"`
+	TaskID      uuid.UUID               `json:"task_id,omitempty"`
+	Task        *models.CreateTaskInput `json:"task,omitempty"`
+	Changes     *models.UpdateTaskInput `json:"changes,omitempty"`
+}
+
+// ServerFrame is a message sent to a WebSocket client.
+type ServerFrame struct {
+	Type string `json:"type"`
+	// ID echoes the id of the client frame an ack or error answers.
+	ID string `json:"id,omitempty"`
+	// Status is the HTTP status code the equivalent REST call would return.
+	Status        int              `json:"status,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	Task          *models.Task     `json:"task,omitempty"`
+	Subscriptions *websocketTopics `json:"subscriptions,omitempty"`
+	// Reset is set on a subscribe ack when events after last_event_id were
+	// already pruned from the event log.
+	Reset   bool            `json:"reset,omitempty"`
+	EventID int64           `json:"event_id,omitempty"`
+	Event   json.RawMessage `json:"event,omitempty"`
+}
+
+// errWebSocketClosed ends work for a connection that was closed meanwhile.
+var errWebSocketClosed = errors.New("websocket closed")
+
+type websocketTopics struct {
+	TaskIDs  []uuid.UUID `json:"task_ids"`
+	Projects []string    `json:"projects"`
+}
+
+// WebSocketHandler serves GET /ws. The endpoint goes through the same mux
+// and middleware as the REST API, so it is subject to the same
+// authentication once the HTTP layer has any.
+type WebSocketHandler struct {
+	tasks    service.TaskService
+	stream   service.EventStreamService
+	upgrader websocket.Upgrader
+}
+
+func NewWebSocketHandler(tasks service.TaskService, stream service.EventStreamService) *WebSocketHandler {
+	return &WebSocketHandler{
+		tasks:  tasks,
+		stream: stream,
+		// The default upgrader rejects cross-origin requests, which keeps
+		// other sites from driving the API through a visitor's browser.
+		upgrader: websocket.Upgrader{ReadBufferSize: 4096, WriteBufferSize: 4096},
+	}
+}
+
+func (h *WebSocketHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /ws", h.handleWebSocket)
+}
+
+func (h *WebSocketHandler) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	subscription, err := h.stream.Subscribe(service.EventStreamFilter{})
+	if err != nil {
+		if errors.Is(err, service.ErrEventStreamClosed) {
+			http.Error(w, ErrMsgEventStreamClosed, http.StatusServiceUnavailable)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer h.stream.Unsubscribe(subscription)
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response.
+		return
+	}
+	client := &websocketClient{
+		conn:     conn,
+		tasks:    h.tasks,
+		stream:   h.stream,
+		send:     make(chan ServerFrame, websocketSendBuffer),
+		replay:   make(chan ServerFrame),
+		done:     make(chan struct{}),
+		taskIDs:  make(map[uuid.UUID]bool),
+		projects: make(map[string]bool),
+		replayed: make(map[int64]bool),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		client.writeLoop()
+	}()
+	go func() {
+		defer wg.Done()
+		client.pumpEvents(subscription)
+	}()
+	client.readLoop(r.Context())
+	client.close(websocket.CloseNormalClosure, "")
+	wg.Wait()
+	_ = conn.Close()
+}
+
+type websocketClient struct {
+	conn   *websocket.Conn
+	tasks  service.TaskService
+	stream service.EventStreamService
+	send   chan ServerFrame
+	// replay takes replayed events. It is unbuffered, so a replay waits for
+	// the writer instead of filling send, and every replayed event is
+	// written before the frames that are queued after the replay.
+	replay chan ServerFrame
+
+	closeOnce sync.Once
+	done      chan struct{}
+	closeCode int
+	closeText string
+
+	// mu guards the subscriptions and the delivery bookkeeping below. It is
+	// never held during I/O, so that pumpEvents keeps draining the live
+	// subscription.
+	mu       sync.Mutex
+	taskIDs  map[uuid.UUID]bool
+	projects map[string]bool
+	// lastEventID is the last live event seen; replayed holds the IDs of
+	// later events that were already sent by a replay.
+	lastEventID int64
+	replayed    map[int64]bool
+}
+
+// close ends the connection with a close frame; it is safe to call more
+// than once and from any goroutine.
+func (c *websocketClient) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeCode, c.closeText = code, text
+		close(c.done)
+	})
+}
+
+// abort gives up on a connection that can't be written to and unblocks
+// readLoop right away.
+func (c *websocketClient) abort() {
+	c.close(websocket.CloseAbnormalClosure, "")
+	_ = c.conn.SetReadDeadline(time.Now())
+}
+
+// enqueue hands a frame to the writer. A client that doesn't read its
+// frames fast enough is disconnected rather than buffered without bound.
+func (c *websocketClient) enqueue(frame ServerFrame) {
+	select {
+	case c.send <- frame:
+	case <-c.done:
+	default:
+		c.close(websocket.ClosePolicyViolation, "client is too slow")
+	}
+}
+
+// sendReplayed hands a replayed event to the writer, waiting for it as long
+// as the connection is open; the writer's deadline bounds how long a client
+// that doesn't read can hold it up. It returns false once the connection is
+// closed.
+func (c *websocketClient) sendReplayed(frame ServerFrame) bool {
+	select {
+	case c.replay <- frame:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+func (c *websocketClient) writeLoop() {
+	ping := time.NewTicker(websocketPingInterval)
+	defer ping.Stop()
+	write := func(frame ServerFrame) bool {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(websocketWriteWait))
+		if err := c.conn.WriteJSON(frame); err != nil {
+			c.abort()
+			return false
+		}
+		return true
+	}
+	for {
+		select {
+		case frame := <-c.send:
+			if !write(frame) {
+				return
+			}
+		case frame := <-c.replay:
+			if !write(frame) {
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(websocketWriteWait)); err != nil {
+				c.abort()
+				return
+			}
+		case <-c.done:
+			if c.closeCode != websocket.CloseAbnormalClosure {
+				message := websocket.FormatCloseMessage(c.closeCode, c.closeText)
+				_ = c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(websocketWriteWait))
+			}
+			// Unblock readLoop if the peer doesn't answer the close frame.
+			_ = c.conn.SetReadDeadline(time.Now().Add(time.Second))
+			return
+		}
+	}
+}
+
+func (c *websocketClient) readLoop(ctx context.Context) {
+	c.conn.SetReadLimit(websocketReadLimit)
+	_ = c.conn.SetReadDeadline(time.Now().Add(websocketPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(websocketPongWait))
+	})
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var frame ClientFrame
+		if err := json.Unmarshal(message, &frame); err == nil {
+			c.handleFrame(ctx, frame)
+		} else {
+			c.enqueue(ServerFrame{Type: FrameError, Status: http.StatusBadRequest, Error: ErrMsgInvalidJSON})
+		}
+		// Handling a frame can take long, e.g. a replay to a slow client;
+		// the time to the next message counts from its end.
+		_ = c.conn.SetReadDeadline(time.Now().Add(websocketPongWait))
+	}
+}
+
+func (c *websocketClient) handleFrame(ctx context.Context, frame ClientFrame) {
+	reply := func(response ServerFrame) {
+		response.ID = frame.ID
+		c.enqueue(response)
+	}
+	fail := func(status int, message string) {
+		reply(ServerFrame{Type: FrameError, Status: status, Error: message})
+	}
+
+	switch frame.Type {
+	case FrameSubscribe:
+		if len(frame.TaskIDs) == 0 && len(frame.Projects) == 0 {
+			fail(http.StatusBadRequest, ErrMsgEmptySubscribe)
+			return
+		}
+		topics, reset, err := c.subscribe(ctx, frame)
+		if errors.Is(err, errWebSocketClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("websocket: replay events: %v", err)
+			fail(http.StatusInternalServerError, ErrMsgFailedToReplayEvents)
+			return
+		}
+		reply(ServerFrame{Type: FrameAck, Status: http.StatusOK, Subscriptions: topics, Reset: reset})
+	case FrameUnsubscribe:
+		reply(ServerFrame{Type: FrameAck, Status: http.StatusOK, Subscriptions: c.unsubscribe(frame)})
+	case FrameCreate:
+		if frame.Task == nil {
+			fail(http.StatusBadRequest, ErrMsgInvalidJSON)
+			return
+		}
+		task, err := c.tasks.CreateTask(ctx, *frame.Task)
+		if err != nil {
+			fail(taskWriteError("websocket create task", err, ErrMsgFailedToCreate))
+			return
+		}
+		reply(ServerFrame{Type: FrameAck, Status: http.StatusCreated, Task: task})
+	case FrameUpdate:
+		if frame.Changes == nil {
+			fail(http.StatusBadRequest, ErrMsgInvalidJSON)
+			return
+		}
+		task, err := c.tasks.UpdateTask(ctx, frame.TaskID, *frame.Changes)
+		if err != nil {
+			fail(taskWriteError("websocket update task", err, ErrMsgFailedToUpdate))
+			return
+		}
+		reply(ServerFrame{Type: FrameAck, Status: http.StatusOK, Task: task})
+	case FrameDelete:
+		if err := c.tasks.DeleteTask(ctx, frame.TaskID); err != nil {
+			if errors.Is(err, repository.ErrTaskNotFound) {
+				fail(http.StatusNotFound, ErrMsgNotFound)
+			} else {
+				fail(http.StatusInternalServerError, ErrMsgFailedToDelete)
+			}
+			return
+		}
+		reply(ServerFrame{Type: FrameAck, Status: http.StatusNoContent})
+	default:
+		fail(http.StatusBadRequest, ErrMsgUnknownFrameType)
+	}
+}
+
+// subscribe adds the frame's topics. If the frame carries last_event_id,
+// the logged events after it that match the new topics, and none the client
+// was already subscribed to, are replayed first.
+//
+// The new topics only take effect for live events once the replay has
+// caught up with them: until then live events are left to the replay, which
+// keeps events in order without holding mu while it reads the log and
+// waits for the client.
+func (c *websocketClient) subscribe(ctx context.Context, frame ClientFrame) (*websocketTopics, bool, error) {
+	added := topicSet{taskIDs: make(map[uuid.UUID]bool), projects: make(map[string]bool)}
+	for _, taskID := range frame.TaskIDs {
+		added.taskIDs[taskID] = true
+	}
+	for _, project := range frame.Projects {
+		added.projects[project] = true
+	}
+	c.mu.Lock()
+	previous := topicSet{taskIDs: maps.Clone(c.taskIDs), projects: maps.Clone(c.projects)}
+	c.mu.Unlock()
+
+	var reset bool
+	var replayed []int64
+	afterID := frame.LastEventID
+	for pass := 0; ; pass++ {
+		c.mu.Lock()
+		target := c.lastEventID
+		if afterID <= 0 || pass > 0 && afterID >= target {
+			// Live events from here on are newer than the replay.
+			maps.Copy(c.taskIDs, added.taskIDs)
+			maps.Copy(c.projects, added.projects)
+			for _, id := range replayed {
+				if id > c.lastEventID {
+					c.replayed[id] = true
+				}
+			}
+			topics := c.topicsLocked()
+			c.mu.Unlock()
+			return topics, reset, nil
+		}
+		c.mu.Unlock()
+
+		// Replay everything logged after afterID, which includes the live
+		// events seen up to target.
+		for {
+			entries, batchReset, err := c.stream.Replay(ctx, afterID, service.EventStreamFilter{}, eventStreamReplayBatch)
+			if err != nil {
+				return nil, false, err
+			}
+			if afterID == frame.LastEventID {
+				reset = batchReset
+			}
+			for _, entry := range entries {
+				afterID = entry.ID
+				// Events matching earlier subscriptions were or will be
+				// sent live.
+				if !added.matches(entry) || previous.matches(entry) {
+					continue
+				}
+				if !c.sendReplayed(ServerFrame{Type: FrameEvent, EventID: entry.ID, Event: entry.Payload}) {
+					return nil, false, errWebSocketClosed
+				}
+				replayed = append(replayed, entry.ID)
+			}
+			if len(entries) < eventStreamReplayBatch {
+				break
+			}
+		}
+		afterID = max(afterID, target)
+	}
+}
+
+func (c *websocketClient) unsubscribe(frame ClientFrame) *websocketTopics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, taskID := range frame.TaskIDs {
+		delete(c.taskIDs, taskID)
+	}
+	for _, project := range frame.Projects {
+		delete(c.projects, project)
+	}
+	return c.topicsLocked()
+}
+
+func (c *websocketClient) topicsLocked() *websocketTopics {
+	topics := &websocketTopics{TaskIDs: []uuid.UUID{}, Projects: []string{}}
+	for taskID := range c.taskIDs {
+		topics.TaskIDs = append(topics.TaskIDs, taskID)
+	}
+	for project := range c.projects {
+		topics.Projects = append(topics.Projects, project)
+	}
+	return topics
+}
+
+// pumpEvents forwards live events to the client. When the subscription ends,
+// because the client fell behind or the server shuts down, the connection is
+// closed and the client is expected to reconnect with last_event_id.
+func (c *websocketClient) pumpEvents(subscription *service.EventSubscription) {
+	for {
+		select {
+		case entry, ok := <-subscription.C:
+			if !ok {
+				c.close(websocket.CloseGoingAway, "event stream closed")
+				return
+			}
+			c.mu.Lock()
+			c.deliverLocked(entry)
+			c.mu.Unlock()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *websocketClient) deliverLocked(entry *models.EventLogEntry) {
+	c.lastEventID = entry.ID
+	if c.replayed[entry.ID] {
+		delete(c.replayed, entry.ID)
+		return
+	}
+	if (topicSet{taskIDs: c.taskIDs, projects: c.projects}).matches(entry) {
+		c.enqueue(ServerFrame{Type: FrameEvent, EventID: entry.ID, Event: entry.Payload})
+	}
+}
+
+type topicSet struct {
+	taskIDs  map[uuid.UUID]bool
+	projects map[string]bool
+}
+
+func (t topicSet) matches(entry *models.EventLogEntry) bool {
+	return t.taskIDs[entry.TaskID] || t.projects[entry.Project]
+}
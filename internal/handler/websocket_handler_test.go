@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	_ "github.com/mattn/go-sqlite3"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/service"
+)
+
+func newWebSocketServer(t *testing.T) (*httptest.Server, service.EventStreamService) {
+	t.Helper()
+	db := newTestDB(t)
+
+	bus := events.NewBus()
+	stream := service.NewEventStreamService(repository.NewSQLiteEventLogRepository(db), time.Hour)
+	bus.Subscribe(stream.HandleEvent)
+	tasks := service.NewTaskService(repository.NewSQLiteTaskRepository(db), repository.NewSQLiteWorkflowRepository(db), bus, repository.NewSQLTransactor(db))
+
+	mux := http.NewServeMux()
+	NewWebSocketHandler(tasks, stream).RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, stream
+}
+
+func dialWebSocket(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func roundTrip(t *testing.T, conn *websocket.Conn, frame any) ServerFrame {
+	t.Helper()
+	if err := conn.WriteJSON(frame); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return readFrame(t, conn)
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) ServerFrame {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var frame ServerFrame
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return frame
+}
+
+func readEvent(t *testing.T, conn *websocket.Conn) (int64, events.Event) {
+	t.Helper()
+	frame := readFrame(t, conn)
+	if frame.Type != FrameEvent {
+		t.Fatalf("expected an event frame, got %+v", frame)
+	}
+	var event events.Event
+	if err := json.Unmarshal(frame.Event, &event); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	return frame.EventID, event
+}
+
+func TestWebSocketSubscribeMutateAndResume(t *testing.T) {
+	server, stream := newWebSocketServer(t)
+	watcher := dialWebSocket(t, server)
+	editor := dialWebSocket(t, server)
+
+	ack := roundTrip(t, watcher, ClientFrame{ID: "s1", Type: FrameSubscribe, Projects: []string{"home"}})
+	if ack.Type != FrameAck || ack.ID != "s1" || len(ack.Subscriptions.Projects) != 1 {
+		t.Fatalf("unexpected subscribe ack %+v", ack)
+	}
+
+	ack = roundTrip(t, editor, ClientFrame{ID: "c1", Type: FrameCreate, Task: &models.CreateTaskInput{Project: "home", Title: "Pay rent"}})
+	if ack.Type != FrameAck || ack.Status != http.StatusCreated || ack.Task == nil {
+		t.Fatalf("unexpected create ack %+v", ack)
+	}
+	task := ack.Task
+	if id, event := readEvent(t, watcher); id != 1 || event.Type != events.TaskCreated || event.TaskID != task.ID {
+		t.Fatalf("unexpected event %d %+v", id, event)
+	}
+
+	done := models.TaskStatusDone
+	ack = roundTrip(t, editor, ClientFrame{ID: "u1", Type: FrameUpdate, TaskID: task.ID, Changes: &models.UpdateTaskInput{Status: &done}})
+	if ack.Type != FrameAck || ack.Task.Status != models.TaskStatusDone {
+		t.Fatalf("unexpected update ack %+v", ack)
+	}
+	if id, event := readEvent(t, watcher); id != 2 || event.Type != events.TaskUpdated {
+		t.Fatalf("unexpected event %d %+v", id, event)
+	}
+
+	backToNew := models.TaskStatusNew
+	errFrame := roundTrip(t, editor, ClientFrame{ID: "u2", Type: FrameUpdate, TaskID: task.ID, Changes: &models.UpdateTaskInput{Status: &backToNew}})
+	if errFrame.Type != FrameError || errFrame.ID != "u2" || errFrame.Status != http.StatusConflict {
+		t.Fatalf("expected a conflict error frame, got %+v", errFrame)
+	}
+	if err := editor.WriteMessage(websocket.TextMessage, []byte("{not json")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if errFrame = readFrame(t, editor); errFrame.Type != FrameError || errFrame.Status != http.StatusBadRequest {
+		t.Fatalf("expected a bad request error frame, got %+v", errFrame)
+	}
+	if errFrame = roundTrip(t, editor, ClientFrame{ID: "x", Type: "explode"}); errFrame.Type != FrameError || errFrame.ID != "x" {
+		t.Fatalf("expected an error frame for an unknown type, got %+v", errFrame)
+	}
+
+	// A reconnecting client catches up on what it missed before the ack.
+	_ = watcher.Close()
+	inProgress := models.TaskStatusInProgress
+	roundTrip(t, editor, ClientFrame{ID: "u3", Type: FrameUpdate, TaskID: task.ID, Changes: &models.UpdateTaskInput{Status: &inProgress}})
+	resumed := dialWebSocket(t, server)
+	if err := resumed.WriteJSON(ClientFrame{ID: "s2", Type: FrameSubscribe, TaskIDs: []uuid.UUID{task.ID}, LastEventID: 1}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for _, want := range []int64{2, 3} {
+		if id, _ := readEvent(t, resumed); id != want {
+			t.Fatalf("replayed event %d, want %d", id, want)
+		}
+	}
+	if ack = readFrame(t, resumed); ack.Type != FrameAck || ack.ID != "s2" || ack.Reset {
+		t.Fatalf("unexpected resume ack %+v", ack)
+	}
+
+	// Shutting the event stream down closes open sockets.
+	stream.Close()
+	_ = resumed.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := resumed.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Fatalf("expected a going away close frame, got %v", err)
+	}
+}
+
+// A client that missed more events than its send buffer holds still gets
+// all of them before the ack.
+func TestWebSocketResumeAfterManyEvents(t *testing.T) {
+	server, _ := newWebSocketServer(t)
+	editor := dialWebSocket(t, server)
+	const missed = 2 * websocketSendBuffer
+	for i := range missed + 1 {
+		ack := roundTrip(t, editor, ClientFrame{ID: "c", Type: FrameCreate, Task: &models.CreateTaskInput{Project: "home", Title: "Task " + strconv.Itoa(i)}})
+		if ack.Type != FrameAck {
+			t.Fatalf("unexpected create ack %+v", ack)
+		}
+	}
+
+	resumed := dialWebSocket(t, server)
+	if err := resumed.WriteJSON(ClientFrame{ID: "s", Type: FrameSubscribe, Projects: []string{"home"}, LastEventID: 1}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for want := int64(2); want <= missed+1; want++ {
+		if id, _ := readEvent(t, resumed); id != want {
+			t.Fatalf("replayed event %d, want %d", id, want)
+		}
+	}
+	if ack := readFrame(t, resumed); ack.Type != FrameAck || ack.ID != "s" {
+		t.Fatalf("unexpected resume ack %+v", ack)
+	}
+
+	// Live events follow the replay.
+	roundTrip(t, editor, ClientFrame{ID: "c", Type: FrameCreate, Task: &models.CreateTaskInput{Project: "home", Title: "One more"}})
+	if id, _ := readEvent(t, resumed); id != missed+2 {
+		t.Fatalf("live event %d, want %d", id, missed+2)
+	}
+}
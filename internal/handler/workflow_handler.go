@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"task-manager/internal/models"
+	"task-manager/internal/service"
+)
+
+const (
+	ErrMsgFailedToGetWorkflow    = "Failed to get workflow due to an internal server error"
+	ErrMsgFailedToUpdateWorkflow = "Failed to update workflow due to an internal server error"
+)
+
+type WorkflowHandler struct {
+	service service.WorkflowService
+}
+
+func NewWorkflowHandler(service service.WorkflowService) *WorkflowHandler {
+	return &WorkflowHandler{service: service}
+}
+
+func (h *WorkflowHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /projects/{project}/workflow", h.handleGetWorkflow)
+	mux.HandleFunc("PUT /projects/{project}/workflow", h.handleUpdateWorkflow)
+	mux.HandleFunc("DELETE /projects/{project}/workflow", h.handleResetWorkflow)
+}
+
+func (h *WorkflowHandler) handleGetWorkflow(w http.ResponseWriter, r *http.Request) {
+	workflow, err := h.service.GetWorkflow(r.Context(), r.PathValue("project"))
+	if err != nil {
+		http.Error(w, ErrMsgFailedToGetWorkflow, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(workflow)
+}
+
+func (h *WorkflowHandler) handleUpdateWorkflow(w http.ResponseWriter, r *http.Request) {
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(r.Body)
+
+	var updateInput models.UpdateWorkflowInput
+	if err := json.NewDecoder(r.Body).Decode(&updateInput); err != nil {
+		http.Error(w, ErrMsgInvalidJSON, http.StatusBadRequest)
+		return
+	}
+	workflow, err := h.service.UpdateWorkflow(r.Context(), r.PathValue("project"), updateInput)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWorkflow) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		} else if errors.Is(err, service.ErrStatusInUse) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, ErrMsgFailedToUpdateWorkflow, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(workflow)
+}
+
+func (h *WorkflowHandler) handleResetWorkflow(w http.ResponseWriter, r *http.Request) {
+	workflow, err := h.service.ResetWorkflow(r.Context(), r.PathValue("project"))
+	if err != nil {
+		if errors.Is(err, service.ErrStatusInUse) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, ErrMsgFailedToUpdateWorkflow, http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(workflow)
+}
@@ -0,0 +1,101 @@
+// Package ical writes iCalendar data as specified by RFC 5545: components,
+// properties with escaped text, and lines folded at 75 octets.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Component is the kind of component a calendar lists tasks as.
+type Component string
+
+const (
+	Event Component = "VEVENT"
+	Todo  Component = "VTODO"
+)
+
+// maxLineOctets is the longest a content line may be, without its CRLF.
+const maxLineOctets = 75
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// Writer writes the content lines of an iCalendar object. The first error
+// sticks; it is returned by Flush.
+type Writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Begin starts a component, such as VCALENDAR or VEVENT.
+func (w *Writer) Begin(component string) {
+	w.Property("BEGIN", component)
+}
+
+// End ends a component started with Begin.
+func (w *Writer) End(component string) {
+	w.Property("END", component)
+}
+
+// Property writes a property with a value that is written as it is. name
+// may include parameters, like "DTSTART;VALUE=DATE".
+func (w *Writer) Property(name, value string) {
+	w.line(name + ":" + value)
+}
+
+// Text writes a property with a text value, escaping what has to be.
+func (w *Writer) Text(name, value string) {
+	w.Property(name, textEscaper.Replace(value))
+}
+
+// Time writes a property with a date-time value in UTC.
+func (w *Writer) Time(name string, t time.Time) {
+	w.Property(name, FormatTime(t))
+}
+
+// Date writes a property with the date of t, in the location of t.
+func (w *Writer) Date(name string, t time.Time) {
+	w.Property(name+";VALUE=DATE", t.Format("20060102"))
+}
+
+// FormatTime formats t as a date-time in UTC.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// line writes a content line, folded so that no line is longer than
+// maxLineOctets and no UTF-8 sequence is split.
+func (w *Writer) line(line string) {
+	if w.err != nil {
+		return
+	}
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		_, _ = w.w.WriteString(line[:cut])
+		_, _ = w.w.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts.
+		limit = maxLineOctets - 1
+	}
+	_, _ = w.w.WriteString(line)
+	_, w.err = w.w.WriteString("\r\n")
+}
+
+// Flush writes out what is buffered and returns the first error.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
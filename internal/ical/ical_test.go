@@ -0,0 +1,97 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	w.Begin("VEVENT")
+	w.Text("SUMMARY", "Rent, water; power\\heat\nsecond line")
+	w.Time("DTSTART", time.Date(2026, 3, 1, 10, 30, 0, 0, time.FixedZone("CET", 3600)))
+	w.Date("DTEND", time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC))
+	w.Text("DESCRIPTION", strings.Repeat("ä", 80))
+	w.End("VEVENT")
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\r\n"), "\r\n")
+	want := []string{
+		"BEGIN:VEVENT",
+		`SUMMARY:Rent\, water\; power\\heat\nsecond line`,
+		"DTSTART:20260301T093000Z",
+		"DTEND;VALUE=DATE:20260302",
+	}
+	for i, line := range want {
+		if lines[i] != line {
+			t.Errorf("line %d is %q, want %q", i, lines[i], line)
+		}
+	}
+	if lines[len(lines)-1] != "END:VEVENT" {
+		t.Errorf("last line is %q", lines[len(lines)-1])
+	}
+
+	// DESCRIPTION is folded into lines of at most 75 octets without
+	// splitting characters.
+	var description string
+	for i, line := range lines[4 : len(lines)-1] {
+		if len(line) > 75 {
+			t.Errorf("line %q is %d octets long", line, len(line))
+		}
+		if i > 0 {
+			if line[0] != ' ' {
+				t.Fatalf("continuation line %q doesn't start with a space", line)
+			}
+			line = line[1:]
+		}
+		description += line
+	}
+	if description != "DESCRIPTION:"+strings.Repeat("ä", 80) {
+		t.Fatalf("unfolded DESCRIPTION is %q", description)
+	}
+}
+
+func TestParseTodo(t *testing.T) {
+	const object = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\nEND:VTIMEZONE\r\n" +
+		"BEGIN:VTODO\r\nUID:abc-1\r\nSUMMARY:Rent\\, water\\; power\r\n" +
+		"DESCRIPTION:first line\\nsecond line that is fol\r\n ded\r\n" +
+		"DUE;TZID=\"Europe/Berlin\":20260301T103000\r\nstatus:completed\r\n" +
+		"BEGIN:VALARM\r\nDESCRIPTION:Reminder\r\nEND:VALARM\r\n" +
+		"END:VTODO\r\nEND:VCALENDAR\r\n"
+	todo, err := ParseTodo([]byte(object))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if todo.UID != "abc-1" || todo.Summary != "Rent, water; power" || todo.Description != "first line\nsecond line that is folded" ||
+		todo.Status != "COMPLETED" || !todo.Due.Equal(time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Fatalf("todo %+v", todo)
+	}
+
+	for due, want := range map[string]time.Time{
+		"DUE;VALUE=DATE:20260301":         time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		"DUE:20260301T103000Z":            time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC),
+		"DUE;TZID=Custom:20260301T103000": time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC),
+	} {
+		todo, err := ParseTodo([]byte("BEGIN:VTODO\nSUMMARY:x\n" + due + "\nEND:VTODO\n"))
+		if err != nil || !todo.Due.Equal(want) {
+			t.Errorf("%s: %+v, %v", due, todo, err)
+		}
+	}
+
+	for _, object := range []string{
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VTODO\nSUMMARY:x\n",
+		"BEGIN:VTODO\nno colon\nEND:VTODO\n",
+		"BEGIN:VTODO\nDUE:tomorrow\nEND:VTODO\n",
+	} {
+		if _, err := ParseTodo([]byte(object)); err == nil {
+			t.Errorf("%q: no error", object)
+		}
+	}
+}
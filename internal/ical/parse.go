@@ -0,0 +1,161 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrNoTodo = errors.New("the calendar object has no VTODO")
+
+// ParsedTodo is what a task takes from a VTODO.
+type ParsedTodo struct {
+	UID         string
+	Summary     string
+	Description string
+	Due         *time.Time
+	// Status is the STATUS of the to-do, like NEEDS-ACTION or COMPLETED,
+	// or empty.
+	Status string
+}
+
+// property is a content line of an iCalendar object.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ParseTodo parses an iCalendar object and returns its first VTODO. Dates
+// are taken as midnight UTC, and times in a TZID that isn't an IANA time
+// zone or without any zone as UTC.
+func ParseTodo(data []byte) (*ParsedTodo, error) {
+	var stack []string
+	var todo *ParsedTodo
+	for i, line := range unfold(string(data)) {
+		if line == "" {
+			continue
+		}
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch prop.name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(prop.value))
+			if todo == nil && stack[len(stack)-1] == string(Todo) {
+				todo = &ParsedTodo{}
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(prop.value) {
+				return nil, fmt.Errorf("line %d: END:%s doesn't end a component", i+1, prop.value)
+			}
+			if stack[len(stack)-1] == string(Todo) && todo != nil {
+				return todo, nil
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		// Only the properties of the VTODO itself count, not those of its
+		// alarms.
+		if todo == nil || stack[len(stack)-1] != string(Todo) {
+			continue
+		}
+		switch prop.name {
+		case "UID":
+			todo.UID = prop.value
+		case "SUMMARY":
+			todo.Summary = unescapeText(prop.value)
+		case "DESCRIPTION":
+			todo.Description = unescapeText(prop.value)
+		case "STATUS":
+			todo.Status = strings.ToUpper(prop.value)
+		case "DUE":
+			due, err := parseTime(prop)
+			if err != nil {
+				return nil, fmt.Errorf("DUE: %w", err)
+			}
+			todo.Due = &due
+		}
+	}
+	if todo != nil {
+		return nil, errors.New("the VTODO doesn't end")
+	}
+	return nil, ErrNoTodo
+}
+
+// unfold splits data into content lines and joins folded ones.
+func unfold(data string) []string {
+	var lines []string
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(lines) > 0 && line != "" && (line[0] == ' ' || line[0] == '\t') {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseLine splits a content line into its name, parameters and value.
+// Parameter values may be quoted and contain ';' and ':' then.
+func parseLine(line string) (property, error) {
+	prop := property{params: make(map[string]string)}
+	quoted := false
+	start := 0
+	name := ""
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			quoted = !quoted
+		case (c == ';' || c == ':') && !quoted:
+			part := line[start:i]
+			if name == "" {
+				name = part
+			} else {
+				key, value, _ := strings.Cut(part, "=")
+				prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+			}
+			start = i + 1
+			if c == ':' {
+				if name == "" {
+					return prop, errors.New("a property has no name")
+				}
+				prop.name = strings.ToUpper(name)
+				prop.value = line[i+1:]
+				return prop, nil
+			}
+		}
+	}
+	return prop, fmt.Errorf("%q is not a property", line)
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescapeText(value string) string {
+	return textUnescaper.Replace(value)
+}
+
+func parseTime(prop property) (time.Time, error) {
+	value := prop.value
+	if prop.params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		return time.Parse("20060102", value)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+	location := time.UTC
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if loaded, err := time.LoadLocation(tzid); err == nil && tzid != "Local" {
+			location = loaded
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, location)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
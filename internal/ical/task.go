@@ -0,0 +1,76 @@
+package ical
+
+import (
+	"fmt"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+// TaskOptions says how WriteTask writes a task.
+type TaskOptions struct {
+	Component Component
+	UID       string
+	// Category is the category of the task's status, which to-dos have a
+	// STATUS for.
+	Category models.StatusCategory
+	// Location is the time zone in which tasks due at midnight are taken to
+	// be due on that day.
+	Location *time.Location
+}
+
+// WriteTask writes a task as an event on its due date or as a to-do, which
+// may be without one. Tasks due at midnight in opts.Location are due on
+// that day; other due dates are written in UTC, which clients show in their
+// own time zone. SEQUENCE is the task's Sequence, so every change
+// increases it.
+func WriteTask(cal *Writer, task *models.Task, opts TaskOptions) {
+	cal.Begin(string(opts.Component))
+	cal.Property("UID", opts.UID)
+	cal.Time("DTSTAMP", task.UpdatedAt)
+	cal.Time("CREATED", task.CreatedAt)
+	cal.Time("LAST-MODIFIED", task.UpdatedAt)
+	cal.Property("SEQUENCE", fmt.Sprint(task.Sequence))
+	cal.Text("SUMMARY", task.Title)
+	if task.Description != "" {
+		cal.Text("DESCRIPTION", task.Description)
+	}
+	cal.Text("CATEGORIES", task.Project)
+
+	var due time.Time
+	allDay := false
+	if task.DueAt != nil {
+		location := opts.Location
+		if location == nil {
+			location = time.UTC
+		}
+		due = task.DueAt.In(location)
+		allDay = due.Equal(time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, location))
+	}
+	if opts.Component == Todo {
+		if allDay {
+			cal.Date("DUE", due)
+		} else if task.DueAt != nil {
+			cal.Time("DUE", due)
+		}
+		switch opts.Category {
+		case models.StatusCategoryDone:
+			cal.Property("STATUS", "COMPLETED")
+			cal.Time("COMPLETED", task.UpdatedAt)
+		case models.StatusCategoryActive:
+			cal.Property("STATUS", "IN-PROCESS")
+		default:
+			cal.Property("STATUS", "NEEDS-ACTION")
+		}
+	} else if task.DueAt != nil {
+		if allDay {
+			cal.Date("DTSTART", due)
+			cal.Date("DTEND", due.AddDate(0, 0, 1))
+		} else {
+			cal.Time("DTSTART", due)
+		}
+		// Tasks don't make anyone busy.
+		cal.Property("TRANSP", "TRANSPARENT")
+	}
+	cal.End(string(opts.Component))
+}
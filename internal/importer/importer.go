@@ -0,0 +1,260 @@
+// Package importer reads tasks to import from CSV and NDJSON files, and from
+// the exports of Trello, Jira and todo.txt. It only parses; validating and
+// writing the tasks is up to the import service.
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatTrello  Format = "trello"
+	FormatJira    Format = "jira"
+	FormatTodoTxt Format = "todotxt"
+)
+
+// MaxRows is the largest number of rows an import may have. Imports run in
+// a single transaction and their rows are kept in memory.
+const MaxRows = 10000
+
+// maxLineSize is the longest line of an NDJSON file.
+const maxLineSize = 1 << 20
+
+var (
+	ErrUnknownFormat = errors.New("unknown import format")
+	ErrTooManyRows   = fmt.Errorf("an import can't have more than %d rows", MaxRows)
+)
+
+// Row is a task read from an import file.
+type Row struct {
+	// Line is the line of the file the row starts on.
+	Line int
+	Task models.ImportTaskInput
+	// Err is why the row couldn't be read. Such rows are reported and
+	// can't be imported.
+	Err error
+}
+
+// ParseFormat parses the name of a format, as used in the format query
+// parameter and the -format flag.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatCSV, FormatNDJSON, FormatTrello, FormatJira, FormatTodoTxt:
+		return format, nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownFormat, name)
+}
+
+// FormatOfMediaType returns the format of a Content-Type.
+func FormatOfMediaType(contentType string) (Format, bool) {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.ToLower(strings.TrimSpace(mediaType)) {
+	case "text/csv":
+		return FormatCSV, true
+	case "application/x-ndjson", "application/ndjson":
+		return FormatNDJSON, true
+	}
+	return "", false
+}
+
+// Read reads the rows of a file in format, mapping the fields of other
+// tools with mapping. Problems with single rows are reported in their Err;
+// an error is returned only if the file can't be read at all.
+func Read(r io.Reader, format Format, mapping Mapping) ([]Row, error) {
+	switch format {
+	case FormatCSV:
+		return ReadCSV(r)
+	case FormatNDJSON:
+		return ReadNDJSON(r)
+	case FormatTrello:
+		return ReadTrello(r, mapping)
+	case FormatJira:
+		return ReadJira(r, mapping)
+	case FormatTodoTxt:
+		return ReadTodoTxt(r, mapping)
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+// csvColumns are the columns a CSV file may have. The ones that GET
+// /tasks/export writes but an import can't set are ignored, so exports can
+// be imported again.
+var csvColumns = map[string]bool{
+	"external_id":   true,
+	"project":       true,
+	"title":         true,
+	"description":   true,
+	"status":        true,
+	"assignee":      true,
+	"due_at":        true,
+	"id":            false,
+	"recurrence_id": false,
+	"created_at":    false,
+	"updated_at":    false,
+}
+
+// ReadCSV reads a CSV file with a header row that names its columns. Only
+// title is required.
+func ReadCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty; it needs a header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = headerName(name)
+		known, ok := csvColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("column %q appears twice", name)
+		}
+		if known {
+			columns[name] = i
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New(`the header has no "title" column`)
+	}
+
+	return readRecords(reader, func(record []string) (models.ImportTaskInput, error) {
+		return csvTask(record, columns)
+	})
+}
+
+// readRecords reads the records after the header of a CSV file and turns
+// them into rows with task.
+func readRecords(reader *csv.Reader, task func(record []string) (models.ImportTaskInput, error)) ([]Row, error) {
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+		row := Row{Line: line, Err: err}
+		if err == nil {
+			row.Task, row.Err = task(record)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func csvTask(record []string, columns map[string]int) (models.ImportTaskInput, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+	// optional returns nil for a column the file doesn't have, so that an
+	// update leaves the field as it is.
+	optional := func(name string) *string {
+		if _, ok := columns[name]; !ok {
+			return nil
+		}
+		value := unescapeFormula(field(name))
+		return &value
+	}
+	task := models.ImportTaskInput{
+		ExternalID:  strings.TrimSpace(field("external_id")),
+		Project:     unescapeFormula(field("project")),
+		Title:       unescapeFormula(field("title")),
+		Description: optional("description"),
+		Status:      models.TaskStatus(strings.TrimSpace(field("status"))),
+		Assignee:    optional("assignee"),
+	}
+	if _, ok := columns["due_at"]; !ok {
+		return task, nil
+	}
+	dueAt := strings.TrimSpace(field("due_at"))
+	if dueAt == "" {
+		task.ClearDueAt = true
+		return task, nil
+	}
+	t, err := ParseTime(dueAt)
+	if err != nil {
+		return task, fmt.Errorf("due_at: %w", err)
+	}
+	task.DueAt = &t
+	return task, nil
+}
+
+// headerName returns the name of a column of a CSV header in lower case.
+func headerName(name string) string {
+	// Spreadsheets may start UTF-8 files with a byte order mark.
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+}
+
+// unescapeFormula undoes the quote that exports put in front of text that
+// spreadsheets could take for a formula.
+func unescapeFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// ParseTime parses a time in RFC 3339 or a date, which is taken as
+// midnight UTC.
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a date like 2006-01-02", value)
+}
+
+// ReadNDJSON reads a file with a JSON object per line, with the fields of
+// the body of POST /tasks plus external_id, status and comments. Blank lines
+// are skipped.
+func ReadNDJSON(r io.Reader) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	var rows []Row
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+		row := Row{Line: line}
+		if err := json.Unmarshal(data, &row.Task); err != nil {
+			row.Err = err
+		}
+		row.Task.ExternalID = strings.TrimSpace(row.Task.ExternalID)
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,119 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/export"
+	"task-manager/internal/models"
+)
+
+func TestReadCSV(t *testing.T) {
+	rows, err := ReadCSV(strings.NewReader("\ufeffTitle,Due_At,status\n" +
+		"Pay rent,2026-03-01,done\n" +
+		"\"Multi\nline\",,\n" +
+		"Bad date,1st of March,\n" +
+		"Too,many,fields,here\n"))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("%d rows, want 4", len(rows))
+	}
+	if rows[0].Err != nil || rows[0].Task.Title != "Pay rent" || rows[0].Task.Status != models.TaskStatusDone ||
+		!rows[0].Task.DueAt.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("row 0: %+v", rows[0])
+	}
+	if rows[1].Line != 3 || rows[1].Task.Title != "Multi\nline" || rows[1].Task.DueAt != nil || !rows[1].Task.ClearDueAt {
+		t.Fatalf("row 1: %+v", rows[1])
+	}
+	if rows[2].Line != 5 || rows[2].Err == nil || rows[3].Line != 6 || rows[3].Err == nil {
+		t.Fatalf("rows 2 and 3: %+v, %+v", rows[2], rows[3])
+	}
+
+	// Columns the file doesn't have are left out rather than cleared.
+	if task := rows[0].Task; task.Description != nil || task.Assignee != nil || task.ClearDueAt {
+		t.Fatalf("row 0 sets missing columns: %+v", task)
+	}
+
+	for _, header := range []string{"", "description\n", "title,owner\n", "title,title\n"} {
+		if _, err := ReadCSV(strings.NewReader(header)); err == nil {
+			t.Errorf("header %q: no error", header)
+		}
+	}
+}
+
+func TestReadCSVOfExport(t *testing.T) {
+	dueAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	task := &models.Task{
+		ID:          uuid.New(),
+		ExternalID:  "S-1",
+		Project:     "home",
+		Title:       "=1+1",
+		Description: "-- notes",
+		Status:      models.TaskStatusInProgress,
+		Assignee:    "ann",
+		DueAt:       &dueAt,
+		CreatedAt:   dueAt,
+		UpdatedAt:   dueAt,
+	}
+	columns, _ := export.ParseColumns("")
+	var out bytes.Buffer
+	writer := export.NewWriter(&out, export.FormatCSV, columns)
+	if err := writer.Write(task); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	rows, err := ReadCSV(&out)
+	if err != nil || len(rows) != 1 || rows[0].Err != nil {
+		t.Fatalf("read: %+v, %v", rows, err)
+	}
+	got := rows[0].Task
+	if got.ExternalID != "S-1" || got.Project != "home" || got.Title != "=1+1" || value(got.Description) != "-- notes" ||
+		got.Status != models.TaskStatusInProgress || value(got.Assignee) != "ann" || !got.DueAt.Equal(dueAt) {
+		t.Fatalf("imported %+v", got)
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	rows, err := ReadNDJSON(strings.NewReader(`{"external_id": " J-1 ", "title": "Pay rent", "due_at": "2026-03-01T09:30:00Z"}
+
+{"title": "Buy milk", "due_at": "tomorrow"}
+[1, 2]
+`))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("%d rows, want 3", len(rows))
+	}
+	if rows[0].Err != nil || rows[0].Task.ExternalID != "J-1" || rows[0].Task.DueAt == nil {
+		t.Fatalf("row 0: %+v", rows[0])
+	}
+	if rows[1].Line != 3 || rows[1].Err == nil || rows[2].Line != 4 || rows[2].Err == nil {
+		t.Fatalf("rows 1 and 2: %+v, %+v", rows[1], rows[2])
+	}
+}
+
+func TestReadTooManyRows(t *testing.T) {
+	input := "title\n" + strings.Repeat("Pay rent\n", MaxRows+1)
+	if _, err := ReadCSV(strings.NewReader(input)); !errors.Is(err, ErrTooManyRows) {
+		t.Fatalf("read: %v, want ErrTooManyRows", err)
+	}
+}
+
+// value returns what s points to, or "<nil>" for a missing field.
+func value(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
@@ -0,0 +1,124 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+// jiraTimeLayouts are the layouts of times in Jira CSV exports, which
+// follow the date format of the Jira site.
+var jiraTimeLayouts = []string{
+	"2/Jan/06 3:04 PM",
+	"2/Jan/06 15:04",
+	"2/Jan/06",
+	"2006-01-02 15:04",
+}
+
+// ReadJira reads a Jira CSV export, as from "Export > Export CSV (all
+// fields)". An issue becomes a task with the external ID jira:<issue key>.
+// Its Summary is the title, and Status, Assignee, Due date, Labels and
+// Comment columns are imported; Jira repeats the last two for each value.
+// Other columns are ignored.
+func ReadJira(r io.Reader, mapping Mapping) ([]Row, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty; it needs a header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string][]int, len(header))
+	for i, name := range header {
+		name = headerName(name)
+		columns[name] = append(columns[name], i)
+	}
+	if _, ok := columns["summary"]; !ok {
+		return nil, errors.New(`the header has no "Summary" column; is this a Jira export?`)
+	}
+	if _, ok := columns["issue key"]; !ok {
+		return nil, errors.New(`the header has no "Issue key" column; is this a Jira export?`)
+	}
+
+	return readRecords(reader, func(record []string) (models.ImportTaskInput, error) {
+		fields := func(name string) []string {
+			var values []string
+			for _, i := range columns[name] {
+				if value := strings.TrimSpace(record[i]); value != "" {
+					values = append(values, value)
+				}
+			}
+			return values
+		}
+		field := func(name string) string {
+			if values := fields(name); len(values) > 0 {
+				return values[0]
+			}
+			return ""
+		}
+
+		// optional returns nil for a column the export doesn't have, so
+		// that an update leaves the field as it is.
+		optional := func(name string) *string {
+			if _, ok := columns[name]; !ok {
+				return nil
+			}
+			value := field(name)
+			return &value
+		}
+
+		key := field("issue key")
+		if key == "" {
+			return models.ImportTaskInput{}, errors.New("the issue has no key")
+		}
+		task := models.ImportTaskInput{
+			ExternalID:  "jira:" + key,
+			Title:       field("summary"),
+			Description: optional("description"),
+		}
+		if dueAt := optional("due date"); dueAt != nil && *dueAt == "" {
+			task.ClearDueAt = true
+		} else if dueAt != nil {
+			t, err := parseJiraTime(*dueAt)
+			if err != nil {
+				return task, fmt.Errorf("due date: %w", err)
+			}
+			task.DueAt = &t
+		}
+		for _, comment := range fields("comment") {
+			task.Comments = append(task.Comments, jiraComment(comment, mapping))
+		}
+		mapping.apply(&task, field("status"), optional("assignee"), fields("labels"))
+		return task, nil
+	})
+}
+
+func parseJiraTime(value string) (time.Time, error) {
+	for _, layout := range jiraTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := ParseTime(value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time like 21/Mar/26 3:04 PM or 2026-03-21", value)
+}
+
+// jiraComment parses a comment of a Jira export, which is its time, author
+// and body separated by semicolons.
+func jiraComment(value string, mapping Mapping) models.ImportCommentInput {
+	parts := strings.SplitN(value, ";", 3)
+	if len(parts) == 3 {
+		if _, err := parseJiraTime(strings.TrimSpace(parts[0])); err == nil {
+			return models.ImportCommentInput{Author: mapping.assignee(parts[1]), Body: parts[2]}
+		}
+	}
+	return models.ImportCommentInput{Body: value}
+}
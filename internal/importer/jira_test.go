@@ -0,0 +1,51 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+func TestReadJira(t *testing.T) {
+	const export = "Summary,Issue key,Issue id,Status,Assignee,Due date,Labels,Labels,Description,Comment,Comment\n" +
+		"Fix login,WEB-1,10001,In Progress,Ann B,21/Mar/26 12:00 AM,frontend,urgent,\"Users can't log in\",\"20/Mar/26 9:15 AM;Sam;Seen it; on Safari\",Just a note\n" +
+		"Ship it,WEB-2,10002,Closed,,2026-03-22,,,,,\n" +
+		"No key,,10003,To Do,,,,,,,\n" +
+		"Bad due date,WEB-4,10004,To Do,,next week,,,,,\n"
+	rows, err := ReadJira(strings.NewReader(export), Mapping{
+		Projects:  map[string]string{"frontend": "web"},
+		Assignees: map[string]string{"ann b": "ann@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("%d rows, want 4", len(rows))
+	}
+
+	login := rows[0].Task
+	if rows[0].Err != nil || login.ExternalID != "jira:WEB-1" || login.Title != "Fix login" || login.Project != "web" ||
+		login.Status != models.TaskStatusInProgress || value(login.Assignee) != "ann@example.com" ||
+		!login.DueAt.Equal(time.Date(2026, 3, 21, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("WEB-1: %+v, %v", login, rows[0].Err)
+	}
+	if value(login.Description) != "Users can't log in\n\nLabels: urgent" {
+		t.Fatalf("WEB-1 description %q", value(login.Description))
+	}
+	if len(login.Comments) != 2 || login.Comments[0] != (models.ImportCommentInput{Author: "Sam", Body: "Seen it; on Safari"}) ||
+		login.Comments[1] != (models.ImportCommentInput{Body: "Just a note"}) {
+		t.Fatalf("WEB-1 comments %+v", login.Comments)
+	}
+	if ship := rows[1].Task; ship.Status != models.TaskStatusDone || ship.Project != "" || ship.DueAt == nil {
+		t.Fatalf("WEB-2: %+v", ship)
+	}
+	if rows[2].Err == nil || rows[3].Err == nil || rows[3].Line != 5 {
+		t.Fatalf("rows 2 and 3: %+v, %+v", rows[2], rows[3])
+	}
+
+	if _, err := ReadJira(strings.NewReader("title,status\nPay rent,done\n"), Mapping{}); err == nil {
+		t.Fatalf("not a Jira export: no error")
+	}
+}
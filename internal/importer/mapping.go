@@ -0,0 +1,129 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"task-manager/internal/models"
+)
+
+// Mapping configures how the cards and issues of other tools become tasks.
+// It is used by the Trello, Jira and todo.txt formats; CSV and NDJSON files
+// already have the fields of tasks. Keys are matched case-insensitively.
+type Mapping struct {
+	// Project is the project of the tasks that no label puts in another
+	// one. It defaults to the default project.
+	Project string `json:"project"`
+	// Statuses maps the statuses of the source to statuses: the names of
+	// Trello lists, Jira statuses, and "open" or "done" for todo.txt.
+	// Statuses that are mapped neither here nor by default leave new tasks
+	// in the initial status and updated ones where they are.
+	Statuses map[string]models.TaskStatus `json:"statuses"`
+	// Projects maps labels to projects: Trello and Jira labels, and the
+	// +project and @context tags of todo.txt. The first label of a task
+	// that is mapped decides its project. The other labels are listed at
+	// the end of the description, as tasks have none.
+	Projects map[string]string `json:"projects"`
+	// Assignees maps the users of the source to assignees. Users that
+	// aren't mapped are assigned as they are named in the source.
+	Assignees map[string]string `json:"assignees"`
+	// Archived also imports archived Trello cards and cards on archived
+	// lists.
+	Archived bool `json:"archived"`
+}
+
+// defaultStatuses are the statuses of the default workflow that common
+// source statuses map to.
+var defaultStatuses = map[string]models.TaskStatus{
+	"backlog":     models.TaskStatusNew,
+	"open":        models.TaskStatusNew,
+	"to do":       models.TaskStatusNew,
+	"todo":        models.TaskStatusNew,
+	"doing":       models.TaskStatusInProgress,
+	"in progress": models.TaskStatusInProgress,
+	"closed":      models.TaskStatusDone,
+	"done":        models.TaskStatusDone,
+	"resolved":    models.TaskStatusDone,
+}
+
+// ReadMapping reads a mapping from JSON. Unknown fields are an error, so
+// that misspelt rules don't go unnoticed.
+func ReadMapping(r io.Reader) (Mapping, error) {
+	var mapping Mapping
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&mapping); err != nil {
+		return Mapping{}, fmt.Errorf("read mapping: %w", err)
+	}
+	mapping.Statuses = foldKeys(mapping.Statuses)
+	mapping.Projects = foldKeys(mapping.Projects)
+	mapping.Assignees = foldKeys(mapping.Assignees)
+	return mapping, nil
+}
+
+func foldKeys[V any](m map[string]V) map[string]V {
+	folded := make(map[string]V, len(m))
+	for key, value := range m {
+		folded[foldKey(key)] = value
+	}
+	return folded
+}
+
+func foldKey(key string) string {
+	return strings.ToLower(strings.TrimSpace(key))
+}
+
+// status returns the status that a source status maps to, or "" if it
+// doesn't map to any.
+func (m Mapping) status(source string) models.TaskStatus {
+	if status, ok := m.Statuses[foldKey(source)]; ok {
+		return status
+	}
+	return defaultStatuses[foldKey(source)]
+}
+
+func (m Mapping) assignee(source string) string {
+	if assignee, ok := m.Assignees[foldKey(source)]; ok {
+		return assignee
+	}
+	return strings.TrimSpace(source)
+}
+
+// apply sets the project, status and assignee of task from what the source
+// calls them, and lists the labels that don't map to a project at the end
+// of its description. A nil assignee is a source without assignees.
+func (m Mapping) apply(task *models.ImportTaskInput, status string, assignee *string, labels []string) {
+	task.Project = m.Project
+	task.Status = m.status(status)
+	if assignee != nil {
+		mapped := m.assignee(*assignee)
+		task.Assignee = &mapped
+	}
+
+	var unmapped []string
+	mapped := false
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" || slices.Contains(unmapped, label) {
+			continue
+		}
+		if project, ok := m.Projects[foldKey(label)]; ok {
+			if !mapped {
+				task.Project, mapped = project, true
+			}
+			continue
+		}
+		unmapped = append(unmapped, label)
+	}
+	if len(unmapped) > 0 {
+		var description string
+		if task.Description != nil && *task.Description != "" {
+			description = *task.Description + "\n\n"
+		}
+		description += "Labels: " + strings.Join(unmapped, ", ")
+		task.Description = &description
+	}
+}
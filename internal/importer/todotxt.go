@@ -0,0 +1,106 @@
+package importer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+// todoTxtPrefix matches what may come before the text of a todo.txt task:
+// the completion mark with its date, or a priority, and a creation date.
+var todoTxtPrefix = regexp.MustCompile(`^(?:(x) (?:\d{4}-\d{2}-\d{2} )?|\(([A-Z])\) )?(?:\d{4}-\d{2}-\d{2} )?`)
+
+// ReadTodoTxt reads a todo.txt file, one task per line. Completed tasks have
+// the source status "done", the others "open". The text of a task is its
+// title, without the due:, id: and pri: tags; +project and @context tags
+// are its labels and a priority goes into the description. The external ID
+// is todotxt:<id tag>, or, as lines have no IDs, todotxt:<hash of the
+// title>, so that completing a task or changing its due date updates it but
+// rewording it imports a new task. Repeated titles, like a paid "x Pay rent"
+// next to this month's "Pay rent", get -2, -3 and so on appended to the hash
+// in the order of the file. Blank lines are skipped.
+func ReadTodoTxt(r io.Reader, mapping Mapping) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	var rows []Row
+	titles := make(map[string]int)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+		row := Row{Line: line}
+		row.Task, row.Err = todoTxtTask(text, mapping, titles)
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// todoTxtTask returns the task of a line. titles counts the lines without
+// an id: tag so far by title.
+func todoTxtTask(text string, mapping Mapping, titles map[string]int) (models.ImportTaskInput, error) {
+	prefix := todoTxtPrefix.FindStringSubmatch(text)
+	status, priority := "open", prefix[2]
+	if prefix[1] == "x" {
+		status = "done"
+	}
+
+	var task models.ImportTaskInput
+	var id string
+	var words, labels []string
+	for _, word := range strings.Fields(text[len(prefix[0]):]) {
+		key, value, _ := strings.Cut(word, ":")
+		switch {
+		case key == "due" && value != "":
+			dueAt, err := time.Parse(time.DateOnly, value)
+			if err != nil {
+				return task, fmt.Errorf("due: %q is not a date like 2006-01-02", value)
+			}
+			task.DueAt = &dueAt
+			continue
+		case key == "id" && value != "":
+			id = value
+			continue
+		case key == "pri" && value != "":
+			priority = value
+			continue
+		case len(word) > 1 && (word[0] == '+' || word[0] == '@'):
+			labels = append(labels, word)
+		}
+		words = append(words, word)
+	}
+	task.Title = strings.Join(words, " ")
+	if id == "" {
+		sum := sha256.Sum256([]byte(task.Title))
+		id = hex.EncodeToString(sum[:8])
+		titles[task.Title]++
+		if n := titles[task.Title]; n > 1 {
+			id += "-" + strconv.Itoa(n)
+		}
+	}
+	task.ExternalID = "todotxt:" + id
+	// A line is the whole task: no priority clears the description and no
+	// due date the due date. todo.txt has no assignees.
+	var description string
+	if priority != "" {
+		description = "Priority: " + priority
+	}
+	task.Description = &description
+	task.ClearDueAt = task.DueAt == nil
+	mapping.apply(&task, status, nil, labels)
+	return task, nil
+}
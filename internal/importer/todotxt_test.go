@@ -0,0 +1,64 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+func TestReadTodoTxt(t *testing.T) {
+	rows, err := ReadTodoTxt(strings.NewReader(`(A) 2026-03-01 Call mum +family @phone due:2026-03-05
+
+x 2026-03-02 2026-03-01 Pay rent +bills pri:B
+Water plants id:plants-1
+Buy milk due:soon
+`), Mapping{Projects: map[string]string{"+bills": "finance"}})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("%d rows, want 4", len(rows))
+	}
+
+	mum := rows[0].Task
+	if rows[0].Err != nil || mum.Title != "Call mum +family @phone" || mum.Status != models.TaskStatusNew ||
+		mum.Project != "" || !mum.DueAt.Equal(time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("line 1: %+v, %v", mum, rows[0].Err)
+	}
+	if value(mum.Description) != "Priority: A\n\nLabels: +family, @phone" || !strings.HasPrefix(mum.ExternalID, "todotxt:") {
+		t.Fatalf("line 1: %+v", mum)
+	}
+	rent := rows[1]
+	if rent.Line != 3 || rent.Task.Title != "Pay rent +bills" || rent.Task.Status != models.TaskStatusDone ||
+		rent.Task.Project != "finance" || value(rent.Task.Description) != "Priority: B" || !rent.Task.ClearDueAt || rent.Task.Assignee != nil {
+		t.Fatalf("line 3: %+v", rent)
+	}
+	if rows[2].Task.ExternalID != "todotxt:plants-1" || rows[2].Task.Title != "Water plants" {
+		t.Fatalf("line 4: %+v", rows[2].Task)
+	}
+	if rows[3].Err == nil {
+		t.Fatalf("line 5: no error for an invalid due date")
+	}
+
+	// Completing a task or changing its due date keeps its external ID.
+	again, err := ReadTodoTxt(strings.NewReader("x 2026-03-06 Call mum +family @phone due:2026-03-07\n"), Mapping{})
+	if err != nil || again[0].Task.ExternalID != mum.ExternalID || again[0].Task.Status != models.TaskStatusDone {
+		t.Fatalf("completed task: %+v, %v", again, err)
+	}
+}
+
+func TestReadTodoTxtNumbersRepeatedTitles(t *testing.T) {
+	rows, err := ReadTodoTxt(strings.NewReader("x 2026-03-02 Pay rent\nPay rent\nPay rent id:rent-4\nPay rent due:2026-05-01\n"), Mapping{})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	first := rows[0].Task.ExternalID
+	want := []string{first, first + "-2", "todotxt:rent-4", first + "-3"}
+	for i, row := range rows {
+		if row.Err != nil || row.Task.ExternalID != want[i] {
+			t.Errorf("line %d: external ID %q, %v, want %q", row.Line, row.Task.ExternalID, row.Err, want[i])
+		}
+	}
+}
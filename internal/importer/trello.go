@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+// trelloBoard is the part of a Trello board export, as downloaded with
+// "Print, export and share > Export as JSON", that imports use.
+type trelloBoard struct {
+	Lists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Members []struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"members"`
+	Cards []struct {
+		ID        string     `json:"id"`
+		Name      string     `json:"name"`
+		Desc      string     `json:"desc"`
+		IDList    string     `json:"idList"`
+		IDMembers []string   `json:"idMembers"`
+		Due       *time.Time `json:"due"`
+		Closed    bool       `json:"closed"`
+		Labels    []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		} `json:"labels"`
+	} `json:"cards"`
+	Actions []trelloAction `json:"actions"`
+}
+
+type trelloAction struct {
+	Type string    `json:"type"`
+	Date time.Time `json:"date"`
+	Data struct {
+		Text string `json:"text"`
+		Card struct {
+			ID string `json:"id"`
+		} `json:"card"`
+	} `json:"data"`
+	MemberCreator struct {
+		Username string `json:"username"`
+	} `json:"memberCreator"`
+}
+
+// ReadTrello reads the cards of a Trello board export. A card becomes a task
+// with the external ID trello:<card ID>; the name of its list is its status
+// and its first member its assignee. Labels without a name go by their
+// color. Comments are in the export only as far as its action history
+// goes. The Line of a row is the position of the card in the export.
+func ReadTrello(r io.Reader, mapping Mapping) ([]Row, error) {
+	var board trelloBoard
+	if err := json.NewDecoder(r).Decode(&board); err != nil {
+		return nil, fmt.Errorf("read Trello board: %w", err)
+	}
+
+	type list struct {
+		name   string
+		closed bool
+	}
+	lists := make(map[string]list, len(board.Lists))
+	for _, l := range board.Lists {
+		lists[l.ID] = list{name: l.Name, closed: l.Closed}
+	}
+	members := make(map[string]string, len(board.Members))
+	for _, member := range board.Members {
+		members[member.ID] = member.Username
+	}
+	// Exports list actions newest first; comments are added oldest first.
+	actions := slices.Clone(board.Actions)
+	slices.SortStableFunc(actions, func(a, b trelloAction) int {
+		return a.Date.Compare(b.Date)
+	})
+	comments := make(map[string][]models.ImportCommentInput)
+	for _, action := range actions {
+		if action.Type != "commentCard" {
+			continue
+		}
+		comments[action.Data.Card.ID] = append(comments[action.Data.Card.ID], models.ImportCommentInput{
+			Author: mapping.assignee(action.MemberCreator.Username),
+			Body:   action.Data.Text,
+		})
+	}
+
+	var rows []Row
+	for i, card := range board.Cards {
+		if (card.Closed || lists[card.IDList].closed) && !mapping.Archived {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+		task := models.ImportTaskInput{
+			ExternalID:  "trello:" + card.ID,
+			Title:       card.Name,
+			Description: &card.Desc,
+			DueAt:       card.Due,
+			ClearDueAt:  card.Due == nil,
+			Comments:    comments[card.ID],
+		}
+		var assignee string
+		if len(card.IDMembers) > 0 {
+			assignee = members[card.IDMembers[0]]
+		}
+		labels := make([]string, 0, len(card.Labels))
+		for _, label := range card.Labels {
+			if label.Name == "" {
+				label.Name = label.Color
+			}
+			labels = append(labels, label.Name)
+		}
+		mapping.apply(&task, lists[card.IDList].name, &assignee, labels)
+		rows = append(rows, Row{Line: i + 1, Task: task})
+	}
+	return rows, nil
+}
@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+const trelloBoardJSON = `{
+  "name": "Home",
+  "lists": [
+    {"id": "l1", "name": "To Do", "closed": false},
+    {"id": "l2", "name": "Waiting", "closed": false},
+    {"id": "l3", "name": "Old", "closed": true}
+  ],
+  "members": [{"id": "m1", "username": "annb"}],
+  "cards": [
+    {"id": "c1", "name": "Pay rent", "desc": "Before the 3rd", "idList": "l1", "idMembers": ["m1"],
+     "due": "2026-03-01T09:00:00.000Z", "closed": false,
+     "labels": [{"name": "Bills", "color": "red"}, {"name": "", "color": "green"}]},
+    {"id": "c2", "name": "Call the landlord", "desc": "", "idList": "l2", "idMembers": [], "due": null,
+     "closed": false, "labels": []},
+    {"id": "c3", "name": "Archived card", "idList": "l1", "closed": true},
+    {"id": "c4", "name": "On an archived list", "idList": "l3", "closed": false}
+  ],
+  "actions": [
+    {"type": "commentCard", "date": "2026-02-02T10:00:00.000Z", "data": {"text": "Paid", "card": {"id": "c1"}},
+     "memberCreator": {"username": "annb"}},
+    {"type": "updateCard", "date": "2026-02-01T12:00:00.000Z", "data": {"card": {"id": "c1"}},
+     "memberCreator": {"username": "annb"}},
+    {"type": "commentCard", "date": "2026-02-01T10:00:00.000Z", "data": {"text": "Rent went up", "card": {"id": "c1"}},
+     "memberCreator": {"username": "sam"}}
+  ]
+}`
+
+func TestReadTrello(t *testing.T) {
+	mapping, err := ReadMapping(strings.NewReader(`{
+  "project": "home",
+  "statuses": {"waiting": "in_progress"},
+  "projects": {"bills": "finance"},
+  "assignees": {"AnnB": "ann@example.com"}
+}`))
+	if err != nil {
+		t.Fatalf("mapping: %v", err)
+	}
+	rows, err := ReadTrello(strings.NewReader(trelloBoardJSON), mapping)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want 2 without the archived cards", len(rows))
+	}
+
+	rent := rows[0].Task
+	if rent.ExternalID != "trello:c1" || rent.Title != "Pay rent" || rent.Project != "finance" ||
+		rent.Status != models.TaskStatusNew || value(rent.Assignee) != "ann@example.com" ||
+		!rent.DueAt.Equal(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("card c1: %+v", rent)
+	}
+	if value(rent.Description) != "Before the 3rd\n\nLabels: green" {
+		t.Fatalf("card c1 description %q", value(rent.Description))
+	}
+	if len(rent.Comments) != 2 || rent.Comments[0].Body != "Rent went up" || rent.Comments[0].Author != "sam" ||
+		rent.Comments[1].Author != "ann@example.com" {
+		t.Fatalf("card c1 comments %+v", rent.Comments)
+	}
+	landlord := rows[1]
+	if landlord.Line != 2 || landlord.Task.Project != "home" || landlord.Task.Status != models.TaskStatusInProgress ||
+		value(landlord.Task.Assignee) != "" || landlord.Task.DueAt != nil || !landlord.Task.ClearDueAt {
+		t.Fatalf("card c2: %+v", landlord)
+	}
+
+	rows, err = ReadTrello(strings.NewReader(trelloBoardJSON), Mapping{Archived: true})
+	if err != nil || len(rows) != 4 {
+		t.Fatalf("with archived cards: %d rows, %v", len(rows), err)
+	}
+	if rows[1].Task.Status != "" {
+		t.Fatalf("unmapped list %q gave status %q", "Waiting", rows[1].Task.Status)
+	}
+
+	if _, err := ReadTrello(strings.NewReader(`{"cards": 1}`), Mapping{}); err == nil {
+		t.Fatalf("invalid board: no error")
+	}
+}
+
+func TestReadMappingRejectsUnknownFields(t *testing.T) {
+	if _, err := ReadMapping(strings.NewReader(`{"status": {"done": "done"}}`)); err == nil {
+		t.Fatalf("unknown field: no error")
+	}
+}
@@ -3,15 +3,41 @@ package migrations
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
+
+	"task-manager/internal/database"
 )
 
-// Run executes all database migrations
-func Run(db *sql.DB) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// migration is a single schema change. Migrations are applied in order and
+// each one runs at most once per database; applied versions are recorded in
+// the schema_migrations table.
+//
+// statements must be portable SQL unless the migration has postgres
+// statements, which replace them on PostgreSQL. Tasks use native UUID and
+// timestamptz columns there; other tables keep the SQLite column types.
+// Migrations that have to transform rows set run, which is called in the
+// migration's transaction before the statements are executed.
+type migration struct {
+	version    int
+	name       string
+	statements []string
+	postgres   []string
+	run        func(ctx context.Context, tx *sql.Tx, dialect database.Dialect) error
+}
+
+func (m migration) statementsFor(dialect database.Dialect) []string {
+	if dialect == database.Postgres && m.postgres != nil {
+		return m.postgres
+	}
+	return m.statements
+}
 
-	const createTable = `
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create_tasks",
+		statements: []string{`
 CREATE TABLE IF NOT EXISTS tasks (
   id TEXT PRIMARY KEY,
   title TEXT NOT NULL,
@@ -20,8 +46,561 @@ CREATE TABLE IF NOT EXISTS tasks (
   created_at TEXT NOT NULL,
   updated_at TEXT NOT NULL
 );
+`},
+		postgres: []string{
+			`
+CREATE TABLE IF NOT EXISTS tasks (
+  id UUID PRIMARY KEY,
+  title TEXT NOT NULL,
+  description TEXT NOT NULL,
+  status TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks (created_at DESC)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "project_workflows",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN project TEXT NOT NULL DEFAULT 'default'`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_project_status ON tasks (project, status)`,
+			`
+CREATE TABLE IF NOT EXISTS workflows (
+  project TEXT PRIMARY KEY,
+  initial_status TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+`,
+			`
+CREATE TABLE IF NOT EXISTS workflow_statuses (
+  project TEXT NOT NULL REFERENCES workflows (project) ON DELETE CASCADE,
+  key TEXT NOT NULL,
+  name TEXT NOT NULL,
+  category TEXT NOT NULL,
+  position INTEGER NOT NULL,
+  PRIMARY KEY (project, key)
+);
+`,
+			`
+CREATE TABLE IF NOT EXISTS workflow_transitions (
+  project TEXT NOT NULL REFERENCES workflows (project) ON DELETE CASCADE,
+  from_status TEXT NOT NULL,
+  to_status TEXT NOT NULL,
+  PRIMARY KEY (project, from_status, to_status)
+);
+`,
+		},
+	},
+	{
+		version: 3,
+		name:    "recurrences",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN due_at TEXT`,
+			`ALTER TABLE tasks ADD COLUMN recurrence_id TEXT`,
+			// One task per occurrence makes materialisation idempotent.
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_recurrence_occurrence ON tasks (recurrence_id, due_at) WHERE recurrence_id IS NOT NULL`,
+			`
+CREATE TABLE IF NOT EXISTS recurrences (
+  id TEXT PRIMARY KEY,
+  project TEXT NOT NULL,
+  title TEXT NOT NULL,
+  description TEXT NOT NULL,
+  rule TEXT NOT NULL,
+  timezone TEXT NOT NULL,
+  starts_at TEXT NOT NULL,
+  active INTEGER NOT NULL DEFAULT 1,
+  occurrences INTEGER NOT NULL DEFAULT 0,
+  last_occurrence_at TEXT,
+  last_task_id TEXT,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_recurrences_active ON recurrences (active)`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN due_at TIMESTAMPTZ`,
+			`ALTER TABLE tasks ADD COLUMN recurrence_id UUID`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_recurrence_occurrence ON tasks (recurrence_id, due_at) WHERE recurrence_id IS NOT NULL`,
+			`
+CREATE TABLE IF NOT EXISTS recurrences (
+  id TEXT PRIMARY KEY,
+  project TEXT NOT NULL,
+  title TEXT NOT NULL,
+  description TEXT NOT NULL,
+  rule TEXT NOT NULL,
+  timezone TEXT NOT NULL,
+  starts_at TEXT NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  occurrences INTEGER NOT NULL DEFAULT 0,
+  last_occurrence_at TEXT,
+  last_task_id TEXT,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_recurrences_active ON recurrences (active)`,
+		},
+	},
+	{
+		version: 4,
+		name:    "jobs",
+		statements: []string{
+			// run_at and locked_until are unix milliseconds so that the
+			// scheduler's range queries compare numerically.
+			`
+CREATE TABLE IF NOT EXISTS jobs (
+  id TEXT PRIMARY KEY,
+  kind TEXT NOT NULL,
+  key TEXT NOT NULL UNIQUE,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  max_attempts INTEGER NOT NULL,
+  run_at INTEGER NOT NULL,
+  locked_until INTEGER,
+  last_error TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_jobs_status_run_at ON jobs (status, run_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_due_at ON tasks (due_at) WHERE due_at IS NOT NULL`,
+		},
+		postgres: []string{
+			`
+CREATE TABLE IF NOT EXISTS jobs (
+  id TEXT PRIMARY KEY,
+  kind TEXT NOT NULL,
+  key TEXT NOT NULL UNIQUE,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  max_attempts INTEGER NOT NULL,
+  run_at BIGINT NOT NULL,
+  locked_until BIGINT,
+  last_error TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_jobs_status_run_at ON jobs (status, run_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_due_at ON tasks (due_at) WHERE due_at IS NOT NULL`,
+		},
+	},
+	{
+		version: 5,
+		name:    "assignees_comments_notifications",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN assignee TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_assignee ON tasks (assignee) WHERE assignee != ''`,
+			`
+CREATE TABLE IF NOT EXISTS comments (
+  id TEXT PRIMARY KEY,
+  task_id TEXT NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+  author TEXT NOT NULL,
+  body TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_comments_task_id ON comments (task_id, created_at)`,
+			`
+CREATE TABLE IF NOT EXISTS notification_preferences (
+  user TEXT PRIMARY KEY,
+  email TEXT NOT NULL,
+  email_enabled INTEGER NOT NULL,
+  events TEXT NOT NULL,
+  digest TEXT NOT NULL,
+  last_digest_at TEXT,
+  updated_at TEXT NOT NULL
+);
+`,
+			`
+CREATE TABLE IF NOT EXISTS notification_digest_items (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user TEXT NOT NULL,
+  type TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_notification_digest_items_user ON notification_digest_items (user, id)`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN assignee TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_assignee ON tasks (assignee) WHERE assignee != ''`,
+			`
+CREATE TABLE IF NOT EXISTS comments (
+  id TEXT PRIMARY KEY,
+  task_id UUID NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+  author TEXT NOT NULL,
+  body TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_comments_task_id ON comments (task_id, created_at)`,
+			`
+CREATE TABLE IF NOT EXISTS notification_preferences (
+  "user" TEXT PRIMARY KEY,
+  email TEXT NOT NULL,
+  email_enabled BOOLEAN NOT NULL,
+  events TEXT NOT NULL,
+  digest TEXT NOT NULL,
+  last_digest_at TEXT,
+  updated_at TEXT NOT NULL
+);
+`,
+			`
+CREATE TABLE IF NOT EXISTS notification_digest_items (
+  id BIGSERIAL PRIMARY KEY,
+  "user" TEXT NOT NULL,
+  type TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_notification_digest_items_user ON notification_digest_items ("user", id)`,
+		},
+	},
+	{
+		version: 6,
+		name:    "webhooks",
+		statements: []string{
+			`
+CREATE TABLE IF NOT EXISTS webhooks (
+  id TEXT PRIMARY KEY,
+  url TEXT NOT NULL,
+  events TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  active INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+`,
+			`
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id TEXT PRIMARY KEY,
+  webhook_id TEXT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+  event_type TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  response_status INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  redelivery_of TEXT,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  delivered_at TEXT
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, created_at)`,
+			`
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  delivery_id TEXT NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+  attempt INTEGER NOT NULL,
+  response_status INTEGER NOT NULL,
+  response_body TEXT NOT NULL,
+  error TEXT NOT NULL,
+  duration_ms INTEGER NOT NULL,
+  created_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts (delivery_id, id)`,
+		},
+		postgres: []string{
+			`
+CREATE TABLE IF NOT EXISTS webhooks (
+  id TEXT PRIMARY KEY,
+  url TEXT NOT NULL,
+  events TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  active BOOLEAN NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+`,
+			`
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id TEXT PRIMARY KEY,
+  webhook_id TEXT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+  event_type TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  response_status INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  redelivery_of TEXT,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  delivered_at TEXT
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, created_at)`,
+			`
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+  id BIGSERIAL PRIMARY KEY,
+  delivery_id TEXT NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+  attempt INTEGER NOT NULL,
+  response_status INTEGER NOT NULL,
+  response_body TEXT NOT NULL,
+  error TEXT NOT NULL,
+  duration_ms BIGINT NOT NULL,
+  created_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts (delivery_id, id)`,
+		},
+	},
+	{
+		version: 7,
+		name:    "event_log",
+		statements: []string{
+			`
+CREATE TABLE IF NOT EXISTS event_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  type TEXT NOT NULL,
+  task_id TEXT NOT NULL,
+  project TEXT NOT NULL,
+  status TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  occurred_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_event_log_occurred_at ON event_log (occurred_at)`,
+		},
+		postgres: []string{
+			`
+CREATE TABLE IF NOT EXISTS event_log (
+  id BIGSERIAL PRIMARY KEY,
+  type TEXT NOT NULL,
+  task_id TEXT NOT NULL,
+  project TEXT NOT NULL,
+  status TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  occurred_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_event_log_occurred_at ON event_log (occurred_at)`,
+		},
+	},
+	{
+		version: 8,
+		name:    "outbox",
+		statements: []string{
+			`
+CREATE TABLE IF NOT EXISTS outbox (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  type TEXT NOT NULL,
+  task_id TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_outbox_created_at ON outbox (created_at)`,
+			`
+CREATE TABLE IF NOT EXISTS outbox_deliveries (
+  outbox_id INTEGER NOT NULL REFERENCES outbox (id) ON DELETE CASCADE,
+  sink TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  retry_at INTEGER,
+  updated_at TEXT NOT NULL,
+  PRIMARY KEY (outbox_id, sink)
+);
+`,
+		},
+		postgres: []string{
+			`
+CREATE TABLE IF NOT EXISTS outbox (
+  id BIGSERIAL PRIMARY KEY,
+  type TEXT NOT NULL,
+  task_id TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+`,
+			`CREATE INDEX IF NOT EXISTS idx_outbox_created_at ON outbox (created_at)`,
+			`
+CREATE TABLE IF NOT EXISTS outbox_deliveries (
+  outbox_id BIGINT NOT NULL REFERENCES outbox (id) ON DELETE CASCADE,
+  sink TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  retry_at BIGINT,
+  updated_at TEXT NOT NULL,
+  PRIMARY KEY (outbox_id, sink)
+);
+`,
+		},
+	},
+	{
+		version: 9,
+		name:    "task_list_indexes",
+		// Task lists are ordered by creation, newest first, and most often
+		// filtered by status.
+		statements: []string{
+			`CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks (created_at DESC)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_status_created_at ON tasks (status, created_at DESC)`,
+		},
+	},
+	{
+		version: 10,
+		name:    "task_epoch_timestamps",
+		run:     migrateTaskTimestamps,
+	},
+	{
+		version: 11,
+		name:    "encryption_key_ids",
+		// The ID of the key that a row's encrypted fields are sealed under;
+		// empty for plaintext rows.
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN key_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE comments ADD COLUMN key_id TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 12,
+		name:    "task_external_ids",
+		// The ID of a task in the system it was imported from, which
+		// repeated imports update the task by.
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN external_id TEXT`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_external_id ON tasks (external_id) WHERE external_id IS NOT NULL`,
+		},
+	},
+	{
+		version: 13,
+		name:    "calendar_feeds",
+		statements: []string{
+			`
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  project TEXT NOT NULL,
+  assignee TEXT NOT NULL,
+  timezone TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  created_at TEXT NOT NULL
+);
+`,
+		},
+	},
+	{
+		version: 14,
+		name:    "payload_key_ids",
+		// Event, webhook, job and digest payloads contain task descriptions
+		// and comment bodies, so they are encrypted like them.
+		statements: []string{
+			`ALTER TABLE outbox ADD COLUMN key_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE event_log ADD COLUMN key_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE webhook_deliveries ADD COLUMN key_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE jobs ADD COLUMN key_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE notification_digest_items ADD COLUMN key_id TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 15,
+		name:    "task_sequences",
+		// SEQUENCE used to be the seconds between a task's creation and
+		// last update; counting on from there keeps it from going back for
+		// calendars that have the task already.
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0`,
+			`UPDATE tasks SET sequence = (updated_at - created_at) / 1000000`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN sequence BIGINT NOT NULL DEFAULT 0`,
+			`UPDATE tasks SET sequence = FLOOR(EXTRACT(EPOCH FROM updated_at - created_at))`,
+		},
+	},
+	{
+		version: 16,
+		name:    "recurrence_key_ids",
+		// Recurrences copy their description into every task they create,
+		// so it is encrypted like a task description.
+		statements: []string{
+			`ALTER TABLE recurrences ADD COLUMN key_id TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+}
+
+// LatestVersion returns the version of the newest migration, the schema
+// version of a database that Run has migrated.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// Run executes all database migrations
+func Run(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	const createVersions = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER PRIMARY KEY,
+  name TEXT NOT NULL,
+  applied_at TEXT NOT NULL
+);
 `
+	if _, err := db.ExecContext(ctx, createVersions); err != nil {
+		return err
+	}
+
+	applied := make(map[int]bool)
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	dialect := database.DialectOf(db)
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := apply(ctx, db, dialect, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func apply(ctx context.Context, db *sql.DB, dialect database.Dialect, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
 
-	_, err := db.ExecContext(ctx, createTable)
-	return err
+	if m.run != nil {
+		if err := m.run(ctx, tx, dialect); err != nil {
+			return err
+		}
+	}
+	for _, statement := range m.statementsFor(dialect) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	const record = `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, dialect.Rebind(record), m.version, m.name, time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"task-manager/internal/database"
+)
+
+func TestMigrateTaskSequences(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "tasks.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	if _, err := db.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL)`); err != nil {
+		t.Fatalf("create schema_migrations: %v", err)
+	}
+	for _, m := range migrations {
+		if m.name == "task_sequences" {
+			break
+		}
+		if err := apply(ctx, db, database.SQLite, m); err != nil {
+			t.Fatalf("migration %d: %v", m.version, err)
+		}
+	}
+
+	// The task was updated 90.5 seconds after its creation, which feeds
+	// wrote as SEQUENCE:90.
+	const insertTask = `
+INSERT INTO tasks (id, project, title, description, status, assignee, created_at, updated_at)
+VALUES ('a', 'default', 'Pay rent', '', 'new', '', 1772355600000000, 1772355690500000)
+`
+	if _, err := db.Exec(insertTask); err != nil {
+		t.Fatalf("insert task: %v", err)
+	}
+	if err := Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	var sequence int64
+	if err := db.QueryRow(`SELECT sequence FROM tasks WHERE id = 'a'`).Scan(&sequence); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if sequence != 90 {
+		t.Fatalf("sequence %d, want 90", sequence)
+	}
+}
@@ -7,46 +7,58 @@ import (
 
 	"github.com/google/uuid"
 
+	"task-manager/internal/database"
 	"task-manager/internal/models"
+	"task-manager/internal/repository"
 )
 
+// sampleTasks are the tasks created by the seed functions, newest first.
+var sampleTasks = []struct {
+	title       string
+	description string
+	status      models.TaskStatus
+}{
+	{"Buy groceries", "Milk, eggs, bread, and vegetables", models.TaskStatusNew},
+	{"Complete project report", "Finish the quarterly project report and submit to manager", models.TaskStatusInProgress},
+	{"Call dentist", "Schedule annual checkup appointment", models.TaskStatusNew},
+	{"Review code changes", "Review pull request #123 for the new feature", models.TaskStatusInProgress},
+	{"Update documentation", "Update API documentation with latest endpoints", models.TaskStatusNew},
+	{"Fix bug in login", "Investigate and fix authentication issue reported by users", models.TaskStatusInProgress},
+	{"Plan team meeting", "Organize agenda and book conference room for next week", models.TaskStatusNew},
+	{"Deploy to staging", "Deploy latest version to staging environment and run smoke tests", models.TaskStatusDone},
+	{"Write unit tests", "Add unit tests for the new service layer", models.TaskStatusInProgress},
+	{"Update dependencies", "Update Go modules and check for security vulnerabilities", models.TaskStatusNew},
+	{"Design new feature", "Create mockups and technical design for user dashboard", models.TaskStatusNew},
+	{"Optimize database queries", "Review and optimize slow queries in task repository", models.TaskStatusInProgress},
+	{"Setup CI/CD pipeline", "Configure GitHub Actions for automated testing and deployment", models.TaskStatusDone},
+	{"Refactor legacy code", "Refactor old authentication module to use new patterns", models.TaskStatusNew},
+	{"Write blog post", "Draft blog post about Go best practices for the company blog", models.TaskStatusNew},
+	{"Conduct code review", "Review and provide feedback on 5 pending pull requests", models.TaskStatusInProgress},
+	{"Setup monitoring", "Configure Prometheus and Grafana for application metrics", models.TaskStatusDone},
+	{"Create API documentation", "Generate OpenAPI spec and publish to documentation site", models.TaskStatusInProgress},
+	{"Implement caching", "Add Redis caching layer for frequently accessed data", models.TaskStatusNew},
+	{"Security audit", "Perform security audit and fix identified vulnerabilities", models.TaskStatusNew},
+	{"Performance testing", "Run load tests and identify bottlenecks", models.TaskStatusInProgress},
+	{"Update README", "Update project README with latest setup instructions", models.TaskStatusDone},
+	{"Setup error tracking", "Integrate Sentry for error tracking and monitoring", models.TaskStatusNew},
+	{"Create user guide", "Write comprehensive user guide for the application", models.TaskStatusNew},
+	{"Backup database", "Create automated backup strategy for production database", models.TaskStatusDone},
+}
+
 // SeedTasks inserts sample tasks into the database
 func SeedTasks(db *sql.DB) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	tasks := []struct {
-		title       string
-		description string
-		status      models.TaskStatus
-	}{
-		{"Buy groceries", "Milk, eggs, bread, and vegetables", models.TaskStatusNew},
-		{"Complete project report", "Finish the quarterly project report and submit to manager", models.TaskStatusInProgress},
-		{"Call dentist", "Schedule annual checkup appointment", models.TaskStatusNew},
-		{"Review code changes", "Review pull request #123 for the new feature", models.TaskStatusInProgress},
-		{"Update documentation", "Update API documentation with latest endpoints", models.TaskStatusNew},
-		{"Fix bug in login", "Investigate and fix authentication issue reported by users", models.TaskStatusInProgress},
-		{"Plan team meeting", "Organize agenda and book conference room for next week", models.TaskStatusNew},
-		{"Deploy to staging", "Deploy latest version to staging environment and run smoke tests", models.TaskStatusDone},
-		{"Write unit tests", "Add unit tests for the new service layer", models.TaskStatusInProgress},
-		{"Update dependencies", "Update Go modules and check for security vulnerabilities", models.TaskStatusNew},
-		{"Design new feature", "Create mockups and technical design for user dashboard", models.TaskStatusNew},
-		{"Optimize database queries", "Review and optimize slow queries in task repository", models.TaskStatusInProgress},
-		{"Setup CI/CD pipeline", "Configure GitHub Actions for automated testing and deployment", models.TaskStatusDone},
-		{"Refactor legacy code", "Refactor old authentication module to use new patterns", models.TaskStatusNew},
-		{"Write blog post", "Draft blog post about Go best practices for the company blog", models.TaskStatusNew},
-		{"Conduct code review", "Review and provide feedback on 5 pending pull requests", models.TaskStatusInProgress},
-		{"Setup monitoring", "Configure Prometheus and Grafana for application metrics", models.TaskStatusDone},
-		{"Create API documentation", "Generate OpenAPI spec and publish to documentation site", models.TaskStatusInProgress},
-		{"Implement caching", "Add Redis caching layer for frequently accessed data", models.TaskStatusNew},
-		{"Security audit", "Perform security audit and fix identified vulnerabilities", models.TaskStatusNew},
-		{"Performance testing", "Run load tests and identify bottlenecks", models.TaskStatusInProgress},
-		{"Update README", "Update project README with latest setup instructions", models.TaskStatusDone},
-		{"Setup error tracking", "Integrate Sentry for error tracking and monitoring", models.TaskStatusNew},
-		{"Create user guide", "Write comprehensive user guide for the application", models.TaskStatusNew},
-		{"Backup database", "Create automated backup strategy for production database", models.TaskStatusDone},
+	dialect := database.DialectOf(db)
+	// Task timestamps are timestamptz on PostgreSQL and unix microseconds on
+	// SQLite.
+	timestamp := func(t time.Time) any {
+		if dialect == database.Postgres {
+			return t
+		}
+		return t.UnixMicro()
 	}
-
 	now := time.Now().UTC()
 	const checkQuery = `SELECT COUNT(*) FROM tasks WHERE title = ?`
 	const insertQuery = `
@@ -54,10 +66,10 @@ INSERT INTO tasks (id, title, description, status, created_at, updated_at)
 VALUES (?, ?, ?, ?, ?, ?)
 `
 
-	for i, task := range tasks {
+	for i, task := range sampleTasks {
 		// Check if a task with this title already exists
 		var count int
-		err := db.QueryRowContext(ctx, checkQuery, task.title).Scan(&count)
+		err := db.QueryRowContext(ctx, dialect.Rebind(checkQuery), task.title).Scan(&count)
 		if err != nil {
 			return err
 		}
@@ -74,13 +86,13 @@ VALUES (?, ?, ?, ?, ?, ?)
 		}
 
 		id := uuid.New()
-		_, err = db.ExecContext(ctx, insertQuery,
+		_, err = db.ExecContext(ctx, dialect.Rebind(insertQuery),
 			id.String(),
 			task.title,
 			task.description,
 			string(task.status),
-			createdAt.Format(time.RFC3339Nano),
-			updatedAt.Format(time.RFC3339Nano),
+			timestamp(createdAt),
+			timestamp(updatedAt),
 		)
 		if err != nil {
 			return err
@@ -89,3 +101,22 @@ VALUES (?, ?, ?, ?, ?, ?)
 
 	return nil
 }
+
+// SeedTaskRepository creates the sample tasks through repo. The repository
+// sets the timestamps, so the tasks are created oldest first to list them in
+// the same order as SeedTasks does.
+func SeedTaskRepository(ctx context.Context, repo repository.TaskRepository) error {
+	for i := len(sampleTasks) - 1; i >= 0; i-- {
+		task := &models.Task{
+			ID:          uuid.New(),
+			Project:     models.DefaultProject,
+			Title:       sampleTasks[i].title,
+			Description: sampleTasks[i].description,
+			Status:      sampleTasks[i].status,
+		}
+		if err := repo.CreateTask(ctx, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"task-manager/internal/database"
+)
+
+// migrateTaskTimestamps converts the created_at, updated_at and due_at
+// columns of tasks on SQLite from RFC 3339 text to unix microseconds.
+// Text only sorted correctly when every value had the same number of
+// fractional digits, which RFC3339Nano doesn't guarantee, and every row
+// had to be parsed when it was read. PostgreSQL stores tasks with
+// timestamptz columns already and only gets the new index.
+//
+// SQLite can't change the type of a column, and rebuilding the table would
+// cascade to the comments of every task, so the values are converted into
+// new columns that then replace the old ones.
+func migrateTaskTimestamps(ctx context.Context, tx *sql.Tx, dialect database.Dialect) error {
+	if dialect == database.Postgres {
+		_, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_tasks_project_created_at ON tasks (project, created_at DESC)`)
+		return err
+	}
+	for _, statement := range []string{
+		// Columns in an index can't be dropped.
+		`DROP INDEX IF EXISTS idx_tasks_created_at`,
+		`DROP INDEX IF EXISTS idx_tasks_status_created_at`,
+		`DROP INDEX IF EXISTS idx_tasks_due_at`,
+		`DROP INDEX IF EXISTS idx_tasks_recurrence_occurrence`,
+		// Added NOT NULL columns need a default; every row gets a value below.
+		`ALTER TABLE tasks ADD COLUMN created_at_us INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN updated_at_us INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN due_at_us INTEGER`,
+	} {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	type taskTimes struct {
+		id                   string
+		createdAt, updatedAt string
+		dueAt                sql.NullString
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT id, created_at, updated_at, due_at FROM tasks`)
+	if err != nil {
+		return err
+	}
+	var tasks []taskTimes
+	for rows.Next() {
+		var task taskTimes
+		if err := rows.Scan(&task.id, &task.createdAt, &task.updatedAt, &task.dueAt); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	update, err := tx.PrepareContext(ctx, `UPDATE tasks SET created_at_us = ?, updated_at_us = ?, due_at_us = ? WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer func(update *sql.Stmt) {
+		_ = update.Close()
+	}(update)
+	for _, task := range tasks {
+		createdAt, err := time.Parse(time.RFC3339Nano, task.createdAt)
+		if err != nil {
+			return fmt.Errorf("task %s: created_at: %w", task.id, err)
+		}
+		updatedAt, err := time.Parse(time.RFC3339Nano, task.updatedAt)
+		if err != nil {
+			return fmt.Errorf("task %s: updated_at: %w", task.id, err)
+		}
+		var dueAt sql.NullInt64
+		if task.dueAt.Valid {
+			t, err := time.Parse(time.RFC3339Nano, task.dueAt.String)
+			if err != nil {
+				return fmt.Errorf("task %s: due_at: %w", task.id, err)
+			}
+			dueAt = sql.NullInt64{Int64: t.UnixMicro(), Valid: true}
+		}
+		if _, err := update.ExecContext(ctx, createdAt.UnixMicro(), updatedAt.UnixMicro(), dueAt, task.id); err != nil {
+			return err
+		}
+	}
+
+	for _, statement := range []string{
+		`ALTER TABLE tasks DROP COLUMN created_at`,
+		`ALTER TABLE tasks DROP COLUMN updated_at`,
+		`ALTER TABLE tasks DROP COLUMN due_at`,
+		`ALTER TABLE tasks RENAME COLUMN created_at_us TO created_at`,
+		`ALTER TABLE tasks RENAME COLUMN updated_at_us TO updated_at`,
+		`ALTER TABLE tasks RENAME COLUMN due_at_us TO due_at`,
+		`CREATE INDEX idx_tasks_created_at ON tasks (created_at DESC)`,
+		`CREATE INDEX idx_tasks_status_created_at ON tasks (status, created_at DESC)`,
+		`CREATE INDEX idx_tasks_project_created_at ON tasks (project, created_at DESC)`,
+		`CREATE INDEX idx_tasks_due_at ON tasks (due_at) WHERE due_at IS NOT NULL`,
+		`CREATE UNIQUE INDEX idx_tasks_recurrence_occurrence ON tasks (recurrence_id, due_at) WHERE recurrence_id IS NOT NULL`,
+	} {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"task-manager/internal/database"
+)
+
+func TestMigrateTaskTimestamps(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "tasks.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	if _, err := db.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL)`); err != nil {
+		t.Fatalf("create schema_migrations: %v", err)
+	}
+	for _, m := range migrations {
+		if m.name == "task_epoch_timestamps" {
+			break
+		}
+		if err := apply(ctx, db, database.SQLite, m); err != nil {
+			t.Fatalf("migration %d: %v", m.version, err)
+		}
+	}
+
+	// RFC3339Nano drops trailing zeros, so "…:00Z" sorted after "…:00.5Z"
+	// as text although it is earlier.
+	const insertTask = `
+INSERT INTO tasks (id, project, title, description, status, assignee, due_at, created_at, updated_at)
+VALUES (?, 'default', ?, '', 'new', '', ?, ?, ?)
+`
+	for _, task := range []struct{ id, title, dueAt, createdAt string }{
+		{"a", "Earlier", "2026-03-01T09:30:00Z", "2026-03-01T09:00:00Z"},
+		{"b", "Later", "", "2026-03-01T09:00:00.5Z"},
+		{"c", "Latest", "2026-03-02T09:30:00.123456789+01:00", "2026-03-01T09:00:01.25Z"},
+	} {
+		dueAt := sql.NullString{String: task.dueAt, Valid: task.dueAt != ""}
+		if _, err := db.Exec(insertTask, task.id, task.title, dueAt, task.createdAt, task.createdAt); err != nil {
+			t.Fatalf("insert task: %v", err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO comments (id, task_id, author, body, created_at) VALUES ('c1', 'a', 'ann', 'Done?', '2026-03-01T10:00:00Z')`); err != nil {
+		t.Fatalf("insert comment: %v", err)
+	}
+
+	if err := Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT id, typeof(created_at), created_at, updated_at, due_at FROM tasks ORDER BY created_at DESC`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+	var ids string
+	for rows.Next() {
+		var id, typ string
+		var createdAt, updatedAt int64
+		var dueAt sql.NullInt64
+		if err := rows.Scan(&id, &typ, &createdAt, &updatedAt, &dueAt); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		if typ != "integer" || createdAt != updatedAt {
+			t.Fatalf("task %s: created_at is %s %d, updated_at %d", id, typ, createdAt, updatedAt)
+		}
+		ids += id
+		switch id {
+		case "a":
+			if want := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC).UnixMicro(); dueAt.Int64 != want {
+				t.Fatalf("task a: due_at %d, want %d", dueAt.Int64, want)
+			}
+		case "b":
+			if dueAt.Valid {
+				t.Fatalf("task b: due_at %d, want NULL", dueAt.Int64)
+			}
+		case "c":
+			if want := time.Date(2026, 3, 2, 8, 30, 0, 123456000, time.UTC).UnixMicro(); dueAt.Int64 != want {
+				t.Fatalf("task c: due_at %d, want %d", dueAt.Int64, want)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("rows: %v", err)
+	}
+	if ids != "cba" {
+		t.Fatalf("order %q, want newest first", ids)
+	}
+
+	var comments int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM comments`).Scan(&comments); err != nil || comments != 1 {
+		t.Fatalf("comments: %d, %v", comments, err)
+	}
+	var index string
+	if err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'index' AND name = 'idx_tasks_recurrence_occurrence'`).Scan(&index); err != nil {
+		t.Fatalf("recurrence index: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// ActivityKind is what happened to a task in an activity entry.
+type ActivityKind string
+
+const (
+	ActivityCreated       ActivityKind = "created"
+	ActivityStatusChanged ActivityKind = "status_changed"
+	// ActivityCompleted is a status change into a status of the done
+	// category.
+	ActivityCompleted ActivityKind = "completed"
+)
+
+func (k ActivityKind) Valid() bool {
+	switch k {
+	case ActivityCreated, ActivityStatusChanged, ActivityCompleted:
+		return true
+	}
+	return false
+}
+
+// Activity is a change of a task as it is shown in activity feeds. ID is
+// the ID of the event log entry it comes from, so it never changes.
+type Activity struct {
+	ID   int64        `json:"id"`
+	Kind ActivityKind `json:"kind"`
+	// Task is the task as it was after the change.
+	Task *Task `json:"task"`
+	// From and To are the names of the statuses of a status change.
+	From       string    `json:"from,omitempty"`
+	To         string    `json:"to,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CalendarFeed is an iCalendar subscription to the tasks with a due date of
+// a project, of an assignee, or of an assignee in a project. The token is
+// the secret part of the feed's path; both are only returned when the feed
+// is created, as just a hash of the token is stored.
+type CalendarFeed struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Project   string    `json:"project,omitempty"`
+	Assignee  string    `json:"assignee,omitempty"`
+	Timezone  string    `json:"timezone"`
+	Token     string    `json:"token,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateCalendarFeedInput struct {
+	Name     string `json:"name"`
+	Project  string `json:"project"`
+	Assignee string `json:"assignee"`
+	Timezone string `json:"timezone"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventLogEntry is a task event persisted so that event streams can be
+// resumed. IDs are assigned in publication order and never reused.
+// Payload is the event as it is sent to clients.
+type EventLogEntry struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	TaskID     uuid.UUID       `json:"task_id"`
+	Project    string          `json:"project"`
+	Status     TaskStatus      `json:"status"`
+	Payload    json.RawMessage `json:"payload"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
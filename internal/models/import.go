@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ImportTaskInput is a task of a bulk import. A task whose ExternalID is
+// taken already updates the task with that external ID. Description,
+// Assignee and DueAt are nil when the source has no such column, and then
+// leave the field of an updated task as it is; ClearDueAt removes its due
+// date.
+type ImportTaskInput struct {
+	ExternalID  string               `json:"external_id"`
+	Project     string               `json:"project"`
+	Title       string               `json:"title"`
+	Description *string              `json:"description"`
+	Status      TaskStatus           `json:"status"`
+	Assignee    *string              `json:"assignee"`
+	DueAt       *time.Time           `json:"due_at"`
+	ClearDueAt  bool                 `json:"clear_due_at,omitempty"`
+	Comments    []ImportCommentInput `json:"comments,omitempty"`
+}
+
+// ImportCommentInput is a comment on a task of a bulk import. A comment
+// with the author and body of one the task has already is skipped.
+type ImportCommentInput struct {
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+// ImportReport is the outcome of a bulk import. Tasks are only imported if
+// no row has errors; Created, Updated and Comments count what was, or in a
+// dry run would have been, imported.
+type ImportReport struct {
+	DryRun   bool          `json:"dry_run"`
+	Imported bool          `json:"imported"`
+	Rows     int           `json:"rows"`
+	Created  int           `json:"created"`
+	Updated  int           `json:"updated"`
+	Comments int           `json:"comments"`
+	Errors   []ImportError `json:"errors"`
+}
+
+// ImportError is a problem with a row of an import.
+type ImportError struct {
+	Line       int    `json:"line"`
+	ExternalID string `json:"external_id,omitempty"`
+	Message    string `json:"message"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"net/mail"
+	"time"
+)
+
+type DigestMode string
+
+const (
+	DigestModeNone   DigestMode = "none"
+	DigestModeHourly DigestMode = "hourly"
+	DigestModeDaily  DigestMode = "daily"
+)
+
+// Period returns how often digests are sent in this mode, or zero if
+// notifications are sent immediately.
+func (m DigestMode) Period() time.Duration {
+	switch m {
+	case DigestModeHourly:
+		return time.Hour
+	case DigestModeDaily:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// NotificationPreferences are the per-user notification settings. Users are
+// identified by the same string that is stored as a task's assignee. An
+// empty Events list means the user wants every notification type.
+type NotificationPreferences struct {
+	User         string     `json:"user"`
+	Email        string     `json:"email"`
+	EmailEnabled bool       `json:"email_enabled"`
+	Events       []string   `json:"events"`
+	Digest       DigestMode `json:"digest"`
+	LastDigestAt *time.Time `json:"last_digest_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// DefaultNotificationPreferences returns the preferences used for users that
+// haven't configured any: immediate email for every notification type, sent
+// to the user itself if it is an email address.
+func DefaultNotificationPreferences(user string) *NotificationPreferences {
+	preferences := &NotificationPreferences{
+		User:         user,
+		EmailEnabled: true,
+		Events:       []string{},
+		Digest:       DigestModeNone,
+	}
+	if IsBareEmailAddress(user) {
+		preferences.Email = user
+	}
+	return preferences
+}
+
+// IsBareEmailAddress reports whether email is a single email address without
+// a display name or angle brackets, so that it can go into a To header as is.
+func IsBareEmailAddress(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Name == "" && address.Address == email
+}
+
+// Wants reports whether the user subscribed to the notification type.
+func (p *NotificationPreferences) Wants(notificationType string) bool {
+	if len(p.Events) == 0 {
+		return true
+	}
+	for _, event := range p.Events {
+		if event == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+type UpdateNotificationPreferencesInput struct {
+	Email        *string     `json:"email"`
+	EmailEnabled *bool       `json:"email_enabled"`
+	Events       []string    `json:"events"`
+	Digest       *DigestMode `json:"digest"`
+}
+
+// DigestItem is a notification held back for a user's next digest.
+type DigestItem struct {
+	ID        int64     `json:"id"`
+	User      string    `json:"user"`
+	Type      string    `json:"type"`
+	Payload   []byte    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OutboxDeliveryStatus string
+
+const (
+	OutboxDeliveryRetrying  OutboxDeliveryStatus = "retrying"
+	OutboxDeliveryDelivered OutboxDeliveryStatus = "delivered"
+	OutboxDeliveryDead      OutboxDeliveryStatus = "dead"
+)
+
+// OutboxMessage is an event stored in the same transaction as the change it
+// describes, waiting to be relayed to the event sinks. Payload is the
+// encoded event.
+//
+// Attempts and RetryAt describe the delivery to the sink the message was
+// listed for.
+type OutboxMessage struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	TaskID    uuid.UUID       `json:"task_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	Attempts  int             `json:"attempts"`
+	RetryAt   *time.Time      `json:"retry_at,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Recurrence is a task template together with an RRULE. Every occurrence of
+// the rule is materialised as a regular task whose due date is the
+// occurrence time.
+type Recurrence struct {
+	ID          uuid.UUID `json:"id"`
+	Project     string    `json:"project"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Rule        string    `json:"rule"`
+	Timezone    string    `json:"timezone"`
+	StartsAt    time.Time `json:"starts_at"`
+	Active      bool      `json:"active"`
+	// Occurrences is the number of occurrences materialised so far; LastOccurrenceAt
+	// and LastTaskID describe the most recent one.
+	Occurrences      int        `json:"occurrences"`
+	LastOccurrenceAt *time.Time `json:"last_occurrence_at,omitempty"`
+	LastTaskID       *uuid.UUID `json:"last_task_id,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+type CreateRecurrenceInput struct {
+	Project     string    `json:"project"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Rule        string    `json:"rule"`
+	Timezone    string    `json:"timezone"`
+	StartsAt    time.Time `json:"starts_at"`
+}
@@ -15,21 +15,38 @@ const (
 )
 
 type Task struct {
-	ID          uuid.UUID  `json:"id"`
-	Title       string     `json:"title"`
-	Description string     `json:"description"`
-	Status      TaskStatus `json:"status"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID           uuid.UUID  `json:"id"`
+	ExternalID   string     `json:"external_id,omitempty"`
+	Project      string     `json:"project"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Status       TaskStatus `json:"status"`
+	Assignee     string     `json:"assignee,omitempty"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	RecurrenceID *uuid.UUID `json:"recurrence_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	// Sequence counts the updates of the task. iCalendar feeds and CalDAV
+	// write it as SEQUENCE, which clients compare to see a newer version.
+	Sequence int64 `json:"-"`
 }
 
 type CreateTaskInput struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	ExternalID  string     `json:"external_id"`
+	Project     string     `json:"project"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Assignee    string     `json:"assignee"`
+	DueAt       *time.Time `json:"due_at"`
 }
 
+// UpdateTaskInput changes the fields that aren't nil. A nil DueAt keeps
+// the due date unless ClearDueAt removes it.
 type UpdateTaskInput struct {
 	Title       *string     `json:"title"`
 	Description *string     `json:"description"`
 	Status      *TaskStatus `json:"status"`
+	Assignee    *string     `json:"assignee"`
+	DueAt       *time.Time  `json:"due_at"`
+	ClearDueAt  bool        `json:"clear_due_at,omitempty"`
 }
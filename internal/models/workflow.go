@@ -0,0 +1,92 @@
+package models
+
+// DefaultProject is used for tasks created without an explicit project.
+const DefaultProject = "default"
+
+// StatusCategory groups workflow statuses so that features which only care
+// about "is it finished?" don't have to know every custom status.
+type StatusCategory string
+
+const (
+	StatusCategoryTodo   StatusCategory = "todo"
+	StatusCategoryActive StatusCategory = "active"
+	StatusCategoryDone   StatusCategory = "done"
+)
+
+func (c StatusCategory) Valid() bool {
+	switch c {
+	case StatusCategoryTodo, StatusCategoryActive, StatusCategoryDone:
+		return true
+	}
+	return false
+}
+
+type WorkflowStatus struct {
+	Key      TaskStatus     `json:"key"`
+	Name     string         `json:"name"`
+	Category StatusCategory `json:"category"`
+}
+
+type WorkflowTransition struct {
+	From TaskStatus `json:"from"`
+	To   TaskStatus `json:"to"`
+}
+
+// Workflow describes the statuses available in a project and which
+// transitions between them are allowed.
+type Workflow struct {
+	Project       string               `json:"project"`
+	InitialStatus TaskStatus           `json:"initial_status"`
+	Statuses      []WorkflowStatus     `json:"statuses"`
+	Transitions   []WorkflowTransition `json:"transitions"`
+}
+
+// DefaultWorkflow returns the built-in new -> in_progress -> done workflow
+// used by projects that haven't configured their own.
+func DefaultWorkflow(project string) *Workflow {
+	return &Workflow{
+		Project:       project,
+		InitialStatus: TaskStatusNew,
+		Statuses: []WorkflowStatus{
+			{Key: TaskStatusNew, Name: "New", Category: StatusCategoryTodo},
+			{Key: TaskStatusInProgress, Name: "In progress", Category: StatusCategoryActive},
+			{Key: TaskStatusDone, Name: "Done", Category: StatusCategoryDone},
+		},
+		Transitions: []WorkflowTransition{
+			{From: TaskStatusNew, To: TaskStatusInProgress},
+			{From: TaskStatusNew, To: TaskStatusDone},
+			{From: TaskStatusInProgress, To: TaskStatusNew},
+			{From: TaskStatusInProgress, To: TaskStatusDone},
+			{From: TaskStatusDone, To: TaskStatusInProgress},
+		},
+	}
+}
+
+func (w *Workflow) Status(key TaskStatus) (WorkflowStatus, bool) {
+	for _, status := range w.Statuses {
+		if status.Key == key {
+			return status, true
+		}
+	}
+	return WorkflowStatus{}, false
+}
+
+// CanTransition reports whether a task may move from one status to another.
+// Staying in the same status is always allowed.
+func (w *Workflow) CanTransition(from, to TaskStatus) bool {
+	if from == to {
+		return true
+	}
+	for _, transition := range w.Transitions {
+		if transition.From == from && transition.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+type UpdateWorkflowInput struct {
+	InitialStatus TaskStatus           `json:"initial_status"`
+	Statuses      []WorkflowStatus     `json:"statuses"`
+	Transitions   []WorkflowTransition `json:"transitions"`
+}
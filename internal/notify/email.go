@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+// EmailNotifier emails notifications to the task's assignee, honouring the
+// assignee's notification preferences. Users with a digest mode get their
+// notifications batched and sent by FlushDigests.
+type EmailNotifier struct {
+	mailer        Mailer
+	from          string
+	notifications repository.NotificationRepository
+}
+
+func NewEmailNotifier(mailer Mailer, from string, notifications repository.NotificationRepository) *EmailNotifier {
+	return &EmailNotifier{mailer: mailer, from: from, notifications: notifications}
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, notification Notification) error {
+	user := notification.Task.Assignee
+	if user == "" {
+		return nil
+	}
+	// Nobody needs an email about their own comment.
+	if notification.Comment != nil && notification.Comment.Author == user {
+		return nil
+	}
+	preferences, err := n.preferences(ctx, user)
+	if err != nil {
+		return err
+	}
+	if !preferences.EmailEnabled || preferences.Email == "" || !preferences.Wants(string(notification.Type)) {
+		return nil
+	}
+
+	if preferences.Digest.Period() > 0 {
+		payload, err := json.Marshal(notification)
+		if err != nil {
+			return err
+		}
+		return n.notifications.AddDigestItem(ctx, &models.DigestItem{
+			User:    user,
+			Type:    string(notification.Type),
+			Payload: payload,
+		})
+	}
+
+	subject, text, html, err := Render(notification)
+	if err != nil {
+		return err
+	}
+	return n.mailer.Send(ctx, Message{
+		From:    n.from,
+		To:      []string{preferences.Email},
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	})
+}
+
+// FlushDigests sends one digest email per user whose digest period has
+// elapsed. Items are only deleted after the email was sent, so a failure
+// leads to a resend rather than a lost digest.
+func (n *EmailNotifier) FlushDigests(ctx context.Context, now time.Time) (int, error) {
+	users, err := n.notifications.ListDigestUsers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	var errs []error
+	for _, user := range users {
+		ok, err := n.flushDigest(ctx, user, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("digest for %s: %w", user, err))
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+func (n *EmailNotifier) flushDigest(ctx context.Context, user string, now time.Time) (bool, error) {
+	items, err := n.notifications.ListDigestItems(ctx, user)
+	if err != nil || len(items) == 0 {
+		return false, err
+	}
+	preferences, err := n.preferences(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	// Items keep waiting for a full period, counted from the last digest or,
+	// for the first one, from the oldest item. Items left over after a user
+	// switched back to immediate delivery go out right away.
+	if period := preferences.Digest.Period(); period > 0 {
+		since := items[0].CreatedAt
+		if preferences.LastDigestAt != nil {
+			since = *preferences.LastDigestAt
+		}
+		if now.Sub(since) < period {
+			return false, nil
+		}
+	}
+
+	lastID := items[len(items)-1].ID
+	if preferences.EmailEnabled && preferences.Email != "" {
+		notifications := make([]Notification, 0, len(items))
+		for _, item := range items {
+			var notification Notification
+			if err := json.Unmarshal(item.Payload, &notification); err != nil {
+				return false, fmt.Errorf("decode digest item %d: %w", item.ID, err)
+			}
+			notifications = append(notifications, notification)
+		}
+		subject, text, html, err := RenderDigest(notifications)
+		if err != nil {
+			return false, err
+		}
+		if err := n.mailer.Send(ctx, Message{
+			From:    n.from,
+			To:      []string{preferences.Email},
+			Subject: subject,
+			Text:    text,
+			HTML:    html,
+		}); err != nil {
+			return false, err
+		}
+	}
+	if err := n.notifications.DeleteDigestItems(ctx, user, lastID); err != nil {
+		return false, err
+	}
+	if err := n.notifications.MarkDigestSent(ctx, user, now); err != nil && !errors.Is(err, repository.ErrPreferencesNotFound) {
+		return false, err
+	}
+	return true, nil
+}
+
+func (n *EmailNotifier) preferences(ctx context.Context, user string) (*models.NotificationPreferences, error) {
+	preferences, err := n.notifications.GetPreferences(ctx, user)
+	if errors.Is(err, repository.ErrPreferencesNotFound) {
+		return models.DefaultNotificationPreferences(user), nil
+	}
+	return preferences, err
+}
@@ -0,0 +1,302 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+
+	"task-manager/internal/migrations"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+// smtpStandIn is a minimal in-process SMTP server that records what it
+// receives. It supports just enough of the protocol for net/smtp.
+type smtpStandIn struct {
+	listener net.Listener
+	mu       sync.Mutex
+	auth     []string
+	from     []string
+	rcpt     []string
+	data     []string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &smtpStandIn{listener: listener}
+	t.Cleanup(func() { _ = listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (s *smtpStandIn) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+
+	reply("220 stand-in ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		s.mu.Lock()
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-stand-in")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "AUTH"):
+			s.auth = append(s.auth, line)
+			reply("235 authenticated")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.from = append(s.from, line[len("MAIL FROM:"):])
+			reply("250 ok")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.rcpt = append(s.rcpt, line[len("RCPT TO:"):])
+			reply("250 ok")
+		case command == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					s.mu.Unlock()
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.data = append(s.data, data.String())
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			s.mu.Unlock()
+			return
+		default:
+			reply("250 ok")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func decodeQuotedPrintable(t *testing.T, s string) string {
+	t.Helper()
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(s)))
+	if err != nil {
+		t.Fatalf("decode quoted-printable: %v", err)
+	}
+	return string(decoded)
+}
+
+func testTask() *models.Task {
+	dueAt := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	return &models.Task{
+		ID:       uuid.New(),
+		Project:  "home",
+		Title:    "Pay rent",
+		Status:   models.TaskStatusNew,
+		Assignee: "alex@example.com",
+		DueAt:    &dueAt,
+	}
+}
+
+func TestSMTPMailerDeliversToServer(t *testing.T) {
+	server := newSMTPStandIn(t)
+	mailer := NewSMTPMailer(SMTPConfig{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		Username: "user",
+		Password: "secret",
+		TLS:      TLSModeNone,
+	})
+
+	subject, text, html, err := Render(Notification{Type: TypeOverdue, Task: testTask()})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	err = mailer.Send(context.Background(), Message{
+		From:    "task-manager@example.com",
+		To:      []string{"alex@example.com"},
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.auth) != 1 || !strings.HasPrefix(server.auth[0], "AUTH PLAIN") {
+		t.Fatalf("expected AUTH PLAIN, got %v", server.auth)
+	}
+	if len(server.rcpt) != 1 || server.rcpt[0] != "<alex@example.com>" {
+		t.Fatalf("unexpected recipients %v", server.rcpt)
+	}
+	if len(server.data) != 1 {
+		t.Fatalf("expected one message, got %d", len(server.data))
+	}
+	message := decodeQuotedPrintable(t, server.data[0])
+	for _, want := range []string{
+		"Subject: [task-manager] Overdue: Pay rent",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Type: text/html; charset=utf-8",
+		`"Pay rent" was due Wed, 01 May 2024 18:00 UTC and is now overdue.`,
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("message does not contain %q:\n%s", want, message)
+		}
+	}
+}
+
+type recordingMailer struct {
+	messages []Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, message Message) error {
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func newNotificationRepository(t *testing.T) *repository.SQLiteNotificationRepository {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return repository.NewSQLiteNotificationRepository(db)
+}
+
+func TestEmailNotifierHonoursPreferencesAndDigests(t *testing.T) {
+	ctx := context.Background()
+	notifications := newNotificationRepository(t)
+	mailer := &recordingMailer{}
+	notifier := NewEmailNotifier(mailer, "task-manager@example.com", notifications)
+	task := testTask()
+
+	// Default preferences: immediate email to the assignee.
+	if err := notifier.Notify(ctx, Notification{Type: TypeAssigned, Task: task}); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if len(mailer.messages) != 1 || mailer.messages[0].To[0] != "alex@example.com" {
+		t.Fatalf("expected one immediate email, got %+v", mailer.messages)
+	}
+
+	// Comments by the assignee don't notify the assignee.
+	ownComment := &models.Comment{Author: task.Assignee, Body: "on it"}
+	if err := notifier.Notify(ctx, Notification{Type: TypeCommented, Task: task, Comment: ownComment}); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if len(mailer.messages) != 1 {
+		t.Fatalf("expected no email for own comment, got %d", len(mailer.messages))
+	}
+
+	preferences := models.DefaultNotificationPreferences(task.Assignee)
+	preferences.Digest = models.DigestModeHourly
+	if err := notifications.SavePreferences(ctx, preferences); err != nil {
+		t.Fatalf("save preferences: %v", err)
+	}
+	comment := &models.Comment{Author: "sam", Body: "rent went up"}
+	for _, notification := range []Notification{
+		{Type: TypeCommented, Task: task, Comment: comment},
+		{Type: TypeOverdue, Task: task},
+	} {
+		if err := notifier.Notify(ctx, notification); err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+	}
+	if len(mailer.messages) != 1 {
+		t.Fatalf("digest notifications must not be sent immediately")
+	}
+
+	if sent, err := notifier.FlushDigests(ctx, time.Now()); err != nil || sent != 0 {
+		t.Fatalf("flush before period: sent=%d err=%v", sent, err)
+	}
+	if sent, err := notifier.FlushDigests(ctx, time.Now().Add(2*time.Hour)); err != nil || sent != 1 {
+		t.Fatalf("flush after period: sent=%d err=%v", sent, err)
+	}
+	digest := mailer.messages[1]
+	if !strings.Contains(digest.Text, "You have 2 task notifications") || !strings.Contains(digest.Text, "rent went up") {
+		t.Fatalf("unexpected digest body:\n%s", digest.Text)
+	}
+	if sent, _ := notifier.FlushDigests(ctx, time.Now().Add(4*time.Hour)); sent != 0 {
+		t.Fatalf("digest items were not removed after sending")
+	}
+}
+
+func TestMaildirMailer(t *testing.T) {
+	dir := t.TempDir()
+	mailer, err := NewMaildirMailer(dir)
+	if err != nil {
+		t.Fatalf("maildir: %v", err)
+	}
+	if err := mailer.Send(context.Background(), Message{
+		From:    "task-manager@example.com",
+		To:      []string{"alex@example.com"},
+		Subject: "hello",
+		Text:    "body",
+	}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one message in new/, got %d (err=%v)", len(entries), err)
+	}
+}
+
+func TestMessageRejectsLineBreaksInHeaders(t *testing.T) {
+	for _, message := range []Message{
+		{From: "task-manager@example.com", To: []string{"alex@example.com\r\nBcc: sam@example.com"}, Text: "body"},
+		{From: "task-manager@example.com\nBcc: sam@example.com", To: []string{"alex@example.com"}, Text: "body"},
+	} {
+		if _, err := message.Bytes(); err == nil {
+			t.Errorf("expected an error for %q", message)
+		}
+	}
+	data, err := Message{From: "task-manager@example.com", To: []string{"alex@example.com"}, Subject: "line\r\nBcc: sam@example.com", Text: "body"}.Bytes()
+	if err != nil {
+		t.Fatalf("subject: %v", err)
+	}
+	if strings.Contains(string(data), "\r\nBcc:") {
+		t.Fatalf("subject added a header:\n%s", data)
+	}
+}
@@ -0,0 +1,40 @@
+// Package notify defines the pluggable notifiers that deliver task
+// notifications such as due-date reminders.
+package notify
+
+import (
+	"context"
+	"log"
+
+	"task-manager/internal/models"
+)
+
+type Type string
+
+const (
+	TypeDueSoon   Type = "task.due_soon"
+	TypeOverdue   Type = "task.overdue"
+	TypeAssigned  Type = "task.assigned"
+	TypeCommented Type = "comment.created"
+)
+
+type Notification struct {
+	Type    Type            `json:"type"`
+	Task    *models.Task    `json:"task"`
+	Comment *models.Comment `json:"comment,omitempty"`
+}
+
+// Notifier delivers a notification. Deliveries are retried on error, so
+// implementations may see the same notification more than once.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// LogNotifier writes notifications to the process log.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	task := notification.Task
+	log.Printf("notify: %s: task %s %q (project %s)", notification.Type, task.ID, task.Title, task.Project)
+	return nil
+}
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+var subjects = map[Type]string{
+	TypeDueSoon:   "Due soon: {{.Task.Title}}",
+	TypeOverdue:   "Overdue: {{.Task.Title}}",
+	TypeAssigned:  "Assigned to you: {{.Task.Title}}",
+	TypeCommented: "New comment on {{.Task.Title}}",
+}
+
+// summary is the one-line description used in bodies and digests.
+const summary = `{{define "summary"}}` +
+	`{{if eq .Type "task.due_soon"}}"{{.Task.Title}}" is due {{.Task.DueAt.Format "Mon, 02 Jan 2006 15:04 MST"}}.` +
+	`{{else if eq .Type "task.overdue"}}"{{.Task.Title}}" was due {{.Task.DueAt.Format "Mon, 02 Jan 2006 15:04 MST"}} and is now overdue.` +
+	`{{else if eq .Type "task.assigned"}}"{{.Task.Title}}" was assigned to you.` +
+	`{{else if eq .Type "comment.created"}}{{.Comment.Author}} commented on "{{.Task.Title}}".` +
+	`{{else}}"{{.Task.Title}}" changed.{{end}}{{end}}`
+
+const textBody = summary + `{{template "summary" .}}
+{{if .Comment}}
+{{.Comment.Body}}
+{{end}}
+Project: {{.Task.Project}}
+Status:  {{.Task.Status}}
+Task ID: {{.Task.ID}}
+{{with .Task.Description}}
+{{.}}
+{{end}}`
+
+const htmlBody = summary + `<p>{{template "summary" .}}</p>
+{{if .Comment}}<blockquote>{{.Comment.Body}}</blockquote>{{end}}
+<table>
+<tr><th align="left">Project</th><td>{{.Task.Project}}</td></tr>
+<tr><th align="left">Status</th><td>{{.Task.Status}}</td></tr>
+<tr><th align="left">Task ID</th><td>{{.Task.ID}}</td></tr>
+</table>
+{{with .Task.Description}}<p>{{.}}</p>{{end}}`
+
+const digestTextBody = summary + `You have {{len .}} task notifications:
+{{range .}}
+- {{template "summary" .}}{{if .Comment}}
+  {{.Comment.Body}}{{end}}{{end}}
+`
+
+const digestHTMLBody = summary + `<p>You have {{len .}} task notifications:</p>
+<ul>
+{{range .}}<li>{{template "summary" .}}{{if .Comment}}<blockquote>{{.Comment.Body}}</blockquote>{{end}}</li>
+{{end}}</ul>`
+
+var (
+	textTemplate       = texttemplate.Must(texttemplate.New("text").Parse(textBody))
+	htmlTemplate       = htmltemplate.Must(htmltemplate.New("html").Parse(htmlBody))
+	digestTextTemplate = texttemplate.Must(texttemplate.New("digest-text").Parse(digestTextBody))
+	digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest-html").Parse(digestHTMLBody))
+)
+
+const subjectPrefix = "[task-manager] "
+
+// Render builds the subject, plain-text and HTML bodies of a notification
+// email.
+func Render(notification Notification) (subject, text, html string, err error) {
+	subjectSource, ok := subjects[notification.Type]
+	if !ok {
+		subjectSource = "Task updated: {{.Task.Title}}"
+	}
+	subjectTemplate, err := texttemplate.New("subject").Parse(subjectSource)
+	if err != nil {
+		return "", "", "", err
+	}
+	var subjectBuf, textBuf, htmlBuf bytes.Buffer
+	if err := subjectTemplate.Execute(&subjectBuf, notification); err != nil {
+		return "", "", "", err
+	}
+	if err := textTemplate.Execute(&textBuf, notification); err != nil {
+		return "", "", "", err
+	}
+	if err := htmlTemplate.Execute(&htmlBuf, notification); err != nil {
+		return "", "", "", err
+	}
+	return subjectPrefix + strings.TrimSpace(subjectBuf.String()), textBuf.String(), htmlBuf.String(), nil
+}
+
+// RenderDigest builds a single email summarising several notifications.
+func RenderDigest(notifications []Notification) (subject, text, html string, err error) {
+	var textBuf, htmlBuf bytes.Buffer
+	if err := digestTextTemplate.Execute(&textBuf, notifications); err != nil {
+		return "", "", "", err
+	}
+	if err := digestHTMLTemplate.Execute(&htmlBuf, notifications); err != nil {
+		return "", "", "", err
+	}
+	subject = subjectPrefix + "Task notification digest"
+	return subject, textBuf.String(), htmlBuf.String(), nil
+}
@@ -0,0 +1,258 @@
+// Package outbox publishes events reliably through the transactional outbox.
+//
+// Services publish events with Relay.Publish in the transaction that stores
+// the change, so an event is recorded if and only if the change commits.
+// The relay then hands the recorded events to every sink in order. A sink's
+// writes and the record of the delivery commit in one transaction, so each
+// sink handles each event exactly once as long as it only writes to the
+// database through the ctx it is given. In-process side effects belong in
+// repository.AfterCommit.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+const (
+	DefaultPollInterval = 5 * time.Second
+	DefaultBatchSize    = 100
+	DefaultMaxAttempts  = 10
+	DefaultBaseBackoff  = time.Second
+	DefaultMaxBackoff   = 10 * time.Minute
+	DefaultRetention    = 24 * time.Hour
+)
+
+type Options struct {
+	// PollInterval is how often the relay looks for messages that are due
+	// for a retry. New messages wake it up right after their commit.
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is how often delivering a message to a sink is attempted
+	// before the relay gives up on it and moves on to the next one.
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Retention is how long settled messages are kept.
+	Retention time.Duration
+}
+
+type sink struct {
+	name   string
+	handle events.Subscriber
+}
+
+// Relay is both the events.Publisher that writes to the outbox and the
+// worker that delivers its messages to the sinks.
+type Relay struct {
+	repo    repository.OutboxRepository
+	tx      repository.Transactor
+	options Options
+	sinks   []*sink
+	wake    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func New(repo repository.OutboxRepository, tx repository.Transactor, options Options) *Relay {
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = DefaultMaxAttempts
+	}
+	if options.BaseBackoff <= 0 {
+		options.BaseBackoff = DefaultBaseBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = DefaultMaxBackoff
+	}
+	if options.Retention <= 0 {
+		options.Retention = DefaultRetention
+	}
+	return &Relay{
+		repo:    repo,
+		tx:      tx,
+		options: options,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// AddSink registers a sink. The name is stored with every delivery, so it
+// must stay stable across releases. It must be called before Start.
+func (r *Relay) AddSink(name string, handle events.Subscriber) {
+	r.sinks = append(r.sinks, &sink{name: name, handle: handle})
+}
+
+// Publish records the event in the outbox. Call it with the ctx of the
+// transaction that stores the change.
+func (r *Relay) Publish(ctx context.Context, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal %s event: %w", event.Type, err)
+	}
+	message := &models.OutboxMessage{
+		Type:    string(event.Type),
+		TaskID:  event.TaskID,
+		Payload: payload,
+	}
+	if err := r.repo.AddMessage(ctx, message); err != nil {
+		return err
+	}
+	repository.AfterCommit(ctx, r.Wake)
+	return nil
+}
+
+// Wake makes a running relay look for new messages right away.
+func (r *Relay) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start launches the relay loop. It stops when ctx is cancelled; Wait
+// blocks until it has returned.
+func (r *Relay) Start(ctx context.Context) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(ctx)
+	}()
+}
+
+// Wait blocks until the loop started by Start has returned.
+func (r *Relay) Wait() {
+	r.wg.Wait()
+}
+
+func (r *Relay) run(ctx context.Context) {
+	ticker := time.NewTicker(r.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			settled, err := r.RunOnce(ctx, time.Now())
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("outbox: %v", err)
+				}
+				break
+			}
+			if settled == 0 {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// RunOnce hands up to one batch of pending messages to every sink and
+// returns how many deliveries were settled. It must not be called
+// concurrently.
+func (r *Relay) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	var total int
+	var errs []error
+	for _, sink := range r.sinks {
+		settled, err := r.runSink(ctx, sink, now)
+		total += settled
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.name, err))
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// runSink delivers pending messages to the sink in ID order. A failed
+// delivery holds back the messages after it until it succeeds or is given
+// up on, so that sinks don't see events out of order. The exception is
+// messages that commit after a later ID was delivered, on PostgreSQL: they
+// are delivered late rather than skipped.
+func (r *Relay) runSink(ctx context.Context, sink *sink, now time.Time) (int, error) {
+	messages, err := r.repo.ListPending(ctx, sink.name, r.options.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	settled := 0
+	for _, message := range messages {
+		if message.RetryAt != nil && message.RetryAt.After(now) {
+			return settled, nil
+		}
+		err := r.deliver(ctx, sink, message)
+		if err == nil || errors.Is(err, repository.ErrOutboxAlreadyDelivered) {
+			settled++
+			continue
+		}
+		if ctx.Err() != nil {
+			return settled, ctx.Err()
+		}
+
+		attempts := message.Attempts + 1
+		var retryAt *time.Time
+		if attempts < r.options.MaxAttempts {
+			next := now.Add(r.backoff(attempts))
+			retryAt = &next
+		}
+		if err := r.repo.RecordFailure(ctx, message.ID, sink.name, attempts, err.Error(), retryAt); err != nil {
+			return settled, err
+		}
+		if retryAt != nil {
+			return settled, nil
+		}
+		log.Printf("outbox: gave up delivering %s message %d to %s after %d attempts: %v", message.Type, message.ID, sink.name, attempts, err)
+		settled++
+	}
+	return settled, nil
+}
+
+func (r *Relay) deliver(ctx context.Context, sink *sink, message *models.OutboxMessage) error {
+	var event events.Event
+	if err := json.Unmarshal(message.Payload, &event); err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+	return r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := sink.handle(ctx, event); err != nil {
+			return err
+		}
+		return r.repo.MarkDelivered(ctx, message.ID, sink.name)
+	})
+}
+
+// Prune deletes messages settled for every sink that are older than the
+// retention period.
+func (r *Relay) Prune(ctx context.Context, now time.Time) (int64, error) {
+	names := make([]string, 0, len(r.sinks))
+	for _, sink := range r.sinks {
+		names = append(names, sink.name)
+	}
+	return r.repo.PruneMessages(ctx, now.Add(-r.options.Retention), names)
+}
+
+// backoff returns the delay before the given attempt is retried: the base
+// delay doubled per attempt, capped, with up to 20% jitter.
+func (r *Relay) backoff(attempt int) time.Duration {
+	delay := r.options.BaseBackoff
+	for i := 1; i < attempt && delay < r.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.options.MaxBackoff {
+		delay = r.options.MaxBackoff
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
@@ -0,0 +1,193 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+
+	"task-manager/internal/events"
+	"task-manager/internal/migrations"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// createTask stores a task and publishes its creation in one transaction,
+// the way the task service does. A non-nil fail makes the transaction roll
+// back after publishing.
+func createTask(t *testing.T, db *sql.DB, relay *Relay, title string, fail error) *models.Task {
+	t.Helper()
+	task := &models.Task{ID: uuid.New(), Project: models.DefaultProject, Title: title, Status: models.TaskStatusNew}
+	err := repository.NewSQLTransactor(db).WithinTx(context.Background(), func(ctx context.Context) error {
+		if err := repository.NewSQLiteTaskRepository(db).CreateTask(ctx, task); err != nil {
+			return err
+		}
+		if err := relay.Publish(ctx, events.NewTaskEvent(events.TaskCreated, task, nil)); err != nil {
+			return err
+		}
+		return fail
+	})
+	if !errors.Is(err, fail) {
+		t.Fatalf("create task: %v", err)
+	}
+	return task
+}
+
+// logSink records the events it receives in the event log, in the relay's
+// transaction, and counts the deliveries that committed.
+type logSink struct {
+	log       *repository.SQLiteEventLogRepository
+	fail      func(event events.Event) error
+	committed []string
+}
+
+func (s *logSink) handle(ctx context.Context, event events.Event) error {
+	if err := s.log.AppendEvent(ctx, &models.EventLogEntry{
+		Type:       string(event.Type),
+		TaskID:     event.TaskID,
+		Project:    event.Project,
+		Status:     event.Task.Status,
+		Payload:    []byte("{}"),
+		OccurredAt: event.OccurredAt,
+	}); err != nil {
+		return err
+	}
+	if s.fail != nil {
+		if err := s.fail(event); err != nil {
+			return err
+		}
+	}
+	repository.AfterCommit(ctx, func() { s.committed = append(s.committed, event.Task.Title) })
+	return nil
+}
+
+func loggedEvents(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	entries, err := repository.NewSQLiteEventLogRepository(db).ListEvents(context.Background(), repository.EventLogFilter{})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	return len(entries)
+}
+
+func TestRelayDeliversCommittedEventsOncePerSink(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	outboxRepository := repository.NewSQLiteOutboxRepository(db)
+	relay := New(outboxRepository, repository.NewSQLTransactor(db), Options{})
+	first := &logSink{log: repository.NewSQLiteEventLogRepository(db)}
+	second := &logSink{log: repository.NewSQLiteEventLogRepository(db)}
+	relay.AddSink("first", first.handle)
+	relay.AddSink("second", second.handle)
+
+	createTask(t, db, relay, "Pay rent", nil)
+	createTask(t, db, relay, "Rolled back", errors.New("boom"))
+	createTask(t, db, relay, "Buy milk", nil)
+
+	settled, err := relay.RunOnce(ctx, time.Now())
+	if err != nil || settled != 4 {
+		t.Fatalf("run once: settled=%d err=%v", settled, err)
+	}
+	for _, sink := range []*logSink{first, second} {
+		if len(sink.committed) != 2 || sink.committed[0] != "Pay rent" || sink.committed[1] != "Buy milk" {
+			t.Fatalf("unexpected deliveries %v", sink.committed)
+		}
+	}
+
+	// Neither running again nor a second relay, as after a restart or in
+	// another process, delivers anything twice.
+	if settled, err := relay.RunOnce(ctx, time.Now()); err != nil || settled != 0 {
+		t.Fatalf("second run: settled=%d err=%v", settled, err)
+	}
+	restarted := New(repository.NewSQLiteOutboxRepository(db), repository.NewSQLTransactor(db), Options{})
+	again := &logSink{log: repository.NewSQLiteEventLogRepository(db)}
+	restarted.AddSink("first", again.handle)
+	if settled, err := restarted.RunOnce(ctx, time.Now()); err != nil || settled != 0 {
+		t.Fatalf("restarted run: settled=%d err=%v", settled, err)
+	}
+	if len(again.committed) != 0 || loggedEvents(t, db) != 4 {
+		t.Fatalf("events were delivered again: %v, %d logged", again.committed, loggedEvents(t, db))
+	}
+	// A relay that lost the race for a message can't record it again, which
+	// rolls its sink's writes back.
+	if err := outboxRepository.MarkDelivered(ctx, 1, "first"); !errors.Is(err, repository.ErrOutboxAlreadyDelivered) {
+		t.Fatalf("expected ErrOutboxAlreadyDelivered, got %v", err)
+	}
+}
+
+func TestRelayRollsBackFailedDeliveriesAndKeepsOrder(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	outboxRepository := repository.NewSQLiteOutboxRepository(db)
+	relay := New(outboxRepository, repository.NewSQLTransactor(db), Options{BaseBackoff: time.Minute, MaxAttempts: 2})
+
+	failures := map[string]int{"Pay rent": 1, "Poison": 2}
+	flaky := &logSink{
+		log: repository.NewSQLiteEventLogRepository(db),
+		fail: func(event events.Event) error {
+			if failures[event.Task.Title] > 0 {
+				failures[event.Task.Title]--
+				return errors.New("sink unavailable")
+			}
+			return nil
+		},
+	}
+	healthy := &logSink{log: repository.NewSQLiteEventLogRepository(db)}
+	relay.AddSink("flaky", flaky.handle)
+	relay.AddSink("healthy", healthy.handle)
+
+	createTask(t, db, relay, "Pay rent", nil)
+	createTask(t, db, relay, "Poison", nil)
+	createTask(t, db, relay, "Buy milk", nil)
+
+	now := time.Now()
+	if _, err := relay.RunOnce(ctx, now); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	// The failed delivery's writes were rolled back and it holds back the
+	// flaky sink, but not the healthy one.
+	if len(flaky.committed) != 0 || len(healthy.committed) != 3 || loggedEvents(t, db) != 3 {
+		t.Fatalf("flaky=%v healthy=%v logged=%d", flaky.committed, healthy.committed, loggedEvents(t, db))
+	}
+	if _, err := relay.RunOnce(ctx, now.Add(time.Second)); err != nil || len(flaky.committed) != 0 {
+		t.Fatalf("retried before the backoff elapsed: %v %v", flaky.committed, err)
+	}
+
+	// The retry succeeds; the poison message fails twice and is given up on
+	// so that the messages after it still get through.
+	for i := 1; i <= 2; i++ {
+		if _, err := relay.RunOnce(ctx, now.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+	}
+	if len(flaky.committed) != 2 || flaky.committed[0] != "Pay rent" || flaky.committed[1] != "Buy milk" {
+		t.Fatalf("unexpected flaky deliveries %v", flaky.committed)
+	}
+	pending, err := outboxRepository.ListPending(ctx, "flaky", 10)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("pending for flaky: %d %v", len(pending), err)
+	}
+
+	// Settled messages are pruned once they are past the retention period.
+	if pruned, err := relay.Prune(ctx, now.Add(DefaultRetention+time.Hour)); err != nil || pruned != 3 {
+		t.Fatalf("prune: pruned=%d err=%v", pruned, err)
+	}
+}
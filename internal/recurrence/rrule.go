@@ -0,0 +1,311 @@
+// Package recurrence implements the subset of RFC 5545 recurrence rules
+// supported for recurring tasks: FREQ=DAILY|WEEKLY|MONTHLY with INTERVAL,
+// BYDAY, COUNT and UNTIL.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// maxPeriods bounds the number of periods scanned when looking for the next
+// occurrence, so a rule that can never match (e.g. BYDAY=5MO in a short
+// month range) can't spin forever.
+const maxPeriods = 100000
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// WeekdayNum is a BYDAY entry. Ordinal is only meaningful for monthly rules:
+// 1 is the first such weekday of the month, -1 the last, 0 every one.
+type WeekdayNum struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []WeekdayNum
+	Count    int
+	Until    *time.Time
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Parse parses an RRULE value such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH".
+// A leading "RRULE:" prefix is accepted.
+func Parse(value string) (Rule, error) {
+	rule := Rule{Interval: 1}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return Rule{}, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return Rule{}, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			switch freq := Frequency(strings.ToUpper(val)); freq {
+			case Daily, Weekly, Monthly:
+				rule.Freq = freq
+			default:
+				return Rule{}, fmt.Errorf("%w: unsupported FREQ %q", ErrInvalidRule, val)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval < 1 {
+				return Rule{}, fmt.Errorf("%w: INTERVAL must be a positive integer", ErrInvalidRule)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count < 1 {
+				return Rule{}, fmt.Errorf("%w: COUNT must be a positive integer", ErrInvalidRule)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return Rule{}, err
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				day, err := parseWeekdayNum(code)
+				if err != nil {
+					return Rule{}, err
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		default:
+			return Rule{}, fmt.Errorf("%w: unsupported part %q", ErrInvalidRule, name)
+		}
+	}
+	if rule.Freq == "" {
+		return Rule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return Rule{}, fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRule)
+	}
+	for _, day := range rule.ByDay {
+		if day.Ordinal != 0 && rule.Freq != Monthly {
+			return Rule{}, fmt.Errorf("%w: ordinal BYDAY is only supported for MONTHLY rules", ErrInvalidRule)
+		}
+	}
+	if rule.Freq == Daily && len(rule.ByDay) > 0 {
+		return Rule{}, fmt.Errorf("%w: BYDAY is not supported for DAILY rules", ErrInvalidRule)
+	}
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102", time.RFC3339} {
+		if until, err := time.Parse(layout, value); err == nil {
+			return until, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: malformed UNTIL %q", ErrInvalidRule, value)
+}
+
+func parseWeekdayNum(code string) (WeekdayNum, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) < 2 {
+		return WeekdayNum{}, fmt.Errorf("%w: malformed BYDAY %q", ErrInvalidRule, code)
+	}
+	weekday, ok := weekdayCodes[code[len(code)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("%w: unknown weekday in BYDAY %q", ErrInvalidRule, code)
+	}
+	day := WeekdayNum{Weekday: weekday}
+	if prefix := code[:len(code)-2]; prefix != "" {
+		ordinal, err := strconv.Atoi(prefix)
+		if err != nil || ordinal == 0 || ordinal < -5 || ordinal > 5 {
+			return WeekdayNum{}, fmt.Errorf("%w: malformed BYDAY ordinal %q", ErrInvalidRule, code)
+		}
+		day.Ordinal = ordinal
+	}
+	return day, nil
+}
+
+// String formats the rule back into its canonical RRULE value.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			code := strings.ToUpper(day.Weekday.String()[:2])
+			if day.Ordinal != 0 {
+				code = strconv.Itoa(day.Ordinal) + code
+			}
+			codes = append(codes, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence strictly after the given time, together
+// with its 1-based index in the series. Occurrences are computed in start's
+// location, so wall-clock times survive DST changes. ok is false once the
+// series is exhausted by COUNT or UNTIL.
+func (r Rule) Next(start, after time.Time) (next time.Time, index int, ok bool) {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	for period := 0; period < maxPeriods; period++ {
+		for _, candidate := range r.candidates(start, period*interval) {
+			if candidate.Before(start) {
+				continue
+			}
+			if r.Until != nil && candidate.After(*r.Until) {
+				return time.Time{}, 0, false
+			}
+			index++
+			if r.Count > 0 && index > r.Count {
+				return time.Time{}, 0, false
+			}
+			if candidate.After(after) {
+				return candidate, index, true
+			}
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+// Latest returns the last occurrence strictly after the given time that is
+// not after now or, if none is, the first occurrence after it like Next.
+// Unlike calling Next for every missed occurrence, it scans the series once.
+func (r Rule) Latest(start, after, now time.Time) (latest time.Time, index int, ok bool) {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	count := 0
+	for period := 0; period < maxPeriods; period++ {
+		for _, candidate := range r.candidates(start, period*interval) {
+			if candidate.Before(start) {
+				continue
+			}
+			if r.Until != nil && candidate.After(*r.Until) {
+				return latest, index, ok
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return latest, index, ok
+			}
+			if !candidate.After(after) {
+				continue
+			}
+			if candidate.After(now) {
+				if ok {
+					return latest, index, true
+				}
+				return candidate, count, true
+			}
+			latest, index, ok = candidate, count, true
+		}
+	}
+	return latest, index, ok
+}
+
+// candidates returns the sorted occurrences of the period that lies offset
+// frequency units after start's period.
+func (r Rule) candidates(start time.Time, offset int) []time.Time {
+	year, month, day := start.Date()
+	hour, minute, second := start.Clock()
+	location := start.Location()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, minute, second, start.Nanosecond(), location)
+	}
+
+	switch r.Freq {
+	case Daily:
+		return []time.Time{at(year, month, day+offset)}
+	case Weekly:
+		if len(r.ByDay) == 0 {
+			return []time.Time{at(year, month, day+7*offset)}
+		}
+		// Weeks start on Monday (RFC 5545 default WKST).
+		monday := day - (int(start.Weekday())+6)%7 + 7*offset
+		var result []time.Time
+		for _, byDay := range r.ByDay {
+			result = append(result, at(year, month, monday+(int(byDay.Weekday)+6)%7))
+		}
+		return sortUnique(result)
+	case Monthly:
+		firstOfMonth := time.Date(year, month+time.Month(offset), 1, 0, 0, 0, 0, location)
+		y, m := firstOfMonth.Year(), firstOfMonth.Month()
+		daysInMonth := time.Date(y, m+1, 0, 0, 0, 0, 0, location).Day()
+		if len(r.ByDay) == 0 {
+			// Months without the start's day of month are skipped, as in RFC 5545.
+			if day > daysInMonth {
+				return nil
+			}
+			return []time.Time{at(y, m, day)}
+		}
+		var result []time.Time
+		for _, byDay := range r.ByDay {
+			var matches []int
+			for d := 1; d <= daysInMonth; d++ {
+				if time.Date(y, m, d, 0, 0, 0, 0, location).Weekday() == byDay.Weekday {
+					matches = append(matches, d)
+				}
+			}
+			switch {
+			case byDay.Ordinal == 0:
+				for _, d := range matches {
+					result = append(result, at(y, m, d))
+				}
+			case byDay.Ordinal > 0 && byDay.Ordinal <= len(matches):
+				result = append(result, at(y, m, matches[byDay.Ordinal-1]))
+			case byDay.Ordinal < 0 && -byDay.Ordinal <= len(matches):
+				result = append(result, at(y, m, matches[len(matches)+byDay.Ordinal]))
+			}
+		}
+		return sortUnique(result)
+	}
+	return nil
+}
+
+func sortUnique(times []time.Time) []time.Time {
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	result := times[:0]
+	for i, t := range times {
+		if i == 0 || !t.Equal(times[i-1]) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
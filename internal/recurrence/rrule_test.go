@@ -0,0 +1,158 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func occurrences(t *testing.T, value string, start time.Time, n int) []time.Time {
+	t.Helper()
+	rule, err := Parse(value)
+	if err != nil {
+		t.Fatalf("parse %q: %v", value, err)
+	}
+	var result []time.Time
+	after := start.Add(-time.Nanosecond)
+	for len(result) < n {
+		next, _, ok := rule.Next(start, after)
+		if !ok {
+			break
+		}
+		result = append(result, next)
+		after = next
+	}
+	return result
+}
+
+func dates(times []time.Time) []string {
+	result := make([]string, 0, len(times))
+	for _, t := range times {
+		result = append(result, t.Format("2006-01-02"))
+	}
+	return result
+}
+
+func assertDates(t *testing.T, got []time.Time, want ...string) {
+	t.Helper()
+	gotDates := dates(got)
+	if len(gotDates) != len(want) {
+		t.Fatalf("got %v, want %v", gotDates, want)
+	}
+	for i := range want {
+		if gotDates[i] != want[i] {
+			t.Fatalf("got %v, want %v", gotDates, want)
+		}
+	}
+}
+
+func TestDailyWithInterval(t *testing.T) {
+	start := time.Date(2024, 1, 30, 9, 0, 0, 0, time.UTC)
+	got := occurrences(t, "FREQ=DAILY;INTERVAL=2", start, 3)
+	assertDates(t, got, "2024-01-30", "2024-02-01", "2024-02-03")
+}
+
+func TestWeeklyByDayWithCount(t *testing.T) {
+	// 2024-01-03 is a Wednesday, so Monday of that week is skipped.
+	start := time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC)
+	got := occurrences(t, "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=4", start, 10)
+	assertDates(t, got, "2024-01-03", "2024-01-05", "2024-01-08", "2024-01-10")
+}
+
+func TestMonthlySkipsShortMonths(t *testing.T) {
+	start := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+	got := occurrences(t, "FREQ=MONTHLY;UNTIL=20240601T000000Z", start, 10)
+	assertDates(t, got, "2024-01-31", "2024-03-31", "2024-05-31")
+}
+
+func TestMonthlyLastFriday(t *testing.T) {
+	start := time.Date(2024, 1, 1, 17, 0, 0, 0, time.UTC)
+	got := occurrences(t, "FREQ=MONTHLY;BYDAY=-1FR", start, 3)
+	assertDates(t, got, "2024-01-26", "2024-02-23", "2024-03-29")
+}
+
+func TestKeepsWallClockAcrossDST(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+	start := time.Date(2024, 3, 30, 9, 0, 0, 0, location)
+	got := occurrences(t, "FREQ=DAILY", start, 2)
+	if got[1].Hour() != 9 {
+		t.Fatalf("expected 09:00 local after DST switch, got %v", got[1])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"FREQ=YEARLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+	} {
+		if _, err := Parse(value); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q): expected ErrInvalidRule, got %v", value, err)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	const value = "FREQ=MONTHLY;INTERVAL=2;BYDAY=1MO,-1FR;COUNT=5"
+	rule, err := Parse(value)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if rule.String() != value {
+		t.Fatalf("got %q, want %q", rule.String(), value)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY;COUNT=5")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	after := start.Add(-time.Nanosecond)
+	for _, tc := range []struct {
+		now   time.Time
+		want  string
+		index int
+	}{
+		// Before the start the first occurrence is next, like Next.
+		{start.Add(-time.Hour), "2024-01-01", 1},
+		{start.Add(2*24*time.Hour + time.Hour), "2024-01-03", 3},
+		// Once COUNT is reached the last occurrence stays the latest.
+		{start.AddDate(1, 0, 0), "2024-01-05", 5},
+	} {
+		latest, index, ok := rule.Latest(start, after, tc.now)
+		if !ok || latest.Format("2006-01-02") != tc.want || index != tc.index {
+			t.Errorf("latest at %v: %v #%d (%v), want %s #%d", tc.now, latest, index, ok, tc.want, tc.index)
+		}
+	}
+	if _, _, ok := rule.Latest(start, time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC), start.AddDate(1, 0, 0)); ok {
+		t.Error("latest after the last occurrence")
+	}
+}
+
+// Catching up on centuries of daily occurrences scans them once rather
+// than once per occurrence.
+func TestLatestScansOnce(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	start := now.AddDate(-250, 0, 0)
+	began := time.Now()
+	latest, _, ok := rule.Latest(start, start.Add(-time.Nanosecond), now)
+	if !ok || !latest.Equal(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("latest %v, %v", latest, ok)
+	}
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("catching up took %v", elapsed)
+	}
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+)
+
+var (
+	ErrCalendarFeedNotFound = errors.New("calendar feed not found")
+)
+
+// CalendarFeedRepository stores calendar feeds with the hash of their
+// token, which they are looked up by.
+type CalendarFeedRepository interface {
+	CreateFeed(ctx context.Context, feed *models.CalendarFeed, tokenHash string) error
+	GetFeedByTokenHash(ctx context.Context, tokenHash string) (*models.CalendarFeed, error)
+	ListFeeds(ctx context.Context) ([]*models.CalendarFeed, error)
+	DeleteFeed(ctx context.Context, feedID uuid.UUID) error
+}
+
+type SQLiteCalendarFeedRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteCalendarFeedRepository(db *sql.DB) *SQLiteCalendarFeedRepository {
+	return &SQLiteCalendarFeedRepository{db: db}
+}
+
+func (r *SQLiteCalendarFeedRepository) CreateFeed(ctx context.Context, feed *models.CalendarFeed, tokenHash string) error {
+	feed.CreatedAt = time.Now().UTC()
+	const query = `
+INSERT INTO calendar_feeds (id, name, project, assignee, timezone, token_hash, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query,
+		feed.ID.String(),
+		feed.Name,
+		feed.Project,
+		feed.Assignee,
+		feed.Timezone,
+		tokenHash,
+		feed.CreatedAt.Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+const selectCalendarFeed = `
+SELECT id, name, project, assignee, timezone, created_at
+FROM calendar_feeds
+`
+
+func scanCalendarFeed(scanner interface{ Scan(dest ...any) error }) (*models.CalendarFeed, error) {
+	var feed models.CalendarFeed
+	var createdAtStr string
+	if err := scanner.Scan(&feed.ID, &feed.Name, &feed.Project, &feed.Assignee, &feed.Timezone, &createdAtStr); err != nil {
+		return nil, err
+	}
+	var err error
+	if feed.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	return &feed, nil
+}
+
+func (r *SQLiteCalendarFeedRepository) GetFeedByTokenHash(ctx context.Context, tokenHash string) (*models.CalendarFeed, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, selectCalendarFeed+"WHERE token_hash = ?", tokenHash)
+	feed, err := scanCalendarFeed(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCalendarFeedNotFound
+	}
+	return feed, err
+}
+
+func (r *SQLiteCalendarFeedRepository) ListFeeds(ctx context.Context) ([]*models.CalendarFeed, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, selectCalendarFeed+"ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var feeds []*models.CalendarFeed
+	for rows.Next() {
+		feed, err := scanCalendarFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return feeds, nil
+}
+
+func (r *SQLiteCalendarFeedRepository) DeleteFeed(ctx context.Context, feedID uuid.UUID) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM calendar_feeds WHERE id = ?`, feedID.String())
+	if err != nil {
+		return err
+	}
+	return expectRow(result, ErrCalendarFeedNotFound)
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+)
+
+type CommentRepository interface {
+	CreateComment(ctx context.Context, comment *models.Comment) error
+	ListComments(ctx context.Context, taskID uuid.UUID) ([]*models.Comment, error)
+	// ListCommentsForTasks returns the comments of all the tasks in one go,
+	// by task and in the order of ListComments.
+	ListCommentsForTasks(ctx context.Context, taskIDs []uuid.UUID) (map[uuid.UUID][]*models.Comment, error)
+}
+
+// commentBatchSize is how many task IDs a query of ListCommentsForTasks
+// takes, well below SQLite's limit on parameters.
+const commentBatchSize = 500
+
+type SQLiteCommentRepository struct {
+	db     *sql.DB
+	reader *sql.DB
+	cipher FieldCipher
+}
+
+func NewSQLiteCommentRepository(db *sql.DB) *SQLiteCommentRepository {
+	return &SQLiteCommentRepository{db: db}
+}
+
+// WithReader makes read-only queries outside of transactions run on the
+// reader pool.
+func (r *SQLiteCommentRepository) WithReader(reader *sql.DB) *SQLiteCommentRepository {
+	r.reader = reader
+	return r
+}
+
+// WithCipher encrypts comment bodies with cipher.
+func (r *SQLiteCommentRepository) WithCipher(cipher FieldCipher) *SQLiteCommentRepository {
+	r.cipher = cipher
+	return r
+}
+
+func (r *SQLiteCommentRepository) CreateComment(ctx context.Context, comment *models.Comment) error {
+	comment.CreatedAt = time.Now().UTC()
+
+	body, keyID, err := sealField(r.cipher, comment.Body, commentBodyAAD(comment.ID.String()))
+	if err != nil {
+		return err
+	}
+	const query = `
+INSERT INTO comments (id, task_id, author, body, created_at, key_id)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+	_, err = conn(ctx, r.db).ExecContext(ctx, query,
+		comment.ID.String(),
+		comment.TaskID.String(),
+		comment.Author,
+		body,
+		comment.CreatedAt.Format(time.RFC3339Nano),
+		keyID,
+	)
+	return err
+}
+
+func (r *SQLiteCommentRepository) ListComments(ctx context.Context, taskID uuid.UUID) ([]*models.Comment, error) {
+	const query = `
+SELECT id, task_id, author, body, created_at, key_id
+FROM comments
+WHERE task_id = ?
+ORDER BY created_at
+`
+	rows, err := readConn(ctx, r.db, r.reader).QueryContext(ctx, query, taskID.String())
+	if err != nil {
+		return nil, err
+	}
+	return r.scanComments(rows)
+}
+
+func (r *SQLiteCommentRepository) ListCommentsForTasks(ctx context.Context, taskIDs []uuid.UUID) (map[uuid.UUID][]*models.Comment, error) {
+	byTask := make(map[uuid.UUID][]*models.Comment, len(taskIDs))
+	for start := 0; start < len(taskIDs); start += commentBatchSize {
+		batch := taskIDs[start:min(start+commentBatchSize, len(taskIDs))]
+		queryArgs := make([]any, len(batch))
+		for i, taskID := range batch {
+			queryArgs[i] = taskID.String()
+		}
+		query := `
+SELECT id, task_id, author, body, created_at, key_id
+FROM comments
+WHERE task_id IN (?` + strings.Repeat(", ?", len(batch)-1) + `)
+ORDER BY created_at
+`
+		rows, err := readConn(ctx, r.db, r.reader).QueryContext(ctx, query, queryArgs...)
+		if err != nil {
+			return nil, err
+		}
+		comments, err := r.scanComments(rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range comments {
+			byTask[comment.TaskID] = append(byTask[comment.TaskID], comment)
+		}
+	}
+	return byTask, nil
+}
+
+// scanComments reads and closes rows of comment columns.
+func (r *SQLiteCommentRepository) scanComments(rows *sql.Rows) ([]*models.Comment, error) {
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var comments []*models.Comment
+	for rows.Next() {
+		var comment models.Comment
+		var createdAtStr, keyID string
+		if err := rows.Scan(&comment.ID, &comment.TaskID, &comment.Author, &comment.Body, &createdAtStr, &keyID); err != nil {
+			return nil, err
+		}
+		var err error
+		comment.Body, err = openField(r.cipher, comment.Body, keyID, commentBodyAAD(comment.ID.String()))
+		if err != nil {
+			return nil, err
+		}
+		comment.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr)
+		if err != nil {
+			return nil, fmt.Errorf("parse created_at: %w", err)
+		}
+		comments = append(comments, &comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var ErrNoCipher = errors.New("field is encrypted but no encryption keys are configured")
+
+// FieldCipher encrypts fields at rest, such as task descriptions and
+// comment bodies. The ID of the key a value is encrypted under is stored in
+// the key_id column of its row; plaintext rows have an empty key_id.
+type FieldCipher interface {
+	Encrypt(plaintext, aad string) (keyID, sealed string, err error)
+	Decrypt(keyID, sealed, aad string) (string, error)
+	PrimaryKeyID() string
+}
+
+// sealField returns the value to store for field value of a row and the
+// key ID to store with it. Without a cipher the value is stored as is.
+func sealField(cipher FieldCipher, value, aad string) (string, string, error) {
+	if cipher == nil {
+		return value, "", nil
+	}
+	keyID, sealed, err := cipher.Encrypt(value, aad)
+	if err != nil {
+		return "", "", fmt.Errorf("encrypt %s: %w", aad, err)
+	}
+	return sealed, keyID, nil
+}
+
+// openField returns the plaintext of a stored field value.
+func openField(cipher FieldCipher, stored, keyID, aad string) (string, error) {
+	if keyID == "" {
+		return stored, nil
+	}
+	if cipher == nil {
+		return "", fmt.Errorf("decrypt %s: %w", aad, ErrNoCipher)
+	}
+	value, err := cipher.Decrypt(keyID, stored, aad)
+	if err != nil {
+		return "", fmt.Errorf("decrypt %s: %w", aad, err)
+	}
+	return value, nil
+}
+
+func taskDescriptionAAD(id string) string { return "tasks.description:" + id }
+
+func commentBodyAAD(id string) string { return "comments.body:" + id }
+
+func outboxPayloadAAD(id string) string { return "outbox.payload:" + id }
+
+func eventLogPayloadAAD(id string) string { return "event_log.payload:" + id }
+
+func webhookDeliveryPayloadAAD(id string) string { return "webhook_deliveries.payload:" + id }
+
+func jobPayloadAAD(id string) string { return "jobs.payload:" + id }
+
+func digestItemPayloadAAD(id string) string { return "notification_digest_items.payload:" + id }
+
+func recurrenceDescriptionAAD(id string) string { return "recurrences.description:" + id }
+
+// sealPayload encrypts the payload of the row of table with the given ID,
+// which was inserted with an empty one: the AAD binds the payload to the ID,
+// which the database only assigns on insert. Call it in the transaction of
+// the insert. Without a cipher the row keeps its payload.
+func sealPayload(ctx context.Context, q querier, cipher FieldCipher, table string, id int64, payload string, aad func(id string) string) error {
+	if cipher == nil {
+		return nil
+	}
+	sealed, keyID, err := sealField(cipher, payload, aad(strconv.FormatInt(id, 10)))
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET payload = ?, key_id = ? WHERE id = ?`, table), sealed, keyID, id)
+	return err
+}
+
+// encryptedField is a column that sealField encrypts.
+type encryptedField struct {
+	table  string
+	column string
+	aad    func(id string) string
+}
+
+var encryptedFields = []encryptedField{
+	{table: "tasks", column: "description", aad: taskDescriptionAAD},
+	{table: "comments", column: "body", aad: commentBodyAAD},
+	{table: "outbox", column: "payload", aad: outboxPayloadAAD},
+	{table: "event_log", column: "payload", aad: eventLogPayloadAAD},
+	{table: "webhook_deliveries", column: "payload", aad: webhookDeliveryPayloadAAD},
+	{table: "jobs", column: "payload", aad: jobPayloadAAD},
+	{table: "notification_digest_items", column: "payload", aad: digestItemPayloadAAD},
+	{table: "recurrences", column: "description", aad: recurrenceDescriptionAAD},
+}
+
+// ReencryptFields re-encrypts up to batch encrypted field values that are
+// not under the primary key of cipher, including plaintext ones, and
+// returns how many it re-encrypted. Each batch is a short transaction per
+// table, and a row changed in the meantime is left for the next batch, so
+// it runs while the server is serving requests; call it until it returns 0.
+func ReencryptFields(ctx context.Context, db *sql.DB, cipher FieldCipher, batch int) (int, error) {
+	primary := cipher.PrimaryKeyID()
+	total := 0
+	for _, field := range encryptedFields {
+		if total >= batch {
+			break
+		}
+		n, err := reencryptField(ctx, db, cipher, field, primary, batch-total)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("re-encrypt %s.%s: %w", field.table, field.column, err)
+		}
+	}
+	return total, nil
+}
+
+func reencryptField(ctx context.Context, db *sql.DB, cipher FieldCipher, field encryptedField, primary string, limit int) (int, error) {
+	type row struct {
+		id, value, keyID string
+	}
+	var updated int
+	err := withinTx(ctx, db, func(ctx context.Context, tx querier) error {
+		query := fmt.Sprintf(`SELECT id, %s, key_id FROM %s WHERE key_id != ? LIMIT ?`, field.column, field.table)
+		rows, err := tx.QueryContext(ctx, query, primary, limit)
+		if err != nil {
+			return err
+		}
+		var pending []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.value, &r.keyID); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			pending = append(pending, r)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+
+		// Sealed values are never the same twice, so comparing the value
+		// detects any concurrent write to the row.
+		update := fmt.Sprintf(`UPDATE %s SET %s = ?, key_id = ? WHERE id = ? AND key_id = ? AND %s = ?`, field.table, field.column, field.column)
+		for _, r := range pending {
+			aad := field.aad(r.id)
+			plaintext, err := openField(cipher, r.value, r.keyID, aad)
+			if err != nil {
+				return err
+			}
+			sealed, keyID, err := sealField(cipher, plaintext, aad)
+			if err != nil {
+				return err
+			}
+			result, err := tx.ExecContext(ctx, update, sealed, keyID, r.id, r.keyID, r.value)
+			if err != nil {
+				return err
+			}
+			if n, err := result.RowsAffected(); err == nil && n > 0 {
+				updated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// CountFieldsToReencrypt returns how many encrypted field values are not
+// under the primary key of cipher.
+func CountFieldsToReencrypt(ctx context.Context, db *sql.DB, cipher FieldCipher) (int, error) {
+	total := 0
+	for _, field := range encryptedFields {
+		var count int
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE key_id != ?`, field.table)
+		if err := conn(ctx, db).QueryRowContext(ctx, query, cipher.PrimaryKeyID()).Scan(&count); err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
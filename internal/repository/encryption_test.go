@@ -0,0 +1,273 @@
+package repository_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+func TestSQLiteRepositoriesEncryptFields(t *testing.T) {
+	ctx := t.Context()
+	db := newSQLiteTestDB(t)
+	keyring := newTestKeyring(t, "a")
+	tasks := repository.NewSQLiteTaskRepository(db).WithCipher(keyring)
+	comments := repository.NewSQLiteCommentRepository(db).WithCipher(keyring)
+
+	task := &models.Task{ID: uuid.New(), Project: "default", Title: "Pay rent", Description: "IBAN DE00 1234", Status: models.TaskStatusNew}
+	if err := tasks.CreateTask(ctx, task); err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	comment := &models.Comment{ID: uuid.New(), TaskID: task.ID, Author: "ann", Body: "PIN is 4321"}
+	if err := comments.CreateComment(ctx, comment); err != nil {
+		t.Fatalf("create comment: %v", err)
+	}
+	if task.Description != "IBAN DE00 1234" || comment.Body != "PIN is 4321" {
+		t.Fatalf("create changed the values: %q, %q", task.Description, comment.Body)
+	}
+
+	var description, body, taskKeyID, commentKeyID string
+	if err := db.QueryRow(`SELECT description, key_id FROM tasks`).Scan(&description, &taskKeyID); err != nil {
+		t.Fatalf("select task: %v", err)
+	}
+	if err := db.QueryRow(`SELECT body, key_id FROM comments`).Scan(&body, &commentKeyID); err != nil {
+		t.Fatalf("select comment: %v", err)
+	}
+	if strings.Contains(description, "IBAN") || strings.Contains(body, "PIN") || taskKeyID != "a" || commentKeyID != "a" {
+		t.Fatalf("stored %q under %q and %q under %q", description, taskKeyID, body, commentKeyID)
+	}
+
+	got, err := tasks.GetTask(ctx, task.ID)
+	if err != nil || got.Description != task.Description {
+		t.Fatalf("get task: %+v, %v", got, err)
+	}
+	listed, err := comments.ListComments(ctx, task.ID)
+	if err != nil || len(listed) != 1 || listed[0].Body != comment.Body {
+		t.Fatalf("list comments: %+v, %v", listed, err)
+	}
+	byTask, err := comments.ListCommentsForTasks(ctx, []uuid.UUID{task.ID, uuid.New()})
+	if err != nil || len(byTask) != 1 || len(byTask[task.ID]) != 1 || byTask[task.ID][0].Body != comment.Body {
+		t.Fatalf("list comments for tasks: %+v, %v", byTask, err)
+	}
+
+	// A value moved to another row no longer decrypts.
+	other := &models.Task{ID: uuid.New(), Project: "default", Title: "Other", Status: models.TaskStatusNew}
+	if err := tasks.CreateTask(ctx, other); err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE tasks SET description = ?, key_id = 'a' WHERE id = ?`, description, other.ID.String()); err != nil {
+		t.Fatalf("move value: %v", err)
+	}
+	if _, err := tasks.GetTask(ctx, other.ID); err == nil {
+		t.Fatal("get task with a value of another row: no error")
+	}
+
+	// Without keys, encrypted rows can't be read.
+	if _, err := repository.NewSQLiteTaskRepository(db).GetTask(ctx, task.ID); err == nil {
+		t.Fatal("get encrypted task without a cipher: no error")
+	}
+}
+
+func TestReencryptFields(t *testing.T) {
+	ctx := t.Context()
+	db := newSQLiteTestDB(t)
+
+	// Rows written before encryption was configured, and under key a.
+	plain := &models.Task{ID: uuid.New(), Project: "default", Title: "Plain", Description: "written in plaintext", Status: models.TaskStatusNew}
+	if err := repository.NewSQLiteTaskRepository(db).CreateTask(ctx, plain); err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	old := newTestKeyring(t, "a")
+	sealed := &models.Task{ID: uuid.New(), Project: "default", Title: "Sealed", Description: "written under a", Status: models.TaskStatusNew}
+	if err := repository.NewSQLiteTaskRepository(db).WithCipher(old).CreateTask(ctx, sealed); err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	comment := &models.Comment{ID: uuid.New(), TaskID: sealed.ID, Author: "ann", Body: "comment under a"}
+	if err := repository.NewSQLiteCommentRepository(db).WithCipher(old).CreateComment(ctx, comment); err != nil {
+		t.Fatalf("create comment: %v", err)
+	}
+
+	rotated := newTestKeyring(t, "b", "a")
+	if n, err := repository.CountFieldsToReencrypt(ctx, db, rotated); err != nil || n != 3 {
+		t.Fatalf("count before: %d, %v, want 3", n, err)
+	}
+	total := 0
+	for {
+		n, err := repository.ReencryptFields(ctx, db, rotated, 2)
+		if err != nil {
+			t.Fatalf("re-encrypt: %v", err)
+		}
+		if n > 2 {
+			t.Fatalf("re-encrypted %d values in a batch of 2", n)
+		}
+		if n == 0 {
+			break
+		}
+		total += n
+	}
+	if total != 3 {
+		t.Fatalf("re-encrypted %d values, want 3", total)
+	}
+	if n, err := repository.CountFieldsToReencrypt(ctx, db, rotated); err != nil || n != 0 {
+		t.Fatalf("count after: %d, %v, want 0", n, err)
+	}
+
+	// Only key b is needed now.
+	current := newTestKeyring(t, "b")
+	tasks := repository.NewSQLiteTaskRepository(db).WithCipher(current)
+	for _, want := range []*models.Task{plain, sealed} {
+		got, err := tasks.GetTask(ctx, want.ID)
+		if err != nil || got.Description != want.Description {
+			t.Fatalf("get task: %+v, %v, want %q", got, err, want.Description)
+		}
+	}
+	listed, err := repository.NewSQLiteCommentRepository(db).WithCipher(current).ListComments(ctx, sealed.ID)
+	if err != nil || len(listed) != 1 || listed[0].Body != comment.Body {
+		t.Fatalf("list comments: %+v, %v", listed, err)
+	}
+}
+
+// Payloads holding events or notifications contain task descriptions and
+// comment bodies, so they are encrypted wherever they are stored.
+func TestSQLiteRepositoriesEncryptPayloads(t *testing.T) {
+	ctx := t.Context()
+	db := newSQLiteTestDB(t)
+	keyring := newTestKeyring(t, "a")
+	outbox := repository.NewSQLiteOutboxRepository(db).WithCipher(keyring)
+	eventLog := repository.NewSQLiteEventLogRepository(db).WithCipher(keyring)
+	webhooks := repository.NewSQLiteWebhookRepository(db).WithCipher(keyring)
+	jobs := repository.NewSQLiteJobRepository(db).WithCipher(keyring)
+	notifications := repository.NewSQLiteNotificationRepository(db).WithCipher(keyring)
+
+	task := &models.Task{ID: uuid.New(), Project: "default", Title: "Pay rent", Description: "IBAN DE00 1234", Status: models.TaskStatusNew}
+	comment := &models.Comment{ID: uuid.New(), TaskID: task.ID, Author: "ann", Body: "PIN is 4321"}
+	taskEvent := events.NewTaskEvent(events.TaskCreated, task, nil)
+	commentEvent := events.Event{Type: events.CommentCreated, TaskID: task.ID, Project: task.Project, Task: task, Comment: comment, OccurredAt: taskEvent.OccurredAt}
+	webhook := &models.Webhook{ID: uuid.New(), URL: "https://example.com/hook", Secret: "s", Active: true}
+	if err := webhooks.CreateWebhook(ctx, webhook); err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	var deliveryIDs []uuid.UUID
+	for _, event := range []events.Event{taskEvent, commentEvent} {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		if err := outbox.AddMessage(ctx, &models.OutboxMessage{Type: string(event.Type), TaskID: task.ID, Payload: payload}); err != nil {
+			t.Fatalf("add message: %v", err)
+		}
+		entry := &models.EventLogEntry{Type: string(event.Type), TaskID: task.ID, Project: task.Project, Status: task.Status, Payload: payload, OccurredAt: event.OccurredAt}
+		if err := eventLog.AppendEvent(ctx, entry); err != nil {
+			t.Fatalf("append event: %v", err)
+		}
+		delivery := &models.WebhookDelivery{ID: uuid.New(), WebhookID: webhook.ID, EventType: string(event.Type), Payload: payload, Status: models.WebhookDeliveryPending}
+		if err := webhooks.CreateDelivery(ctx, delivery); err != nil {
+			t.Fatalf("create delivery: %v", err)
+		}
+		deliveryIDs = append(deliveryIDs, delivery.ID)
+		if _, err := jobs.EnqueueJob(ctx, &models.Job{ID: uuid.New(), Kind: "notification", Payload: payload, MaxAttempts: 1, RunAt: time.Now()}); err != nil {
+			t.Fatalf("enqueue job: %v", err)
+		}
+		if err := notifications.AddDigestItem(ctx, &models.DigestItem{User: "ann", Type: string(event.Type), Payload: payload}); err != nil {
+			t.Fatalf("add digest item: %v", err)
+		}
+	}
+
+	for _, table := range []string{"outbox", "event_log", "webhook_deliveries", "jobs", "notification_digest_items"} {
+		rows, err := db.Query(`SELECT payload, key_id FROM ` + table)
+		if err != nil {
+			t.Fatalf("select %s: %v", table, err)
+		}
+		var count int
+		for rows.Next() {
+			var payload, keyID string
+			if err := rows.Scan(&payload, &keyID); err != nil {
+				t.Fatalf("scan %s: %v", table, err)
+			}
+			if strings.Contains(payload, "IBAN") || strings.Contains(payload, "PIN") || keyID != "a" {
+				t.Errorf("%s row stores %q under %q", table, payload, keyID)
+			}
+			count++
+		}
+		if err := rows.Close(); err != nil || count != 2 {
+			t.Fatalf("%s has %d rows, %v", table, count, err)
+		}
+	}
+
+	// Reading decrypts the payloads.
+	secret := func(payload []byte, i int) bool {
+		return strings.Contains(string(payload), []string{task.Description, comment.Body}[i])
+	}
+	messages, err := outbox.ListPending(ctx, "log", 10)
+	if err != nil || len(messages) != 2 || !secret(messages[0].Payload, 0) || !secret(messages[1].Payload, 1) {
+		t.Fatalf("list pending: %+v, %v", messages, err)
+	}
+	entries, err := eventLog.ListEvents(ctx, repository.EventLogFilter{})
+	if err != nil || len(entries) != 2 || !secret(entries[0].Payload, 0) || !secret(entries[1].Payload, 1) {
+		t.Fatalf("list events: %+v, %v", entries, err)
+	}
+	for i, id := range deliveryIDs {
+		if delivery, err := webhooks.GetDelivery(ctx, id); err != nil || !secret(delivery.Payload, i) {
+			t.Fatalf("get delivery: %+v, %v", delivery, err)
+		}
+	}
+	claimed, err := jobs.ClaimDueJobs(ctx, time.Now(), time.Minute, 10)
+	if err != nil || len(claimed) != 2 || !strings.Contains(string(claimed[0].Payload)+string(claimed[1].Payload), comment.Body) {
+		t.Fatalf("claim jobs: %+v, %v", claimed, err)
+	}
+	items, err := notifications.ListDigestItems(ctx, "ann")
+	if err != nil || len(items) != 2 || !secret(items[0].Payload, 0) || !secret(items[1].Payload, 1) {
+		t.Fatalf("list digest items: %+v, %v", items, err)
+	}
+
+	// Payloads are bound to their row and rotate with the other fields.
+	if _, err := db.Exec(`UPDATE event_log SET payload = (SELECT payload FROM event_log WHERE id = 1) WHERE id = 2`); err != nil {
+		t.Fatalf("move payload: %v", err)
+	}
+	if _, err := eventLog.ListEvents(ctx, repository.EventLogFilter{AfterID: 1}); err == nil {
+		t.Error("list an entry with the payload of another row: no error")
+	}
+	if n, err := repository.CountFieldsToReencrypt(ctx, db, newTestKeyring(t, "b", "a")); err != nil || n != 10 {
+		t.Errorf("count to re-encrypt: %d, %v, want 10", n, err)
+	}
+}
+
+func TestSQLiteRecurrenceRepositoryEncryptsDescriptions(t *testing.T) {
+	ctx := t.Context()
+	db := newSQLiteTestDB(t)
+	recurrences := repository.NewSQLiteRecurrenceRepository(db).WithCipher(newTestKeyring(t, "a"))
+
+	recurrence := &models.Recurrence{ID: uuid.New(), Project: "default", Title: "Pay rent", Description: "IBAN DE00 1234", Rule: "FREQ=MONTHLY", Timezone: "UTC", StartsAt: time.Now(), Active: true}
+	if err := recurrences.CreateRecurrence(ctx, recurrence); err != nil {
+		t.Fatalf("create recurrence: %v", err)
+	}
+	var description, keyID string
+	if err := db.QueryRow(`SELECT description, key_id FROM recurrences`).Scan(&description, &keyID); err != nil {
+		t.Fatalf("select recurrence: %v", err)
+	}
+	if strings.Contains(description, "IBAN") || keyID != "a" {
+		t.Fatalf("stored %q under %q", description, keyID)
+	}
+	got, err := recurrences.GetRecurrence(ctx, recurrence.ID)
+	if err != nil || got.Description != recurrence.Description {
+		t.Fatalf("get recurrence: %+v, %v", got, err)
+	}
+
+	rotated := newTestKeyring(t, "b", "a")
+	if n, err := repository.ReencryptFields(ctx, db, rotated, 10); err != nil || n != 1 {
+		t.Fatalf("re-encrypt: %d, %v, want 1", n, err)
+	}
+	listed, err := repository.NewSQLiteRecurrenceRepository(db).WithCipher(newTestKeyring(t, "b")).ListRecurrences(ctx, true)
+	if err != nil || len(listed) != 1 || listed[0].Description != recurrence.Description {
+		t.Fatalf("list recurrences under the new key: %+v, %v", listed, err)
+	}
+	if _, err := repository.NewSQLiteRecurrenceRepository(db).GetRecurrence(ctx, recurrence.ID); err == nil {
+		t.Fatal("get encrypted recurrence without a cipher: no error")
+	}
+}
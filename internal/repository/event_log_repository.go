@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+type EventLogFilter struct {
+	AfterID int64
+	// BeforeID limits entries to those before it unless it is 0.
+	BeforeID int64
+	Project  string
+	Status   *models.TaskStatus
+	// Newest lists the most recent entries first.
+	Newest bool
+	Limit  int
+}
+
+type EventLogRepository interface {
+	// AppendEvent stores the entry and sets its ID.
+	AppendEvent(ctx context.Context, entry *models.EventLogEntry) error
+	// ListEvents returns entries after filter.AfterID in ID order, or in
+	// reverse with filter.Newest.
+	ListEvents(ctx context.Context, filter EventLogFilter) ([]*models.EventLogEntry, error)
+	// FirstEventID returns the ID of the oldest retained entry, or 0 if the
+	// log is empty.
+	FirstEventID(ctx context.Context) (int64, error)
+	// LastEventID returns the ID of the newest entry, or 0 if the log is
+	// empty.
+	LastEventID(ctx context.Context) (int64, error)
+	// PruneEvents deletes entries that occurred before the given time. The
+	// most recent entry is always kept so that FirstEventID keeps telling
+	// resuming clients whether they missed pruned events.
+	PruneEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+type SQLiteEventLogRepository struct {
+	db     *sql.DB
+	reader *sql.DB
+	cipher FieldCipher
+}
+
+func NewSQLiteEventLogRepository(db *sql.DB) *SQLiteEventLogRepository {
+	return &SQLiteEventLogRepository{db: db}
+}
+
+// WithReader makes read-only queries outside of transactions run on the
+// reader pool.
+func (r *SQLiteEventLogRepository) WithReader(reader *sql.DB) *SQLiteEventLogRepository {
+	r.reader = reader
+	return r
+}
+
+// WithCipher encrypts entry payloads with cipher. They hold the events'
+// tasks and comments, descriptions and bodies included.
+func (r *SQLiteEventLogRepository) WithCipher(cipher FieldCipher) *SQLiteEventLogRepository {
+	r.cipher = cipher
+	return r
+}
+
+func (r *SQLiteEventLogRepository) AppendEvent(ctx context.Context, entry *models.EventLogEntry) error {
+	const query = `
+INSERT INTO event_log (type, task_id, project, status, payload, occurred_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id
+`
+	payload := string(entry.Payload)
+	return withinTx(ctx, r.db, func(ctx context.Context, q querier) error {
+		stored := payload
+		if r.cipher != nil {
+			stored = ""
+		}
+		if err := q.QueryRowContext(ctx, query,
+			entry.Type,
+			entry.TaskID.String(),
+			entry.Project,
+			string(entry.Status),
+			stored,
+			entry.OccurredAt.UTC().Format(time.RFC3339Nano),
+		).Scan(&entry.ID); err != nil {
+			return err
+		}
+		return sealPayload(ctx, q, r.cipher, "event_log", entry.ID, payload, eventLogPayloadAAD)
+	})
+}
+
+func (r *SQLiteEventLogRepository) ListEvents(ctx context.Context, filter EventLogFilter) ([]*models.EventLogEntry, error) {
+	query := `
+SELECT id, type, task_id, project, status, payload, key_id, occurred_at
+FROM event_log
+WHERE id > ? `
+	queryArgs := []any{filter.AfterID}
+	if filter.BeforeID > 0 {
+		query += "AND id < ? "
+		queryArgs = append(queryArgs, filter.BeforeID)
+	}
+	if filter.Project != "" {
+		query += "AND project = ? "
+		queryArgs = append(queryArgs, filter.Project)
+	}
+	if filter.Status != nil {
+		query += "AND status = ? "
+		queryArgs = append(queryArgs, string(*filter.Status))
+	}
+	query += "ORDER BY id "
+	if filter.Newest {
+		query += "DESC "
+	}
+	if filter.Limit > 0 {
+		query += "LIMIT ?"
+		queryArgs = append(queryArgs, filter.Limit)
+	}
+
+	rows, err := readConn(ctx, r.db, r.reader).QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var entries []*models.EventLogEntry
+	for rows.Next() {
+		var entry models.EventLogEntry
+		var status, payload, keyID, occurredAtStr string
+		if err := rows.Scan(&entry.ID, &entry.Type, &entry.TaskID, &entry.Project, &status, &payload, &keyID, &occurredAtStr); err != nil {
+			return nil, err
+		}
+		entry.Status = models.TaskStatus(status)
+		if payload, err = openField(r.cipher, payload, keyID, eventLogPayloadAAD(strconv.FormatInt(entry.ID, 10))); err != nil {
+			return nil, err
+		}
+		entry.Payload = json.RawMessage(payload)
+		if entry.OccurredAt, err = time.Parse(time.RFC3339Nano, occurredAtStr); err != nil {
+			return nil, fmt.Errorf("parse occurred_at: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *SQLiteEventLogRepository) FirstEventID(ctx context.Context) (int64, error) {
+	var id sql.NullInt64
+	if err := readConn(ctx, r.db, r.reader).QueryRowContext(ctx, `SELECT MIN(id) FROM event_log`).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id.Int64, nil
+}
+
+func (r *SQLiteEventLogRepository) LastEventID(ctx context.Context) (int64, error) {
+	var id sql.NullInt64
+	if err := readConn(ctx, r.db, r.reader).QueryRowContext(ctx, `SELECT MAX(id) FROM event_log`).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id.Int64, nil
+}
+
+func (r *SQLiteEventLogRepository) PruneEvents(ctx context.Context, before time.Time) (int64, error) {
+	const query = `
+DELETE FROM event_log
+WHERE occurred_at < ? AND id < (SELECT MAX(id) FROM event_log)
+`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, before.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/database"
+	"task-manager/internal/models"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+)
+
+type JobFilter struct {
+	Status *models.JobStatus
+	Kind   string
+	Limit  int
+	Offset int
+}
+
+type JobRepository interface {
+	// EnqueueJob stores a pending job. It returns false if a job with the
+	// same key already exists, in which case nothing is written.
+	EnqueueJob(ctx context.Context, job *models.Job) (bool, error)
+	// ClaimDueJobs marks up to limit runnable jobs as running until now+lease
+	// and returns them. Jobs whose lease expired (e.g. the process died while
+	// running them) are claimed again.
+	ClaimDueJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*models.Job, error)
+	CompleteJob(ctx context.Context, jobID uuid.UUID) error
+	// FailJob records a failed attempt. A nil retryAt marks the job as
+	// permanently failed, otherwise it becomes pending again at retryAt.
+	FailJob(ctx context.Context, jobID uuid.UUID, lastError string, retryAt *time.Time) error
+	ListJobs(ctx context.Context, filter JobFilter) ([]*models.Job, error)
+}
+
+type SQLiteJobRepository struct {
+	db     *sql.DB
+	cipher FieldCipher
+}
+
+func NewSQLiteJobRepository(db *sql.DB) *SQLiteJobRepository {
+	return &SQLiteJobRepository{db: db}
+}
+
+// WithCipher encrypts job payloads with cipher. Notification jobs hold the
+// notified tasks and comments, descriptions and bodies included.
+func (r *SQLiteJobRepository) WithCipher(cipher FieldCipher) *SQLiteJobRepository {
+	r.cipher = cipher
+	return r
+}
+
+func (r *SQLiteJobRepository) EnqueueJob(ctx context.Context, job *models.Job) (bool, error) {
+	now := time.Now().UTC()
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	if job.Key == "" {
+		job.Key = job.ID.String()
+	}
+	if job.Status == "" {
+		job.Status = models.JobStatusPending
+	}
+
+	payload, keyID, err := sealField(r.cipher, string(job.Payload), jobPayloadAAD(job.ID.String()))
+	if err != nil {
+		return false, err
+	}
+
+	const query = `
+INSERT INTO jobs (id, kind, key, payload, key_id, status, attempts, max_attempts, run_at, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (key) DO NOTHING
+`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query,
+		job.ID.String(),
+		job.Kind,
+		job.Key,
+		payload,
+		keyID,
+		string(job.Status),
+		job.Attempts,
+		job.MaxAttempts,
+		job.RunAt.UnixMilli(),
+		job.CreatedAt.Format(time.RFC3339Nano),
+		job.UpdatedAt.Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+const jobColumns = `id, kind, key, payload, key_id, status, attempts, max_attempts, run_at, locked_until, last_error, created_at, updated_at`
+
+func (r *SQLiteJobRepository) scanJob(scanner interface{ Scan(dest ...any) error }) (*models.Job, error) {
+	var job models.Job
+	var payload, keyID, status, createdAtStr, updatedAtStr string
+	var runAt int64
+	var lockedUntil sql.NullInt64
+	if err := scanner.Scan(
+		&job.ID,
+		&job.Kind,
+		&job.Key,
+		&payload,
+		&keyID,
+		&status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&runAt,
+		&lockedUntil,
+		&job.LastError,
+		&createdAtStr,
+		&updatedAtStr,
+	); err != nil {
+		return nil, err
+	}
+	payload, err := openField(r.cipher, payload, keyID, jobPayloadAAD(job.ID.String()))
+	if err != nil {
+		return nil, err
+	}
+	job.Payload = []byte(payload)
+	job.Status = models.JobStatus(status)
+	job.RunAt = time.UnixMilli(runAt).UTC()
+	if lockedUntil.Valid {
+		t := time.UnixMilli(lockedUntil.Int64).UTC()
+		job.LockedUntil = &t
+	}
+
+	if job.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	if job.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAtStr); err != nil {
+		return nil, fmt.Errorf("parse updated_at: %w", err)
+	}
+	return &job, nil
+}
+
+func (r *SQLiteJobRepository) ClaimDueJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*models.Job, error) {
+	// SQLite has a single writer. On PostgreSQL, jobs that another process
+	// is claiming are locked and skipped, so that no job is claimed twice.
+	lock := ""
+	if database.DialectOf(r.db) == database.Postgres {
+		lock = "FOR UPDATE SKIP LOCKED"
+	}
+	query := `
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, locked_until = ?, updated_at = ?
+WHERE id IN (
+  SELECT id FROM jobs
+  WHERE (status = 'pending' AND run_at <= ?) OR (status = 'running' AND locked_until <= ?)
+  ORDER BY run_at
+  LIMIT ?
+  ` + lock + `
+)
+RETURNING ` + jobColumns
+
+	nowMillis := now.UnixMilli()
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query,
+		now.Add(lease).UnixMilli(),
+		now.UTC().Format(time.RFC3339Nano),
+		nowMillis,
+		nowMillis,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var jobs []*models.Job
+	for rows.Next() {
+		job, err := r.scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (r *SQLiteJobRepository) CompleteJob(ctx context.Context, jobID uuid.UUID) error {
+	const query = `
+UPDATE jobs
+SET status = 'done', locked_until = NULL, last_error = '', updated_at = ?
+WHERE id = ?
+`
+	return r.exec(ctx, query, time.Now().UTC().Format(time.RFC3339Nano), jobID.String())
+}
+
+func (r *SQLiteJobRepository) FailJob(ctx context.Context, jobID uuid.UUID, lastError string, retryAt *time.Time) error {
+	now := time.Now().UTC()
+	if retryAt == nil {
+		const query = `
+UPDATE jobs
+SET status = 'failed', locked_until = NULL, last_error = ?, updated_at = ?
+WHERE id = ?
+`
+		return r.exec(ctx, query, lastError, now.Format(time.RFC3339Nano), jobID.String())
+	}
+	const query = `
+UPDATE jobs
+SET status = 'pending', locked_until = NULL, last_error = ?, run_at = ?, updated_at = ?
+WHERE id = ?
+`
+	return r.exec(ctx, query, lastError, retryAt.UnixMilli(), now.Format(time.RFC3339Nano), jobID.String())
+}
+
+func (r *SQLiteJobRepository) ListJobs(ctx context.Context, filter JobFilter) ([]*models.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE 1 = 1 `
+	var queryArgs []any
+	if filter.Status != nil {
+		query += "AND status = ? "
+		queryArgs = append(queryArgs, string(*filter.Status))
+	}
+	if filter.Kind != "" {
+		query += "AND kind = ? "
+		queryArgs = append(queryArgs, filter.Kind)
+	}
+	query += "ORDER BY run_at DESC "
+	if filter.Limit > 0 {
+		query += "LIMIT ? "
+		queryArgs = append(queryArgs, filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query += "OFFSET ?"
+		queryArgs = append(queryArgs, filter.Offset)
+	}
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var jobs []*models.Job
+	for rows.Next() {
+		job, err := r.scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (r *SQLiteJobRepository) exec(ctx context.Context, query string, args ...any) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
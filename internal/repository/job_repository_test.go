@@ -0,0 +1,55 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+// A job that another process is claiming is skipped rather than claimed
+// twice.
+func TestPostgresClaimDueJobsSkipsLockedJobs(t *testing.T) {
+	ctx := context.Background()
+	db := newPostgresTestDB(t)
+	repo := repository.NewSQLiteJobRepository(db)
+	now := time.Now().UTC()
+	for i := range 2 {
+		job := &models.Job{ID: uuid.New(), Kind: "test", Payload: []byte(`{}`), MaxAttempts: 1, RunAt: now.Add(time.Duration(i-2) * time.Minute)}
+		if _, err := repo.EnqueueJob(ctx, job); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	claimed := make(chan []*models.Job)
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- repository.NewSQLTransactor(db).WithinTx(ctx, func(ctx context.Context) error {
+			jobs, err := repo.ClaimDueJobs(ctx, now, time.Minute, 1)
+			if err != nil {
+				close(claimed)
+				return err
+			}
+			claimed <- jobs
+			<-release
+			return nil
+		})
+	}()
+	first, ok := <-claimed
+	if !ok {
+		t.Fatalf("claim in a transaction: %v", <-done)
+	}
+	second, err := repo.ClaimDueJobs(ctx, now, time.Minute, 10)
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if err != nil || len(first) != 1 || len(second) != 1 || first[0].ID == second[0].ID {
+		t.Fatalf("claimed %v and then %v, %v; want one job each", first, second, err)
+	}
+}
@@ -0,0 +1,395 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+)
+
+// InMemoryTaskRepository keeps tasks in memory. It behaves like the SQL
+// repositories: tasks are copied on the way in and out, so callers never
+// share them with the store, and writes made with the ctx of a transaction
+// are only seen by that transaction until it commits. They are dropped if
+// it rolls back. Uniqueness is checked when a write is made, not again at
+// the commit.
+type InMemoryTaskRepository struct {
+	mu    sync.RWMutex
+	tasks map[uuid.UUID]*models.Task
+	// cipher seals the descriptions in snapshots; nil writes them as is.
+	cipher FieldCipher
+}
+
+func NewInMemoryTaskRepository() *InMemoryTaskRepository {
+	return &InMemoryTaskRepository{tasks: make(map[uuid.UUID]*models.Task)}
+}
+
+// WithCipher encrypts task descriptions in snapshots with cipher, like the
+// SQL repositories encrypt them in the database.
+func (r *InMemoryTaskRepository) WithCipher(cipher FieldCipher) *InMemoryTaskRepository {
+	r.cipher = cipher
+	return r
+}
+
+// memoryTaskWrites are the writes of a transaction, by task ID; deleted
+// tasks map to nil.
+type memoryTaskWrites map[uuid.UUID]*models.Task
+
+// pending returns the writes of the transaction carried by ctx, or nil if
+// there are none. r.mu must be held.
+func (r *InMemoryTaskRepository) pending(ctx context.Context) memoryTaskWrites {
+	writes, _ := txLocal[memoryTaskWrites](ctx, r, nil)
+	return writes
+}
+
+// store writes task, or deletes it if it is nil, in the transaction of ctx
+// or right away outside of one. The writes of a transaction are applied
+// when it commits. r.mu must be held for writing.
+func (r *InMemoryTaskRepository) store(ctx context.Context, taskID uuid.UUID, task *models.Task) {
+	writes, inTx := txLocal(ctx, r, func() memoryTaskWrites {
+		writes := make(memoryTaskWrites)
+		AfterCommit(ctx, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			for id, task := range writes {
+				if task == nil {
+					delete(r.tasks, id)
+				} else {
+					r.tasks[id] = task
+				}
+			}
+		})
+		return writes
+	})
+	if inTx {
+		writes[taskID] = task
+	} else if task == nil {
+		delete(r.tasks, taskID)
+	} else {
+		r.tasks[taskID] = task
+	}
+}
+
+// lookup returns the task as the transaction of ctx sees it. r.mu must be
+// held.
+func (r *InMemoryTaskRepository) lookup(ctx context.Context, taskID uuid.UUID) (*models.Task, bool) {
+	if task, ok := r.pending(ctx)[taskID]; ok {
+		return task, task != nil
+	}
+	task, ok := r.tasks[taskID]
+	return task, ok
+}
+
+// each calls fn with every task as the transaction of ctx sees it. r.mu
+// must be held.
+func (r *InMemoryTaskRepository) each(ctx context.Context, fn func(*models.Task)) {
+	writes := r.pending(ctx)
+	for id, task := range r.tasks {
+		if _, ok := writes[id]; !ok {
+			fn(task)
+		}
+	}
+	for _, task := range writes {
+		if task != nil {
+			fn(task)
+		}
+	}
+}
+
+func copyTask(task *models.Task) *models.Task {
+	copied := *task
+	if task.DueAt != nil {
+		dueAt := *task.DueAt
+		copied.DueAt = &dueAt
+	}
+	if task.RecurrenceID != nil {
+		recurrenceID := *task.RecurrenceID
+		copied.RecurrenceID = &recurrenceID
+	}
+	return &copied
+}
+
+// storedTask returns the copy of task that is stored, with times in UTC like
+// the SQL repositories return them.
+func storedTask(task *models.Task) *models.Task {
+	stored := copyTask(task)
+	if stored.DueAt != nil {
+		*stored.DueAt = stored.DueAt.UTC()
+	}
+	return stored
+}
+
+func (r *InMemoryTaskRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r *InMemoryTaskRepository) CreateTask(ctx context.Context, task *models.Task) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.lookup(ctx, task.ID); ok {
+		return fmt.Errorf("task %s already exists", task.ID)
+	}
+	if err := r.checkExternalID(ctx, task); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	task.CreatedAt = now
+	task.UpdatedAt = now
+	r.store(ctx, task.ID, storedTask(task))
+	return nil
+}
+
+func (r *InMemoryTaskRepository) GetTask(ctx context.Context, taskID uuid.UUID) (*models.Task, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	task, ok := r.lookup(ctx, taskID)
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	return copyTask(task), nil
+}
+
+func (r *InMemoryTaskRepository) GetTaskByExternalID(ctx context.Context, externalID string) (*models.Task, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var found *models.Task
+	r.each(ctx, func(task *models.Task) {
+		if externalID != "" && task.ExternalID == externalID {
+			found = task
+		}
+	})
+	if found == nil {
+		return nil, ErrTaskNotFound
+	}
+	return copyTask(found), nil
+}
+
+// checkExternalID keeps external IDs unique, like the unique index of the
+// SQL repositories. r.mu must be held.
+func (r *InMemoryTaskRepository) checkExternalID(ctx context.Context, task *models.Task) error {
+	if task.ExternalID == "" {
+		return nil
+	}
+	var taken *models.Task
+	r.each(ctx, func(other *models.Task) {
+		if other.ID != task.ID && other.ExternalID == task.ExternalID {
+			taken = other
+		}
+	})
+	if taken != nil {
+		return fmt.Errorf("external ID %q is taken by task %s", task.ExternalID, taken.ID)
+	}
+	return nil
+}
+
+func (r *InMemoryTaskRepository) ListTasks(ctx context.Context, filter TaskFilter) ([]*models.Task, error) {
+	r.mu.RLock()
+	var tasks []*models.Task
+	r.each(ctx, func(task *models.Task) {
+		if matchesFilter(task, filter) {
+			tasks = append(tasks, copyTask(task))
+		}
+	})
+	r.mu.RUnlock()
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].CreatedAt.Equal(tasks[j].CreatedAt) {
+			return tasks[i].CreatedAt.After(tasks[j].CreatedAt)
+		}
+		return tasks[i].ID.String() < tasks[j].ID.String()
+	})
+	if filter.Offset > 0 {
+		if filter.Offset >= len(tasks) {
+			return nil, nil
+		}
+		tasks = tasks[filter.Offset:]
+	}
+	if filter.Limit > 0 && filter.Limit < len(tasks) {
+		tasks = tasks[:filter.Limit]
+	}
+	return tasks, nil
+}
+
+// EachTask calls fn with the result of ListTasks, which holds copies of the
+// tasks already.
+func (r *InMemoryTaskRepository) EachTask(ctx context.Context, filter TaskFilter, fn func(*models.Task) error) error {
+	tasks, err := r.ListTasks(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchesFilter(task *models.Task, filter TaskFilter) bool {
+	if filter.Project != "" && task.Project != filter.Project {
+		return false
+	}
+	if filter.Status != nil && task.Status != *filter.Status {
+		return false
+	}
+	if filter.Assignee != "" && task.Assignee != filter.Assignee {
+		return false
+	}
+	if filter.DueAfter != nil && (task.DueAt == nil || task.DueAt.Before(*filter.DueAfter)) {
+		return false
+	}
+	if filter.DueBefore != nil && (task.DueAt == nil || !task.DueAt.Before(*filter.DueBefore)) {
+		return false
+	}
+	return true
+}
+
+func (r *InMemoryTaskRepository) UpdateTask(ctx context.Context, task *models.Task) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.lookup(ctx, task.ID)
+	if !ok {
+		return ErrTaskNotFound
+	}
+	if err := r.checkExternalID(ctx, task); err != nil {
+		return err
+	}
+	task.UpdatedAt = time.Now().UTC()
+	task.Sequence = current.Sequence + 1
+	// Like the SQL repositories, an update leaves the creation time and the
+	// recurrence a task was materialised from alone.
+	updated := storedTask(task)
+	updated.CreatedAt = current.CreatedAt
+	updated.RecurrenceID = current.RecurrenceID
+	r.store(ctx, task.ID, updated)
+	return nil
+}
+
+func (r *InMemoryTaskRepository) DeleteTask(ctx context.Context, taskID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.lookup(ctx, taskID); !ok {
+		return ErrTaskNotFound
+	}
+	r.store(ctx, taskID, nil)
+	return nil
+}
+
+const taskSnapshotVersion = 1
+
+// taskSnapshot is the file format of InMemoryTaskRepository snapshots.
+type taskSnapshot struct {
+	Version int             `json:"version"`
+	SavedAt time.Time       `json:"saved_at"`
+	Tasks   []*snapshotTask `json:"tasks"`
+}
+
+type snapshotTask struct {
+	*models.Task
+	// KeyID is the ID of the key the description is encrypted under, like
+	// the key_id column of the SQL repositories; empty for plaintext.
+	KeyID string `json:"key_id,omitempty"`
+	// Sequence is the task's Sequence, which its JSON leaves out.
+	Sequence int64 `json:"sequence,omitempty"`
+}
+
+// SaveSnapshot writes all committed tasks to the file at path. The file is
+// replaced atomically, so a crash while saving leaves the previous snapshot
+// intact.
+func (r *InMemoryTaskRepository) SaveSnapshot(path string) error {
+	r.mu.RLock()
+	snapshot := taskSnapshot{
+		Version: taskSnapshotVersion,
+		SavedAt: time.Now().UTC(),
+		Tasks:   make([]*snapshotTask, 0, len(r.tasks)),
+	}
+	for _, task := range r.tasks {
+		snapshot.Tasks = append(snapshot.Tasks, &snapshotTask{Task: copyTask(task), Sequence: task.Sequence})
+	}
+	r.mu.RUnlock()
+	sort.Slice(snapshot.Tasks, func(i, j int) bool {
+		return snapshot.Tasks[i].CreatedAt.Before(snapshot.Tasks[j].CreatedAt)
+	})
+	for _, task := range snapshot.Tasks {
+		var err error
+		task.Description, task.KeyID, err = sealField(r.cipher, task.Description, taskDescriptionAAD(task.ID.String()))
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// LoadSnapshot replaces all tasks with the ones in the snapshot at path and
+// returns how many were loaded. A missing file loads nothing, so the first
+// run starts empty.
+func (r *InMemoryTaskRepository) LoadSnapshot(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var snapshot taskSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return 0, fmt.Errorf("decode snapshot %s: %w", path, err)
+	}
+	if snapshot.Version != taskSnapshotVersion {
+		return 0, fmt.Errorf("snapshot %s has version %d, want %d", path, snapshot.Version, taskSnapshotVersion)
+	}
+
+	tasks := make(map[uuid.UUID]*models.Task, len(snapshot.Tasks))
+	for _, entry := range snapshot.Tasks {
+		if entry == nil || entry.Task == nil || entry.ID == uuid.Nil {
+			return 0, fmt.Errorf("snapshot %s contains a task without an ID", path)
+		}
+		task := entry.Task
+		if _, ok := tasks[task.ID]; ok {
+			return 0, fmt.Errorf("snapshot %s contains task %s twice", path, task.ID)
+		}
+		if task.Description, err = openField(r.cipher, task.Description, entry.KeyID, taskDescriptionAAD(task.ID.String())); err != nil {
+			return 0, fmt.Errorf("snapshot %s: %w", path, err)
+		}
+		task.CreatedAt = task.CreatedAt.UTC()
+		task.UpdatedAt = task.UpdatedAt.UTC()
+		task.Sequence = entry.Sequence
+		tasks[task.ID] = storedTask(task)
+	}
+
+	r.mu.Lock()
+	r.tasks = tasks
+	r.mu.Unlock()
+	return len(tasks), nil
+}
@@ -0,0 +1,179 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+func TestInMemoryTaskRepositorySnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tasks.json")
+
+	empty := repository.NewInMemoryTaskRepository()
+	if count, err := empty.LoadSnapshot(path); err != nil || count != 0 {
+		t.Fatalf("load missing snapshot: count=%d err=%v", count, err)
+	}
+
+	repo := repository.NewInMemoryTaskRepository()
+	dueAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	var tasks []*models.Task
+	for _, title := range []string{"Pay rent", "Buy milk"} {
+		task := &models.Task{ID: uuid.New(), Project: models.DefaultProject, Title: title, Status: models.TaskStatusNew}
+		if err := repo.CreateTask(ctx, task); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		tasks = append(tasks, task)
+	}
+	tasks[0].DueAt = &dueAt
+	tasks[0].Assignee = "ann"
+	if err := repo.UpdateTask(ctx, tasks[0]); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := repo.SaveSnapshot(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	restored := repository.NewInMemoryTaskRepository()
+	if err := restored.CreateTask(ctx, &models.Task{ID: uuid.New(), Title: "Replaced"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if count, err := restored.LoadSnapshot(path); err != nil || count != 2 {
+		t.Fatalf("load: count=%d err=%v", count, err)
+	}
+	listed, err := restored.ListTasks(ctx, repository.TaskFilter{})
+	if err != nil || len(listed) != 2 {
+		t.Fatalf("list: %d tasks, %v", len(listed), err)
+	}
+	got, err := restored.GetTask(ctx, tasks[0].ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Title != "Pay rent" || got.Assignee != "ann" || got.DueAt == nil || !got.DueAt.Equal(dueAt) ||
+		!got.CreatedAt.Equal(tasks[0].CreatedAt) || !got.UpdatedAt.Equal(tasks[0].UpdatedAt) {
+		t.Fatalf("got %+v, want %+v", got, tasks[0])
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": 2, "tasks": []}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := restored.LoadSnapshot(path); err == nil {
+		t.Fatal("expected an error for an unknown snapshot version")
+	}
+	if err := os.WriteFile(path, []byte(`{"version": 1, "tasks": [`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := restored.LoadSnapshot(path); err == nil {
+		t.Fatal("expected an error for a truncated snapshot")
+	}
+	if _, err := restored.GetTask(ctx, tasks[0].ID); err != nil {
+		t.Fatalf("a failed load changed the tasks: %v", err)
+	}
+}
+
+// Writes made in a transaction are only seen by it until it commits, and
+// dropped if it rolls back.
+func TestInMemoryTaskRepositoryTransactions(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryTaskRepository()
+	transactor := repository.NewSQLTransactor(newSQLiteTestDB(t))
+	kept := &models.Task{ID: uuid.New(), Title: "Pay rent", ExternalID: "rent"}
+	if err := repo.CreateTask(ctx, kept); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	errRollback := errors.New("rollback")
+	created := &models.Task{ID: uuid.New(), Title: "Buy milk"}
+	err := transactor.WithinTx(ctx, func(txCtx context.Context) error {
+		if err := repo.CreateTask(txCtx, created); err != nil {
+			return err
+		}
+		if err := repo.DeleteTask(txCtx, kept.ID); err != nil {
+			return err
+		}
+		if _, err := repo.GetTask(txCtx, created.ID); err != nil {
+			t.Errorf("the transaction doesn't see its own task: %v", err)
+		}
+		if _, err := repo.GetTask(txCtx, kept.ID); !errors.Is(err, repository.ErrTaskNotFound) {
+			t.Errorf("the transaction sees the task it deleted: %v", err)
+		}
+		// The deleted task's external ID is free within the transaction.
+		if err := repo.CreateTask(txCtx, &models.Task{ID: uuid.New(), Title: "Pay rent again", ExternalID: "rent"}); err != nil {
+			t.Errorf("reuse the external ID: %v", err)
+		}
+		if listed, err := repo.ListTasks(txCtx, repository.TaskFilter{}); err != nil || len(listed) != 2 {
+			t.Errorf("list in the transaction: %d tasks, %v", len(listed), err)
+		}
+		if _, err := repo.GetTask(ctx, created.ID); !errors.Is(err, repository.ErrTaskNotFound) {
+			t.Errorf("an uncommitted task is visible outside the transaction: %v", err)
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("transaction: %v", err)
+	}
+	if _, err := repo.GetTask(ctx, created.ID); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Errorf("a rolled back task was kept: %v", err)
+	}
+	if got, err := repo.GetTaskByExternalID(ctx, "rent"); err != nil || got.ID != kept.ID {
+		t.Errorf("a rolled back delete was applied: %+v, %v", got, err)
+	}
+
+	err = transactor.WithinTx(ctx, func(txCtx context.Context) error {
+		kept.Title = "Pay the rent"
+		if err := repo.UpdateTask(txCtx, kept); err != nil {
+			return err
+		}
+		return repo.CreateTask(txCtx, created)
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+	if got, err := repo.GetTask(ctx, kept.ID); err != nil || got.Title != "Pay the rent" {
+		t.Errorf("committed update: %+v, %v", got, err)
+	}
+	if _, err := repo.GetTask(ctx, created.ID); err != nil {
+		t.Errorf("committed create: %v", err)
+	}
+}
+
+func TestInMemoryTaskRepositorySnapshotEncryption(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	keyring := newTestKeyring(t, "a")
+	repo := repository.NewInMemoryTaskRepository().WithCipher(keyring)
+	task := &models.Task{ID: uuid.New(), Title: "Pay rent", Description: "account 1234-5678"}
+	if err := repo.CreateTask(ctx, task); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := repo.SaveSnapshot(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if strings.Contains(string(data), task.Description) {
+		t.Errorf("snapshot contains the plaintext description: %s", data)
+	}
+
+	restored := repository.NewInMemoryTaskRepository().WithCipher(keyring)
+	if _, err := restored.LoadSnapshot(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got, err := restored.GetTask(ctx, task.ID); err != nil || got.Description != task.Description {
+		t.Errorf("restored task %+v, %v", got, err)
+	}
+	if _, err := repository.NewInMemoryTaskRepository().LoadSnapshot(path); !errors.Is(err, repository.ErrNoCipher) {
+		t.Errorf("load without keys: %v", err)
+	}
+}
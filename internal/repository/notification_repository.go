@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+var (
+	ErrPreferencesNotFound = errors.New("notification preferences not found")
+)
+
+type NotificationRepository interface {
+	GetPreferences(ctx context.Context, user string) (*models.NotificationPreferences, error)
+	SavePreferences(ctx context.Context, preferences *models.NotificationPreferences) error
+	MarkDigestSent(ctx context.Context, user string, sentAt time.Time) error
+
+	AddDigestItem(ctx context.Context, item *models.DigestItem) error
+	// ListDigestUsers returns the users that have pending digest items.
+	ListDigestUsers(ctx context.Context) ([]string, error)
+	ListDigestItems(ctx context.Context, user string) ([]*models.DigestItem, error)
+	// DeleteDigestItems removes a user's digest items up to and including
+	// the given ID, i.e. the ones that were just sent.
+	DeleteDigestItems(ctx context.Context, user string, upToID int64) error
+}
+
+type SQLiteNotificationRepository struct {
+	db     *sql.DB
+	cipher FieldCipher
+}
+
+func NewSQLiteNotificationRepository(db *sql.DB) *SQLiteNotificationRepository {
+	return &SQLiteNotificationRepository{db: db}
+}
+
+// WithCipher encrypts digest item payloads with cipher. They hold the
+// notified tasks and comments, descriptions and bodies included.
+func (r *SQLiteNotificationRepository) WithCipher(cipher FieldCipher) *SQLiteNotificationRepository {
+	r.cipher = cipher
+	return r
+}
+
+func (r *SQLiteNotificationRepository) GetPreferences(ctx context.Context, user string) (*models.NotificationPreferences, error) {
+	const query = `
+SELECT "user", email, email_enabled, events, digest, last_digest_at, updated_at
+FROM notification_preferences
+WHERE "user" = ?
+`
+	var preferences models.NotificationPreferences
+	var eventsStr, digestStr, updatedAtStr string
+	var lastDigestAtStr sql.NullString
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, user).Scan(
+		&preferences.User,
+		&preferences.Email,
+		&preferences.EmailEnabled,
+		&eventsStr,
+		&digestStr,
+		&lastDigestAtStr,
+		&updatedAtStr,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPreferencesNotFound
+		}
+		return nil, err
+	}
+	preferences.Digest = models.DigestMode(digestStr)
+	if err := json.Unmarshal([]byte(eventsStr), &preferences.Events); err != nil {
+		return nil, fmt.Errorf("parse events: %w", err)
+	}
+	if preferences.LastDigestAt, err = parseNullableTime(lastDigestAtStr); err != nil {
+		return nil, fmt.Errorf("parse last_digest_at: %w", err)
+	}
+	if preferences.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAtStr); err != nil {
+		return nil, fmt.Errorf("parse updated_at: %w", err)
+	}
+	return &preferences, nil
+}
+
+func (r *SQLiteNotificationRepository) SavePreferences(ctx context.Context, preferences *models.NotificationPreferences) error {
+	preferences.UpdatedAt = time.Now().UTC()
+	events, err := json.Marshal(preferences.Events)
+	if err != nil {
+		return err
+	}
+
+	const query = `
+INSERT INTO notification_preferences ("user", email, email_enabled, events, digest, last_digest_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT ("user") DO UPDATE SET
+  email = excluded.email,
+  email_enabled = excluded.email_enabled,
+  events = excluded.events,
+  digest = excluded.digest,
+  updated_at = excluded.updated_at
+`
+	_, err = conn(ctx, r.db).ExecContext(ctx, query,
+		preferences.User,
+		preferences.Email,
+		preferences.EmailEnabled,
+		string(events),
+		string(preferences.Digest),
+		formatNullableTime(preferences.LastDigestAt),
+		preferences.UpdatedAt.Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+func (r *SQLiteNotificationRepository) MarkDigestSent(ctx context.Context, user string, sentAt time.Time) error {
+	const query = `UPDATE notification_preferences SET last_digest_at = ? WHERE "user" = ?`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, formatNullableTime(&sentAt), user)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrPreferencesNotFound
+	}
+	return nil
+}
+
+func (r *SQLiteNotificationRepository) AddDigestItem(ctx context.Context, item *models.DigestItem) error {
+	item.CreatedAt = time.Now().UTC()
+
+	const query = `
+INSERT INTO notification_digest_items ("user", type, payload, created_at)
+VALUES (?, ?, ?, ?)
+RETURNING id
+`
+	payload := string(item.Payload)
+	return withinTx(ctx, r.db, func(ctx context.Context, q querier) error {
+		stored := payload
+		if r.cipher != nil {
+			stored = ""
+		}
+		if err := q.QueryRowContext(ctx, query,
+			item.User,
+			item.Type,
+			stored,
+			item.CreatedAt.Format(time.RFC3339Nano),
+		).Scan(&item.ID); err != nil {
+			return err
+		}
+		return sealPayload(ctx, q, r.cipher, "notification_digest_items", item.ID, payload, digestItemPayloadAAD)
+	})
+}
+
+func (r *SQLiteNotificationRepository) ListDigestUsers(ctx context.Context) ([]string, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT DISTINCT "user" FROM notification_digest_items ORDER BY "user"`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var users []string
+	for rows.Next() {
+		var user string
+		if err := rows.Scan(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *SQLiteNotificationRepository) ListDigestItems(ctx context.Context, user string) ([]*models.DigestItem, error) {
+	const query = `
+SELECT id, "user", type, payload, key_id, created_at
+FROM notification_digest_items
+WHERE "user" = ?
+ORDER BY id
+`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, user)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var items []*models.DigestItem
+	for rows.Next() {
+		var item models.DigestItem
+		var payload, keyID, createdAtStr string
+		if err := rows.Scan(&item.ID, &item.User, &item.Type, &payload, &keyID, &createdAtStr); err != nil {
+			return nil, err
+		}
+		if payload, err = openField(r.cipher, payload, keyID, digestItemPayloadAAD(strconv.FormatInt(item.ID, 10))); err != nil {
+			return nil, err
+		}
+		item.Payload = []byte(payload)
+		if item.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+			return nil, fmt.Errorf("parse created_at: %w", err)
+		}
+		items = append(items, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *SQLiteNotificationRepository) DeleteDigestItems(ctx context.Context, user string, upToID int64) error {
+	const query = `DELETE FROM notification_digest_items WHERE "user" = ? AND id <= ?`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, user, upToID)
+	return err
+}
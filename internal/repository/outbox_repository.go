@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+var (
+	ErrOutboxAlreadyDelivered = errors.New("outbox message already delivered")
+)
+
+type OutboxRepository interface {
+	// AddMessage stores the message and sets its ID. Call it with the ctx
+	// of the transaction that makes the change the message describes.
+	AddMessage(ctx context.Context, message *models.OutboxMessage) error
+	// ListPending returns up to limit messages, in ID order, that were
+	// neither delivered to the sink nor given up on. Messages are selected
+	// by their delivery state rather than after the last one settled: on
+	// PostgreSQL IDs are taken when a message is added but become visible
+	// when its transaction commits, which needn't be in ID order.
+	ListPending(ctx context.Context, sink string, limit int) ([]*models.OutboxMessage, error)
+	// MarkDelivered records the delivery of a message to the sink. It
+	// returns ErrOutboxAlreadyDelivered if that was already recorded, so
+	// that a sink running in the same transaction can be rolled back.
+	MarkDelivered(ctx context.Context, messageID int64, sink string) error
+	// RecordFailure records a failed delivery attempt. A nil retryAt gives
+	// up on delivering the message to the sink.
+	RecordFailure(ctx context.Context, messageID int64, sink string, attempts int, lastError string, retryAt *time.Time) error
+	// PruneMessages deletes messages created before the given time that are
+	// settled, delivered or given up on, for every one of the sinks.
+	PruneMessages(ctx context.Context, before time.Time, sinks []string) (int64, error)
+}
+
+type SQLiteOutboxRepository struct {
+	db     *sql.DB
+	cipher FieldCipher
+}
+
+func NewSQLiteOutboxRepository(db *sql.DB) *SQLiteOutboxRepository {
+	return &SQLiteOutboxRepository{db: db}
+}
+
+// WithCipher encrypts message payloads with cipher. They hold the events'
+// tasks and comments, descriptions and bodies included.
+func (r *SQLiteOutboxRepository) WithCipher(cipher FieldCipher) *SQLiteOutboxRepository {
+	r.cipher = cipher
+	return r
+}
+
+func (r *SQLiteOutboxRepository) AddMessage(ctx context.Context, message *models.OutboxMessage) error {
+	message.CreatedAt = time.Now().UTC()
+
+	const query = `
+INSERT INTO outbox (type, task_id, payload, created_at)
+VALUES (?, ?, ?, ?)
+RETURNING id
+`
+	payload := string(message.Payload)
+	return withinTx(ctx, r.db, func(ctx context.Context, q querier) error {
+		stored := payload
+		if r.cipher != nil {
+			stored = ""
+		}
+		if err := q.QueryRowContext(ctx, query,
+			message.Type,
+			message.TaskID.String(),
+			stored,
+			message.CreatedAt.Format(time.RFC3339Nano),
+		).Scan(&message.ID); err != nil {
+			return err
+		}
+		return sealPayload(ctx, q, r.cipher, "outbox", message.ID, payload, outboxPayloadAAD)
+	})
+}
+
+func (r *SQLiteOutboxRepository) ListPending(ctx context.Context, sink string, limit int) ([]*models.OutboxMessage, error) {
+	const query = `
+SELECT o.id, o.type, o.task_id, o.payload, o.key_id, o.created_at, COALESCE(d.attempts, 0), d.retry_at
+FROM outbox o
+LEFT JOIN outbox_deliveries d ON d.outbox_id = o.id AND d.sink = ?
+WHERE d.status IS NULL OR d.status = ?
+ORDER BY o.id
+LIMIT ?
+`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, sink, string(models.OutboxDeliveryRetrying), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var messages []*models.OutboxMessage
+	for rows.Next() {
+		var message models.OutboxMessage
+		var payload, keyID, createdAtStr string
+		var retryAt sql.NullInt64
+		if err := rows.Scan(
+			&message.ID,
+			&message.Type,
+			&message.TaskID,
+			&payload,
+			&keyID,
+			&createdAtStr,
+			&message.Attempts,
+			&retryAt,
+		); err != nil {
+			return nil, err
+		}
+		if payload, err = openField(r.cipher, payload, keyID, outboxPayloadAAD(strconv.FormatInt(message.ID, 10))); err != nil {
+			return nil, err
+		}
+		message.Payload = []byte(payload)
+		if message.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+			return nil, fmt.Errorf("parse created_at: %w", err)
+		}
+		if retryAt.Valid {
+			t := time.UnixMilli(retryAt.Int64).UTC()
+			message.RetryAt = &t
+		}
+		messages = append(messages, &message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *SQLiteOutboxRepository) MarkDelivered(ctx context.Context, messageID int64, sink string) error {
+	// The guarded upsert only changes a delivery that isn't settled yet, so
+	// two relays racing for the same message can't both record it.
+	const query = `
+INSERT INTO outbox_deliveries (outbox_id, sink, status, attempts, updated_at)
+VALUES (?, ?, ?, 1, ?)
+ON CONFLICT (outbox_id, sink) DO UPDATE
+SET status = excluded.status, attempts = outbox_deliveries.attempts + 1, last_error = '', retry_at = NULL, updated_at = excluded.updated_at
+WHERE outbox_deliveries.status = ?
+`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query,
+		messageID,
+		sink,
+		string(models.OutboxDeliveryDelivered),
+		time.Now().UTC().Format(time.RFC3339Nano),
+		string(models.OutboxDeliveryRetrying),
+	)
+	if err != nil {
+		return err
+	}
+	return expectRow(result, ErrOutboxAlreadyDelivered)
+}
+
+func (r *SQLiteOutboxRepository) RecordFailure(ctx context.Context, messageID int64, sink string, attempts int, lastError string, retryAt *time.Time) error {
+	status := models.OutboxDeliveryDead
+	var retryAtMillis sql.NullInt64
+	if retryAt != nil {
+		status = models.OutboxDeliveryRetrying
+		retryAtMillis = sql.NullInt64{Int64: retryAt.UnixMilli(), Valid: true}
+	}
+
+	const query = `
+INSERT INTO outbox_deliveries (outbox_id, sink, status, attempts, last_error, retry_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (outbox_id, sink) DO UPDATE
+SET status = excluded.status, attempts = excluded.attempts, last_error = excluded.last_error,
+    retry_at = excluded.retry_at, updated_at = excluded.updated_at
+WHERE outbox_deliveries.status = ?
+`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query,
+		messageID,
+		sink,
+		string(status),
+		attempts,
+		lastError,
+		retryAtMillis,
+		time.Now().UTC().Format(time.RFC3339Nano),
+		string(models.OutboxDeliveryRetrying),
+	)
+	return err
+}
+
+func (r *SQLiteOutboxRepository) PruneMessages(ctx context.Context, before time.Time, sinks []string) (int64, error) {
+	if len(sinks) == 0 {
+		return 0, nil
+	}
+	query := `
+DELETE FROM outbox
+WHERE created_at < ? AND (
+  SELECT COUNT(*) FROM outbox_deliveries d
+  WHERE d.outbox_id = outbox.id AND d.status <> ? AND d.sink IN (?` + strings.Repeat(", ?", len(sinks)-1) + `)
+) = ?
+`
+	queryArgs := []any{before.UTC().Format(time.RFC3339Nano), string(models.OutboxDeliveryRetrying)}
+	for _, sink := range sinks {
+		queryArgs = append(queryArgs, sink)
+	}
+	queryArgs = append(queryArgs, len(sinks))
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, queryArgs...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
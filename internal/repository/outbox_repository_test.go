@@ -0,0 +1,98 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+func addOutboxMessage(t *testing.T, ctx context.Context, repo repository.OutboxRepository) *models.OutboxMessage {
+	t.Helper()
+	message := &models.OutboxMessage{Type: "task.created", TaskID: uuid.New(), Payload: []byte(`{}`)}
+	if err := repo.AddMessage(ctx, message); err != nil {
+		t.Fatalf("add message: %v", err)
+	}
+	return message
+}
+
+func pendingIDs(t *testing.T, repo repository.OutboxRepository, sink string) []int64 {
+	t.Helper()
+	messages, err := repo.ListPending(context.Background(), sink, 10)
+	if err != nil {
+		t.Fatalf("list pending: %v", err)
+	}
+	var ids []int64
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	return ids
+}
+
+func TestOutboxListPending(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewSQLiteOutboxRepository(newSQLiteTestDB(t))
+	first := addOutboxMessage(t, ctx, repo)
+	delivered := addOutboxMessage(t, ctx, repo)
+	dead := addOutboxMessage(t, ctx, repo)
+	if err := repo.MarkDelivered(ctx, delivered.ID, "log"); err != nil {
+		t.Fatalf("mark delivered: %v", err)
+	}
+	if err := repo.RecordFailure(ctx, dead.ID, "log", 3, "boom", nil); err != nil {
+		t.Fatalf("record failure: %v", err)
+	}
+
+	// A message before a settled one is still pending.
+	if ids := pendingIDs(t, repo, "log"); len(ids) != 1 || ids[0] != first.ID {
+		t.Errorf("pending %v, want [%d]", ids, first.ID)
+	}
+	if ids := pendingIDs(t, repo, "webhooks"); len(ids) != 3 {
+		t.Errorf("pending for another sink %v", ids)
+	}
+}
+
+// A message whose transaction commits after a message with a higher ID was
+// delivered is still delivered.
+func TestPostgresOutboxLateCommit(t *testing.T) {
+	ctx := context.Background()
+	db := newPostgresTestDB(t)
+	repo := repository.NewSQLiteOutboxRepository(db)
+
+	added := make(chan *models.OutboxMessage)
+	commit := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- repository.NewSQLTransactor(db).WithinTx(ctx, func(ctx context.Context) error {
+			message := &models.OutboxMessage{Type: "task.created", TaskID: uuid.New(), Payload: []byte(`{}`)}
+			if err := repo.AddMessage(ctx, message); err != nil {
+				close(added)
+				return err
+			}
+			added <- message
+			<-commit
+			return nil
+		})
+	}()
+	early, ok := <-added
+	if !ok {
+		t.Fatalf("add message: %v", <-done)
+	}
+	late := addOutboxMessage(t, ctx, repo)
+	if ids := pendingIDs(t, repo, "log"); len(ids) != 1 || ids[0] != late.ID {
+		t.Fatalf("pending before the commit %v, want [%d]", ids, late.ID)
+	}
+	if err := repo.MarkDelivered(ctx, late.ID, "log"); err != nil {
+		t.Fatalf("mark delivered: %v", err)
+	}
+
+	close(commit)
+	if err := <-done; err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if ids := pendingIDs(t, repo, "log"); len(ids) != 1 || ids[0] != early.ID || early.ID > late.ID {
+		t.Errorf("pending after the commit %v, want [%d] before %d", ids, early.ID, late.ID)
+	}
+}
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+)
+
+// PostgresTaskRepository stores tasks in PostgreSQL with native uuid and
+// timestamptz columns. timestamptz keeps microseconds, so timestamps are
+// truncated to that before they are stored and tasks read back are equal
+// to the ones written.
+type PostgresTaskRepository struct {
+	db     *sql.DB
+	cipher FieldCipher
+}
+
+func NewPostgresTaskRepository(db *sql.DB) *PostgresTaskRepository {
+	return &PostgresTaskRepository{db: db}
+}
+
+// WithCipher encrypts task descriptions with cipher.
+func (r *PostgresTaskRepository) WithCipher(cipher FieldCipher) *PostgresTaskRepository {
+	r.cipher = cipher
+	return r
+}
+
+const postgresTaskColumns = `id, project, title, description, status, assignee, due_at, recurrence_id, created_at, updated_at, key_id, external_id, sequence`
+
+func scanPostgresTask(scanner interface{ Scan(dest ...any) error }, cipher FieldCipher) (*models.Task, error) {
+	var task models.Task
+	var status string
+	var keyID string
+	var externalID sql.NullString
+	var dueAt sql.NullTime
+	var recurrenceID uuid.NullUUID
+	if err := scanner.Scan(
+		&task.ID,
+		&task.Project,
+		&task.Title,
+		&task.Description,
+		&status,
+		&task.Assignee,
+		&dueAt,
+		&recurrenceID,
+		&task.CreatedAt,
+		&task.UpdatedAt,
+		&keyID,
+		&externalID,
+		&task.Sequence,
+	); err != nil {
+		return nil, err
+	}
+	task.Status = models.TaskStatus(status)
+	task.ExternalID = externalID.String
+	description, err := openField(cipher, task.Description, keyID, taskDescriptionAAD(task.ID.String()))
+	if err != nil {
+		return nil, err
+	}
+	task.Description = description
+	if dueAt.Valid {
+		t := dueAt.Time.UTC()
+		task.DueAt = &t
+	}
+	if recurrenceID.Valid {
+		task.RecurrenceID = &recurrenceID.UUID
+	}
+	task.CreatedAt = task.CreatedAt.UTC()
+	task.UpdatedAt = task.UpdatedAt.UTC()
+	return &task, nil
+}
+
+func (r *PostgresTaskRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func (r *PostgresTaskRepository) CreateTask(ctx context.Context, task *models.Task) error {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	task.CreatedAt = now
+	task.UpdatedAt = now
+
+	description, keyID, err := sealField(r.cipher, task.Description, taskDescriptionAAD(task.ID.String()))
+	if err != nil {
+		return err
+	}
+	const query = `
+INSERT INTO tasks (` + postgresTaskColumns + `)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+`
+	_, err = conn(ctx, r.db).ExecContext(ctx, query,
+		task.ID,
+		task.Project,
+		task.Title,
+		description,
+		string(task.Status),
+		task.Assignee,
+		task.DueAt,
+		task.RecurrenceID,
+		task.CreatedAt,
+		task.UpdatedAt,
+		keyID,
+		formatNullableString(task.ExternalID),
+		task.Sequence,
+	)
+	return err
+}
+
+func (r *PostgresTaskRepository) GetTask(ctx context.Context, taskID uuid.UUID) (*models.Task, error) {
+	query := `SELECT ` + postgresTaskColumns + ` FROM tasks WHERE id = $1`
+	// Within a transaction the row stays locked until it ends, so that a
+	// read-modify-write update can't overwrite a concurrent one.
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		query += ` FOR UPDATE`
+	}
+	task, err := scanPostgresTask(conn(ctx, r.db).QueryRowContext(ctx, query, taskID), r.cipher)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTaskNotFound
+	}
+	return task, err
+}
+
+func (r *PostgresTaskRepository) GetTaskByExternalID(ctx context.Context, externalID string) (*models.Task, error) {
+	query := `SELECT ` + postgresTaskColumns + ` FROM tasks WHERE external_id = $1`
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		query += ` FOR UPDATE`
+	}
+	task, err := scanPostgresTask(conn(ctx, r.db).QueryRowContext(ctx, query, externalID), r.cipher)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTaskNotFound
+	}
+	return task, err
+}
+
+func (r *PostgresTaskRepository) ListTasks(ctx context.Context, filter TaskFilter) ([]*models.Task, error) {
+	var tasks []*models.Task
+	err := r.EachTask(ctx, filter, func(task *models.Task) error {
+		tasks = append(tasks, task)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r *PostgresTaskRepository) EachTask(ctx context.Context, filter TaskFilter, fn func(*models.Task) error) error {
+	query := `SELECT ` + postgresTaskColumns + ` FROM tasks `
+	var queryArgs []any
+	var conditions []string
+	addCondition := func(condition string, arg any) {
+		queryArgs = append(queryArgs, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(queryArgs)))
+	}
+	if filter.Project != "" {
+		addCondition("project = $%d", filter.Project)
+	}
+	if filter.Status != nil {
+		addCondition("status = $%d", string(*filter.Status))
+	}
+	if filter.Assignee != "" {
+		addCondition("assignee = $%d", filter.Assignee)
+	}
+	if filter.DueAfter != nil {
+		addCondition("due_at >= $%d", *filter.DueAfter)
+	}
+	if filter.DueBefore != nil {
+		addCondition("due_at < $%d", *filter.DueBefore)
+	}
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ") + " "
+	}
+	query += "ORDER BY created_at DESC "
+	if filter.Limit > 0 {
+		queryArgs = append(queryArgs, filter.Limit)
+		query += fmt.Sprintf("LIMIT $%d ", len(queryArgs))
+	}
+	if filter.Offset > 0 {
+		queryArgs = append(queryArgs, filter.Offset)
+		query += fmt.Sprintf("OFFSET $%d ", len(queryArgs))
+	}
+	// Within a transaction the rows stay locked until it ends, like the
+	// row GetTask reads.
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		query += "FOR UPDATE"
+	}
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		task, err := scanPostgresTask(rows, r.cipher)
+		if err != nil {
+			return err
+		}
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *PostgresTaskRepository) UpdateTask(ctx context.Context, task *models.Task) error {
+	task.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	description, keyID, err := sealField(r.cipher, task.Description, taskDescriptionAAD(task.ID.String()))
+	if err != nil {
+		return err
+	}
+	const query = `
+UPDATE tasks
+SET project = $1, title = $2, description = $3, status = $4, assignee = $5, due_at = $6, updated_at = $7, key_id = $8, external_id = $9,
+  sequence = sequence + 1
+WHERE id = $10
+RETURNING sequence
+`
+	err = conn(ctx, r.db).QueryRowContext(ctx, query,
+		task.Project,
+		task.Title,
+		description,
+		string(task.Status),
+		task.Assignee,
+		task.DueAt,
+		task.UpdatedAt,
+		keyID,
+		formatNullableString(task.ExternalID),
+		task.ID,
+	).Scan(&task.Sequence)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTaskNotFound
+	}
+	return err
+}
+
+func (r *PostgresTaskRepository) DeleteTask(ctx context.Context, taskID uuid.UUID) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM tasks WHERE id = $1`, taskID)
+	if err != nil {
+		return err
+	}
+	return expectRow(result, ErrTaskNotFound)
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+)
+
+var (
+	ErrRecurrenceNotFound = errors.New("recurrence not found")
+)
+
+type RecurrenceRepository interface {
+	CreateRecurrence(ctx context.Context, recurrence *models.Recurrence) error
+	GetRecurrence(ctx context.Context, recurrenceID uuid.UUID) (*models.Recurrence, error)
+	ListRecurrences(ctx context.Context, activeOnly bool) ([]*models.Recurrence, error)
+	DeactivateRecurrence(ctx context.Context, recurrenceID uuid.UUID) error
+	// AdvanceRecurrence moves the recurrence past the occurrence with the
+	// given 1-based index, whose task is taskID. It returns false without
+	// changes if the recurrence has moved on since it was read, which makes
+	// concurrent or repeated runs harmless when the task is created in the
+	// same transaction.
+	AdvanceRecurrence(ctx context.Context, recurrence *models.Recurrence, index int, dueAt *time.Time, taskID uuid.UUID) (bool, error)
+}
+
+type SQLiteRecurrenceRepository struct {
+	db     *sql.DB
+	cipher FieldCipher
+}
+
+func NewSQLiteRecurrenceRepository(db *sql.DB) *SQLiteRecurrenceRepository {
+	return &SQLiteRecurrenceRepository{db: db}
+}
+
+// WithCipher encrypts recurrence descriptions with cipher.
+func (r *SQLiteRecurrenceRepository) WithCipher(cipher FieldCipher) *SQLiteRecurrenceRepository {
+	r.cipher = cipher
+	return r
+}
+
+func (r *SQLiteRecurrenceRepository) CreateRecurrence(ctx context.Context, recurrence *models.Recurrence) error {
+	now := time.Now().UTC()
+	recurrence.CreatedAt = now
+	recurrence.UpdatedAt = now
+
+	description, keyID, err := sealField(r.cipher, recurrence.Description, recurrenceDescriptionAAD(recurrence.ID.String()))
+	if err != nil {
+		return err
+	}
+	const query = `
+INSERT INTO recurrences (id, project, title, description, rule, timezone, starts_at, active, occurrences, created_at, updated_at, key_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+	_, err = conn(ctx, r.db).ExecContext(ctx, query,
+		recurrence.ID.String(),
+		recurrence.Project,
+		recurrence.Title,
+		description,
+		recurrence.Rule,
+		recurrence.Timezone,
+		recurrence.StartsAt.UTC().Format(time.RFC3339Nano),
+		recurrence.Active,
+		recurrence.Occurrences,
+		recurrence.CreatedAt.Format(time.RFC3339Nano),
+		recurrence.UpdatedAt.Format(time.RFC3339Nano),
+		keyID,
+	)
+	return err
+}
+
+const selectRecurrence = `
+SELECT id, project, title, description, rule, timezone, starts_at, active, occurrences, last_occurrence_at, last_task_id, created_at, updated_at, key_id
+FROM recurrences
+`
+
+func (r *SQLiteRecurrenceRepository) scanRecurrence(scanner interface{ Scan(dest ...any) error }) (*models.Recurrence, error) {
+	var recurrence models.Recurrence
+	var startsAtStr, createdAtStr, updatedAtStr, keyID string
+	var lastOccurrenceAtStr, lastTaskIDStr sql.NullString
+	if err := scanner.Scan(
+		&recurrence.ID,
+		&recurrence.Project,
+		&recurrence.Title,
+		&recurrence.Description,
+		&recurrence.Rule,
+		&recurrence.Timezone,
+		&startsAtStr,
+		&recurrence.Active,
+		&recurrence.Occurrences,
+		&lastOccurrenceAtStr,
+		&lastTaskIDStr,
+		&createdAtStr,
+		&updatedAtStr,
+		&keyID,
+	); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if recurrence.Description, err = openField(r.cipher, recurrence.Description, keyID, recurrenceDescriptionAAD(recurrence.ID.String())); err != nil {
+		return nil, err
+	}
+	if recurrence.StartsAt, err = time.Parse(time.RFC3339Nano, startsAtStr); err != nil {
+		return nil, fmt.Errorf("parse starts_at: %w", err)
+	}
+	if recurrence.LastOccurrenceAt, err = parseNullableTime(lastOccurrenceAtStr); err != nil {
+		return nil, fmt.Errorf("parse last_occurrence_at: %w", err)
+	}
+	if recurrence.LastTaskID, err = parseNullableUUID(lastTaskIDStr); err != nil {
+		return nil, fmt.Errorf("parse last_task_id: %w", err)
+	}
+	if recurrence.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	if recurrence.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAtStr); err != nil {
+		return nil, fmt.Errorf("parse updated_at: %w", err)
+	}
+	return &recurrence, nil
+}
+
+func (r *SQLiteRecurrenceRepository) GetRecurrence(ctx context.Context, recurrenceID uuid.UUID) (*models.Recurrence, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, selectRecurrence+"WHERE id = ?", recurrenceID.String())
+	recurrence, err := r.scanRecurrence(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecurrenceNotFound
+	}
+	return recurrence, err
+}
+
+func (r *SQLiteRecurrenceRepository) ListRecurrences(ctx context.Context, activeOnly bool) ([]*models.Recurrence, error) {
+	query := selectRecurrence
+	if activeOnly {
+		query += "WHERE active = TRUE "
+	}
+	query += "ORDER BY created_at"
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var recurrences []*models.Recurrence
+	for rows.Next() {
+		recurrence, err := r.scanRecurrence(rows)
+		if err != nil {
+			return nil, err
+		}
+		recurrences = append(recurrences, recurrence)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return recurrences, nil
+}
+
+func (r *SQLiteRecurrenceRepository) DeactivateRecurrence(ctx context.Context, recurrenceID uuid.UUID) error {
+	const query = `UPDATE recurrences SET active = FALSE, updated_at = ? WHERE id = ?`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, time.Now().UTC().Format(time.RFC3339Nano), recurrenceID.String())
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecurrenceNotFound
+	}
+	return nil
+}
+
+func (r *SQLiteRecurrenceRepository) AdvanceRecurrence(ctx context.Context, recurrence *models.Recurrence, index int, dueAt *time.Time, taskID uuid.UUID) (bool, error) {
+	now := time.Now().UTC()
+	const query = `
+UPDATE recurrences
+SET occurrences = ?, last_occurrence_at = ?, last_task_id = ?, updated_at = ?
+WHERE id = ? AND occurrences = ? AND active = TRUE
+`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query,
+		index,
+		formatNullableTime(dueAt),
+		taskID.String(),
+		now.Format(time.RFC3339Nano),
+		recurrence.ID.String(),
+		recurrence.Occurrences,
+	)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	recurrence.Occurrences = index
+	recurrence.LastOccurrenceAt = dueAt
+	recurrence.LastTaskID = &taskID
+	recurrence.UpdatedAt = now
+	return true, nil
+}
@@ -0,0 +1,396 @@
+// Package repositorytest implements conformance tests for repository
+// implementations.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+// TestTaskRepository checks that the repositories returned by newRepository
+// behave like a TaskRepository. Every subtest gets a new, empty repository.
+func TestTaskRepository(t *testing.T, newRepository func(t *testing.T) repository.TaskRepository) {
+	t.Run("CreateAndGet", func(t *testing.T) { testCreateAndGet(t, newRepository(t)) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newRepository(t)) })
+	t.Run("DuplicateID", func(t *testing.T) { testDuplicateID(t, newRepository(t)) })
+	t.Run("ExternalID", func(t *testing.T) { testExternalID(t, newRepository(t)) })
+	t.Run("UpdateAndDelete", func(t *testing.T) { testUpdateAndDelete(t, newRepository(t)) })
+	t.Run("ReturnsCopies", func(t *testing.T) { testReturnsCopies(t, newRepository(t)) })
+	t.Run("List", func(t *testing.T) { testList(t, newRepository(t)) })
+	t.Run("Each", func(t *testing.T) { testEach(t, newRepository(t)) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, newRepository(t)) })
+}
+
+func newTask(title string) *models.Task {
+	return &models.Task{
+		ID:          uuid.New(),
+		Project:     models.DefaultProject,
+		Title:       title,
+		Description: "desc",
+		Status:      models.TaskStatusNew,
+	}
+}
+
+func createTask(t *testing.T, repo repository.TaskRepository, task *models.Task) {
+	t.Helper()
+	if err := repo.CreateTask(context.Background(), task); err != nil {
+		t.Fatalf("create %q: %v", task.Title, err)
+	}
+}
+
+func getTask(t *testing.T, repo repository.TaskRepository, taskID uuid.UUID) *models.Task {
+	t.Helper()
+	task, err := repo.GetTask(context.Background(), taskID)
+	if err != nil {
+		t.Fatalf("get %s: %v", taskID, err)
+	}
+	return task
+}
+
+func testCreateAndGet(t *testing.T, repo repository.TaskRepository) {
+	dueAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+	task := newTask("Pay rent")
+	task.Assignee = "ann"
+	task.DueAt = &dueAt
+	before := time.Now().Add(-time.Millisecond)
+	createTask(t, repo, task)
+	after := time.Now().Add(time.Millisecond)
+	if task.CreatedAt.Before(before) || task.CreatedAt.After(after) || !task.UpdatedAt.Equal(task.CreatedAt) {
+		t.Fatalf("create set timestamps %v and %v, want the current time", task.CreatedAt, task.UpdatedAt)
+	}
+
+	got := getTask(t, repo, task.ID)
+	if got.ID != task.ID || got.Project != task.Project || got.Title != task.Title || got.Description != task.Description ||
+		got.Status != task.Status || got.Assignee != task.Assignee || got.RecurrenceID != nil {
+		t.Fatalf("got %+v, want %+v", got, task)
+	}
+	if got.DueAt == nil || !got.DueAt.Equal(dueAt) || got.DueAt.Location() != time.UTC {
+		t.Fatalf("due_at %v, want %v in UTC", got.DueAt, dueAt)
+	}
+	if !got.CreatedAt.Equal(task.CreatedAt) || !got.UpdatedAt.Equal(task.UpdatedAt) ||
+		got.CreatedAt.Location() != time.UTC || got.UpdatedAt.Location() != time.UTC {
+		t.Fatalf("timestamps %v and %v, want %v in UTC", got.CreatedAt, got.UpdatedAt, task.CreatedAt)
+	}
+}
+
+func testNotFound(t *testing.T, repo repository.TaskRepository) {
+	ctx := context.Background()
+	if _, err := repo.GetTask(ctx, uuid.New()); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Fatalf("get: expected ErrTaskNotFound, got %v", err)
+	}
+	if err := repo.UpdateTask(ctx, newTask("Missing")); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Fatalf("update: expected ErrTaskNotFound, got %v", err)
+	}
+	if err := repo.DeleteTask(ctx, uuid.New()); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Fatalf("delete: expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func testDuplicateID(t *testing.T, repo repository.TaskRepository) {
+	task := newTask("Pay rent")
+	createTask(t, repo, task)
+	duplicate := newTask("Buy milk")
+	duplicate.ID = task.ID
+	if err := repo.CreateTask(context.Background(), duplicate); err == nil {
+		t.Fatal("expected an error for a duplicate ID")
+	}
+	if got := getTask(t, repo, task.ID); got.Title != "Pay rent" {
+		t.Fatalf("duplicate overwrote the task: %+v", got)
+	}
+}
+
+func testExternalID(t *testing.T, repo repository.TaskRepository) {
+	ctx := context.Background()
+	// Tasks without an external ID don't conflict.
+	createTask(t, repo, newTask("Buy milk"))
+	createTask(t, repo, newTask("Buy bread"))
+	task := newTask("Pay rent")
+	task.ExternalID = "SHEET-1"
+	createTask(t, repo, task)
+
+	got, err := repo.GetTaskByExternalID(ctx, "SHEET-1")
+	if err != nil || got.ID != task.ID || got.ExternalID != "SHEET-1" {
+		t.Fatalf("get by external ID: %+v, %v", got, err)
+	}
+	if _, err := repo.GetTaskByExternalID(ctx, "SHEET-2"); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Fatalf("get unknown external ID: %v, want ErrTaskNotFound", err)
+	}
+	if _, err := repo.GetTaskByExternalID(ctx, ""); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Fatalf("get empty external ID: %v, want ErrTaskNotFound", err)
+	}
+
+	duplicate := newTask("Pay rent again")
+	duplicate.ExternalID = "SHEET-1"
+	if err := repo.CreateTask(ctx, duplicate); err == nil {
+		t.Fatal("expected an error for a duplicate external ID")
+	}
+
+	task.ExternalID = "SHEET-2"
+	if err := repo.UpdateTask(ctx, task); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, err := repo.GetTaskByExternalID(ctx, "SHEET-2"); err != nil || got.ID != task.ID {
+		t.Fatalf("get by changed external ID: %+v, %v", got, err)
+	}
+	if _, err := repo.GetTaskByExternalID(ctx, "SHEET-1"); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Fatalf("get old external ID: %v, want ErrTaskNotFound", err)
+	}
+}
+
+func testUpdateAndDelete(t *testing.T, repo repository.TaskRepository) {
+	ctx := context.Background()
+	task := newTask("Pay rent")
+	createTask(t, repo, task)
+	createdAt := task.CreatedAt
+	time.Sleep(2 * time.Millisecond)
+
+	dueAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	task.Title = "Pay the rent"
+	task.Description = "by transfer"
+	task.Project = "home"
+	task.Status = models.TaskStatusDone
+	task.Assignee = "bob"
+	task.DueAt = &dueAt
+	if err := repo.UpdateTask(ctx, task); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if !task.UpdatedAt.After(createdAt) {
+		t.Fatalf("update set updated_at %v, want it after %v", task.UpdatedAt, createdAt)
+	}
+	got := getTask(t, repo, task.ID)
+	if got.Title != "Pay the rent" || got.Description != "by transfer" || got.Project != "home" ||
+		got.Status != models.TaskStatusDone || got.Assignee != "bob" || got.DueAt == nil || !got.DueAt.Equal(dueAt) {
+		t.Fatalf("update not stored: %+v", got)
+	}
+	if !got.CreatedAt.Equal(createdAt) || !got.UpdatedAt.Equal(task.UpdatedAt) {
+		t.Fatalf("timestamps %v and %v after update, want %v and %v", got.CreatedAt, got.UpdatedAt, createdAt, task.UpdatedAt)
+	}
+
+	task.DueAt = nil
+	if err := repo.UpdateTask(ctx, task); err != nil {
+		t.Fatalf("clear due_at: %v", err)
+	}
+	if got := getTask(t, repo, task.ID); got.DueAt != nil {
+		t.Fatalf("due_at %v, want it cleared", got.DueAt)
+	}
+	// Every update counts, even within the same second.
+	if got := getTask(t, repo, task.ID); task.Sequence != 2 || got.Sequence != 2 {
+		t.Fatalf("sequence %d and %d after two updates, want 2", task.Sequence, got.Sequence)
+	}
+
+	if err := repo.DeleteTask(ctx, task.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repo.GetTask(ctx, task.ID); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Fatalf("get after delete: expected ErrTaskNotFound, got %v", err)
+	}
+	if err := repo.DeleteTask(ctx, task.ID); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Fatalf("second delete: expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+// testReturnsCopies checks that changing a task, before or after it is
+// stored, doesn't change the stored task.
+func testReturnsCopies(t *testing.T, repo repository.TaskRepository) {
+	ctx := context.Background()
+	dueAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	taskDueAt := dueAt
+	task := newTask("Pay rent")
+	task.DueAt = &taskDueAt
+	createTask(t, repo, task)
+	task.Title = "Changed"
+	*task.DueAt = dueAt.Add(time.Hour)
+
+	got := getTask(t, repo, task.ID)
+	got.Title = "Changed"
+	*got.DueAt = dueAt.Add(time.Hour)
+	listed, err := repo.ListTasks(ctx, repository.TaskFilter{})
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("list: %d tasks, %v", len(listed), err)
+	}
+	listed[0].Title = "Changed"
+
+	if got := getTask(t, repo, task.ID); got.Title != "Pay rent" || !got.DueAt.Equal(dueAt) {
+		t.Fatalf("stored task was changed: %+v", got)
+	}
+}
+
+func testList(t *testing.T, repo repository.TaskRepository) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		task := newTask(fmt.Sprintf("Task %d", i))
+		if i%2 == 1 {
+			task.Project = "home"
+		}
+		if i >= 3 {
+			task.Status = models.TaskStatusDone
+			task.Assignee = "ann"
+		}
+		// The last task has no due date.
+		if i < 6 {
+			dueAt := day.AddDate(0, 0, i)
+			task.DueAt = &dueAt
+		}
+		createTask(t, repo, task)
+		// Distinct creation times make the order deterministic.
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	list := func(filter repository.TaskFilter) string {
+		t.Helper()
+		tasks, err := repo.ListTasks(context.Background(), filter)
+		if err != nil {
+			t.Fatalf("list %+v: %v", filter, err)
+		}
+		var got []string
+		for _, task := range tasks {
+			got = append(got, strings.TrimPrefix(task.Title, "Task "))
+		}
+		return strings.Join(got, ",")
+	}
+	done := models.TaskStatusDone
+	dueAfter, dueBefore := day.AddDate(0, 0, 2), day.AddDate(0, 0, 4)
+	for _, tc := range []struct {
+		filter repository.TaskFilter
+		want   string
+	}{
+		{repository.TaskFilter{}, "6,5,4,3,2,1,0"},
+		{repository.TaskFilter{Project: "home"}, "5,3,1"},
+		{repository.TaskFilter{Status: &done}, "6,5,4,3"},
+		{repository.TaskFilter{Project: "home", Status: &done}, "5,3"},
+		{repository.TaskFilter{Assignee: "ann"}, "6,5,4,3"},
+		{repository.TaskFilter{Assignee: "bob"}, ""},
+		{repository.TaskFilter{DueAfter: &dueAfter}, "5,4,3,2"},
+		{repository.TaskFilter{DueBefore: &dueBefore}, "3,2,1,0"},
+		{repository.TaskFilter{DueAfter: &dueAfter, DueBefore: &dueBefore}, "3,2"},
+		{repository.TaskFilter{Limit: 2}, "6,5"},
+		{repository.TaskFilter{Limit: 2, Offset: 2}, "4,3"},
+		{repository.TaskFilter{Offset: 5}, "1,0"},
+		{repository.TaskFilter{Offset: 7}, ""},
+		{repository.TaskFilter{Limit: 10}, "6,5,4,3,2,1,0"},
+		{repository.TaskFilter{Status: &done, Limit: 2, Offset: 1}, "5,4"},
+		{repository.TaskFilter{Project: "work"}, ""},
+	} {
+		if got := list(tc.filter); got != tc.want {
+			t.Errorf("list %+v = %q, want %q", tc.filter, got, tc.want)
+		}
+	}
+}
+
+func testEach(t *testing.T, repo repository.TaskRepository) {
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		createTask(t, repo, newTask(fmt.Sprintf("Task %d", i)))
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	filter := repository.TaskFilter{Limit: 3, Offset: 1}
+	listed, err := repo.ListTasks(ctx, filter)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var each []*models.Task
+	err = repo.EachTask(ctx, filter, func(task *models.Task) error {
+		each = append(each, task)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("each: %v", err)
+	}
+	if len(each) != len(listed) {
+		t.Fatalf("each: %d tasks, list: %d", len(each), len(listed))
+	}
+	for i := range listed {
+		if each[i].ID != listed[i].ID || each[i].Title != listed[i].Title || each[i].Description != listed[i].Description {
+			t.Fatalf("each task %d = %+v, list %+v", i, each[i], listed[i])
+		}
+	}
+
+	// An error of fn stops the iteration and is returned.
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.EachTask(ctx, repository.TaskFilter{}, func(*models.Task) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("each: %v after %d calls, want stop after 1", err, calls)
+	}
+}
+
+// testConcurrency runs creates, reads, updates and deletes from several
+// goroutines; run it with -race to check in-memory implementations.
+func testConcurrency(t *testing.T, repo repository.TaskRepository) {
+	ctx := context.Background()
+	const workers = 8
+	const tasksPerWorker = 5
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*tasksPerWorker*3)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < tasksPerWorker; i++ {
+				task := newTask(fmt.Sprintf("Task %d-%d", w, i))
+				if err := repo.CreateTask(ctx, task); err != nil {
+					errs <- fmt.Errorf("create: %w", err)
+					continue
+				}
+				task.Status = models.TaskStatusDone
+				if err := repo.UpdateTask(ctx, task); err != nil {
+					errs <- fmt.Errorf("update: %w", err)
+				}
+				if _, err := repo.ListTasks(ctx, repository.TaskFilter{Limit: 5}); err != nil {
+					errs <- fmt.Errorf("list: %w", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	done := models.TaskStatusDone
+	tasks, err := repo.ListTasks(ctx, repository.TaskFilter{Status: &done})
+	if err != nil || len(tasks) != workers*tasksPerWorker {
+		t.Fatalf("list: %d tasks, want %d (%v)", len(tasks), workers*tasksPerWorker, err)
+	}
+
+	// Of several concurrent deletes of a task exactly one succeeds.
+	var deleted, notFound int
+	var mu sync.Mutex
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.DeleteTask(ctx, tasks[0].ID)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				deleted++
+			case errors.Is(err, repository.ErrTaskNotFound):
+				notFound++
+			default:
+				t.Errorf("delete: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if deleted != 1 || notFound != workers-1 {
+		t.Fatalf("%d deletes succeeded and %d found nothing, want 1 and %d", deleted, notFound, workers-1)
+	}
+}
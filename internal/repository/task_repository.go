@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,105 +18,170 @@ var (
 )
 
 type TaskFilter struct {
-	Status *models.TaskStatus
-	Limit  int
-	Offset int
+	Project   string
+	Status    *models.TaskStatus
+	Assignee  string
+	DueAfter  *time.Time
+	DueBefore *time.Time
+	Limit     int
+	Offset    int
 }
 
 type TaskRepository interface {
 	CreateTask(ctx context.Context, task *models.Task) error
 	GetTask(ctx context.Context, taskID uuid.UUID) (*models.Task, error)
+	// GetTaskByExternalID returns the task with the given external ID, or
+	// ErrTaskNotFound.
+	GetTaskByExternalID(ctx context.Context, externalID string) (*models.Task, error)
 	ListTasks(ctx context.Context, filter TaskFilter) ([]*models.Task, error)
+	// EachTask calls fn with the tasks that ListTasks would return, in the
+	// same order, reading them as fn consumes them instead of all at once.
+	// It stops at the first error of fn and returns it. fn must not use the
+	// repository, which may still hold the connection of the query.
+	EachTask(ctx context.Context, filter TaskFilter, fn func(*models.Task) error) error
 	UpdateTask(ctx context.Context, task *models.Task) error
 	DeleteTask(ctx context.Context, taskID uuid.UUID) error
 	Ping(ctx context.Context) error
 }
 
+// SQLiteTaskRepository stores tasks in SQLite. Timestamps are stored as unix
+// microseconds, the precision of PostgreSQL's timestamptz, so they sort
+// numerically and tasks read back are equal to the ones written.
 type SQLiteTaskRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	reader *sql.DB
+	cipher FieldCipher
 }
 
 func NewSQLiteTaskRepository(db *sql.DB) *SQLiteTaskRepository {
 	return &SQLiteTaskRepository{db: db}
 }
 
+// WithReader makes read-only queries outside of transactions run on the
+// reader pool.
+func (r *SQLiteTaskRepository) WithReader(reader *sql.DB) *SQLiteTaskRepository {
+	r.reader = reader
+	return r
+}
+
+// WithCipher encrypts task descriptions with cipher.
+func (r *SQLiteTaskRepository) WithCipher(cipher FieldCipher) *SQLiteTaskRepository {
+	r.cipher = cipher
+	return r
+}
+
 func (r *SQLiteTaskRepository) Ping(ctx context.Context) error {
 	return r.db.PingContext(ctx)
 }
 
 func (r *SQLiteTaskRepository) CreateTask(ctx context.Context, task *models.Task) error {
-	now := time.Now().UTC()
+	now := time.Now().UTC().Truncate(time.Microsecond)
 	task.CreatedAt = now
 	task.UpdatedAt = now
 
+	description, keyID, err := sealField(r.cipher, task.Description, taskDescriptionAAD(task.ID.String()))
+	if err != nil {
+		return err
+	}
 	const query = `
-INSERT INTO tasks (id, title, description, status, created_at, updated_at)
-VALUES (?, ?, ?, ?, ?, ?)
+INSERT INTO tasks (` + sqliteTaskColumns + `)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = conn(ctx, r.db).ExecContext(ctx, query,
 		task.ID.String(),
+		task.Project,
 		task.Title,
-		task.Description,
+		description,
 		string(task.Status),
-		task.CreatedAt.Format(time.RFC3339Nano),
-		task.UpdatedAt.Format(time.RFC3339Nano),
+		task.Assignee,
+		formatNullableTimestamp(task.DueAt),
+		formatNullableUUID(task.RecurrenceID),
+		task.CreatedAt.UnixMicro(),
+		task.UpdatedAt.UnixMicro(),
+		keyID,
+		formatNullableString(task.ExternalID),
+		task.Sequence,
 	)
 	return err
 }
 
 func (r *SQLiteTaskRepository) GetTask(ctx context.Context, taskID uuid.UUID) (*models.Task, error) {
-	const query = `
-SELECT id, title, description, status, created_at, updated_at
-FROM tasks
-WHERE id = ?
-`
-	row := r.db.QueryRowContext(ctx, query, taskID.String())
-	var task models.Task
-	var statusStr string
-	var createdAtStr, updatedAtStr string
-	if err := row.Scan(&task.ID, &task.Title, &task.Description, &statusStr, &createdAtStr, &updatedAtStr); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrTaskNotFound
-		}
-		return nil, err
+	const query = `SELECT ` + sqliteTaskColumns + ` FROM tasks WHERE id = ?`
+	task, err := scanSQLiteTask(readConn(ctx, r.db, r.reader).QueryRowContext(ctx, query, taskID.String()), r.cipher)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTaskNotFound
 	}
-	task.Status = models.TaskStatus(statusStr)
+	return task, err
+}
 
-	var err error
-	task.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr)
-	if err != nil {
-		return nil, fmt.Errorf("parse created_at: %w", err)
+func (r *SQLiteTaskRepository) GetTaskByExternalID(ctx context.Context, externalID string) (*models.Task, error) {
+	const query = `SELECT ` + sqliteTaskColumns + ` FROM tasks WHERE external_id = ?`
+	task, err := scanSQLiteTask(readConn(ctx, r.db, r.reader).QueryRowContext(ctx, query, externalID), r.cipher)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTaskNotFound
 	}
-	task.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAtStr)
+	return task, err
+}
+
+func (r *SQLiteTaskRepository) ListTasks(ctx context.Context, filter TaskFilter) ([]*models.Task, error) {
+	var tasks []*models.Task
+	err := r.EachTask(ctx, filter, func(task *models.Task) error {
+		tasks = append(tasks, task)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("parse_updated_at: %w", err)
+		return nil, err
 	}
-	return &task, nil
+	return tasks, nil
 }
 
-func (r *SQLiteTaskRepository) ListTasks(ctx context.Context, filter TaskFilter) ([]*models.Task, error) {
+func (r *SQLiteTaskRepository) EachTask(ctx context.Context, filter TaskFilter, fn func(*models.Task) error) error {
 	baseQuery := `
-SELECT id, title, description, status, created_at, updated_at
+SELECT ` + sqliteTaskColumns + `
 FROM tasks
 `
 	queryArgs := []any{}
+	var conditions []string
+	if filter.Project != "" {
+		conditions = append(conditions, "project = ?")
+		queryArgs = append(queryArgs, filter.Project)
+	}
 	if filter.Status != nil {
-		baseQuery += "WHERE status = ? "
+		conditions = append(conditions, "status = ?")
 		queryArgs = append(queryArgs, string(*filter.Status))
 	}
+	if filter.Assignee != "" {
+		conditions = append(conditions, "assignee = ?")
+		queryArgs = append(queryArgs, filter.Assignee)
+	}
+	if filter.DueAfter != nil {
+		conditions = append(conditions, "due_at >= ?")
+		queryArgs = append(queryArgs, filter.DueAfter.UnixMicro())
+	}
+	if filter.DueBefore != nil {
+		conditions = append(conditions, "due_at < ?")
+		queryArgs = append(queryArgs, filter.DueBefore.UnixMicro())
+	}
+	if len(conditions) > 0 {
+		baseQuery += "WHERE " + strings.Join(conditions, " AND ") + " "
+	}
 	baseQuery += "ORDER BY created_at DESC "
 	if filter.Limit > 0 {
 		baseQuery += "LIMIT ? "
 		queryArgs = append(queryArgs, filter.Limit)
 	}
 	if filter.Offset > 0 {
+		// SQLite only accepts OFFSET after a LIMIT; -1 means no limit.
+		if filter.Limit <= 0 {
+			baseQuery += "LIMIT -1 "
+		}
 		baseQuery += "OFFSET ?"
 		queryArgs = append(queryArgs, filter.Offset)
 	}
 
-	rows, err := r.db.QueryContext(ctx, baseQuery, queryArgs...)
+	rows, err := readConn(ctx, r.db, r.reader).QueryContext(ctx, baseQuery, queryArgs...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	defer func(rows *sql.Rows) {
@@ -125,45 +191,52 @@ FROM tasks
 		}
 	}(rows)
 
-	var tasks []*models.Task
 	for rows.Next() {
-		var task models.Task
-		var statusStr string
-		var createdAtStr, updatedAtStr string
-		if err := rows.Scan(&task.ID, &task.Title, &task.Description, &statusStr, &createdAtStr, &updatedAtStr); err != nil {
-			return nil, err
-		}
-		task.Status = models.TaskStatus(statusStr)
-		task.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr)
+		task, err := scanSQLiteTask(rows, r.cipher)
 		if err != nil {
-			return nil, fmt.Errorf("parse created_at: %w", err)
+			return err
 		}
-		task.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAtStr)
-		if err != nil {
-			return nil, fmt.Errorf("parse_updated_at: %w", err)
+		if err := fn(task); err != nil {
+			return err
 		}
-		tasks = append(tasks, &task)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
 	}
-	return tasks, nil
+	return rows.Err()
 }
 
 func (r *SQLiteTaskRepository) UpdateTask(ctx context.Context, task *models.Task) error {
-	task.UpdatedAt = time.Now().UTC()
+	task.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	description, keyID, err := sealField(r.cipher, task.Description, taskDescriptionAAD(task.ID.String()))
+	if err != nil {
+		return err
+	}
 	const query = `
 UPDATE tasks
-SET title = ?, description = ?, status = ?, updated_at = ?
+SET project = ?, title = ?, description = ?, status = ?, assignee = ?, due_at = ?, updated_at = ?, key_id = ?, external_id = ?,
+  sequence = sequence + 1
 WHERE id = ?
+RETURNING sequence
 `
-	result, err := r.db.ExecContext(ctx, query,
+	err = conn(ctx, r.db).QueryRowContext(ctx, query,
+		task.Project,
 		task.Title,
-		task.Description,
+		description,
 		string(task.Status),
-		task.UpdatedAt.Format(time.RFC3339Nano),
+		task.Assignee,
+		formatNullableTimestamp(task.DueAt),
+		task.UpdatedAt.UnixMicro(),
+		keyID,
+		formatNullableString(task.ExternalID),
 		task.ID.String(),
-	)
+	).Scan(&task.Sequence)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTaskNotFound
+	}
+	return err
+}
+
+func (r *SQLiteTaskRepository) DeleteTask(ctx context.Context, taskID uuid.UUID) error {
+	const query = `DELETE FROM tasks WHERE id = ?`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, taskID.String())
 	if err != nil {
 		return err
 	}
@@ -177,18 +250,105 @@ WHERE id = ?
 	return nil
 }
 
-func (r *SQLiteTaskRepository) DeleteTask(ctx context.Context, taskID uuid.UUID) error {
-	const query = `DELETE FROM tasks WHERE id = ?`
-	result, err := r.db.ExecContext(ctx, query, taskID.String())
+const sqliteTaskColumns = `id, project, title, description, status, assignee, due_at, recurrence_id, created_at, updated_at, key_id, external_id, sequence`
+
+// scanSQLiteTask scans a row of sqliteTaskColumns and decrypts its
+// description with cipher.
+func scanSQLiteTask(scanner interface{ Scan(dest ...any) error }, cipher FieldCipher) (*models.Task, error) {
+	var task models.Task
+	var status string
+	var dueAt sql.NullInt64
+	var recurrenceID sql.NullString
+	var createdAt, updatedAt int64
+	var keyID string
+	var externalID sql.NullString
+	if err := scanner.Scan(
+		&task.ID,
+		&task.Project,
+		&task.Title,
+		&task.Description,
+		&status,
+		&task.Assignee,
+		&dueAt,
+		&recurrenceID,
+		&createdAt,
+		&updatedAt,
+		&keyID,
+		&externalID,
+		&task.Sequence,
+	); err != nil {
+		return nil, err
+	}
+	task.Status = models.TaskStatus(status)
+	task.ExternalID = externalID.String
+	var err error
+	task.Description, err = openField(cipher, task.Description, keyID, taskDescriptionAAD(task.ID.String()))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	rowsAffected, err := result.RowsAffected()
+	if dueAt.Valid {
+		t := time.UnixMicro(dueAt.Int64).UTC()
+		task.DueAt = &t
+	}
+	task.RecurrenceID, err = parseNullableUUID(recurrenceID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("parse recurrence_id: %w", err)
 	}
-	if rowsAffected == 0 {
-		return ErrTaskNotFound
+	task.CreatedAt = time.UnixMicro(createdAt).UTC()
+	task.UpdatedAt = time.UnixMicro(updatedAt).UTC()
+	return &task, nil
+}
+
+// formatNullableTimestamp returns t in unix microseconds, the way task
+// timestamps are stored in SQLite.
+func formatNullableTimestamp(t *time.Time) any {
+	if t == nil {
+		return nil
 	}
-	return nil
+	return t.UnixMicro()
+}
+
+func formatNullableTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseNullableTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// formatNullableString stores an empty value as NULL, which unique
+// indexes don't compare.
+func formatNullableString(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func formatNullableUUID(id *uuid.UUID) any {
+	if id == nil {
+		return nil
+	}
+	return id.String()
+}
+
+func parseNullableUUID(value sql.NullString) (*uuid.UUID, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	id, err := uuid.Parse(value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
 }
@@ -0,0 +1,85 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/migrations"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+// BenchmarkSQLiteTaskRepositoryParallel measures a mixed load of 80% reads
+// and 20% writes from parallel goroutines. "single-pool" opens the database
+// the way the server used to, with one default pool in rollback journal
+// mode; "wal-pools" uses the writer and reader pools of the server. Errors,
+// mostly "database is locked", are reported per operation instead of
+// failing the benchmark.
+//
+//	go test ./internal/repository -run '^$' -bench SQLiteTaskRepositoryParallel -cpu 8
+func BenchmarkSQLiteTaskRepositoryParallel(b *testing.B) {
+	b.Run("single-pool", func(b *testing.B) {
+		db, err := sql.Open("sqlite3", filepath.Join(b.TempDir(), "tasks.db")+"?_foreign_keys=on&_txlock=immediate")
+		if err != nil {
+			b.Fatalf("open db: %v", err)
+		}
+		b.Cleanup(func() { _ = db.Close() })
+		if err := migrations.Run(db); err != nil {
+			b.Fatalf("migrate: %v", err)
+		}
+		benchmarkTaskRepositoryParallel(b, repository.NewSQLiteTaskRepository(db), repository.NewSQLTransactor(db))
+	})
+	b.Run("wal-pools", func(b *testing.B) {
+		writer, reader := newSQLiteTestPools(b)
+		benchmarkTaskRepositoryParallel(b, repository.NewSQLiteTaskRepository(writer).WithReader(reader), repository.NewSQLTransactor(writer))
+	})
+}
+
+func benchmarkTaskRepositoryParallel(b *testing.B, repo repository.TaskRepository, tx repository.Transactor) {
+	ctx := context.Background()
+	const seeded = 1000
+	ids := make([]uuid.UUID, seeded)
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		for i := range ids {
+			task := &models.Task{ID: uuid.New(), Project: models.DefaultProject, Title: fmt.Sprintf("Task %d", i), Status: models.TaskStatusNew}
+			if err := repo.CreateTask(ctx, task); err != nil {
+				return err
+			}
+			ids[i] = task.ID
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("seed: %v", err)
+	}
+
+	done := models.TaskStatusDone
+	var failed atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			var err error
+			switch n := rand.IntN(10); {
+			case n < 5:
+				_, err = repo.GetTask(ctx, ids[rand.IntN(len(ids))])
+			case n < 8:
+				_, err = repo.ListTasks(ctx, repository.TaskFilter{Status: &done, Limit: 20})
+			case n < 9:
+				err = repo.CreateTask(ctx, &models.Task{ID: uuid.New(), Project: models.DefaultProject, Title: "New task", Status: models.TaskStatusNew})
+			default:
+				err = repo.UpdateTask(ctx, &models.Task{ID: ids[rand.IntN(len(ids))], Project: models.DefaultProject, Title: "Updated task", Status: done})
+			}
+			if err != nil {
+				failed.Add(1)
+			}
+		}
+	})
+	b.ReportMetric(float64(failed.Load())/float64(b.N), "errors/op")
+}
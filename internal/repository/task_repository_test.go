@@ -0,0 +1,147 @@
+package repository_test
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"task-manager/internal/database"
+	"task-manager/internal/encryption"
+	"task-manager/internal/migrations"
+	"task-manager/internal/repository"
+	"task-manager/internal/repository/repositorytest"
+)
+
+// postgresTestURL points the Postgres tests at a server. They are skipped
+// when it can't be reached.
+const postgresTestURL = "TASK_MANAGER_TEST_POSTGRES_URL"
+
+func newSQLiteTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// newSQLiteTestPools returns the writer and reader pools of a migrated
+// database file, opened like the server opens it.
+func newSQLiteTestPools(t testing.TB) (*sql.DB, *sql.DB) {
+	t.Helper()
+	writer, reader, err := database.OpenSQLite(filepath.Join(t.TempDir(), "tasks.db"), database.DefaultSQLiteOptions())
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = reader.Close()
+		_ = writer.Close()
+	})
+	if err := migrations.Run(writer); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return writer, reader
+}
+
+// newPostgresTestDB returns a database whose connections use a fresh schema
+// that is dropped when the test ends.
+func newPostgresTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(postgresTestURL)
+	if dsn == "" {
+		dsn = "postgres://postgres@localhost:5432/postgres?sslmode=disable&connect_timeout=2"
+	}
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+	if err := admin.Ping(); err != nil {
+		t.Skipf("no PostgreSQL server (set %s): %v", postgresTestURL, err)
+	}
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	separator := " "
+	if strings.Contains(dsn, "://") {
+		separator = "&"
+		if !strings.Contains(dsn, "?") {
+			separator = "?"
+		}
+	}
+	db, err := sql.Open("postgres", dsn+separator+"search_path="+schema)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestSQLiteTaskRepository(t *testing.T) {
+	repositorytest.TestTaskRepository(t, func(t *testing.T) repository.TaskRepository {
+		return repository.NewSQLiteTaskRepository(newSQLiteTestDB(t))
+	})
+}
+
+func TestSQLiteTaskRepositoryWithReader(t *testing.T) {
+	repositorytest.TestTaskRepository(t, func(t *testing.T) repository.TaskRepository {
+		writer, reader := newSQLiteTestPools(t)
+		return repository.NewSQLiteTaskRepository(writer).WithReader(reader)
+	})
+}
+
+func TestPostgresTaskRepository(t *testing.T) {
+	repositorytest.TestTaskRepository(t, func(t *testing.T) repository.TaskRepository {
+		return repository.NewPostgresTaskRepository(newPostgresTestDB(t))
+	})
+}
+
+func TestInMemoryTaskRepository(t *testing.T) {
+	repositorytest.TestTaskRepository(t, func(t *testing.T) repository.TaskRepository {
+		return repository.NewInMemoryTaskRepository()
+	})
+}
+
+func newTestKeyring(t *testing.T, primary string, ids ...string) *encryption.Keyring {
+	t.Helper()
+	keys := make(map[string][]byte)
+	for _, id := range append(ids, primary) {
+		keys[id] = bytes.Repeat([]byte(id[:1]), 32)
+	}
+	keyring, err := encryption.NewKeyring(primary, keys)
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	return keyring
+}
+
+func TestSQLiteTaskRepositoryWithCipher(t *testing.T) {
+	repositorytest.TestTaskRepository(t, func(t *testing.T) repository.TaskRepository {
+		return repository.NewSQLiteTaskRepository(newSQLiteTestDB(t)).WithCipher(newTestKeyring(t, "a"))
+	})
+}
+
+func TestPostgresTaskRepositoryWithCipher(t *testing.T) {
+	repositorytest.TestTaskRepository(t, func(t *testing.T) repository.TaskRepository {
+		return repository.NewPostgresTaskRepository(newPostgresTestDB(t)).WithCipher(newTestKeyring(t, "a"))
+	})
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"task-manager/internal/database"
+)
+
+// Transactor runs a unit of work in a database transaction. Repository
+// calls made with the ctx passed to fn join that transaction, and so do
+// nested WithinTx calls: only the outermost one commits.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// NoTx is a Transactor for repositories without transactions, such as the
+// in-memory ones used in tests. It just calls fn.
+var NoTx Transactor = noTx{}
+
+type noTx struct{}
+
+func (noTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// SQLTransactor runs transactions on a database/sql database of any
+// supported dialect.
+type SQLTransactor struct {
+	db *sql.DB
+}
+
+func NewSQLTransactor(db *sql.DB) *SQLTransactor {
+	return &SQLTransactor{db: db}
+}
+
+func (t *SQLTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, t.db, func(ctx context.Context, _ querier) error {
+		return fn(ctx)
+	})
+}
+
+// AfterCommit runs fn once the transaction carried by ctx has committed, or
+// right away when ctx carries none. It is dropped if the transaction rolls
+// back, which makes it the place for in-process side effects such as waking
+// workers or notifying subscribers.
+func AfterCommit(ctx context.Context, fn func()) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+// querier is what *sql.DB and *sql.Tx have in common.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+type txState struct {
+	tx          *sql.Tx
+	afterCommit []func()
+	// local holds the pending writes of stores outside of the database,
+	// by store, until AfterCommit applies them.
+	local map[any]any
+}
+
+// txLocal returns the value of key for the transaction carried by ctx,
+// calling create on its first use in the transaction if create isn't nil.
+// It returns the zero value and false when there is no value.
+func txLocal[T any](ctx context.Context, key any, create func() T) (T, bool) {
+	var zero T
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return zero, false
+	}
+	if value, ok := state.local[key]; ok {
+		return value.(T), true
+	}
+	if create == nil {
+		return zero, false
+	}
+	if state.local == nil {
+		state.local = make(map[any]any)
+	}
+	value := create()
+	state.local[key] = value
+	return value, true
+}
+
+// conn returns the transaction carried by ctx, or db outside of one. On
+// PostgreSQL the placeholders of its queries are rebound.
+func conn(ctx context.Context, db *sql.DB) querier {
+	var q querier = db
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		q = state.tx
+	}
+	if dialect := database.DialectOf(db); dialect != database.SQLite {
+		return rebinder{q: q, dialect: dialect}
+	}
+	return q
+}
+
+// readConn is conn for read-only queries. Outside of a transaction they run
+// on reader, if there is one, so that they don't queue for the writer.
+func readConn(ctx context.Context, db, reader *sql.DB) querier {
+	if _, ok := ctx.Value(txKey{}).(*txState); ok || reader == nil {
+		return conn(ctx, db)
+	}
+	return conn(ctx, reader)
+}
+
+type rebinder struct {
+	q       querier
+	dialect database.Dialect
+}
+
+func (r rebinder) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.q.ExecContext(ctx, r.dialect.Rebind(query), args...)
+}
+
+func (r rebinder) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.q.QueryContext(ctx, r.dialect.Rebind(query), args...)
+}
+
+func (r rebinder) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return r.q.QueryRowContext(ctx, r.dialect.Rebind(query), args...)
+}
+
+// withinTx runs fn in the transaction carried by ctx or, without one, in a
+// new transaction that is committed when fn returns nil.
+func withinTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context, q querier) error) error {
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		return fn(ctx, conn(ctx, db))
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	state := &txState{tx: tx}
+	ctx = context.WithValue(ctx, txKey{}, state)
+	if err := fn(ctx, conn(ctx, db)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, after := range state.afterCommit {
+		after()
+	}
+	return nil
+}
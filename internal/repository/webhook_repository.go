@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	GetWebhook(ctx context.Context, webhookID uuid.UUID) (*models.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*models.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *models.Webhook) error
+	DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error
+
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDelivery(ctx context.Context, deliveryID uuid.UUID) (*models.WebhookDelivery, error)
+	// ListDeliveries returns a webhook's deliveries, most recent first.
+	ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit, offset int) ([]*models.WebhookDelivery, error)
+	// RecordAttempt stores the outcome of an attempt on the delivery and
+	// appends it to the delivery's attempt log, atomically.
+	RecordAttempt(ctx context.Context, delivery *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) error
+	ListAttempts(ctx context.Context, deliveryID uuid.UUID) ([]*models.WebhookDeliveryAttempt, error)
+}
+
+type SQLiteWebhookRepository struct {
+	db     *sql.DB
+	cipher FieldCipher
+}
+
+func NewSQLiteWebhookRepository(db *sql.DB) *SQLiteWebhookRepository {
+	return &SQLiteWebhookRepository{db: db}
+}
+
+// WithCipher encrypts delivery payloads with cipher. They are the events
+// sent to the webhook, descriptions and comment bodies included.
+func (r *SQLiteWebhookRepository) WithCipher(cipher FieldCipher) *SQLiteWebhookRepository {
+	r.cipher = cipher
+	return r
+}
+
+func (r *SQLiteWebhookRepository) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	now := time.Now().UTC()
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+
+	const query = `
+INSERT INTO webhooks (id, url, events, secret, active, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+`
+	_, err = conn(ctx, r.db).ExecContext(ctx, query,
+		webhook.ID.String(),
+		webhook.URL,
+		string(events),
+		webhook.Secret,
+		webhook.Active,
+		webhook.CreatedAt.Format(time.RFC3339Nano),
+		webhook.UpdatedAt.Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+const selectWebhook = `
+SELECT id, url, events, secret, active, created_at, updated_at
+FROM webhooks
+`
+
+func scanWebhook(scanner interface{ Scan(dest ...any) error }) (*models.Webhook, error) {
+	var webhook models.Webhook
+	var eventsStr, createdAtStr, updatedAtStr string
+	if err := scanner.Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&eventsStr,
+		&webhook.Secret,
+		&webhook.Active,
+		&createdAtStr,
+		&updatedAtStr,
+	); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if err = json.Unmarshal([]byte(eventsStr), &webhook.Events); err != nil {
+		return nil, fmt.Errorf("parse events: %w", err)
+	}
+	if webhook.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	if webhook.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAtStr); err != nil {
+		return nil, fmt.Errorf("parse updated_at: %w", err)
+	}
+	return &webhook, nil
+}
+
+func (r *SQLiteWebhookRepository) GetWebhook(ctx context.Context, webhookID uuid.UUID) (*models.Webhook, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, selectWebhook+"WHERE id = ?", webhookID.String())
+	webhook, err := scanWebhook(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+func (r *SQLiteWebhookRepository) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, selectWebhook+"ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *SQLiteWebhookRepository) UpdateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	webhook.UpdatedAt = time.Now().UTC()
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+
+	const query = `
+UPDATE webhooks
+SET url = ?, events = ?, secret = ?, active = ?, updated_at = ?
+WHERE id = ?
+`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query,
+		webhook.URL,
+		string(events),
+		webhook.Secret,
+		webhook.Active,
+		webhook.UpdatedAt.Format(time.RFC3339Nano),
+		webhook.ID.String(),
+	)
+	if err != nil {
+		return err
+	}
+	return expectRow(result, ErrWebhookNotFound)
+}
+
+func (r *SQLiteWebhookRepository) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, webhookID.String())
+	if err != nil {
+		return err
+	}
+	return expectRow(result, ErrWebhookNotFound)
+}
+
+func (r *SQLiteWebhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	now := time.Now().UTC()
+	delivery.CreatedAt = now
+	delivery.UpdatedAt = now
+
+	payload, keyID, err := sealField(r.cipher, string(delivery.Payload), webhookDeliveryPayloadAAD(delivery.ID.String()))
+	if err != nil {
+		return err
+	}
+
+	const query = `
+INSERT INTO webhook_deliveries (id, webhook_id, event_type, payload, key_id, status, redelivery_of, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+	_, err = conn(ctx, r.db).ExecContext(ctx, query,
+		delivery.ID.String(),
+		delivery.WebhookID.String(),
+		delivery.EventType,
+		payload,
+		keyID,
+		string(delivery.Status),
+		formatNullableUUID(delivery.RedeliveryOf),
+		delivery.CreatedAt.Format(time.RFC3339Nano),
+		delivery.UpdatedAt.Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+const selectWebhookDelivery = `
+SELECT id, webhook_id, event_type, payload, key_id, status, attempts, response_status, last_error, redelivery_of, created_at, updated_at, delivered_at
+FROM webhook_deliveries
+`
+
+func (r *SQLiteWebhookRepository) scanDelivery(scanner interface{ Scan(dest ...any) error }) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var payload, keyID, status, createdAtStr, updatedAtStr string
+	var redeliveryOfStr, deliveredAtStr sql.NullString
+	if err := scanner.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventType,
+		&payload,
+		&keyID,
+		&status,
+		&delivery.Attempts,
+		&delivery.ResponseStatus,
+		&delivery.LastError,
+		&redeliveryOfStr,
+		&createdAtStr,
+		&updatedAtStr,
+		&deliveredAtStr,
+	); err != nil {
+		return nil, err
+	}
+	payload, err := openField(r.cipher, payload, keyID, webhookDeliveryPayloadAAD(delivery.ID.String()))
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	delivery.Status = models.WebhookDeliveryStatus(status)
+
+	if delivery.RedeliveryOf, err = parseNullableUUID(redeliveryOfStr); err != nil {
+		return nil, fmt.Errorf("parse redelivery_of: %w", err)
+	}
+	if delivery.DeliveredAt, err = parseNullableTime(deliveredAtStr); err != nil {
+		return nil, fmt.Errorf("parse delivered_at: %w", err)
+	}
+	if delivery.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	if delivery.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAtStr); err != nil {
+		return nil, fmt.Errorf("parse updated_at: %w", err)
+	}
+	return &delivery, nil
+}
+
+func (r *SQLiteWebhookRepository) GetDelivery(ctx context.Context, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, selectWebhookDelivery+"WHERE id = ?", deliveryID.String())
+	delivery, err := r.scanDelivery(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	return delivery, err
+}
+
+func (r *SQLiteWebhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit, offset int) ([]*models.WebhookDelivery, error) {
+	query := selectWebhookDelivery + "WHERE webhook_id = ? ORDER BY created_at DESC "
+	queryArgs := []any{webhookID.String()}
+	if limit > 0 {
+		query += "LIMIT ? "
+		queryArgs = append(queryArgs, limit)
+	}
+	if offset > 0 {
+		query += "OFFSET ?"
+		queryArgs = append(queryArgs, offset)
+	}
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := r.scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *SQLiteWebhookRepository) RecordAttempt(ctx context.Context, delivery *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) error {
+	now := time.Now().UTC()
+	delivery.UpdatedAt = now
+	attempt.CreatedAt = now
+
+	return withinTx(ctx, r.db, func(ctx context.Context, tx querier) error {
+		const update = `
+UPDATE webhook_deliveries
+SET status = ?, attempts = ?, response_status = ?, last_error = ?, updated_at = ?, delivered_at = ?
+WHERE id = ?
+`
+		result, err := tx.ExecContext(ctx, update,
+			string(delivery.Status),
+			delivery.Attempts,
+			delivery.ResponseStatus,
+			delivery.LastError,
+			delivery.UpdatedAt.Format(time.RFC3339Nano),
+			formatNullableTime(delivery.DeliveredAt),
+			delivery.ID.String(),
+		)
+		if err != nil {
+			return err
+		}
+		if err := expectRow(result, ErrWebhookDeliveryNotFound); err != nil {
+			return err
+		}
+
+		const insert = `
+INSERT INTO webhook_delivery_attempts (delivery_id, attempt, response_status, response_body, error, duration_ms, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING id
+`
+		if err := tx.QueryRowContext(ctx, insert,
+			delivery.ID.String(),
+			attempt.Attempt,
+			attempt.ResponseStatus,
+			attempt.ResponseBody,
+			attempt.Error,
+			attempt.DurationMS,
+			attempt.CreatedAt.Format(time.RFC3339Nano),
+		).Scan(&attempt.ID); err != nil {
+			return err
+		}
+		attempt.DeliveryID = delivery.ID
+		return nil
+	})
+}
+
+func (r *SQLiteWebhookRepository) ListAttempts(ctx context.Context, deliveryID uuid.UUID) ([]*models.WebhookDeliveryAttempt, error) {
+	const query = `
+SELECT id, delivery_id, attempt, response_status, response_body, error, duration_ms, created_at
+FROM webhook_delivery_attempts
+WHERE delivery_id = ?
+ORDER BY id
+`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, deliveryID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var attempts []*models.WebhookDeliveryAttempt
+	for rows.Next() {
+		var attempt models.WebhookDeliveryAttempt
+		var createdAtStr string
+		if err := rows.Scan(
+			&attempt.ID,
+			&attempt.DeliveryID,
+			&attempt.Attempt,
+			&attempt.ResponseStatus,
+			&attempt.ResponseBody,
+			&attempt.Error,
+			&attempt.DurationMS,
+			&createdAtStr,
+		); err != nil {
+			return nil, err
+		}
+		if attempt.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+			return nil, fmt.Errorf("parse created_at: %w", err)
+		}
+		attempts = append(attempts, &attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// expectRow turns an update or delete that matched no rows into notFound.
+func expectRow(result sql.Result, notFound error) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"task-manager/internal/models"
+)
+
+var (
+	ErrWorkflowNotFound = errors.New("workflow not found")
+)
+
+type WorkflowRepository interface {
+	GetWorkflow(ctx context.Context, project string) (*models.Workflow, error)
+	SaveWorkflow(ctx context.Context, workflow *models.Workflow) error
+	DeleteWorkflow(ctx context.Context, project string) error
+	// HasStatus reports whether the workflow of any project defines
+	// status; the default workflow isn't stored.
+	HasStatus(ctx context.Context, status models.TaskStatus) (bool, error)
+}
+
+type SQLiteWorkflowRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteWorkflowRepository(db *sql.DB) *SQLiteWorkflowRepository {
+	return &SQLiteWorkflowRepository{db: db}
+}
+
+func (r *SQLiteWorkflowRepository) GetWorkflow(ctx context.Context, project string) (*models.Workflow, error) {
+	workflow := &models.Workflow{Project: project}
+
+	const workflowQuery = `SELECT initial_status FROM workflows WHERE project = ?`
+	var initialStatus string
+	if err := conn(ctx, r.db).QueryRowContext(ctx, workflowQuery, project).Scan(&initialStatus); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkflowNotFound
+		}
+		return nil, err
+	}
+	workflow.InitialStatus = models.TaskStatus(initialStatus)
+
+	const statusesQuery = `
+SELECT key, name, category
+FROM workflow_statuses
+WHERE project = ?
+ORDER BY position
+`
+	statusRows, err := conn(ctx, r.db).QueryContext(ctx, statusesQuery, project)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(statusRows)
+	for statusRows.Next() {
+		var status models.WorkflowStatus
+		if err := statusRows.Scan(&status.Key, &status.Name, &status.Category); err != nil {
+			return nil, err
+		}
+		workflow.Statuses = append(workflow.Statuses, status)
+	}
+	if err := statusRows.Err(); err != nil {
+		return nil, err
+	}
+
+	const transitionsQuery = `
+SELECT from_status, to_status
+FROM workflow_transitions
+WHERE project = ?
+ORDER BY from_status, to_status
+`
+	transitionRows, err := conn(ctx, r.db).QueryContext(ctx, transitionsQuery, project)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(transitionRows)
+	for transitionRows.Next() {
+		var transition models.WorkflowTransition
+		if err := transitionRows.Scan(&transition.From, &transition.To); err != nil {
+			return nil, err
+		}
+		workflow.Transitions = append(workflow.Transitions, transition)
+	}
+	if err := transitionRows.Err(); err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
+
+func (r *SQLiteWorkflowRepository) HasStatus(ctx context.Context, status models.TaskStatus) (bool, error) {
+	const query = `SELECT EXISTS (SELECT 1 FROM workflow_statuses WHERE key = ?)`
+	var exists bool
+	if err := conn(ctx, r.db).QueryRowContext(ctx, query, string(status)).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// SaveWorkflow replaces the stored workflow of a project in a single
+// transaction, so readers never observe a half-written status graph.
+func (r *SQLiteWorkflowRepository) SaveWorkflow(ctx context.Context, workflow *models.Workflow) error {
+	return withinTx(ctx, r.db, func(ctx context.Context, tx querier) error {
+		const upsertWorkflow = `
+INSERT INTO workflows (project, initial_status, updated_at)
+VALUES (?, ?, ?)
+ON CONFLICT (project) DO UPDATE SET initial_status = excluded.initial_status, updated_at = excluded.updated_at
+`
+		if _, err := tx.ExecContext(ctx, upsertWorkflow,
+			workflow.Project,
+			string(workflow.InitialStatus),
+			time.Now().UTC().Format(time.RFC3339Nano),
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM workflow_statuses WHERE project = ?`, workflow.Project); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM workflow_transitions WHERE project = ?`, workflow.Project); err != nil {
+			return err
+		}
+
+		const insertStatus = `
+INSERT INTO workflow_statuses (project, key, name, category, position)
+VALUES (?, ?, ?, ?, ?)
+`
+		for position, status := range workflow.Statuses {
+			if _, err := tx.ExecContext(ctx, insertStatus,
+				workflow.Project,
+				string(status.Key),
+				status.Name,
+				string(status.Category),
+				position,
+			); err != nil {
+				return err
+			}
+		}
+
+		const insertTransition = `
+INSERT INTO workflow_transitions (project, from_status, to_status)
+VALUES (?, ?, ?)
+`
+		for _, transition := range workflow.Transitions {
+			if _, err := tx.ExecContext(ctx, insertTransition,
+				workflow.Project,
+				string(transition.From),
+				string(transition.To),
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *SQLiteWorkflowRepository) DeleteWorkflow(ctx context.Context, project string) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM workflows WHERE project = ?`, project)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrWorkflowNotFound
+	}
+	return nil
+}
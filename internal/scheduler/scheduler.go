@@ -0,0 +1,269 @@
+// Package scheduler runs durable background jobs stored through
+// repository.JobRepository, plus in-process periodic tasks.
+//
+// Delivery is at-least-once: a job is marked done only after its handler
+// returns nil, and jobs left running by a crashed process are picked up
+// again once their lease expires. Handlers must therefore be idempotent.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+const (
+	DefaultPollInterval = 5 * time.Second
+	DefaultLease        = 5 * time.Minute
+	DefaultMaxAttempts  = 8
+	DefaultBaseBackoff  = 10 * time.Second
+	DefaultMaxBackoff   = time.Hour
+	DefaultBatchSize    = 20
+)
+
+// ErrPermanent can be wrapped by handlers to fail a job without retrying.
+var ErrPermanent = errors.New("permanent job failure")
+
+// Handler processes one job. Returning an error schedules a retry with
+// exponential backoff until the job's attempts are exhausted.
+type Handler func(ctx context.Context, job *models.Job) error
+
+// JobRequest describes a job to enqueue. Key deduplicates jobs: enqueueing
+// a request whose key already exists is a no-op.
+type JobRequest struct {
+	Kind        string
+	Key         string
+	RunAt       time.Time
+	Payload     any
+	MaxAttempts int
+}
+
+// Enqueuer is the part of the scheduler that producers depend on.
+type Enqueuer interface {
+	Enqueue(ctx context.Context, request JobRequest) (bool, error)
+}
+
+type Options struct {
+	PollInterval time.Duration
+	Lease        time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	BatchSize    int
+}
+
+type periodicTask struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	jobs     repository.JobRepository
+	options  Options
+	handlers map[string]Handler
+	periodic []periodicTask
+	wake     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func New(jobs repository.JobRepository, options Options) *Scheduler {
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+	if options.Lease <= 0 {
+		options.Lease = DefaultLease
+	}
+	if options.BaseBackoff <= 0 {
+		options.BaseBackoff = DefaultBaseBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = DefaultMaxBackoff
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+	return &Scheduler{
+		jobs:     jobs,
+		options:  options,
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Handle registers the handler for a job kind. It must be called before Start.
+func (s *Scheduler) Handle(kind string, handler Handler) {
+	s.handlers[kind] = handler
+}
+
+// Every registers a function that runs every interval while the scheduler
+// is running. It must be called before Start.
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.periodic = append(s.periodic, periodicTask{name: name, interval: interval, run: run})
+}
+
+func (s *Scheduler) Enqueue(ctx context.Context, request JobRequest) (bool, error) {
+	payload, err := json.Marshal(request.Payload)
+	if err != nil {
+		return false, fmt.Errorf("marshal %s payload: %w", request.Kind, err)
+	}
+	if request.MaxAttempts <= 0 {
+		request.MaxAttempts = DefaultMaxAttempts
+	}
+	if request.RunAt.IsZero() {
+		request.RunAt = time.Now()
+	}
+	job := &models.Job{
+		ID:          uuid.New(),
+		Kind:        request.Kind,
+		Key:         request.Key,
+		Payload:     payload,
+		MaxAttempts: request.MaxAttempts,
+		RunAt:       request.RunAt,
+	}
+	created, err := s.jobs.EnqueueJob(ctx, job)
+	if err != nil {
+		return false, err
+	}
+	if created && !request.RunAt.After(time.Now()) {
+		// Inside a transaction the job only becomes visible on commit.
+		repository.AfterCommit(ctx, s.wakeUp)
+	}
+	return created, nil
+}
+
+func (s *Scheduler) wakeUp() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start launches the job loop and the periodic tasks. They stop when ctx is
+// cancelled; Wait blocks until they have returned.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runJobs(ctx)
+	}()
+	for _, task := range s.periodic {
+		s.wg.Add(1)
+		go func(task periodicTask) {
+			defer s.wg.Done()
+			s.runPeriodic(ctx, task)
+		}(task)
+	}
+}
+
+// Wait blocks until all goroutines started by Start have returned.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) runPeriodic(ctx context.Context, task periodicTask) {
+	ticker := time.NewTicker(task.interval)
+	defer ticker.Stop()
+	for {
+		if err := task.run(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("scheduler: %s: %v", task.name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) runJobs(ctx context.Context) {
+	ticker := time.NewTicker(s.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			processed, err := s.RunDue(ctx, time.Now())
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("scheduler: claim jobs: %v", err)
+				}
+				break
+			}
+			if processed < s.options.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// RunDue claims and runs one batch of jobs that are due at now, returning
+// how many were processed.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) (int, error) {
+	jobs, err := s.jobs.ClaimDueJobs(ctx, now, s.options.Lease, s.options.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, job := range jobs {
+		s.run(ctx, job)
+	}
+	return len(jobs), nil
+}
+
+func (s *Scheduler) run(ctx context.Context, job *models.Job) {
+	handler, ok := s.handlers[job.Kind]
+	var err error
+	if !ok {
+		err = fmt.Errorf("%w: no handler for job kind %q", ErrPermanent, job.Kind)
+	} else {
+		err = handler(ctx, job)
+	}
+
+	// Bookkeeping must not be skipped because shutdown cancelled ctx while
+	// the handler was running.
+	storeCtx := context.WithoutCancel(ctx)
+	if err == nil {
+		if err := s.jobs.CompleteJob(storeCtx, job.ID); err != nil {
+			log.Printf("scheduler: complete job %s: %v", job.ID, err)
+		}
+		return
+	}
+
+	var retryAt *time.Time
+	if !errors.Is(err, ErrPermanent) && job.Attempts < job.MaxAttempts {
+		next := time.Now().Add(s.backoff(job.Attempts))
+		retryAt = &next
+	}
+	if retryAt == nil {
+		log.Printf("scheduler: job %s (%s) failed permanently after %d attempts: %v", job.ID, job.Kind, job.Attempts, err)
+	}
+	if err := s.jobs.FailJob(storeCtx, job.ID, err.Error(), retryAt); err != nil {
+		log.Printf("scheduler: fail job %s: %v", job.ID, err)
+	}
+}
+
+// backoff returns the delay before the given attempt is retried: the base
+// delay doubled per attempt, capped, with up to 20% jitter.
+func (s *Scheduler) backoff(attempt int) time.Duration {
+	delay := s.options.BaseBackoff
+	for i := 1; i < attempt && delay < s.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.options.MaxBackoff {
+		delay = s.options.MaxBackoff
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
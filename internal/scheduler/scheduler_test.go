@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"task-manager/internal/migrations"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+func newTestRepository(t *testing.T) *repository.SQLiteJobRepository {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return repository.NewSQLiteJobRepository(db)
+}
+
+func TestEnqueueIsIdempotent(t *testing.T) {
+	s := New(newTestRepository(t), Options{})
+	ctx := context.Background()
+
+	for i, want := range []bool{true, false} {
+		created, err := s.Enqueue(ctx, JobRequest{Kind: "noop", Key: "same"})
+		if err != nil {
+			t.Fatalf("enqueue %d: %v", i, err)
+		}
+		if created != want {
+			t.Fatalf("enqueue %d: created=%v, want %v", i, created, want)
+		}
+	}
+}
+
+func TestFailedJobIsRetriedWithBackoff(t *testing.T) {
+	jobs := newTestRepository(t)
+	s := New(jobs, Options{BaseBackoff: time.Minute})
+	ctx := context.Background()
+
+	calls := 0
+	s.Handle("flaky", func(ctx context.Context, job *models.Job) error {
+		calls++
+		if calls == 1 {
+			return errors.New("temporary")
+		}
+		return nil
+	})
+	if _, err := s.Enqueue(ctx, JobRequest{Kind: "flaky", Key: "flaky"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	now := time.Now()
+	if n, err := s.RunDue(ctx, now); err != nil || n != 1 {
+		t.Fatalf("first run: n=%d err=%v", n, err)
+	}
+	// The retry is scheduled at least BaseBackoff later.
+	if n, _ := s.RunDue(ctx, now.Add(30*time.Second)); n != 0 {
+		t.Fatalf("job retried before its backoff elapsed")
+	}
+	if n, err := s.RunDue(ctx, now.Add(2*time.Minute)); err != nil || n != 1 {
+		t.Fatalf("retry run: n=%d err=%v", n, err)
+	}
+
+	done := models.JobStatusDone
+	list, err := jobs.ListJobs(ctx, repository.JobFilter{Status: &done})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 1 || list[0].Attempts != 2 {
+		t.Fatalf("expected one done job after 2 attempts, got %+v", list)
+	}
+}
+
+func TestPermanentFailureAndExpiredLease(t *testing.T) {
+	jobs := newTestRepository(t)
+	s := New(jobs, Options{Lease: time.Minute})
+	ctx := context.Background()
+
+	s.Handle("broken", func(ctx context.Context, job *models.Job) error {
+		return ErrPermanent
+	})
+	if _, err := s.Enqueue(ctx, JobRequest{Kind: "broken", Key: "broken"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := s.RunDue(ctx, time.Now()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	failed := models.JobStatusFailed
+	list, err := jobs.ListJobs(ctx, repository.JobFilter{Status: &failed})
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected one failed job, got %d (err=%v)", len(list), err)
+	}
+
+	// A job claimed by a process that died is claimed again once its lease
+	// expires.
+	if _, err := s.Enqueue(ctx, JobRequest{Kind: "orphan", Key: "orphan"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	now := time.Now()
+	if claimed, err := jobs.ClaimDueJobs(ctx, now, time.Minute, 10); err != nil || len(claimed) != 1 {
+		t.Fatalf("claim: %d jobs, err=%v", len(claimed), err)
+	}
+	if claimed, _ := jobs.ClaimDueJobs(ctx, now.Add(30*time.Second), time.Minute, 10); len(claimed) != 0 {
+		t.Fatalf("job claimed twice within its lease")
+	}
+	if claimed, _ := jobs.ClaimDueJobs(ctx, now.Add(2*time.Minute), time.Minute, 10); len(claimed) != 1 {
+		t.Fatalf("job not reclaimed after its lease expired")
+	}
+}
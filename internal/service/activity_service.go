@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+var (
+	ErrInvalidActivityKind = errors.New("invalid activity kind")
+)
+
+// activityPageSize is how many event log entries are read at a time while
+// looking for activity that matches a filter.
+const activityPageSize = 200
+
+// maxActivityScan is how many event log entries ListActivity reads at most,
+// so that a filter that few entries match doesn't walk the whole log.
+const maxActivityScan = 5000
+
+type ActivityFilter struct {
+	Project  string
+	Assignee string
+	// Kind limits activity to one kind unless it is empty.
+	Kind models.ActivityKind
+}
+
+type ActivityService interface {
+	// ListActivity returns up to limit of the most recent activities that
+	// match filter, newest first. Activity goes back as far as the event
+	// log does, but only the most recent maxActivityScan entries of it are
+	// looked at.
+	ListActivity(ctx context.Context, filter ActivityFilter, limit int) ([]*models.Activity, error)
+}
+
+type activityService struct {
+	eventLog  repository.EventLogRepository
+	workflows repository.WorkflowRepository
+	// maxScan is how many event log entries ListActivity reads at most.
+	maxScan int
+}
+
+// NewActivityService returns an ActivityService that derives activity from
+// the task events in eventLog, naming statuses after the current workflows
+// of the tasks' projects.
+func NewActivityService(eventLog repository.EventLogRepository, workflows repository.WorkflowRepository) ActivityService {
+	return &activityService{eventLog: eventLog, workflows: workflows, maxScan: maxActivityScan}
+}
+
+func (s *activityService) ListActivity(ctx context.Context, filter ActivityFilter, limit int) ([]*models.Activity, error) {
+	if filter.Kind != "" && !filter.Kind.Valid() {
+		return nil, fmt.Errorf("%w: kind must be created, status_changed or completed", ErrInvalidActivityKind)
+	}
+
+	workflows := make(map[string]*models.Workflow)
+	activities := make([]*models.Activity, 0, limit)
+	logFilter := repository.EventLogFilter{Project: filter.Project, Newest: true}
+	for scanned := 0; len(activities) < limit && scanned < s.maxScan; {
+		logFilter.Limit = min(activityPageSize, s.maxScan-scanned)
+		entries, err := s.eventLog.ListEvents(ctx, logFilter)
+		if err != nil {
+			return nil, err
+		}
+		scanned += len(entries)
+		for _, entry := range entries {
+			logFilter.BeforeID = entry.ID
+			var event events.Event
+			if err := json.Unmarshal(entry.Payload, &event); err != nil {
+				return nil, fmt.Errorf("decode event log entry %d: %w", entry.ID, err)
+			}
+			if event.Task == nil || (filter.Assignee != "" && event.Task.Assignee != filter.Assignee) {
+				continue
+			}
+			workflow, ok := workflows[event.Task.Project]
+			if !ok {
+				if workflow, err = loadWorkflow(ctx, s.workflows, event.Task.Project); err != nil {
+					return nil, err
+				}
+				workflows[event.Task.Project] = workflow
+			}
+			activity := newActivity(entry, &event, workflow)
+			if activity == nil || (filter.Kind != "" && activity.Kind != filter.Kind) {
+				continue
+			}
+			activities = append(activities, activity)
+			if len(activities) == limit {
+				break
+			}
+		}
+		if len(entries) < logFilter.Limit {
+			break
+		}
+	}
+	return activities, nil
+}
+
+// newActivity returns the activity of an event, or nil if it isn't one that
+// activity feeds show: only creations and status changes are.
+func newActivity(entry *models.EventLogEntry, event *events.Event, workflow *models.Workflow) *models.Activity {
+	activity := &models.Activity{ID: entry.ID, Task: event.Task, OccurredAt: entry.OccurredAt}
+	switch {
+	case event.Type == events.TaskCreated:
+		activity.Kind = models.ActivityCreated
+	case event.Type == events.TaskUpdated && event.Previous != nil && event.Previous.Status != event.Task.Status:
+		from, _ := workflow.Status(event.Previous.Status)
+		to, _ := workflow.Status(event.Task.Status)
+		activity.Kind = models.ActivityStatusChanged
+		if to.Category == models.StatusCategoryDone && from.Category != models.StatusCategoryDone {
+			activity.Kind = models.ActivityCompleted
+		}
+		activity.From = statusName(from, event.Previous.Status)
+		activity.To = statusName(to, event.Task.Status)
+	default:
+		return nil
+	}
+	return activity
+}
+
+// statusName returns the name of a status, or its key if the workflow no
+// longer has it.
+func statusName(status models.WorkflowStatus, key models.TaskStatus) string {
+	if status.Name != "" {
+		return status.Name
+	}
+	return string(key)
+}
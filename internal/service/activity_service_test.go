@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+func TestListActivity(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	eventLog := repository.NewSQLiteEventLogRepository(db)
+	workflows := repository.NewSQLiteWorkflowRepository(db)
+	bus := events.NewBus()
+	bus.Subscribe(NewEventStreamService(eventLog, time.Hour).HandleEvent)
+	tasks := NewTaskService(repository.NewSQLiteTaskRepository(db), workflows, bus, repository.NewSQLTransactor(db))
+	svc := NewActivityService(eventLog, workflows)
+
+	rent, err := tasks.CreateTask(ctx, models.CreateTaskInput{Project: "home", Title: "Pay rent", Assignee: "sam"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := tasks.CreateTask(ctx, models.CreateTaskInput{Project: "work", Title: "Write report"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	title := "Pay the rent"
+	inProgress, done := models.TaskStatusInProgress, models.TaskStatusDone
+	for _, input := range []models.UpdateTaskInput{{Title: &title}, {Status: &inProgress}, {Status: &done}} {
+		if _, err := tasks.UpdateTask(ctx, rent.ID, input); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	}
+
+	// The title change isn't activity.
+	activities, err := svc.ListActivity(ctx, ActivityFilter{}, 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var kinds []models.ActivityKind
+	for _, activity := range activities {
+		kinds = append(kinds, activity.Kind)
+	}
+	want := []models.ActivityKind{models.ActivityCompleted, models.ActivityStatusChanged, models.ActivityCreated, models.ActivityCreated}
+	if len(kinds) != len(want) {
+		t.Fatalf("kinds %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("kinds %v, want %v", kinds, want)
+		}
+	}
+	if activities[1].From != "New" || activities[1].To != "In progress" || activities[0].Task.Title != "Pay the rent" {
+		t.Errorf("status change %+v, completion %+v", activities[1], activities[0])
+	}
+	if activities[0].ID <= activities[1].ID {
+		t.Errorf("activity isn't newest first: %d, %d", activities[0].ID, activities[1].ID)
+	}
+
+	for _, test := range []struct {
+		filter ActivityFilter
+		limit  int
+		want   int
+	}{
+		{ActivityFilter{Project: "home"}, 10, 3},
+		{ActivityFilter{Assignee: "sam"}, 10, 3},
+		{ActivityFilter{Project: "work", Assignee: "sam"}, 10, 0},
+		{ActivityFilter{Kind: models.ActivityCreated}, 10, 2},
+		{ActivityFilter{Kind: models.ActivityCompleted, Project: "home"}, 10, 1},
+		{ActivityFilter{}, 2, 2},
+	} {
+		activities, err := svc.ListActivity(ctx, test.filter, test.limit)
+		if err != nil || len(activities) != test.want {
+			t.Errorf("%+v: %d activities, %v; want %d", test.filter, len(activities), err, test.want)
+		}
+	}
+
+	// Only the most recent entries are looked at: the newest two of the
+	// five are the completion and the status change.
+	svc.(*activityService).maxScan = 2
+	if activities, err := svc.ListActivity(ctx, ActivityFilter{Assignee: "sam"}, 10); err != nil || len(activities) != 2 {
+		t.Errorf("assignee with a scan of 2 entries: %d activities, %v; want 2", len(activities), err)
+	}
+	if activities, err := svc.ListActivity(ctx, ActivityFilter{Kind: models.ActivityCreated}, 10); err != nil || len(activities) != 0 {
+		t.Errorf("creations with a scan of 2 entries: %d activities, %v; want 0", len(activities), err)
+	}
+	svc.(*activityService).maxScan = maxActivityScan
+
+	if _, err := svc.ListActivity(ctx, ActivityFilter{Kind: "deleted"}, 10); !errors.Is(err, ErrInvalidActivityKind) {
+		t.Errorf("unknown kind: %v", err)
+	}
+}
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/events"
+	"task-manager/internal/ical"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+var (
+	// ErrPreconditionFailed is returned when a calendar object doesn't
+	// have the ETag a request is conditional on.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrInvalidSyncToken is returned for sync tokens that weren't issued
+	// or whose changes were pruned from the event log.
+	ErrInvalidSyncToken    = errors.New("invalid sync token")
+	ErrInvalidCalendarData = errors.New("invalid calendar data")
+)
+
+// caldavExternalIDPrefix marks the external IDs of tasks that CalDAV
+// clients created; the rest of the ID is the name they gave the resource.
+const caldavExternalIDPrefix = "caldav:"
+
+// syncTokenPrefix makes sync tokens the URIs that RFC 6578 asks for. The
+// rest of a token is the ID of the last event log entry it covers.
+const syncTokenPrefix = "urn:task-manager:sync:"
+
+// syncPageSize is how many event log entries are read at a time.
+const syncPageSize = 500
+
+// CalDAVObject is a task as a CalDAV calendar object resource.
+type CalDAVObject struct {
+	// Name is the name of the resource without .ics.
+	Name string
+	ETag string
+	Task *models.Task
+	// Data is a VCALENDAR with the task as VTODO.
+	Data []byte
+}
+
+// CalDAVService maps the calendars and calendar objects of CalDAV onto
+// projects and tasks. Objects are addressed by their project and name.
+type CalDAVService interface {
+	// Projects returns the projects that have tasks, and the default
+	// project, in order.
+	Projects(ctx context.Context) ([]string, error)
+	ListObjects(ctx context.Context, project string) ([]*CalDAVObject, error)
+	GetObject(ctx context.Context, project, name string) (*CalDAVObject, error)
+	// PutObject creates or updates the task of an object from the VTODO in
+	// data. ifMatch is the ETag the object must have, or "*" if it must
+	// exist; ifNoneMatch "*" means it must not exist yet.
+	PutObject(ctx context.Context, project, name string, data []byte, ifMatch, ifNoneMatch string) (object *CalDAVObject, created bool, err error)
+	DeleteObject(ctx context.Context, project, name, ifMatch string) error
+	// SyncToken returns the token that covers all changes so far.
+	SyncToken(ctx context.Context) (string, error)
+	// Changes returns the objects of project that changed since token, the
+	// names of those that were removed, and the token to ask with next.
+	Changes(ctx context.Context, project, token string) (changed []*CalDAVObject, removed []string, next string, err error)
+}
+
+type calDAVService struct {
+	service   TaskService
+	repo      repository.TaskRepository
+	workflows repository.WorkflowRepository
+	eventLog  repository.EventLogRepository
+	tx        repository.Transactor
+}
+
+// NewCalDAVService returns a CalDAVService that changes tasks through
+// service. Sync tokens are positions in the event log, so that changes,
+// including deletions, show up once the outbox relayed their events.
+func NewCalDAVService(service TaskService, repo repository.TaskRepository, workflows repository.WorkflowRepository, eventLog repository.EventLogRepository, tx repository.Transactor) CalDAVService {
+	return &calDAVService{service: service, repo: repo, workflows: workflows, eventLog: eventLog, tx: tx}
+}
+
+// caldavName returns the name of the resource of a task: the name a client
+// gave it, or the task's ID.
+func caldavName(task *models.Task) string {
+	if name, ok := strings.CutPrefix(task.ExternalID, caldavExternalIDPrefix); ok {
+		return name
+	}
+	return task.ID.String()
+}
+
+// caldavETag returns the ETag of a task: its ID and Sequence, which counts
+// every update, unlike updated_at, which two updates can share. The ID keeps
+// the ETag of a deleted object from matching one created under its name.
+func caldavETag(task *models.Task) string {
+	return `"` + task.ID.String() + "-" + strconv.FormatInt(task.Sequence, 10) + `"`
+}
+
+func (s *calDAVService) Projects(ctx context.Context) ([]string, error) {
+	projects := []string{models.DefaultProject}
+	err := s.service.EachTask(ctx, repository.TaskFilter{}, func(task *models.Task) error {
+		if !slices.Contains(projects, task.Project) {
+			projects = append(projects, task.Project)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(projects)
+	return projects, nil
+}
+
+func (s *calDAVService) ListObjects(ctx context.Context, project string) ([]*CalDAVObject, error) {
+	tasks, err := s.repo.ListTasks(ctx, repository.TaskFilter{Project: project})
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]*CalDAVObject, 0, len(tasks))
+	for _, task := range tasks {
+		object, err := s.object(ctx, task)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+func (s *calDAVService) object(ctx context.Context, task *models.Task) (*CalDAVObject, error) {
+	workflow, err := loadWorkflow(ctx, s.workflows, task.Project)
+	if err != nil {
+		return nil, err
+	}
+	status, _ := workflow.Status(task.Status)
+	name := caldavName(task)
+
+	var data bytes.Buffer
+	cal := ical.NewWriter(&data)
+	cal.Begin("VCALENDAR")
+	cal.Property("VERSION", "2.0")
+	cal.Property("PRODID", "-//task-manager//caldav//EN")
+	ical.WriteTask(cal, task, ical.TaskOptions{Component: ical.Todo, UID: name, Category: status.Category})
+	cal.End("VCALENDAR")
+	if err := cal.Flush(); err != nil {
+		return nil, err
+	}
+	return &CalDAVObject{Name: name, ETag: caldavETag(task), Task: task, Data: data.Bytes()}, nil
+}
+
+// findTask returns the task of the object name in project, or
+// ErrTaskNotFound. Names of objects created by clients are looked up by
+// their external ID first, as they may be UUIDs too.
+func (s *calDAVService) findTask(ctx context.Context, project, name string) (*models.Task, error) {
+	task, err := s.repo.GetTaskByExternalID(ctx, caldavExternalIDPrefix+name)
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		taskID, parseErr := uuid.Parse(name)
+		if parseErr != nil {
+			return nil, repository.ErrTaskNotFound
+		}
+		task, err = s.repo.GetTask(ctx, taskID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if task.Project != project {
+		return nil, repository.ErrTaskNotFound
+	}
+	return task, nil
+}
+
+func (s *calDAVService) GetObject(ctx context.Context, project, name string) (*CalDAVObject, error) {
+	task, err := s.findTask(ctx, project, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.object(ctx, task)
+}
+
+func (s *calDAVService) PutObject(ctx context.Context, project, name string, data []byte, ifMatch, ifNoneMatch string) (*CalDAVObject, bool, error) {
+	todo, err := ical.ParseTodo(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %w", ErrInvalidCalendarData, err)
+	}
+
+	var task *models.Task
+	created := false
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		existing, err := s.findTask(ctx, project, name)
+		if err != nil && !errors.Is(err, repository.ErrTaskNotFound) {
+			return err
+		}
+		if err := checkPreconditions(existing, ifMatch, ifNoneMatch); err != nil {
+			return err
+		}
+		workflow, err := loadWorkflow(ctx, s.workflows, project)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			created = true
+			existing, err = s.service.CreateTask(ctx, models.CreateTaskInput{
+				ExternalID:  caldavExternalIDPrefix + name,
+				Project:     project,
+				Title:       todo.Summary,
+				Description: todo.Description,
+				DueAt:       todo.Due,
+			})
+			if err != nil {
+				return err
+			}
+			status, err := todoStatus(workflow, existing.Status, todo.Status)
+			if err != nil || status == nil {
+				task = existing
+				return err
+			}
+			task, err = s.service.UpdateTask(ctx, existing.ID, models.UpdateTaskInput{Status: status})
+			return err
+		}
+
+		status, err := todoStatus(workflow, existing.Status, todo.Status)
+		if err != nil {
+			return err
+		}
+		task, err = s.service.UpdateTask(ctx, existing.ID, models.UpdateTaskInput{
+			Title:       &todo.Summary,
+			Description: &todo.Description,
+			Status:      status,
+			DueAt:       todo.Due,
+			ClearDueAt:  todo.Due == nil,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	object, err := s.object(ctx, task)
+	return object, created, err
+}
+
+// checkPreconditions checks the If-Match and If-None-Match headers of a
+// request against the object of existing, which is nil if there is none.
+func checkPreconditions(existing *models.Task, ifMatch, ifNoneMatch string) error {
+	switch {
+	case ifMatch != "" && existing == nil:
+		return fmt.Errorf("%w: the object doesn't exist", ErrPreconditionFailed)
+	case ifMatch != "" && ifMatch != "*" && ifMatch != caldavETag(existing):
+		return fmt.Errorf("%w: the object was changed", ErrPreconditionFailed)
+	case ifNoneMatch == "*" && existing != nil:
+		return fmt.Errorf("%w: the object exists", ErrPreconditionFailed)
+	}
+	return nil
+}
+
+// todoStatus returns the status that the STATUS of a VTODO moves a task to,
+// or nil if it stays where it is. A to-do that is COMPLETED, IN-PROCESS or
+// NEEDS-ACTION moves to the first status of the done, active or todo
+// category that the workflow allows, unless its status is in that category
+// already.
+func todoStatus(workflow *models.Workflow, current models.TaskStatus, todoStatus string) (*models.TaskStatus, error) {
+	var category models.StatusCategory
+	switch todoStatus {
+	case "COMPLETED":
+		category = models.StatusCategoryDone
+	case "IN-PROCESS":
+		category = models.StatusCategoryActive
+	case "NEEDS-ACTION":
+		category = models.StatusCategoryTodo
+	default:
+		return nil, nil
+	}
+	if status, ok := workflow.Status(current); ok && status.Category == category {
+		return nil, nil
+	}
+	for _, status := range workflow.Statuses {
+		if status.Category == category && workflow.CanTransition(current, status.Key) {
+			return &status.Key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: the workflow doesn't allow moving %q to a %s status", ErrInvalidTransition, current, category)
+}
+
+func (s *calDAVService) DeleteObject(ctx context.Context, project, name, ifMatch string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		task, err := s.findTask(ctx, project, name)
+		if err != nil {
+			return err
+		}
+		if err := checkPreconditions(task, ifMatch, ""); err != nil {
+			return err
+		}
+		return s.service.DeleteTask(ctx, task.ID)
+	})
+}
+
+func (s *calDAVService) SyncToken(ctx context.Context) (string, error) {
+	lastID, err := s.eventLog.LastEventID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return syncTokenPrefix + strconv.FormatInt(lastID, 10), nil
+}
+
+func (s *calDAVService) Changes(ctx context.Context, project, token string) ([]*CalDAVObject, []string, string, error) {
+	afterID, err := strconv.ParseInt(strings.TrimPrefix(token, syncTokenPrefix), 10, 64)
+	if err != nil || !strings.HasPrefix(token, syncTokenPrefix) || afterID < 0 {
+		return nil, nil, "", ErrInvalidSyncToken
+	}
+	firstID, err := s.eventLog.FirstEventID(ctx)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if afterID > 0 && firstID > afterID+1 {
+		return nil, nil, "", fmt.Errorf("%w: its changes were pruned", ErrInvalidSyncToken)
+	}
+
+	// Tasks are reported by their state now; the log only tells which ones
+	// changed, and what they were called in case they are gone. A task
+	// that moved to another project is gone from this one.
+	var changedIDs []uuid.UUID
+	names := make(map[uuid.UUID]string)
+	for {
+		entries, err := s.eventLog.ListEvents(ctx, repository.EventLogFilter{AfterID: afterID, Limit: syncPageSize})
+		if err != nil {
+			return nil, nil, "", err
+		}
+		for _, entry := range entries {
+			afterID = entry.ID
+			var event events.Event
+			if err := json.Unmarshal(entry.Payload, &event); err != nil || event.Task == nil {
+				continue
+			}
+			if event.Task.Project != project && (event.Previous == nil || event.Previous.Project != project) {
+				continue
+			}
+			if _, ok := names[entry.TaskID]; !ok {
+				changedIDs = append(changedIDs, entry.TaskID)
+			}
+			names[entry.TaskID] = caldavName(event.Task)
+		}
+		if len(entries) < syncPageSize {
+			break
+		}
+	}
+
+	var changed []*CalDAVObject
+	var removed []string
+	for _, taskID := range changedIDs {
+		task, err := s.repo.GetTask(ctx, taskID)
+		if errors.Is(err, repository.ErrTaskNotFound) || (err == nil && task.Project != project) {
+			removed = append(removed, names[taskID])
+			continue
+		}
+		if err != nil {
+			return nil, nil, "", err
+		}
+		object, err := s.object(ctx, task)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		changed = append(changed, object)
+	}
+	return changed, removed, syncTokenPrefix + strconv.FormatInt(afterID, 10), nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/ical"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+var (
+	ErrInvalidCalendarFeed = errors.New("invalid calendar feed")
+)
+
+// calendarHistory is how long tasks stay in calendar feeds after they were
+// due.
+const calendarHistory = 365 * 24 * time.Hour
+
+// calendarRefresh is how often calendar clients are asked to check a feed
+// for changes.
+const calendarRefresh = "PT15M"
+
+type CalendarService interface {
+	CreateFeed(ctx context.Context, input models.CreateCalendarFeedInput) (*models.CalendarFeed, error)
+	ListFeeds(ctx context.Context) ([]*models.CalendarFeed, error)
+	DeleteFeed(ctx context.Context, feedID uuid.UUID) error
+	// WriteFeed writes the calendar of the feed with token to w, listing its
+	// tasks as component. It returns ErrCalendarFeedNotFound for unknown
+	// tokens.
+	WriteFeed(ctx context.Context, token string, component ical.Component, w io.Writer) error
+}
+
+type calendarService struct {
+	repo      repository.CalendarFeedRepository
+	tasks     repository.TaskRepository
+	workflows repository.WorkflowRepository
+}
+
+func NewCalendarService(repo repository.CalendarFeedRepository, tasks repository.TaskRepository, workflows repository.WorkflowRepository) CalendarService {
+	return &calendarService{repo: repo, tasks: tasks, workflows: workflows}
+}
+
+// CalendarFeedPath returns the path that a feed with token is served at.
+func CalendarFeedPath(token string) string {
+	return "/ical/" + token + ".ics"
+}
+
+func (s *calendarService) CreateFeed(ctx context.Context, input models.CreateCalendarFeedInput) (*models.CalendarFeed, error) {
+	feed := &models.CalendarFeed{
+		ID:       uuid.New(),
+		Name:     strings.TrimSpace(input.Name),
+		Project:  strings.TrimSpace(input.Project),
+		Assignee: strings.TrimSpace(input.Assignee),
+		Timezone: strings.TrimSpace(input.Timezone),
+	}
+	if feed.Project == "" && feed.Assignee == "" {
+		return nil, fmt.Errorf("%w: a project, an assignee or both are required", ErrInvalidCalendarFeed)
+	}
+	if feed.Timezone == "" {
+		feed.Timezone = "UTC"
+	}
+	// Local would depend on the server's configuration.
+	if _, err := time.LoadLocation(feed.Timezone); err != nil || feed.Timezone == "Local" {
+		return nil, fmt.Errorf("%w: timezone must be an IANA time zone like Europe/Berlin", ErrInvalidCalendarFeed)
+	}
+	if feed.Name == "" {
+		feed.Name = strings.Trim(feed.Project+" / "+feed.Assignee, " /")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	if err := s.repo.CreateFeed(ctx, feed, hashCalendarToken(token)); err != nil {
+		return nil, err
+	}
+	feed.Token = token
+	feed.Path = CalendarFeedPath(token)
+	return feed, nil
+}
+
+// hashCalendarToken returns what is stored of a token, so that the
+// database doesn't give away the feeds' URLs.
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *calendarService) ListFeeds(ctx context.Context) ([]*models.CalendarFeed, error) {
+	return s.repo.ListFeeds(ctx)
+}
+
+func (s *calendarService) DeleteFeed(ctx context.Context, feedID uuid.UUID) error {
+	return s.repo.DeleteFeed(ctx, feedID)
+}
+
+func (s *calendarService) WriteFeed(ctx context.Context, token string, component ical.Component, w io.Writer) error {
+	feed, err := s.repo.GetFeedByTokenHash(ctx, hashCalendarToken(token))
+	if err != nil {
+		return err
+	}
+	location, err := time.LoadLocation(feed.Timezone)
+	if err != nil {
+		return err
+	}
+	since := time.Now().Add(-calendarHistory)
+	tasks, err := s.tasks.ListTasks(ctx, repository.TaskFilter{Project: feed.Project, Assignee: feed.Assignee, DueAfter: &since})
+	if err != nil {
+		return err
+	}
+
+	cal := ical.NewWriter(w)
+	cal.Begin("VCALENDAR")
+	cal.Property("VERSION", "2.0")
+	cal.Property("PRODID", "-//task-manager//calendar feed//EN")
+	cal.Property("CALSCALE", "GREGORIAN")
+	cal.Text("NAME", feed.Name)
+	cal.Text("X-WR-CALNAME", feed.Name)
+	cal.Text("X-WR-TIMEZONE", feed.Timezone)
+	cal.Property("REFRESH-INTERVAL;VALUE=DURATION", calendarRefresh)
+	cal.Property("X-PUBLISHED-TTL", calendarRefresh)
+	workflows := make(map[string]*models.Workflow)
+	for _, task := range tasks {
+		var category models.StatusCategory
+		if component == ical.Todo {
+			workflow, ok := workflows[task.Project]
+			if !ok {
+				if workflow, err = loadWorkflow(ctx, s.workflows, task.Project); err != nil {
+					return err
+				}
+				workflows[task.Project] = workflow
+			}
+			status, _ := workflow.Status(task.Status)
+			category = status.Category
+		}
+		ical.WriteTask(cal, task, ical.TaskOptions{
+			Component: component,
+			UID:       task.ID.String() + "@task-manager",
+			Category:  category,
+			Location:  location,
+		})
+	}
+	cal.End("VCALENDAR")
+	return cal.Flush()
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+type CommentService interface {
+	CreateComment(ctx context.Context, taskID uuid.UUID, input models.CreateCommentInput) (*models.Comment, error)
+	ListComments(ctx context.Context, taskID uuid.UUID) ([]*models.Comment, error)
+	// ListCommentsForTasks returns the comments of many tasks with one
+	// query, by task. Tasks that don't exist have no comments.
+	ListCommentsForTasks(ctx context.Context, taskIDs []uuid.UUID) (map[uuid.UUID][]*models.Comment, error)
+}
+
+type commentService struct {
+	repo   repository.CommentRepository
+	tasks  repository.TaskRepository
+	events events.Publisher
+	tx     repository.Transactor
+}
+
+func NewCommentService(repo repository.CommentRepository, tasks repository.TaskRepository, publisher events.Publisher, tx repository.Transactor) CommentService {
+	return &commentService{repo: repo, tasks: tasks, events: publisher, tx: tx}
+}
+
+func (s *commentService) CreateComment(ctx context.Context, taskID uuid.UUID, input models.CreateCommentInput) (*models.Comment, error) {
+	body := strings.TrimSpace(input.Body)
+	if body == "" {
+		return nil, fmt.Errorf("comment body must not be empty")
+	}
+	comment := &models.Comment{
+		ID:     uuid.New(),
+		Author: strings.TrimSpace(input.Author),
+		Body:   body,
+	}
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		task, err := s.tasks.GetTask(ctx, taskID)
+		if err != nil {
+			return err
+		}
+		comment.TaskID = task.ID
+		if err := s.repo.CreateComment(ctx, comment); err != nil {
+			return err
+		}
+		return s.events.Publish(ctx, events.Event{
+			Type:       events.CommentCreated,
+			TaskID:     task.ID,
+			Project:    task.Project,
+			Task:       task,
+			Comment:    comment,
+			OccurredAt: time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (s *commentService) ListComments(ctx context.Context, taskID uuid.UUID) ([]*models.Comment, error) {
+	if _, err := s.tasks.GetTask(ctx, taskID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListComments(ctx, taskID)
+}
+
+func (s *commentService) ListCommentsForTasks(ctx context.Context, taskIDs []uuid.UUID) (map[uuid.UUID][]*models.Comment, error) {
+	return s.repo.ListCommentsForTasks(ctx, taskIDs)
+}
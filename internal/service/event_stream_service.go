@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+// subscriptionBuffer is how many entries a subscriber may fall behind before
+// it is dropped. Dropped clients reconnect and resume from the event log.
+const subscriptionBuffer = 64
+
+var (
+	ErrEventStreamClosed = errors.New("event stream closed")
+)
+
+// streamEvents are the event types recorded in the event log.
+var streamEvents = map[events.Type]bool{
+	events.TaskCreated: true,
+	events.TaskUpdated: true,
+	events.TaskDeleted: true,
+}
+
+type EventStreamFilter struct {
+	Project string
+	Status  *models.TaskStatus
+}
+
+func (f EventStreamFilter) matches(entry *models.EventLogEntry) bool {
+	if f.Project != "" && entry.Project != f.Project {
+		return false
+	}
+	return f.Status == nil || entry.Status == *f.Status
+}
+
+// EventSubscription receives the entries appended to the event log after it
+// was created. C is closed when the subscriber fell too far behind or the
+// stream was closed.
+type EventSubscription struct {
+	C      <-chan *models.EventLogEntry
+	ch     chan *models.EventLogEntry
+	filter EventStreamFilter
+}
+
+type EventStreamService interface {
+	// HandleEvent appends task events to the event log and fans them out to
+	// subscribers. It is meant to be an outbox sink.
+	HandleEvent(ctx context.Context, event events.Event) error
+	Subscribe(filter EventStreamFilter) (*EventSubscription, error)
+	Unsubscribe(subscription *EventSubscription)
+	// Replay returns up to limit logged entries after afterID. reset reports
+	// that entries after afterID were already pruned, so the client missed
+	// events and has to reload its state.
+	Replay(ctx context.Context, afterID int64, filter EventStreamFilter, limit int) (entries []*models.EventLogEntry, reset bool, err error)
+	// Prune deletes entries older than the retention period.
+	Prune(ctx context.Context, now time.Time) (int64, error)
+	// Close ends all subscriptions; later Subscribe calls fail. It is called
+	// on shutdown so that open streams don't hold it up.
+	Close()
+}
+
+type eventStreamService struct {
+	repo      repository.EventLogRepository
+	retention time.Duration
+
+	mu            sync.Mutex
+	subscriptions map[*EventSubscription]struct{}
+	closed        bool
+}
+
+func NewEventStreamService(repo repository.EventLogRepository, retention time.Duration) EventStreamService {
+	return &eventStreamService{
+		repo:          repo,
+		retention:     retention,
+		subscriptions: make(map[*EventSubscription]struct{}),
+	}
+}
+
+func (s *eventStreamService) HandleEvent(ctx context.Context, event events.Event) error {
+	if !streamEvents[event.Type] || event.Task == nil {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	entry := &models.EventLogEntry{
+		Type:       string(event.Type),
+		TaskID:     event.TaskID,
+		Project:    event.Project,
+		Status:     event.Task.Status,
+		Payload:    payload,
+		OccurredAt: event.OccurredAt,
+	}
+
+	if err := s.repo.AppendEvent(ctx, entry); err != nil {
+		return err
+	}
+	// Subscribers only hear about entries that were committed. Entries
+	// reach them in ID order as long as HandleEvent isn't called
+	// concurrently, which the outbox relay guarantees.
+	repository.AfterCommit(ctx, func() { s.broadcast(entry) })
+	return nil
+}
+
+func (s *eventStreamService) broadcast(entry *models.EventLogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for subscription := range s.subscriptions {
+		if !subscription.filter.matches(entry) {
+			continue
+		}
+		select {
+		case subscription.ch <- entry:
+		default:
+			delete(s.subscriptions, subscription)
+			close(subscription.ch)
+		}
+	}
+}
+
+func (s *eventStreamService) Subscribe(filter EventStreamFilter) (*EventSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrEventStreamClosed
+	}
+	ch := make(chan *models.EventLogEntry, subscriptionBuffer)
+	subscription := &EventSubscription{C: ch, ch: ch, filter: filter}
+	s.subscriptions[subscription] = struct{}{}
+	return subscription, nil
+}
+
+func (s *eventStreamService) Unsubscribe(subscription *EventSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscriptions[subscription]; ok {
+		delete(s.subscriptions, subscription)
+		close(subscription.ch)
+	}
+}
+
+func (s *eventStreamService) Replay(ctx context.Context, afterID int64, filter EventStreamFilter, limit int) ([]*models.EventLogEntry, bool, error) {
+	firstID, err := s.repo.FirstEventID(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	reset := afterID > 0 && firstID > afterID+1
+	entries, err := s.repo.ListEvents(ctx, repository.EventLogFilter{
+		AfterID: afterID,
+		Project: filter.Project,
+		Status:  filter.Status,
+		Limit:   limit,
+	})
+	return entries, reset, err
+}
+
+func (s *eventStreamService) Prune(ctx context.Context, now time.Time) (int64, error) {
+	return s.repo.PruneEvents(ctx, now.Add(-s.retention))
+}
+
+func (s *eventStreamService) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for subscription := range s.subscriptions {
+		delete(s.subscriptions, subscription)
+		close(subscription.ch)
+	}
+}
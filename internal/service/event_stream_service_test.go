@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+func TestEventStreamReplayAndLiveDelivery(t *testing.T) {
+	ctx := context.Background()
+	svc := NewEventStreamService(repository.NewSQLiteEventLogRepository(newTestDB(t)), time.Hour)
+
+	publish := func(eventType events.Type, project string, status models.TaskStatus) {
+		t.Helper()
+		task := &models.Task{ID: uuid.New(), Project: project, Title: "Pay rent", Status: status}
+		if err := svc.HandleEvent(ctx, events.NewTaskEvent(eventType, task, nil)); err != nil {
+			t.Fatalf("handle event: %v", err)
+		}
+	}
+	publish(events.TaskCreated, "home", models.TaskStatusNew)
+	publish(events.TaskCreated, "work", models.TaskStatusNew)
+	publish(events.TaskAssigned, "home", models.TaskStatusNew) // not logged
+
+	done := models.TaskStatusDone
+	subscription, err := svc.Subscribe(EventStreamFilter{Project: "home", Status: &done})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	publish(events.TaskUpdated, "home", models.TaskStatusInProgress)
+	publish(events.TaskUpdated, "home", models.TaskStatusDone)
+	publish(events.TaskUpdated, "work", models.TaskStatusDone)
+
+	select {
+	case entry := <-subscription.C:
+		if entry.ID != 4 || entry.Type != string(events.TaskUpdated) || entry.Status != models.TaskStatusDone {
+			t.Fatalf("unexpected live entry %+v", entry)
+		}
+	default:
+		t.Fatalf("expected a live entry")
+	}
+	select {
+	case entry := <-subscription.C:
+		t.Fatalf("entry %d doesn't match the filter", entry.ID)
+	default:
+	}
+
+	entries, reset, err := svc.Replay(ctx, 1, EventStreamFilter{Project: "home"}, 10)
+	if err != nil || reset {
+		t.Fatalf("replay: reset=%v err=%v", reset, err)
+	}
+	if len(entries) != 2 || entries[0].ID != 3 || entries[1].ID != 4 {
+		t.Fatalf("unexpected replay %+v", entries)
+	}
+
+	// Pruning everything but the latest entry makes resuming from an old ID
+	// a reset.
+	if _, err := svc.Prune(ctx, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	entries, reset, err = svc.Replay(ctx, 1, EventStreamFilter{}, 10)
+	if err != nil || !reset || len(entries) != 1 || entries[0].ID != 5 {
+		t.Fatalf("replay after prune: entries=%d reset=%v err=%v", len(entries), reset, err)
+	}
+
+	svc.Close()
+	if _, ok := <-subscription.C; ok {
+		t.Fatalf("subscription must be closed by Close")
+	}
+	if _, err := svc.Subscribe(EventStreamFilter{}); err != ErrEventStreamClosed {
+		t.Fatalf("subscribe after close: %v", err)
+	}
+}
+
+func TestEventStreamDropsSlowSubscribers(t *testing.T) {
+	ctx := context.Background()
+	svc := NewEventStreamService(repository.NewSQLiteEventLogRepository(newTestDB(t)), time.Hour)
+	subscription, err := svc.Subscribe(EventStreamFilter{})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	task := &models.Task{ID: uuid.New(), Project: models.DefaultProject, Title: "Pay rent", Status: models.TaskStatusNew}
+	for i := 0; i <= subscriptionBuffer; i++ {
+		if err := svc.HandleEvent(ctx, events.NewTaskEvent(events.TaskUpdated, task, task)); err != nil {
+			t.Fatalf("handle event: %v", err)
+		}
+	}
+	received := 0
+	for range subscription.C {
+		received++
+	}
+	if received != subscriptionBuffer {
+		t.Fatalf("received %d entries before the subscription was dropped, want %d", received, subscriptionBuffer)
+	}
+	// Unsubscribing a dropped subscription is harmless.
+	svc.Unsubscribe(subscription)
+}
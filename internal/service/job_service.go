@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+type JobService interface {
+	ListJobs(ctx context.Context, filter repository.JobFilter) ([]*models.Job, error)
+}
+
+type jobService struct {
+	repo repository.JobRepository
+}
+
+func NewJobService(repo repository.JobRepository) JobService {
+	return &jobService{repo: repo}
+}
+
+func (s *jobService) ListJobs(ctx context.Context, filter repository.JobFilter) ([]*models.Job, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultLimit
+	}
+	return s.repo.ListJobs(ctx, filter)
+}
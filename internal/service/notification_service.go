@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/notify"
+	"task-manager/internal/repository"
+	"task-manager/internal/scheduler"
+)
+
+const JobKindNotification = "notification"
+
+var (
+	ErrInvalidPreferences = errors.New("invalid notification preferences")
+)
+
+type notificationPayload struct {
+	Notifier     string              `json:"notifier"`
+	Notification notify.Notification `json:"notification"`
+}
+
+type NotificationService interface {
+	GetPreferences(ctx context.Context, user string) (*models.NotificationPreferences, error)
+	UpdatePreferences(ctx context.Context, user string, input models.UpdateNotificationPreferencesInput) (*models.NotificationPreferences, error)
+	// HandleEvent turns assignment and comment events into notification
+	// jobs, one per notifier. It is meant to be subscribed to the event bus.
+	HandleEvent(ctx context.Context, event events.Event) error
+	// HandleNotification is the scheduler handler for notification jobs.
+	HandleNotification(ctx context.Context, job *models.Job) error
+}
+
+type notificationService struct {
+	repo      repository.NotificationRepository
+	jobs      scheduler.Enqueuer
+	notifiers map[string]notify.Notifier
+}
+
+func NewNotificationService(repo repository.NotificationRepository, jobs scheduler.Enqueuer, notifiers map[string]notify.Notifier) NotificationService {
+	return &notificationService{repo: repo, jobs: jobs, notifiers: notifiers}
+}
+
+func (s *notificationService) GetPreferences(ctx context.Context, user string) (*models.NotificationPreferences, error) {
+	preferences, err := s.repo.GetPreferences(ctx, user)
+	if errors.Is(err, repository.ErrPreferencesNotFound) {
+		return models.DefaultNotificationPreferences(user), nil
+	}
+	return preferences, err
+}
+
+func (s *notificationService) UpdatePreferences(ctx context.Context, user string, input models.UpdateNotificationPreferencesInput) (*models.NotificationPreferences, error) {
+	user = strings.TrimSpace(user)
+	if user == "" {
+		return nil, fmt.Errorf("%w: user must not be empty", ErrInvalidPreferences)
+	}
+	preferences, err := s.GetPreferences(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if input.Email != nil {
+		email := strings.TrimSpace(*input.Email)
+		if email != "" && !models.IsBareEmailAddress(email) {
+			return nil, fmt.Errorf("%w: %q is not an email address", ErrInvalidPreferences, email)
+		}
+		preferences.Email = email
+	}
+	if input.EmailEnabled != nil {
+		preferences.EmailEnabled = *input.EmailEnabled
+	}
+	if input.Events != nil {
+		for _, event := range input.Events {
+			switch notify.Type(event) {
+			case notify.TypeDueSoon, notify.TypeOverdue, notify.TypeAssigned, notify.TypeCommented:
+			default:
+				return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidPreferences, event)
+			}
+		}
+		preferences.Events = input.Events
+	}
+	if input.Digest != nil {
+		switch *input.Digest {
+		case models.DigestModeNone, models.DigestModeHourly, models.DigestModeDaily:
+			preferences.Digest = *input.Digest
+		default:
+			return nil, fmt.Errorf("%w: digest must be one of none, hourly, daily", ErrInvalidPreferences)
+		}
+	}
+	if err := s.repo.SavePreferences(ctx, preferences); err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+func (s *notificationService) HandleEvent(ctx context.Context, event events.Event) error {
+	var notification notify.Notification
+	switch event.Type {
+	case events.TaskAssigned:
+		notification = notify.Notification{Type: notify.TypeAssigned, Task: event.Task}
+	case events.CommentCreated:
+		notification = notify.Notification{Type: notify.TypeCommented, Task: event.Task, Comment: event.Comment}
+	default:
+		return nil
+	}
+	if notification.Task == nil || notification.Task.Assignee == "" {
+		return nil
+	}
+
+	names := make([]string, 0, len(s.notifiers))
+	for name := range s.notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key string
+	if event.Comment != nil {
+		key = event.Comment.ID.String()
+	} else {
+		key = fmt.Sprintf("%s:%s:%d", event.TaskID, event.Task.Assignee, event.OccurredAt.UnixNano())
+	}
+	for _, name := range names {
+		if _, err := s.jobs.Enqueue(ctx, scheduler.JobRequest{
+			Kind:    JobKindNotification,
+			Key:     fmt.Sprintf("notification:%s:%s:%s", name, notification.Type, key),
+			Payload: notificationPayload{Notifier: name, Notification: notification},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *notificationService) HandleNotification(ctx context.Context, job *models.Job) error {
+	var payload notificationPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("%w: decode payload: %v", scheduler.ErrPermanent, err)
+	}
+	notifier, ok := s.notifiers[payload.Notifier]
+	if !ok {
+		return fmt.Errorf("%w: unknown notifier %q", scheduler.ErrPermanent, payload.Notifier)
+	}
+	return notifier.Notify(ctx, payload.Notification)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+func TestUpdatePreferencesValidatesEmail(t *testing.T) {
+	svc := NewNotificationService(repository.NewSQLiteNotificationRepository(newTestDB(t)), nil, nil)
+	tests := []struct {
+		email string
+		valid bool
+	}{
+		{"sam@example.com", true},
+		{"", true},
+		{"sam", false},
+		{"@", false},
+		{"sam@", false},
+		{"Sam <sam@example.com>", false},
+		{"<sam@example.com>", false},
+		{"sam@example.com, alex@example.com", false},
+		{"sam@example.com (Sam)", false},
+	}
+	for _, tt := range tests {
+		email := tt.email
+		_, err := svc.UpdatePreferences(context.Background(), "sam", models.UpdateNotificationPreferencesInput{Email: &email})
+		if tt.valid && err != nil {
+			t.Errorf("%q: %v", tt.email, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidPreferences) {
+			t.Errorf("%q: expected ErrInvalidPreferences, got %v", tt.email, err)
+		}
+	}
+}
+
+func TestDefaultPreferencesOnlyMailBareAddresses(t *testing.T) {
+	svc := NewNotificationService(repository.NewSQLiteNotificationRepository(newTestDB(t)), nil, nil)
+	tests := []struct {
+		user  string
+		email string
+	}{
+		{"sam@example.com", "sam@example.com"},
+		{"sam", ""},
+		{"Sam <sam@example.com>", ""},
+		{"sam@example.com, alex@example.com", ""},
+		{"sam@example.com\r\nBcc: alex@example.com", ""},
+	}
+	for _, tt := range tests {
+		preferences, err := svc.GetPreferences(context.Background(), tt.user)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.user, err)
+		}
+		if preferences.Email != tt.email {
+			t.Errorf("%q: email = %q, want %q", tt.user, preferences.Email, tt.email)
+		}
+	}
+}
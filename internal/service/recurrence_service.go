@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/recurrence"
+	"task-manager/internal/repository"
+)
+
+var (
+	ErrInvalidRecurrence = errors.New("invalid recurrence")
+)
+
+// maxRecurrenceAge is how far in the past a recurrence may start. Catching
+// up scans the occurrences since the start, and the rule scans at most
+// about 274 years of daily occurrences.
+const maxRecurrenceAge = 10 * 365 * 24 * time.Hour
+
+type RecurrenceService interface {
+	CreateRecurrence(ctx context.Context, input models.CreateRecurrenceInput) (*models.Recurrence, error)
+	GetRecurrence(ctx context.Context, recurrenceID uuid.UUID) (*models.Recurrence, error)
+	ListRecurrences(ctx context.Context) ([]*models.Recurrence, error)
+	StopRecurrence(ctx context.Context, recurrenceID uuid.UUID) error
+	// MaterialiseDue creates the next task of every active recurrence whose
+	// current occurrence is completed or whose next occurrence is due, and
+	// returns how many tasks were created. Recurrences that fail are skipped
+	// and their errors joined.
+	MaterialiseDue(ctx context.Context, now time.Time) (int, error)
+}
+
+type recurrenceService struct {
+	repo      repository.RecurrenceRepository
+	tasks     repository.TaskRepository
+	workflows repository.WorkflowRepository
+	tx        repository.Transactor
+	// inserter creates the tasks of occurrences like TaskService does, so
+	// that they publish the same events.
+	inserter *taskService
+}
+
+// NewRecurrenceService returns a RecurrenceService that creates the task of
+// an occurrence and publishes its events in the transaction that advances
+// the recurrence.
+func NewRecurrenceService(repo repository.RecurrenceRepository, tasks repository.TaskRepository, workflows repository.WorkflowRepository, publisher events.Publisher, tx repository.Transactor) RecurrenceService {
+	return &recurrenceService{
+		repo:      repo,
+		tasks:     tasks,
+		workflows: workflows,
+		tx:        tx,
+		inserter:  &taskService{repo: tasks, workflows: workflows, events: publisher, tx: tx},
+	}
+}
+
+func (s *recurrenceService) CreateRecurrence(ctx context.Context, input models.CreateRecurrenceInput) (*models.Recurrence, error) {
+	if len(input.Title) < 3 {
+		return nil, ErrTitleTooShort
+	}
+	rule, err := recurrence.Parse(input.Rule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+	if input.Timezone == "" {
+		input.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(input.Timezone); err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidRecurrence, input.Timezone)
+	}
+	if input.StartsAt.IsZero() {
+		input.StartsAt = time.Now().UTC()
+	}
+	if time.Since(input.StartsAt) > maxRecurrenceAge {
+		return nil, fmt.Errorf("%w: starts_at may be at most 10 years in the past", ErrInvalidRecurrence)
+	}
+
+	rec := &models.Recurrence{
+		ID:          uuid.New(),
+		Project:     normalizeProject(input.Project),
+		Title:       input.Title,
+		Description: input.Description,
+		Rule:        rule.String(),
+		Timezone:    input.Timezone,
+		StartsAt:    input.StartsAt.UTC(),
+		Active:      true,
+	}
+	// The first occurrence is created right away so the task is visible
+	// without waiting for the next scheduler run, and in the same
+	// transaction so that a recurrence without it isn't left behind.
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateRecurrence(ctx, rec); err != nil {
+			return err
+		}
+		_, err := s.materialiseNext(ctx, rec, time.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *recurrenceService) GetRecurrence(ctx context.Context, recurrenceID uuid.UUID) (*models.Recurrence, error) {
+	return s.repo.GetRecurrence(ctx, recurrenceID)
+}
+
+func (s *recurrenceService) ListRecurrences(ctx context.Context) ([]*models.Recurrence, error) {
+	return s.repo.ListRecurrences(ctx, false)
+}
+
+// StopRecurrence deactivates a recurrence. Tasks that were already
+// materialised are left untouched.
+func (s *recurrenceService) StopRecurrence(ctx context.Context, recurrenceID uuid.UUID) error {
+	return s.repo.DeactivateRecurrence(ctx, recurrenceID)
+}
+
+func (s *recurrenceService) MaterialiseDue(ctx context.Context, now time.Time) (int, error) {
+	recurrences, err := s.repo.ListRecurrences(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	created := 0
+	var failed []error
+	for _, rec := range recurrences {
+		if ctx.Err() != nil {
+			return created, ctx.Err()
+		}
+		// One broken recurrence mustn't hold up the others.
+		ok, err := s.materialiseNext(ctx, rec, now)
+		if err != nil {
+			failed = append(failed, fmt.Errorf("recurrence %s: %w", rec.ID, err))
+			continue
+		}
+		if ok {
+			created++
+		}
+	}
+	return created, errors.Join(failed...)
+}
+
+func (s *recurrenceService) materialiseNext(ctx context.Context, rec *models.Recurrence, now time.Time) (bool, error) {
+	rule, err := recurrence.Parse(rec.Rule)
+	if err != nil {
+		return false, err
+	}
+	location, err := time.LoadLocation(rec.Timezone)
+	if err != nil {
+		return false, err
+	}
+	start := rec.StartsAt.In(location)
+	after := start.Add(-time.Nanosecond)
+	if rec.LastOccurrenceAt != nil {
+		after = rec.LastOccurrenceAt.In(location)
+	}
+
+	// After downtime only the most recent missed occurrence is created, so
+	// a stopped server doesn't produce a backlog of stale chores.
+	next, index, ok := rule.Latest(start, after, now)
+	if !ok {
+		return false, s.repo.DeactivateRecurrence(ctx, rec.ID)
+	}
+	if rec.LastTaskID != nil && next.After(now) {
+		completed, err := s.isCompleted(ctx, *rec.LastTaskID)
+		if err != nil || !completed {
+			return false, err
+		}
+	}
+
+	workflow, err := loadWorkflow(ctx, s.workflows, rec.Project)
+	if err != nil {
+		return false, err
+	}
+	dueAt := next.UTC()
+	task := &models.Task{
+		ID:           uuid.New(),
+		Project:      rec.Project,
+		Title:        rec.Title,
+		Description:  rec.Description,
+		Status:       workflow.InitialStatus,
+		DueAt:        &dueAt,
+		RecurrenceID: &rec.ID,
+	}
+	// The recurrence is advanced first, so a concurrent run that loses the
+	// race creates no task. rec is only changed once the transaction
+	// commits.
+	advanced := *rec
+	created := false
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		ok, err := s.repo.AdvanceRecurrence(ctx, &advanced, index, task.DueAt, task.ID)
+		if err != nil || !ok {
+			return err
+		}
+		created = true
+		return s.inserter.insertTask(ctx, task)
+	})
+	if err != nil || !created {
+		return false, err
+	}
+	*rec = advanced
+	return true, nil
+}
+
+// isCompleted reports whether the task is in a done-category status of its
+// project's workflow. A deleted task counts as completed.
+func (s *recurrenceService) isCompleted(ctx context.Context, taskID uuid.UUID) (bool, error) {
+	task, err := s.tasks.GetTask(ctx, taskID)
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return isDone(ctx, s.workflows, task)
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+// createdEvents records the IDs of tasks with task.created events.
+type createdEvents struct {
+	mu  sync.Mutex
+	ids []uuid.UUID
+}
+
+func (c *createdEvents) handle(ctx context.Context, event events.Event) error {
+	if event.Type == events.TaskCreated {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.ids = append(c.ids, event.TaskID)
+	}
+	return nil
+}
+
+func (c *createdEvents) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.ids)
+}
+
+func TestMaterialiseDue(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	recurrences := repository.NewSQLiteRecurrenceRepository(db)
+	taskRepository := repository.NewSQLiteTaskRepository(db)
+	workflows := repository.NewSQLiteWorkflowRepository(db)
+	transactor := repository.NewSQLTransactor(db)
+	created := &createdEvents{}
+	bus := events.NewBus()
+	bus.Subscribe(created.handle)
+	newService := func() RecurrenceService {
+		return NewRecurrenceService(recurrences, taskRepository, workflows, bus, transactor)
+	}
+	svc := newService()
+	tasks := NewTaskService(taskRepository, workflows, bus, transactor)
+
+	// Daily at the start's time of day; the first occurrence is the most
+	// recent one that is due.
+	now := time.Now().UTC().Truncate(time.Second)
+	rec, err := svc.CreateRecurrence(ctx, models.CreateRecurrenceInput{
+		Project:  "home",
+		Title:    "Water plants",
+		Rule:     "FREQ=DAILY",
+		StartsAt: now.Add(-10*24*time.Hour - time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("create recurrence: %v", err)
+	}
+	rec, err = svc.GetRecurrence(ctx, rec.ID)
+	if err != nil || rec.Occurrences != 11 || rec.LastTaskID == nil {
+		t.Fatalf("recurrence after creation: %+v, %v", rec, err)
+	}
+	first := *rec.LastTaskID
+	if created.count() != 1 || created.ids[0] != first {
+		t.Fatalf("task.created events %v, want the first occurrence %s", created.ids, first)
+	}
+
+	// The next occurrence isn't due and the current one isn't completed.
+	if n, err := svc.MaterialiseDue(ctx, now); err != nil || n != 0 {
+		t.Fatalf("MaterialiseDue before completion: %d, %v", n, err)
+	}
+
+	// Completing the current occurrence creates the next one early.
+	done := models.TaskStatusDone
+	if _, err := tasks.UpdateTask(ctx, first, models.UpdateTaskInput{Status: &done}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if n, err := svc.MaterialiseDue(ctx, now); err != nil || n != 1 {
+		t.Fatalf("MaterialiseDue after completion: %d, %v", n, err)
+	}
+	rec, _ = svc.GetRecurrence(ctx, rec.ID)
+	if rec.Occurrences != 12 || rec.LastOccurrenceAt == nil || !rec.LastOccurrenceAt.After(now) {
+		t.Fatalf("recurrence after completion: %+v", rec)
+	}
+
+	// After three days of downtime only the latest missed occurrence is
+	// created, and runs after restarts don't repeat it.
+	later := now.Add(3 * 24 * time.Hour)
+	if n, err := svc.MaterialiseDue(ctx, later); err != nil || n != 1 {
+		t.Fatalf("MaterialiseDue after downtime: %d, %v", n, err)
+	}
+	for range 2 {
+		if n, err := newService().MaterialiseDue(ctx, later); err != nil || n != 0 {
+			t.Fatalf("MaterialiseDue after a restart: %d, %v", n, err)
+		}
+	}
+	rec, _ = svc.GetRecurrence(ctx, rec.ID)
+	if rec.Occurrences != 14 || rec.LastOccurrenceAt.After(later) || !rec.LastOccurrenceAt.After(later.Add(-24*time.Hour)) {
+		t.Fatalf("recurrence after downtime: %+v", rec)
+	}
+
+	list, err := tasks.ListTasks(ctx, repository.TaskFilter{Project: "home"})
+	if err != nil || len(list) != 3 {
+		t.Fatalf("tasks %v, %v", list, err)
+	}
+	if created.count() != 3 {
+		t.Errorf("%d task.created events for 3 occurrences", created.count())
+	}
+	for _, task := range list {
+		if task.RecurrenceID == nil || *task.RecurrenceID != rec.ID {
+			t.Errorf("task %+v isn't an occurrence of %s", task, rec.ID)
+		}
+	}
+}
+
+func TestMaterialiseDueSkipsBrokenRecurrences(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	recurrences := repository.NewSQLiteRecurrenceRepository(db)
+	taskRepository := repository.NewSQLiteTaskRepository(db)
+	svc := NewRecurrenceService(recurrences, taskRepository, repository.NewSQLiteWorkflowRepository(db), events.Discard, repository.NewSQLTransactor(db))
+
+	now := time.Now().UTC()
+	// The repository doesn't validate, so a recurrence can still end up
+	// with a timezone that this server doesn't know.
+	broken := &models.Recurrence{
+		ID:       uuid.New(),
+		Project:  "home",
+		Title:    "Broken",
+		Rule:     "FREQ=DAILY",
+		Timezone: "Nowhere/Nope",
+		StartsAt: now.Add(-time.Hour),
+		Active:   true,
+	}
+	if err := recurrences.CreateRecurrence(ctx, broken); err != nil {
+		t.Fatalf("create broken recurrence: %v", err)
+	}
+	working := &models.Recurrence{
+		ID:       uuid.New(),
+		Project:  "home",
+		Title:    "Water plants",
+		Rule:     "FREQ=DAILY",
+		Timezone: "UTC",
+		StartsAt: now.Add(-time.Hour),
+		Active:   true,
+	}
+	if err := recurrences.CreateRecurrence(ctx, working); err != nil {
+		t.Fatalf("create recurrence: %v", err)
+	}
+
+	n, err := svc.MaterialiseDue(ctx, now)
+	if err == nil || n != 1 {
+		t.Fatalf("MaterialiseDue: %d, %v; want 1 task and the broken recurrence's error", n, err)
+	}
+	rec, err := svc.GetRecurrence(ctx, working.ID)
+	if err != nil || rec.Occurrences != 1 {
+		t.Errorf("working recurrence %+v, %v", rec, err)
+	}
+}
+
+// failingWorkflowRepo fails to load workflows, which creating an
+// occurrence needs.
+type failingWorkflowRepo struct {
+	repository.WorkflowRepository
+}
+
+func (failingWorkflowRepo) GetWorkflow(ctx context.Context, project string) (*models.Workflow, error) {
+	return nil, errors.New("database is gone")
+}
+
+func TestCreateRecurrenceValidation(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	recurrences := repository.NewSQLiteRecurrenceRepository(db)
+	taskRepository := repository.NewSQLiteTaskRepository(db)
+	transactor := repository.NewSQLTransactor(db)
+	svc := NewRecurrenceService(recurrences, taskRepository, repository.NewSQLiteWorkflowRepository(db), events.Discard, transactor)
+
+	input := models.CreateRecurrenceInput{Title: "Water plants", Rule: "FREQ=DAILY"}
+	short := input
+	short.Title = "ab"
+	if _, err := svc.CreateRecurrence(ctx, short); !errors.Is(err, ErrTitleTooShort) {
+		t.Errorf("short title: %v", err)
+	}
+	// Catching up scans every occurrence since the start.
+	ancient := input
+	ancient.StartsAt = time.Now().AddDate(-11, 0, 0)
+	if _, err := svc.CreateRecurrence(ctx, ancient); !errors.Is(err, ErrInvalidRecurrence) {
+		t.Errorf("start 11 years ago: %v", err)
+	}
+	old := input
+	old.StartsAt = time.Now().AddDate(-9, 0, 0)
+	if _, err := svc.CreateRecurrence(ctx, old); err != nil {
+		t.Errorf("start 9 years ago: %v", err)
+	}
+
+	// A recurrence whose first occurrence can't be created isn't stored.
+	failing := NewRecurrenceService(recurrences, taskRepository, failingWorkflowRepo{}, events.Discard, transactor)
+	if _, err := failing.CreateRecurrence(ctx, input); err == nil {
+		t.Fatal("create without workflows: no error")
+	}
+	if list, err := svc.ListRecurrences(ctx); err != nil || len(list) != 1 {
+		t.Errorf("recurrences %+v, %v; want only the one from 9 years ago", list, err)
+	}
+}
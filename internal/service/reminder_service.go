@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/models"
+	"task-manager/internal/notify"
+	"task-manager/internal/repository"
+	"task-manager/internal/scheduler"
+)
+
+const (
+	JobKindReminder = "reminder"
+
+	// reminderHorizon is how far ahead of their run time reminder jobs are
+	// planned. Planning runs far more often than this, so no reminder is
+	// missed, and the unique job key keeps replanning idempotent.
+	reminderHorizon = time.Hour
+	// overdueGrace bounds how far in the past a due date may be for its
+	// overdue reminder to still be planned, e.g. after downtime.
+	overdueGrace = 24 * time.Hour
+)
+
+type reminderPayload struct {
+	TaskID   uuid.UUID   `json:"task_id"`
+	Type     notify.Type `json:"type"`
+	DueAt    time.Time   `json:"due_at"`
+	Notifier string      `json:"notifier"`
+}
+
+type ReminderService interface {
+	// PlanReminders enqueues a due-soon and an overdue reminder job per
+	// notifier for every open task whose reminders fall due soon.
+	PlanReminders(ctx context.Context, now time.Time) (int, error)
+	// HandleReminder is the scheduler handler for reminder jobs.
+	HandleReminder(ctx context.Context, job *models.Job) error
+}
+
+type reminderService struct {
+	tasks     repository.TaskRepository
+	workflows repository.WorkflowRepository
+	jobs      scheduler.Enqueuer
+	notifiers map[string]notify.Notifier
+	leadTime  time.Duration
+}
+
+func NewReminderService(
+	tasks repository.TaskRepository,
+	workflows repository.WorkflowRepository,
+	jobs scheduler.Enqueuer,
+	notifiers map[string]notify.Notifier,
+	leadTime time.Duration,
+) ReminderService {
+	return &reminderService{
+		tasks:     tasks,
+		workflows: workflows,
+		jobs:      jobs,
+		notifiers: notifiers,
+		leadTime:  leadTime,
+	}
+}
+
+func (s *reminderService) PlanReminders(ctx context.Context, now time.Time) (int, error) {
+	dueAfter := now.Add(-overdueGrace)
+	dueBefore := now.Add(s.leadTime + reminderHorizon)
+	tasks, err := s.tasks.ListTasks(ctx, repository.TaskFilter{
+		DueAfter:  &dueAfter,
+		DueBefore: &dueBefore,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	names := make([]string, 0, len(s.notifiers))
+	for name := range s.notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	planned := 0
+	for _, task := range tasks {
+		done, err := isDone(ctx, s.workflows, task)
+		if err != nil {
+			return planned, err
+		}
+		if done {
+			continue
+		}
+		dueAt := task.DueAt.UTC()
+		reminders := []struct {
+			kind  notify.Type
+			runAt time.Time
+		}{
+			{notify.TypeDueSoon, dueAt.Add(-s.leadTime)},
+			{notify.TypeOverdue, dueAt},
+		}
+		for _, reminder := range reminders {
+			if reminder.kind == notify.TypeDueSoon && !now.Before(dueAt) {
+				continue
+			}
+			for _, name := range names {
+				created, err := s.jobs.Enqueue(ctx, scheduler.JobRequest{
+					Kind:  JobKindReminder,
+					Key:   fmt.Sprintf("reminder:%s:%s:%s:%d", name, reminder.kind, task.ID, dueAt.UnixMilli()),
+					RunAt: reminder.runAt,
+					Payload: reminderPayload{
+						TaskID:   task.ID,
+						Type:     reminder.kind,
+						DueAt:    dueAt,
+						Notifier: name,
+					},
+				})
+				if err != nil {
+					return planned, err
+				}
+				if created {
+					planned++
+				}
+			}
+		}
+	}
+	return planned, nil
+}
+
+func (s *reminderService) HandleReminder(ctx context.Context, job *models.Job) error {
+	var payload reminderPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("%w: decode payload: %v", scheduler.ErrPermanent, err)
+	}
+	notifier, ok := s.notifiers[payload.Notifier]
+	if !ok {
+		return fmt.Errorf("%w: unknown notifier %q", scheduler.ErrPermanent, payload.Notifier)
+	}
+
+	// The task may have changed since the job was planned; reminders for a
+	// deleted, completed or rescheduled task are dropped.
+	task, err := s.tasks.GetTask(ctx, payload.TaskID)
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if task.DueAt == nil || !task.DueAt.Equal(payload.DueAt) {
+		return nil
+	}
+	done, err := isDone(ctx, s.workflows, task)
+	if err != nil || done {
+		return err
+	}
+	return notifier.Notify(ctx, notify.Notification{Type: payload.Type, Task: task})
+}
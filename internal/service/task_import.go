@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/events"
+	"task-manager/internal/importer"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+type ImportService interface {
+	// ImportTasks creates the tasks of rows, or updates them if their
+	// external ID is taken, and adds their new comments, in a single
+	// transaction. Nothing is written if any row is invalid or dryRun is
+	// set; the report lists the problems.
+	ImportTasks(ctx context.Context, rows []importer.Row, dryRun bool) (*models.ImportReport, error)
+}
+
+type importService struct {
+	tasks    *taskService
+	comments repository.CommentRepository
+}
+
+// NewImportService returns an ImportService that creates and updates tasks
+// like the TaskService with the same arguments, and publishes the same
+// events.
+func NewImportService(tasks repository.TaskRepository, comments repository.CommentRepository, workflows repository.WorkflowRepository, publisher events.Publisher, tx repository.Transactor) ImportService {
+	return &importService{
+		tasks:    &taskService{repo: tasks, workflows: workflows, events: publisher, tx: tx},
+		comments: comments,
+	}
+}
+
+// importedTask is a valid row of an import and what it does: it creates
+// task, or updates it if previous is set, and adds comments.
+type importedTask struct {
+	task     *models.Task
+	previous *models.Task
+	comments []*models.Comment
+}
+
+// ImportTasks validates every row before it writes anything, so that an
+// import with errors leaves no trace even where the task repository
+// doesn't take part in transactions.
+func (s *importService) ImportTasks(ctx context.Context, rows []importer.Row, dryRun bool) (*models.ImportReport, error) {
+	report := &models.ImportReport{DryRun: dryRun, Rows: len(rows), Errors: []models.ImportError{}}
+	err := s.tasks.tx.WithinTx(ctx, func(ctx context.Context) error {
+		imported := make([]importedTask, 0, len(rows))
+		lines := make(map[string]int)
+		for _, row := range rows {
+			reject := func(err error) {
+				report.Errors = append(report.Errors, models.ImportError{
+					Line:       row.Line,
+					ExternalID: row.Task.ExternalID,
+					Message:    err.Error(),
+				})
+			}
+			if row.Err != nil {
+				reject(row.Err)
+				continue
+			}
+			if externalID := row.Task.ExternalID; externalID != "" {
+				if line, ok := lines[externalID]; ok {
+					reject(fmt.Errorf("external ID %q is on line %d already", externalID, line))
+					continue
+				}
+				lines[externalID] = row.Line
+			}
+			task, err := s.importTask(ctx, row.Task)
+			if isImportError(err) {
+				reject(err)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("line %d: %w", row.Line, err)
+			}
+			if task.previous == nil {
+				report.Created++
+			} else {
+				report.Updated++
+			}
+			report.Comments += len(task.comments)
+			imported = append(imported, task)
+		}
+		if dryRun || len(report.Errors) > 0 {
+			return nil
+		}
+
+		for _, task := range imported {
+			var err error
+			if task.previous == nil {
+				err = s.tasks.insertTask(ctx, task.task)
+			} else {
+				err = s.tasks.saveTask(ctx, task.task, task.previous)
+			}
+			if err == nil {
+				err = s.addComments(ctx, task.task, task.comments)
+			}
+			if err != nil {
+				return fmt.Errorf("import %q: %w", task.task.Title, err)
+			}
+		}
+		report.Imported = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// importTask validates a row of an import and returns what it does. A row
+// is validated like the input of CreateTask; its status, if it has one,
+// only needs to be defined by the project's workflow, as an import sets it
+// rather than moving the task there.
+func (s *importService) importTask(ctx context.Context, input models.ImportTaskInput) (importedTask, error) {
+	var existing *models.Task
+	if input.ExternalID != "" {
+		var err error
+		existing, err = s.tasks.repo.GetTaskByExternalID(ctx, input.ExternalID)
+		if errors.Is(err, repository.ErrTaskNotFound) {
+			existing = nil
+		} else if err != nil {
+			return importedTask{}, err
+		}
+	}
+
+	var imported importedTask
+	if existing == nil {
+		task, err := s.tasks.newTask(ctx, models.CreateTaskInput{
+			ExternalID:  input.ExternalID,
+			Project:     input.Project,
+			Title:       input.Title,
+			Description: valueOf(input.Description),
+			Assignee:    valueOf(input.Assignee),
+			DueAt:       input.DueAt,
+		})
+		if err != nil {
+			return importedTask{}, err
+		}
+		imported.task = task
+	} else {
+		if len(input.Title) < 3 {
+			return importedTask{}, ErrTitleTooShort
+		}
+		if input.Project != "" && normalizeProject(input.Project) != existing.Project {
+			return importedTask{}, fmt.Errorf("%w: the task with external ID %q is in project %q", errImportMovesTask, input.ExternalID, existing.Project)
+		}
+		previous := *existing
+		existing.Title = input.Title
+		if input.Description != nil {
+			existing.Description = *input.Description
+		}
+		if input.Assignee != nil {
+			existing.Assignee = strings.TrimSpace(*input.Assignee)
+		}
+		if input.DueAt != nil || input.ClearDueAt {
+			existing.DueAt = input.DueAt
+		}
+		imported = importedTask{task: existing, previous: &previous}
+	}
+
+	if input.Status != "" {
+		workflow, err := loadWorkflow(ctx, s.tasks.workflows, imported.task.Project)
+		if err != nil {
+			return importedTask{}, err
+		}
+		if _, ok := workflow.Status(input.Status); !ok {
+			return importedTask{}, fmt.Errorf("%w: %q is not defined in project %q", ErrInvalidStatus, input.Status, workflow.Project)
+		}
+		imported.task.Status = input.Status
+	}
+
+	comments, err := s.newComments(ctx, existing, input.Comments)
+	if err != nil {
+		return importedTask{}, err
+	}
+	imported.comments = comments
+	return imported, nil
+}
+
+// newComments returns the comments of an import that existing, which is nil
+// for a new task, doesn't have yet. Comments are told apart by their author
+// and body, as imports don't know the IDs of comments.
+func (s *importService) newComments(ctx context.Context, existing *models.Task, inputs []models.ImportCommentInput) ([]*models.Comment, error) {
+	type key struct{ author, body string }
+	seen := make(map[key]bool)
+	if existing != nil && len(inputs) > 0 {
+		current, err := s.comments.ListComments(ctx, existing.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range current {
+			seen[key{comment.Author, comment.Body}] = true
+		}
+	}
+	var comments []*models.Comment
+	for _, input := range inputs {
+		comment := &models.Comment{
+			ID:     uuid.New(),
+			Author: strings.TrimSpace(input.Author),
+			Body:   strings.TrimSpace(input.Body),
+		}
+		if comment.Body == "" || seen[key{comment.Author, comment.Body}] {
+			continue
+		}
+		comments = append(comments, comment)
+	}
+	return comments, nil
+}
+
+// addComments stores the comments of a task and publishes their events,
+// like CommentService.CreateComment.
+func (s *importService) addComments(ctx context.Context, task *models.Task, comments []*models.Comment) error {
+	for _, comment := range comments {
+		comment.TaskID = task.ID
+		if err := s.comments.CreateComment(ctx, comment); err != nil {
+			return err
+		}
+		err := s.tasks.events.Publish(ctx, events.Event{
+			Type:       events.CommentCreated,
+			TaskID:     task.ID,
+			Project:    task.Project,
+			Task:       task,
+			Comment:    comment,
+			OccurredAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errImportMovesTask = errors.New("imports can't move tasks to another project")
+
+// isImportError reports whether err is a problem with a row rather than a
+// failure of the import.
+func isImportError(err error) bool {
+	return errors.Is(err, ErrTitleTooShort) ||
+		errors.Is(err, ErrExternalIDTaken) ||
+		errors.Is(err, ErrInvalidStatus) ||
+		errors.Is(err, errImportMovesTask)
+}
+
+// valueOf returns what s points to, or "" for a field the import doesn't
+// have.
+func valueOf(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
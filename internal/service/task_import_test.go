@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/events"
+	"task-manager/internal/importer"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+type inMemoryCommentRepo struct {
+	comments []*models.Comment
+}
+
+func (r *inMemoryCommentRepo) CreateComment(ctx context.Context, comment *models.Comment) error {
+	r.comments = append(r.comments, comment)
+	return nil
+}
+
+func (r *inMemoryCommentRepo) ListComments(ctx context.Context, taskID uuid.UUID) ([]*models.Comment, error) {
+	var comments []*models.Comment
+	for _, comment := range r.comments {
+		if comment.TaskID == taskID {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+func (r *inMemoryCommentRepo) ListCommentsForTasks(ctx context.Context, taskIDs []uuid.UUID) (map[uuid.UUID][]*models.Comment, error) {
+	byTask := make(map[uuid.UUID][]*models.Comment)
+	for _, taskID := range taskIDs {
+		byTask[taskID], _ = r.ListComments(ctx, taskID)
+	}
+	return byTask, nil
+}
+
+func readImport(t *testing.T, csv string) []importer.Row {
+	t.Helper()
+	rows, err := importer.ReadCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return rows
+}
+
+func TestImportTasks(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryTaskRepository()
+	var published []events.Type
+	bus := events.NewBus()
+	bus.Subscribe(func(ctx context.Context, event events.Event) error {
+		published = append(published, event.Type)
+		return nil
+	})
+	workflows := newInMemoryWorkflowRepo()
+	service := NewImportService(repo, &inMemoryCommentRepo{}, workflows, bus, repository.NoTx)
+	existing, err := NewTaskService(repo, workflows, bus, repository.NoTx).CreateTask(ctx, models.CreateTaskInput{ExternalID: "S-1", Title: "Pay rent"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	published = nil
+
+	rows := readImport(t, `external_id,title,status,assignee,due_at
+S-1,Pay rent twice,done,ann,2026-03-01
+S-2,Buy milk,,,
+,Call mum,in_progress,,2026-03-02T18:00:00+01:00
+`)
+	report, err := service.ImportTasks(ctx, rows, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if report.Imported || report.Created != 2 || report.Updated != 1 || len(report.Errors) != 0 {
+		t.Fatalf("dry run report %+v", report)
+	}
+	if tasks, _ := repo.ListTasks(ctx, repository.TaskFilter{}); len(tasks) != 1 || len(published) != 0 {
+		t.Fatalf("dry run wrote %d tasks and published %v", len(tasks), published)
+	}
+
+	report, err = service.ImportTasks(ctx, rows, false)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !report.Imported || report.Created != 2 || report.Updated != 1 {
+		t.Fatalf("report %+v", report)
+	}
+	updated, err := repo.GetTask(ctx, existing.ID)
+	if err != nil || updated.Title != "Pay rent twice" || updated.Status != models.TaskStatusDone || updated.Assignee != "ann" || updated.DueAt == nil {
+		t.Fatalf("updated task %+v, %v", updated, err)
+	}
+	created, err := repo.GetTaskByExternalID(ctx, "S-2")
+	if err != nil || created.Status != models.TaskStatusNew || created.Project != models.DefaultProject {
+		t.Fatalf("created task %+v, %v", created, err)
+	}
+	want := []events.Type{events.TaskUpdated, events.TaskAssigned, events.TaskCreated, events.TaskCreated}
+	if strings.Join(eventTypes(published), ",") != strings.Join(eventTypes(want), ",") {
+		t.Fatalf("published %v, want %v", published, want)
+	}
+}
+
+func TestImportTasksKeepsMissingColumns(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryTaskRepository()
+	workflows := newInMemoryWorkflowRepo()
+	bus := events.NewBus()
+	service := NewImportService(repo, &inMemoryCommentRepo{}, workflows, bus, repository.NoTx)
+	if _, err := service.ImportTasks(ctx, readImport(t, `external_id,title,description,assignee,due_at
+S-1,Pay rent,By the 3rd,ann,2026-03-01
+`), false); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	// A file without the description, assignee and due_at columns leaves
+	// them as they are.
+	if _, err := service.ImportTasks(ctx, readImport(t, "external_id,title\nS-1,Pay the rent\n"), false); err != nil {
+		t.Fatalf("import titles: %v", err)
+	}
+	task, err := repo.GetTaskByExternalID(ctx, "S-1")
+	if err != nil || task.Title != "Pay the rent" || task.Description != "By the 3rd" || task.Assignee != "ann" || task.DueAt == nil {
+		t.Fatalf("task after importing titles %+v, %v", task, err)
+	}
+
+	// Empty columns clear them.
+	if _, err := service.ImportTasks(ctx, readImport(t, "external_id,title,description,assignee,due_at\nS-1,Pay the rent,,,\n"), false); err != nil {
+		t.Fatalf("import empty columns: %v", err)
+	}
+	task, err = repo.GetTaskByExternalID(ctx, "S-1")
+	if err != nil || task.Description != "" || task.Assignee != "" || task.DueAt != nil {
+		t.Fatalf("task after importing empty columns %+v, %v", task, err)
+	}
+}
+
+func eventTypes(types []events.Type) []string {
+	names := make([]string, len(types))
+	for i, typ := range types {
+		names[i] = string(typ)
+	}
+	return names
+}
+
+func TestImportTasksReportsRowErrors(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryTaskRepository()
+	workflows := newInMemoryWorkflowRepo()
+	tasks := NewTaskService(repo, workflows, events.Discard, repository.NoTx)
+	service := NewImportService(repo, &inMemoryCommentRepo{}, workflows, events.Discard, repository.NoTx)
+	if _, err := tasks.CreateTask(ctx, models.CreateTaskInput{ExternalID: "S-1", Project: "home", Title: "Pay rent"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	report, err := service.ImportTasks(ctx, readImport(t, `external_id,project,title,status,due_at
+S-2,,Buy milk,,
+S-3,,ab,,
+S-2,,Buy milk again,,
+S-4,,Water plants,archived,
+S-5,,Call mum,,tomorrow
+S-1,work,Pay rent,,
+S-6,too,many,fields,,
+`), false)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if report.Imported || report.Rows != 7 || report.Created != 1 {
+		t.Fatalf("report %+v", report)
+	}
+	var lines []int
+	for _, rowErr := range report.Errors {
+		lines = append(lines, rowErr.Line)
+	}
+	if len(lines) != 6 || lines[0] != 3 || lines[5] != 8 {
+		t.Fatalf("errors on lines %v: %+v", lines, report.Errors)
+	}
+	if tasks, _ := repo.ListTasks(ctx, repository.TaskFilter{}); len(tasks) != 1 {
+		t.Fatalf("an import with errors wrote %d tasks", len(tasks)-1)
+	}
+
+	_, err = tasks.CreateTask(ctx, models.CreateTaskInput{ExternalID: " S-1 ", Title: "Pay rent"})
+	if !errors.Is(err, ErrExternalIDTaken) {
+		t.Fatalf("create with a taken external ID: %v, want ErrExternalIDTaken", err)
+	}
+}
+
+func TestImportTasksAddsNewComments(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryTaskRepository()
+	comments := &inMemoryCommentRepo{}
+	var published []events.Type
+	bus := events.NewBus()
+	bus.Subscribe(func(ctx context.Context, event events.Event) error {
+		published = append(published, event.Type)
+		return nil
+	})
+	service := NewImportService(repo, comments, newInMemoryWorkflowRepo(), bus, repository.NoTx)
+	rows := []importer.Row{{Line: 1, Task: models.ImportTaskInput{
+		ExternalID: "trello:1",
+		Title:      "Pay rent",
+		Comments:   []models.ImportCommentInput{{Author: "ann", Body: "Rent went up"}, {Author: "sam", Body: " "}},
+	}}}
+
+	report, err := service.ImportTasks(ctx, rows, false)
+	if err != nil || report.Created != 1 || report.Comments != 1 {
+		t.Fatalf("import: report %+v, %v", report, err)
+	}
+	want := []events.Type{events.TaskCreated, events.CommentCreated}
+	if strings.Join(eventTypes(published), ",") != strings.Join(eventTypes(want), ",") {
+		t.Fatalf("published %v, want %v", published, want)
+	}
+
+	// Importing again only adds the comments that are new.
+	rows[0].Task.Comments = append(rows[0].Task.Comments, models.ImportCommentInput{Author: "sam", Body: "Paid"})
+	report, err = service.ImportTasks(ctx, rows, false)
+	if err != nil || report.Updated != 1 || report.Comments != 1 {
+		t.Fatalf("import again: report %+v, %v", report, err)
+	}
+	task, err := repo.GetTaskByExternalID(ctx, "trello:1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	listed, _ := comments.ListComments(ctx, task.ID)
+	if len(listed) != 2 || listed[0].Body != "Rent went up" || listed[1].Author != "sam" {
+		t.Fatalf("comments %+v", listed)
+	}
+}
@@ -2,31 +2,50 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 
+	"task-manager/internal/events"
 	"task-manager/internal/models"
 	"task-manager/internal/repository"
 )
 
 const DefaultLimit = 50
 
+var (
+	ErrTitleTooShort = errors.New("title must be at least 3 characters")
+	// ErrExternalIDTaken is returned when a new task has the external ID
+	// of another task.
+	ErrExternalIDTaken = errors.New("external ID is taken")
+)
+
 type TaskService interface {
 	CreateTask(ctx context.Context, input models.CreateTaskInput) (*models.Task, error)
 	GetTask(ctx context.Context, taskID uuid.UUID) (*models.Task, error)
-	ListTasks(ctx context.Context, status *models.TaskStatus, limit, offset int) ([]*models.Task, error)
+	ListTasks(ctx context.Context, filter repository.TaskFilter) ([]*models.Task, error)
+	// EachTask streams the tasks matching filter to fn, all of them unless
+	// filter has a limit.
+	EachTask(ctx context.Context, filter repository.TaskFilter, fn func(*models.Task) error) error
 	UpdateTask(ctx context.Context, taskID uuid.UUID, input models.UpdateTaskInput) (*models.Task, error)
 	DeleteTask(ctx context.Context, taskID uuid.UUID) error
 	Ping(ctx context.Context) error
 }
 
 type taskService struct {
-	repo repository.TaskRepository
+	repo      repository.TaskRepository
+	workflows repository.WorkflowRepository
+	events    events.Publisher
+	tx        repository.Transactor
 }
 
-func NewTaskService(repo repository.TaskRepository) TaskService {
-	return &taskService{repo: repo}
+// NewTaskService returns a TaskService that publishes events in the same
+// transaction as the change they describe, so that the publisher can write
+// them to the outbox.
+func NewTaskService(repo repository.TaskRepository, workflows repository.WorkflowRepository, publisher events.Publisher, tx repository.Transactor) TaskService {
+	return &taskService{repo: repo, workflows: workflows, events: publisher, tx: tx}
 }
 
 func (s *taskService) Ping(ctx context.Context) error {
@@ -34,65 +53,193 @@ func (s *taskService) Ping(ctx context.Context) error {
 }
 
 func (s *taskService) CreateTask(ctx context.Context, input models.CreateTaskInput) (*models.Task, error) {
+	var task *models.Task
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if task, err = s.newTask(ctx, input); err != nil {
+			return err
+		}
+		return s.insertTask(ctx, task)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// newTask validates input and returns the task it describes.
+func (s *taskService) newTask(ctx context.Context, input models.CreateTaskInput) (*models.Task, error) {
 	if len(input.Title) < 3 {
-		return nil, fmt.Errorf("title must be at least 3 characters")
+		return nil, ErrTitleTooShort
+	}
+	externalID := strings.TrimSpace(input.ExternalID)
+	if externalID != "" {
+		_, err := s.repo.GetTaskByExternalID(ctx, externalID)
+		if err == nil {
+			return nil, fmt.Errorf("%w: %q", ErrExternalIDTaken, externalID)
+		}
+		if !errors.Is(err, repository.ErrTaskNotFound) {
+			return nil, err
+		}
+	}
+	workflow, err := loadWorkflow(ctx, s.workflows, input.Project)
+	if err != nil {
+		return nil, err
 	}
-	task := &models.Task{
+	return &models.Task{
 		ID:          uuid.New(),
+		ExternalID:  externalID,
+		Project:     workflow.Project,
 		Title:       input.Title,
 		Description: input.Description,
-		Status:      models.TaskStatusNew,
-	}
+		Status:      workflow.InitialStatus,
+		Assignee:    strings.TrimSpace(input.Assignee),
+		DueAt:       input.DueAt,
+	}, nil
+}
+
+// insertTask stores a new task and publishes its events.
+func (s *taskService) insertTask(ctx context.Context, task *models.Task) error {
 	if err := s.repo.CreateTask(ctx, task); err != nil {
-		return nil, err
+		return err
 	}
-	return task, nil
+	if err := s.events.Publish(ctx, events.NewTaskEvent(events.TaskCreated, task, nil)); err != nil {
+		return err
+	}
+	if task.Assignee != "" {
+		return s.events.Publish(ctx, events.NewTaskEvent(events.TaskAssigned, task, nil))
+	}
+	return nil
 }
 
 func (s *taskService) GetTask(ctx context.Context, taskID uuid.UUID) (*models.Task, error) {
 	return s.repo.GetTask(ctx, taskID)
 }
 
-func (s *taskService) ListTasks(ctx context.Context, status *models.TaskStatus, limit, offset int) ([]*models.Task, error) {
-	if limit <= 0 {
-		limit = DefaultLimit
+func (s *taskService) ListTasks(ctx context.Context, filter repository.TaskFilter) ([]*models.Task, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultLimit
 	}
-	filter := repository.TaskFilter{
-		Status: status,
-		Limit:  limit,
-		Offset: offset,
+	if err := s.validateFilter(ctx, filter); err != nil {
+		return nil, err
 	}
 	return s.repo.ListTasks(ctx, filter)
 }
 
+func (s *taskService) EachTask(ctx context.Context, filter repository.TaskFilter, fn func(*models.Task) error) error {
+	if err := s.validateFilter(ctx, filter); err != nil {
+		return err
+	}
+	return s.repo.EachTask(ctx, filter, fn)
+}
+
+// validateFilter checks a status filter against the project's workflow or,
+// across projects, against all workflows.
+func (s *taskService) validateFilter(ctx context.Context, filter repository.TaskFilter) error {
+	if filter.Status == nil {
+		return nil
+	}
+	if filter.Project != "" {
+		workflow, err := loadWorkflow(ctx, s.workflows, filter.Project)
+		if err != nil {
+			return err
+		}
+		if _, ok := workflow.Status(*filter.Status); !ok {
+			return fmt.Errorf("%w: %q is not defined in project %q", ErrInvalidStatus, *filter.Status, workflow.Project)
+		}
+		return nil
+	}
+	if _, ok := models.DefaultWorkflow(models.DefaultProject).Status(*filter.Status); ok {
+		return nil
+	}
+	defined, err := s.workflows.HasStatus(ctx, *filter.Status)
+	if err != nil {
+		return err
+	}
+	if !defined {
+		return fmt.Errorf("%w: %q is not defined in any workflow", ErrInvalidStatus, *filter.Status)
+	}
+	return nil
+}
+
 func (s *taskService) UpdateTask(ctx context.Context, taskID uuid.UUID, input models.UpdateTaskInput) (*models.Task, error) {
+	var task *models.Task
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		task, err = s.updateTask(ctx, taskID, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// updateTask applies the update and publishes its events. Reading the task
+// in the same transaction keeps concurrent updates from being lost.
+func (s *taskService) updateTask(ctx context.Context, taskID uuid.UUID, input models.UpdateTaskInput) (*models.Task, error) {
 	task, err := s.repo.GetTask(ctx, taskID)
 	if err != nil {
 		return nil, err
 	}
+	previous := *task
 	if input.Title != nil {
 		if len(*input.Title) < 3 {
-			return nil, fmt.Errorf("title must be at least 3 characters")
+			return nil, ErrTitleTooShort
 		}
 		task.Title = *input.Title
 	}
 	if input.Description != nil {
 		task.Description = *input.Description
 	}
+	if input.Assignee != nil {
+		task.Assignee = strings.TrimSpace(*input.Assignee)
+	}
+	if input.DueAt != nil || input.ClearDueAt {
+		task.DueAt = input.DueAt
+	}
 	if input.Status != nil {
-		switch *input.Status {
-		case models.TaskStatusNew, models.TaskStatusInProgress, models.TaskStatusDone:
-			task.Status = *input.Status
-		default:
-			return nil, fmt.Errorf("invalid status")
+		workflow, err := loadWorkflow(ctx, s.workflows, task.Project)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := workflow.Status(*input.Status); !ok {
+			return nil, fmt.Errorf("%w: %q is not defined in project %q", ErrInvalidStatus, *input.Status, workflow.Project)
+		}
+		if !workflow.CanTransition(task.Status, *input.Status) {
+			return nil, fmt.Errorf("%w: %q -> %q", ErrInvalidTransition, task.Status, *input.Status)
 		}
+		task.Status = *input.Status
 	}
-	if err := s.repo.UpdateTask(ctx, task); err != nil {
+	if err := s.saveTask(ctx, task, &previous); err != nil {
 		return nil, err
 	}
 	return task, nil
 }
 
+// saveTask stores the changes to task and publishes their events.
+func (s *taskService) saveTask(ctx context.Context, task, previous *models.Task) error {
+	if err := s.repo.UpdateTask(ctx, task); err != nil {
+		return err
+	}
+	if err := s.events.Publish(ctx, events.NewTaskEvent(events.TaskUpdated, task, previous)); err != nil {
+		return err
+	}
+	if task.Assignee != "" && task.Assignee != previous.Assignee {
+		return s.events.Publish(ctx, events.NewTaskEvent(events.TaskAssigned, task, previous))
+	}
+	return nil
+}
+
 func (s *taskService) DeleteTask(ctx context.Context, taskID uuid.UUID) error {
-	return s.repo.DeleteTask(ctx, taskID)
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		task, err := s.repo.GetTask(ctx, taskID)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.DeleteTask(ctx, taskID); err != nil {
+			return err
+		}
+		return s.events.Publish(ctx, events.NewTaskEvent(events.TaskDeleted, task, nil))
+	})
 }
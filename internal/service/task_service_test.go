@@ -2,65 +2,55 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 
-	"github.com/google/uuid"
-
+	"task-manager/internal/events"
 	"task-manager/internal/models"
 	"task-manager/internal/repository"
 )
 
-type inMemoryRepo struct {
-	store map[uuid.UUID]*models.Task
-}
-
-func newInMemoryRepo() *inMemoryRepo {
-	return &inMemoryRepo{store: make(map[uuid.UUID]*models.Task)}
-}
-
-func (r *inMemoryRepo) Ping(ctx context.Context) error {
-	return nil
+type inMemoryWorkflowRepo struct {
+	store map[string]*models.Workflow
 }
 
-func (r *inMemoryRepo) CreateTask(ctx context.Context, task *models.Task) error {
-	r.store[task.ID] = task
-	return nil
+func newInMemoryWorkflowRepo() *inMemoryWorkflowRepo {
+	return &inMemoryWorkflowRepo{store: make(map[string]*models.Workflow)}
 }
 
-func (r *inMemoryRepo) GetTask(ctx context.Context, taskID uuid.UUID) (*models.Task, error) {
-	if task, ok := r.store[taskID]; ok {
-		return task, nil
+func (r *inMemoryWorkflowRepo) GetWorkflow(ctx context.Context, project string) (*models.Workflow, error) {
+	if workflow, ok := r.store[project]; ok {
+		return workflow, nil
 	}
-	return nil, repository.ErrTaskNotFound
+	return nil, repository.ErrWorkflowNotFound
 }
 
-func (r *inMemoryRepo) ListTasks(ctx context.Context, filter repository.TaskFilter) ([]*models.Task, error) {
-	var tasks []*models.Task
-	for _, task := range r.store {
-		tasks = append(tasks, task)
-	}
-	return tasks, nil
+func (r *inMemoryWorkflowRepo) SaveWorkflow(ctx context.Context, workflow *models.Workflow) error {
+	r.store[workflow.Project] = workflow
+	return nil
 }
 
-func (r *inMemoryRepo) UpdateTask(ctx context.Context, task *models.Task) error {
-	if _, ok := r.store[task.ID]; !ok {
-		return repository.ErrTaskNotFound
+func (r *inMemoryWorkflowRepo) DeleteWorkflow(ctx context.Context, project string) error {
+	if _, ok := r.store[project]; !ok {
+		return repository.ErrWorkflowNotFound
 	}
-	r.store[task.ID] = task
+	delete(r.store, project)
 	return nil
 }
 
-func (r *inMemoryRepo) DeleteTask(ctx context.Context, taskID uuid.UUID) error {
-	if _, ok := r.store[taskID]; !ok {
-		return repository.ErrTaskNotFound
+func (r *inMemoryWorkflowRepo) HasStatus(ctx context.Context, status models.TaskStatus) (bool, error) {
+	for _, workflow := range r.store {
+		if _, ok := workflow.Status(status); ok {
+			return true, nil
+		}
 	}
-	delete(r.store, taskID)
-	return nil
+	return false, nil
 }
 
 func TestCreateTaskValidation(t *testing.T) {
-	repository := newInMemoryRepo()
-	service := NewTaskService(repository)
+	repo := repository.NewInMemoryTaskRepository()
+	service := NewTaskService(repo, newInMemoryWorkflowRepo(), events.Discard, repository.NoTx)
 
 	_, err := service.CreateTask(context.Background(), models.CreateTaskInput{
 		Title:       "ab",
@@ -72,8 +62,8 @@ func TestCreateTaskValidation(t *testing.T) {
 }
 
 func TestUpdateTaskStatusValidation(t *testing.T) {
-	repository := newInMemoryRepo()
-	service := NewTaskService(repository)
+	repo := repository.NewInMemoryTaskRepository()
+	service := NewTaskService(repo, newInMemoryWorkflowRepo(), events.Discard, repository.NoTx)
 
 	createdTask, err := service.CreateTask(context.Background(), models.CreateTaskInput{
 		Title:       "valid title",
@@ -87,7 +77,156 @@ func TestUpdateTaskStatusValidation(t *testing.T) {
 	_, err = service.UpdateTask(context.Background(), createdTask.ID, models.UpdateTaskInput{
 		Status: &badStatus,
 	})
-	if err == nil {
-		t.Fatal("expected error for invalid status")
+	if !errors.Is(err, ErrInvalidStatus) {
+		t.Fatalf("expected ErrInvalidStatus, got %v", err)
+	}
+}
+
+func TestUpdateTaskStatusTransition(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	service := NewTaskService(repo, newInMemoryWorkflowRepo(), events.Discard, repository.NoTx)
+
+	createdTask, err := service.CreateTask(context.Background(), models.CreateTaskInput{
+		Title: "valid title",
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if createdTask.Project != models.DefaultProject || createdTask.Status != models.TaskStatusNew {
+		t.Fatalf("unexpected defaults: project=%q status=%q", createdTask.Project, createdTask.Status)
+	}
+
+	done := models.TaskStatusDone
+	if _, err := service.UpdateTask(context.Background(), createdTask.ID, models.UpdateTaskInput{Status: &done}); err != nil {
+		t.Fatalf("new -> done: %v", err)
+	}
+	back := models.TaskStatusNew
+	_, err = service.UpdateTask(context.Background(), createdTask.ID, models.UpdateTaskInput{Status: &back})
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition for done -> new, got %v", err)
+	}
+}
+
+func TestCustomWorkflow(t *testing.T) {
+	workflows := newInMemoryWorkflowRepo()
+	tasks := repository.NewInMemoryTaskRepository()
+	service := NewTaskService(tasks, workflows, events.Discard, repository.NoTx)
+	workflowService := NewWorkflowService(workflows, tasks, repository.NoTx)
+
+	_, err := workflowService.UpdateWorkflow(context.Background(), "ops", models.UpdateWorkflowInput{
+		Statuses: []models.WorkflowStatus{
+			{Key: "triage", Category: models.StatusCategoryTodo},
+			{Key: "closed", Category: models.StatusCategoryDone},
+		},
+		Transitions: []models.WorkflowTransition{{From: "triage", To: "unknown"}},
+	})
+	if !errors.Is(err, ErrInvalidWorkflow) {
+		t.Fatalf("expected ErrInvalidWorkflow, got %v", err)
+	}
+
+	_, err = workflowService.UpdateWorkflow(context.Background(), "ops", models.UpdateWorkflowInput{
+		Statuses: []models.WorkflowStatus{
+			{Key: "triage", Category: models.StatusCategoryTodo},
+			{Key: "closed", Category: models.StatusCategoryDone},
+		},
+		Transitions: []models.WorkflowTransition{{From: "triage", To: "closed"}},
+	})
+	if err != nil {
+		t.Fatalf("update workflow: %v", err)
+	}
+
+	createdTask, err := service.CreateTask(context.Background(), models.CreateTaskInput{
+		Project: "ops",
+		Title:   "rotate certificates",
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if createdTask.Status != "triage" {
+		t.Fatalf("expected initial status triage, got %q", createdTask.Status)
+	}
+
+	inProgress := models.TaskStatusInProgress
+	_, err = service.UpdateTask(context.Background(), createdTask.ID, models.UpdateTaskInput{Status: &inProgress})
+	if !errors.Is(err, ErrInvalidStatus) {
+		t.Fatalf("expected ErrInvalidStatus for status outside workflow, got %v", err)
+	}
+
+	// Across projects a status must be defined by some workflow.
+	for status, valid := range map[models.TaskStatus]bool{"triage": true, "done": true, "bogus": false} {
+		_, err := service.ListTasks(context.Background(), repository.TaskFilter{Status: &status})
+		if valid && err != nil || !valid && !errors.Is(err, ErrInvalidStatus) {
+			t.Errorf("list with status %q: %v", status, err)
+		}
+	}
+}
+
+func TestUpdateWorkflowKeepsStatusesInUse(t *testing.T) {
+	ctx := context.Background()
+	workflows := newInMemoryWorkflowRepo()
+	tasks := repository.NewInMemoryTaskRepository()
+	service := NewTaskService(tasks, workflows, events.Discard, repository.NoTx)
+	workflowService := NewWorkflowService(workflows, tasks, repository.NoTx)
+
+	if _, err := service.CreateTask(ctx, models.CreateTaskInput{Project: "ops", Title: "rotate certificates"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	// Dropping "new", which the task has, would leave it stuck.
+	_, err := workflowService.UpdateWorkflow(ctx, "ops", models.UpdateWorkflowInput{
+		Statuses: []models.WorkflowStatus{
+			{Key: "triage", Category: models.StatusCategoryTodo},
+			{Key: "closed", Category: models.StatusCategoryDone},
+		},
+	})
+	if !errors.Is(err, ErrStatusInUse) || !strings.Contains(err.Error(), `"new"`) {
+		t.Fatalf("expected ErrStatusInUse naming \"new\", got %v", err)
+	}
+
+	_, err = workflowService.UpdateWorkflow(ctx, "ops", models.UpdateWorkflowInput{
+		Statuses: []models.WorkflowStatus{
+			{Key: "new", Category: models.StatusCategoryTodo},
+			{Key: "closed", Category: models.StatusCategoryDone},
+		},
+		Transitions: []models.WorkflowTransition{{From: "new", To: "closed"}},
+	})
+	if err != nil {
+		t.Fatalf("update workflow keeping \"new\": %v", err)
+	}
+	if _, err := service.CreateTask(ctx, models.CreateTaskInput{Project: "ops", Title: "renew domain"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	closed := models.TaskStatus("closed")
+	list, err := service.ListTasks(ctx, repository.TaskFilter{Project: "ops"})
+	if err != nil || len(list) != 2 {
+		t.Fatalf("list: %v, %v", list, err)
+	}
+	if _, err := service.UpdateTask(ctx, list[0].ID, models.UpdateTaskInput{Status: &closed}); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	// The default workflow has no "closed".
+	if _, err := workflowService.ResetWorkflow(ctx, "ops"); !errors.Is(err, ErrStatusInUse) {
+		t.Fatalf("expected ErrStatusInUse on reset, got %v", err)
+	}
+}
+
+func TestListTasksAppliesDefaultLimit(t *testing.T) {
+	service := NewTaskService(repository.NewInMemoryTaskRepository(), newInMemoryWorkflowRepo(), events.Discard, repository.NoTx)
+	for i := 0; i < DefaultLimit+1; i++ {
+		project := models.DefaultProject
+		if i == 0 {
+			project = "home"
+		}
+		if _, err := service.CreateTask(context.Background(), models.CreateTaskInput{Project: project, Title: "valid title"}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	tasks, err := service.ListTasks(context.Background(), repository.TaskFilter{})
+	if err != nil || len(tasks) != DefaultLimit {
+		t.Fatalf("list: %d tasks, want %d (%v)", len(tasks), DefaultLimit, err)
+	}
+	tasks, err = service.ListTasks(context.Background(), repository.TaskFilter{Project: "home"})
+	if err != nil || len(tasks) != 1 || tasks[0].Project != "home" {
+		t.Fatalf("list home: %v %v", tasks, err)
 	}
 }
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/scheduler"
+	"task-manager/internal/webhook"
+)
+
+const JobKindWebhookDelivery = "webhook.delivery"
+
+var (
+	ErrInvalidWebhook = errors.New("invalid webhook")
+)
+
+// webhookEvents are the event types webhooks can subscribe to.
+var webhookEvents = []events.Type{events.TaskCreated, events.TaskUpdated, events.TaskDeleted}
+
+// WebhookSender sends a signed webhook request; webhook.Client implements it.
+type WebhookSender interface {
+	Send(ctx context.Context, request webhook.Request) (*webhook.Response, error)
+}
+
+type webhookDeliveryPayload struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, input models.CreateWebhookInput) (*models.Webhook, error)
+	GetWebhook(ctx context.Context, webhookID uuid.UUID) (*models.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*models.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhookID uuid.UUID, input models.UpdateWebhookInput) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error
+
+	ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit, offset int) ([]*models.WebhookDelivery, error)
+	// GetDelivery returns a delivery of the webhook including its attempt log.
+	GetDelivery(ctx context.Context, webhookID, deliveryID uuid.UUID) (*models.WebhookDelivery, error)
+	// Redeliver queues a new delivery with the payload of an earlier one.
+	Redeliver(ctx context.Context, webhookID, deliveryID uuid.UUID) (*models.WebhookDelivery, error)
+
+	// HandleEvent queues a delivery for every active webhook subscribed to
+	// the event. It is meant to be subscribed to the event bus.
+	HandleEvent(ctx context.Context, event events.Event) error
+	// HandleDelivery is the scheduler handler for webhook delivery jobs.
+	HandleDelivery(ctx context.Context, job *models.Job) error
+}
+
+type webhookService struct {
+	repo        repository.WebhookRepository
+	jobs        scheduler.Enqueuer
+	sender      WebhookSender
+	maxAttempts int
+}
+
+// NewWebhookService creates the webhook service. Deliveries are retried
+// until maxAttempts requests have failed.
+func NewWebhookService(repo repository.WebhookRepository, jobs scheduler.Enqueuer, sender WebhookSender, maxAttempts int) WebhookService {
+	if maxAttempts <= 0 {
+		maxAttempts = scheduler.DefaultMaxAttempts
+	}
+	return &webhookService{repo: repo, jobs: jobs, sender: sender, maxAttempts: maxAttempts}
+}
+
+func validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	return nil
+}
+
+func validateWebhookEvents(eventTypes []string) error {
+	for _, eventType := range eventTypes {
+		known := false
+		for _, webhookEvent := range webhookEvents {
+			if eventType == string(webhookEvent) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, eventType)
+		}
+	}
+	return nil
+}
+
+func (s *webhookService) CreateWebhook(ctx context.Context, input models.CreateWebhookInput) (*models.Webhook, error) {
+	hook := &models.Webhook{
+		ID:     uuid.New(),
+		URL:    strings.TrimSpace(input.URL),
+		Events: input.Events,
+		Secret: input.Secret,
+		Active: true,
+	}
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	if err := validateWebhookURL(hook.URL); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookEvents(hook.Events); err != nil {
+		return nil, err
+	}
+	if hook.Secret == "" {
+		return nil, fmt.Errorf("%w: secret must not be empty", ErrInvalidWebhook)
+	}
+	if err := s.repo.CreateWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+func (s *webhookService) GetWebhook(ctx context.Context, webhookID uuid.UUID) (*models.Webhook, error) {
+	return s.repo.GetWebhook(ctx, webhookID)
+}
+
+func (s *webhookService) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	return s.repo.ListWebhooks(ctx)
+}
+
+func (s *webhookService) UpdateWebhook(ctx context.Context, webhookID uuid.UUID, input models.UpdateWebhookInput) (*models.Webhook, error) {
+	hook, err := s.repo.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if input.URL != nil {
+		hook.URL = strings.TrimSpace(*input.URL)
+		if err := validateWebhookURL(hook.URL); err != nil {
+			return nil, err
+		}
+	}
+	if input.Events != nil {
+		if err := validateWebhookEvents(input.Events); err != nil {
+			return nil, err
+		}
+		hook.Events = input.Events
+	}
+	if input.Secret != nil {
+		if *input.Secret == "" {
+			return nil, fmt.Errorf("%w: secret must not be empty", ErrInvalidWebhook)
+		}
+		hook.Secret = *input.Secret
+	}
+	if input.Active != nil {
+		hook.Active = *input.Active
+	}
+	if err := s.repo.UpdateWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+func (s *webhookService) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error {
+	return s.repo.DeleteWebhook(ctx, webhookID)
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit, offset int) ([]*models.WebhookDelivery, error) {
+	if _, err := s.repo.GetWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return s.repo.ListDeliveries(ctx, webhookID, limit, offset)
+}
+
+func (s *webhookService) GetDelivery(ctx context.Context, webhookID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	delivery, err := s.repo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.WebhookID != webhookID {
+		return nil, repository.ErrWebhookDeliveryNotFound
+	}
+	if delivery.AttemptLog, err = s.repo.ListAttempts(ctx, deliveryID); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *webhookService) Redeliver(ctx context.Context, webhookID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	original, err := s.repo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if original.WebhookID != webhookID {
+		return nil, repository.ErrWebhookDeliveryNotFound
+	}
+	return s.queueDelivery(ctx, webhookID, original.EventType, original.Payload, &original.ID)
+}
+
+func (s *webhookService) HandleEvent(ctx context.Context, event events.Event) error {
+	if validateWebhookEvents([]string{string(event.Type)}) != nil {
+		return nil
+	}
+	hooks, err := s.repo.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	var payload []byte
+	var errs []error
+	for _, hook := range hooks {
+		if !hook.Active || !hook.Wants(string(event.Type)) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				return err
+			}
+		}
+		if _, err := s.queueDelivery(ctx, hook.ID, string(event.Type), payload, nil); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", hook.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *webhookService) queueDelivery(ctx context.Context, webhookID uuid.UUID, eventType string, payload []byte, redeliveryOf *uuid.UUID) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{
+		ID:           uuid.New(),
+		WebhookID:    webhookID,
+		EventType:    eventType,
+		Payload:      payload,
+		Status:       models.WebhookDeliveryPending,
+		RedeliveryOf: redeliveryOf,
+	}
+	if err := s.repo.CreateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	if _, err := s.jobs.Enqueue(ctx, scheduler.JobRequest{
+		Kind:        JobKindWebhookDelivery,
+		Key:         "webhook:" + delivery.ID.String(),
+		Payload:     webhookDeliveryPayload{DeliveryID: delivery.ID},
+		MaxAttempts: s.maxAttempts,
+	}); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *webhookService) HandleDelivery(ctx context.Context, job *models.Job) error {
+	var payload webhookDeliveryPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("%w: decode payload: %v", scheduler.ErrPermanent, err)
+	}
+	delivery, err := s.repo.GetDelivery(ctx, payload.DeliveryID)
+	if errors.Is(err, repository.ErrWebhookDeliveryNotFound) {
+		// The webhook was deleted together with its deliveries.
+		return fmt.Errorf("%w: %v", scheduler.ErrPermanent, err)
+	}
+	if err != nil {
+		return err
+	}
+	// A job reclaimed after a crash may find its delivery already finished.
+	if delivery.Status != models.WebhookDeliveryPending {
+		return nil
+	}
+	hook, err := s.repo.GetWebhook(ctx, delivery.WebhookID)
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		return fmt.Errorf("%w: %v", scheduler.ErrPermanent, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	attempt := &models.WebhookDeliveryAttempt{Attempt: job.Attempts}
+	delivery.Attempts = job.Attempts
+	var deliveryErr error
+	if !hook.Active {
+		deliveryErr = errors.New("webhook is disabled")
+	} else {
+		response, err := s.sender.Send(ctx, webhook.Request{
+			URL:        hook.URL,
+			Secret:     hook.Secret,
+			Event:      delivery.EventType,
+			DeliveryID: delivery.ID.String(),
+			Body:       delivery.Payload,
+		})
+		switch {
+		case err != nil:
+			deliveryErr = err
+		case !response.OK():
+			deliveryErr = fmt.Errorf("receiver responded with status %d", response.Status)
+		}
+		if response != nil {
+			attempt.ResponseStatus = response.Status
+			attempt.ResponseBody = response.Body
+			attempt.DurationMS = response.Duration.Milliseconds()
+		}
+	}
+
+	delivery.ResponseStatus = attempt.ResponseStatus
+	if deliveryErr == nil {
+		now := time.Now().UTC()
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	} else {
+		attempt.Error = deliveryErr.Error()
+		delivery.LastError = attempt.Error
+		if !hook.Active || job.Attempts >= job.MaxAttempts {
+			delivery.Status = models.WebhookDeliveryFailed
+		}
+	}
+	// The attempt log must be written even if shutdown cancelled ctx during
+	// the request.
+	if err := s.repo.RecordAttempt(context.WithoutCancel(ctx), delivery, attempt); err != nil {
+		return err
+	}
+	if deliveryErr != nil && !hook.Active {
+		return fmt.Errorf("%w: %v", scheduler.ErrPermanent, deliveryErr)
+	}
+	return deliveryErr
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+
+	"task-manager/internal/events"
+	"task-manager/internal/migrations"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/scheduler"
+	"task-manager/internal/webhook"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// webhookReceiver records verified webhook requests and answers with the
+// queued status codes, then 204.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	secret   string
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (rec *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err := webhook.Verify(rec.secret, r.Header, body, time.Minute, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	rec.bodies = append(rec.bodies, body)
+	rec.headers = append(rec.headers, r.Header.Clone())
+	status := http.StatusNoContent
+	if len(rec.statuses) > 0 {
+		status, rec.statuses = rec.statuses[0], rec.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestWebhookDeliveryRetriesAndRedelivers(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	jobs := scheduler.New(repository.NewSQLiteJobRepository(db), scheduler.Options{BaseBackoff: time.Second, MaxBackoff: time.Second})
+	svc := NewWebhookService(repository.NewSQLiteWebhookRepository(db), jobs, webhook.NewClient(time.Second), 3)
+	jobs.Handle(JobKindWebhookDelivery, svc.HandleDelivery)
+
+	receiver := &webhookReceiver{secret: "s3cret", statuses: []int{http.StatusInternalServerError}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	hook, err := svc.CreateWebhook(ctx, models.CreateWebhookInput{
+		URL:    server.URL,
+		Events: []string{string(events.TaskCreated)},
+		Secret: "s3cret",
+	})
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+
+	task := &models.Task{ID: uuid.New(), Project: models.DefaultProject, Title: "Pay rent", Status: models.TaskStatusNew}
+	if err := svc.HandleEvent(ctx, events.NewTaskEvent(events.TaskUpdated, task, task)); err != nil {
+		t.Fatalf("handle unsubscribed event: %v", err)
+	}
+	if err := svc.HandleEvent(ctx, events.NewTaskEvent(events.TaskCreated, task, nil)); err != nil {
+		t.Fatalf("handle event: %v", err)
+	}
+
+	// First attempt gets a 500 and stays pending, the retry succeeds.
+	now := time.Now()
+	if _, err := jobs.RunDue(ctx, now); err != nil {
+		t.Fatalf("run jobs: %v", err)
+	}
+	deliveries, err := svc.ListDeliveries(ctx, hook.ID, 0, 0)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("expected one delivery, got %d (err=%v)", len(deliveries), err)
+	}
+	delivery := deliveries[0]
+	if delivery.Status != models.WebhookDeliveryPending || delivery.ResponseStatus != http.StatusInternalServerError {
+		t.Fatalf("after failed attempt: status=%s response=%d", delivery.Status, delivery.ResponseStatus)
+	}
+	if _, err := jobs.RunDue(ctx, now.Add(5*time.Second)); err != nil {
+		t.Fatalf("run jobs: %v", err)
+	}
+	delivery, err = svc.GetDelivery(ctx, hook.ID, delivery.ID)
+	if err != nil {
+		t.Fatalf("get delivery: %v", err)
+	}
+	if delivery.Status != models.WebhookDeliverySucceeded || delivery.Attempts != 2 || len(delivery.AttemptLog) != 2 {
+		t.Fatalf("after retry: status=%s attempts=%d log=%d", delivery.Status, delivery.Attempts, len(delivery.AttemptLog))
+	}
+
+	receiver.mu.Lock()
+	if len(receiver.bodies) != 2 {
+		t.Fatalf("expected two verified requests, got %d", len(receiver.bodies))
+	}
+	var received events.Event
+	if err := json.Unmarshal(receiver.bodies[1], &received); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if received.Type != events.TaskCreated || received.TaskID != task.ID {
+		t.Fatalf("unexpected payload %+v", received)
+	}
+	if got := receiver.headers[1].Get(webhook.HeaderDelivery); got != delivery.ID.String() {
+		t.Fatalf("delivery header = %q, want %s", got, delivery.ID)
+	}
+	receiver.mu.Unlock()
+
+	redelivery, err := svc.Redeliver(ctx, hook.ID, delivery.ID)
+	if err != nil {
+		t.Fatalf("redeliver: %v", err)
+	}
+	if redelivery.RedeliveryOf == nil || *redelivery.RedeliveryOf != delivery.ID {
+		t.Fatalf("redelivery does not reference the original delivery")
+	}
+	if _, err := jobs.RunDue(ctx, time.Now()); err != nil {
+		t.Fatalf("run jobs: %v", err)
+	}
+	redelivery, _ = svc.GetDelivery(ctx, hook.ID, redelivery.ID)
+	if redelivery.Status != models.WebhookDeliverySucceeded || string(redelivery.Payload) != string(delivery.Payload) {
+		t.Fatalf("redelivery: status=%s payload=%s", redelivery.Status, redelivery.Payload)
+	}
+}
+
+func TestWebhookDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	jobs := scheduler.New(repository.NewSQLiteJobRepository(db), scheduler.Options{BaseBackoff: time.Second, MaxBackoff: time.Second})
+	svc := NewWebhookService(repository.NewSQLiteWebhookRepository(db), jobs, webhook.NewClient(time.Second), 2)
+	jobs.Handle(JobKindWebhookDelivery, svc.HandleDelivery)
+
+	// The receiver checks a different secret, so every attempt gets a 401.
+	server := httptest.NewServer(&webhookReceiver{secret: "other"})
+	defer server.Close()
+	hook, err := svc.CreateWebhook(ctx, models.CreateWebhookInput{URL: server.URL, Secret: "s3cret"})
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	task := &models.Task{ID: uuid.New(), Project: models.DefaultProject, Title: "Pay rent", Status: models.TaskStatusNew}
+	if err := svc.HandleEvent(ctx, events.NewTaskEvent(events.TaskDeleted, task, nil)); err != nil {
+		t.Fatalf("handle event: %v", err)
+	}
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := jobs.RunDue(ctx, now.Add(time.Duration(i)*5*time.Second)); err != nil {
+			t.Fatalf("run jobs: %v", err)
+		}
+	}
+	deliveries, _ := svc.ListDeliveries(ctx, hook.ID, 0, 0)
+	if len(deliveries) != 1 {
+		t.Fatalf("expected one delivery, got %d", len(deliveries))
+	}
+	if got := deliveries[0]; got.Status != models.WebhookDeliveryFailed || got.Attempts != 2 || got.ResponseStatus != http.StatusUnauthorized {
+		t.Fatalf("unexpected delivery %+v", got)
+	}
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	svc := NewWebhookService(repository.NewSQLiteWebhookRepository(newTestDB(t)), nil, nil, 0)
+	for _, input := range []models.CreateWebhookInput{
+		{URL: "ftp://example.com/hook", Secret: "s3cret"},
+		{URL: "/relative", Secret: "s3cret"},
+		{URL: "https://example.com/hook", Secret: ""},
+		{URL: "https://example.com/hook", Secret: "s3cret", Events: []string{"task.exploded"}},
+	} {
+		if _, err := svc.CreateWebhook(context.Background(), input); err == nil {
+			t.Errorf("expected %+v to be rejected", input)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+)
+
+var (
+	// ErrInvalidStatus is returned when a status is not defined by the
+	// workflow of the task's project.
+	ErrInvalidStatus = errors.New("invalid status")
+	// ErrInvalidTransition is returned when the workflow doesn't allow
+	// moving a task from its current status to the requested one.
+	ErrInvalidTransition = errors.New("status transition not allowed")
+	// ErrInvalidWorkflow is returned when a workflow definition is malformed.
+	ErrInvalidWorkflow = errors.New("invalid workflow")
+	// ErrStatusInUse is returned when a workflow change would remove
+	// statuses that tasks of the project still have.
+	ErrStatusInUse = errors.New("statuses are still in use")
+)
+
+type WorkflowService interface {
+	GetWorkflow(ctx context.Context, project string) (*models.Workflow, error)
+	UpdateWorkflow(ctx context.Context, project string, input models.UpdateWorkflowInput) (*models.Workflow, error)
+	ResetWorkflow(ctx context.Context, project string) (*models.Workflow, error)
+}
+
+type workflowService struct {
+	repo  repository.WorkflowRepository
+	tasks repository.TaskRepository
+	tx    repository.Transactor
+}
+
+func NewWorkflowService(repo repository.WorkflowRepository, tasks repository.TaskRepository, tx repository.Transactor) WorkflowService {
+	return &workflowService{repo: repo, tasks: tasks, tx: tx}
+}
+
+func (s *workflowService) GetWorkflow(ctx context.Context, project string) (*models.Workflow, error) {
+	return loadWorkflow(ctx, s.repo, project)
+}
+
+func (s *workflowService) UpdateWorkflow(ctx context.Context, project string, input models.UpdateWorkflowInput) (*models.Workflow, error) {
+	workflow := &models.Workflow{
+		Project:       normalizeProject(project),
+		InitialStatus: input.InitialStatus,
+		Statuses:      input.Statuses,
+		Transitions:   input.Transitions,
+	}
+	if err := validateWorkflow(workflow); err != nil {
+		return nil, err
+	}
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.checkRemovedStatuses(ctx, workflow); err != nil {
+			return err
+		}
+		return s.repo.SaveWorkflow(ctx, workflow)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
+
+// ResetWorkflow drops a project's custom workflow so it falls back to the
+// default one.
+func (s *workflowService) ResetWorkflow(ctx context.Context, project string) (*models.Workflow, error) {
+	project = normalizeProject(project)
+	workflow := models.DefaultWorkflow(project)
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.checkRemovedStatuses(ctx, workflow); err != nil {
+			return err
+		}
+		if err := s.repo.DeleteWorkflow(ctx, project); err != nil && !errors.Is(err, repository.ErrWorkflowNotFound) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
+
+// checkRemovedStatuses returns ErrStatusInUse if replacing the project's
+// workflow with workflow would drop statuses that tasks have; they would
+// be stuck, since no transition leads out of an unknown status. It reads
+// all tasks of the project rather than those in the removed statuses, so
+// that on PostgreSQL they stay locked until the transaction ends and no
+// update can move one into a removed status meanwhile.
+func (s *workflowService) checkRemovedStatuses(ctx context.Context, workflow *models.Workflow) error {
+	current, err := loadWorkflow(ctx, s.repo, workflow.Project)
+	if err != nil {
+		return err
+	}
+	removed := make(map[models.TaskStatus]bool)
+	for _, status := range current.Statuses {
+		if _, ok := workflow.Status(status.Key); !ok {
+			removed[status.Key] = true
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	used := make(map[models.TaskStatus]bool)
+	err = s.tasks.EachTask(ctx, repository.TaskFilter{Project: workflow.Project}, func(task *models.Task) error {
+		if removed[task.Status] {
+			used[task.Status] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var inUse []string
+	for _, status := range current.Statuses {
+		if used[status.Key] {
+			inUse = append(inUse, fmt.Sprintf("%q", status.Key))
+		}
+	}
+	if len(inUse) > 0 {
+		return fmt.Errorf("%w: tasks of project %q have %s; move them to other statuses first", ErrStatusInUse, workflow.Project, strings.Join(inUse, ", "))
+	}
+	return nil
+}
+
+// loadWorkflow returns the workflow configured for a project, or the default
+// workflow if the project doesn't have one.
+func loadWorkflow(ctx context.Context, repo repository.WorkflowRepository, project string) (*models.Workflow, error) {
+	project = normalizeProject(project)
+	workflow, err := repo.GetWorkflow(ctx, project)
+	if errors.Is(err, repository.ErrWorkflowNotFound) {
+		return models.DefaultWorkflow(project), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
+
+// isDone reports whether the task is in a done-category status of its
+// project's workflow.
+func isDone(ctx context.Context, workflows repository.WorkflowRepository, task *models.Task) (bool, error) {
+	workflow, err := loadWorkflow(ctx, workflows, task.Project)
+	if err != nil {
+		return false, err
+	}
+	status, ok := workflow.Status(task.Status)
+	return ok && status.Category == models.StatusCategoryDone, nil
+}
+
+func normalizeProject(project string) string {
+	project = strings.TrimSpace(project)
+	if project == "" {
+		return models.DefaultProject
+	}
+	return project
+}
+
+func validateWorkflow(workflow *models.Workflow) error {
+	if len(workflow.Statuses) == 0 {
+		return fmt.Errorf("%w: at least one status is required", ErrInvalidWorkflow)
+	}
+	seen := make(map[models.TaskStatus]bool, len(workflow.Statuses))
+	for i, status := range workflow.Statuses {
+		if strings.TrimSpace(string(status.Key)) == "" {
+			return fmt.Errorf("%w: status %d has an empty key", ErrInvalidWorkflow, i)
+		}
+		if seen[status.Key] {
+			return fmt.Errorf("%w: duplicate status %q", ErrInvalidWorkflow, status.Key)
+		}
+		if !status.Category.Valid() {
+			return fmt.Errorf("%w: status %q has unknown category %q", ErrInvalidWorkflow, status.Key, status.Category)
+		}
+		if status.Name == "" {
+			workflow.Statuses[i].Name = string(status.Key)
+		}
+		seen[status.Key] = true
+	}
+	if workflow.InitialStatus == "" {
+		workflow.InitialStatus = workflow.Statuses[0].Key
+	}
+	if !seen[workflow.InitialStatus] {
+		return fmt.Errorf("%w: initial status %q is not defined", ErrInvalidWorkflow, workflow.InitialStatus)
+	}
+	for _, transition := range workflow.Transitions {
+		if !seen[transition.From] || !seen[transition.To] {
+			return fmt.Errorf("%w: transition %q -> %q references an unknown status", ErrInvalidWorkflow, transition.From, transition.To)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"type":"task.created"}`)
+	now := time.Unix(1714586400, 0)
+	header := http.Header{}
+	header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	header.Set(HeaderSignature, Sign("s3cret", now.Unix(), body))
+
+	if err := Verify("s3cret", header, body, 5*time.Minute, now.Add(time.Minute)); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	for name, check := range map[string]func() error{
+		"wrong secret":  func() error { return Verify("other", header, body, 0, now) },
+		"modified body": func() error { return Verify("s3cret", header, []byte(`{}`), 0, now) },
+		"stale":         func() error { return Verify("s3cret", header, body, 5*time.Minute, now.Add(time.Hour)) },
+	} {
+		if err := check(); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+}
+
+func TestClientSendsSignedRequest(t *testing.T) {
+	var verifyErr error
+	var event, delivery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = Verify("s3cret", r.Header, body, time.Minute, time.Now())
+		event, delivery = r.Header.Get(HeaderEvent), r.Header.Get(HeaderDelivery)
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = io.WriteString(w, strings.Repeat("x", 2*maxResponseBody))
+	}))
+	defer server.Close()
+
+	response, err := NewClient(time.Second).Send(context.Background(), Request{
+		URL:        server.URL,
+		Secret:     "s3cret",
+		Event:      "task.created",
+		DeliveryID: "d-1",
+		Body:       []byte(`{"type":"task.created"}`),
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if verifyErr != nil {
+		t.Fatalf("receiver could not verify signature: %v", verifyErr)
+	}
+	if event != "task.created" || delivery != "d-1" {
+		t.Fatalf("unexpected headers event=%q delivery=%q", event, delivery)
+	}
+	if response.OK() || response.Status != http.StatusBadGateway || len(response.Body) != maxResponseBody {
+		t.Fatalf("unexpected response status=%d body=%d bytes", response.Status, len(response.Body))
+	}
+}
@@ -3,26 +3,46 @@ package main
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	// Calendar feeds need time zones even where the system has none.
+	_ "time/tzdata"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 
+	"task-manager/internal/backup"
 	"task-manager/internal/config"
+	"task-manager/internal/database"
+	"task-manager/internal/encryption"
+	"task-manager/internal/graphql"
+	"task-manager/internal/grpcapi"
 	"task-manager/internal/handler"
 	"task-manager/internal/migrations"
+	"task-manager/internal/notify"
+	"task-manager/internal/outbox"
 	"task-manager/internal/repository"
+	"task-manager/internal/scheduler"
 	"task-manager/internal/service"
+	"task-manager/internal/webhook"
 )
 
 func main() {
 	cfg := config.Load()
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	db, err := sql.Open("sqlite3", cfg.SQLitePath+"?_foreign_keys=on")
+	db, readDB, err := openDB(cfg)
 	if err != nil {
 		log.Fatalf("open db: %v", err)
 	}
@@ -32,13 +52,72 @@ func main() {
 
 		}
 	}(db)
+	if readDB != nil {
+		defer func(db *sql.DB) {
+			_ = db.Close()
+		}(readDB)
+	}
+
+	// In memory mode, everything but tasks is snapshotted as a database
+	// file next to the task snapshot, and loaded before it is migrated.
+	memoryDBSnapshotPath := ""
+	if cfg.DBDriver == "memory" && cfg.MemorySnapshotPath != "" {
+		memoryDBSnapshotPath = cfg.MemorySnapshotPath + ".db"
+		loaded, err := backup.LoadMemory(context.Background(), db, memoryDBSnapshotPath)
+		if err != nil {
+			log.Fatalf("load snapshot: %v", err)
+		}
+		if loaded {
+			log.Printf("loaded database from snapshot %s", memoryDBSnapshotPath)
+		}
+	}
 
 	if err := migrations.Run(db); err != nil {
 		log.Fatalf("migrate: %v", err)
 	}
 
+	var keyring *encryption.Keyring
+	var cipher repository.FieldCipher
+	if cfg.EncryptionKeyFile != "" {
+		keyring, err = encryption.LoadKeyring(cfg.EncryptionKeyFile)
+		if err != nil {
+			log.Fatalf("load encryption keys: %v", err)
+		}
+		cipher = keyring
+		log.Printf("encrypting task descriptions and comments with key %s", keyring.PrimaryKeyID())
+	}
+
+	var taskRepository repository.TaskRepository
+	var memoryTasks *repository.InMemoryTaskRepository
+	switch cfg.DBDriver {
+	case "memory":
+		memoryTasks = repository.NewInMemoryTaskRepository().WithCipher(cipher)
+		if cfg.MemorySnapshotPath != "" {
+			count, err := memoryTasks.LoadSnapshot(cfg.MemorySnapshotPath)
+			if err != nil {
+				log.Fatalf("load snapshot: %v", err)
+			}
+			log.Printf("loaded %d tasks from snapshot %s", count, cfg.MemorySnapshotPath)
+		}
+		taskRepository = memoryTasks
+	default:
+		taskRepository = newTaskRepository(cfg, db, readDB, cipher)
+	}
+
 	// Seed data if SEED_DATA environment variable is set
-	if os.Getenv("SEED_DATA") == "true" {
+	if os.Getenv("SEED_DATA") == "true" && memoryTasks != nil {
+		tasks, err := memoryTasks.ListTasks(context.Background(), repository.TaskFilter{Limit: 1})
+		if err != nil {
+			log.Printf("warning: failed to check existing tasks: %v", err)
+		} else if len(tasks) == 0 {
+			log.Println("seeding memory store with sample tasks...")
+			if err := migrations.SeedTaskRepository(context.Background(), memoryTasks); err != nil {
+				log.Printf("warning: failed to seed data: %v", err)
+			}
+		} else {
+			log.Println("snapshot already contains tasks, skipping seed")
+		}
+	} else if os.Getenv("SEED_DATA") == "true" {
 		// Check if database already has tasks
 		var count int
 		err := db.QueryRow("SELECT COUNT(*) FROM tasks").Scan(&count)
@@ -56,12 +135,155 @@ func main() {
 		}
 	}
 
-	taskRepository := repository.NewSQLiteTaskRepository(db)
-	taskService := service.NewTaskService(taskRepository)
+	workflowRepository := repository.NewSQLiteWorkflowRepository(db)
+	recurrenceRepository := repository.NewSQLiteRecurrenceRepository(db).WithCipher(cipher)
+	jobRepository := repository.NewSQLiteJobRepository(db).WithCipher(cipher)
+	commentRepository := repository.NewSQLiteCommentRepository(db).WithReader(readDB).WithCipher(cipher)
+	notificationRepository := repository.NewSQLiteNotificationRepository(db).WithCipher(cipher)
+	webhookRepository := repository.NewSQLiteWebhookRepository(db).WithCipher(cipher)
+	eventLogRepository := repository.NewSQLiteEventLogRepository(db).WithReader(readDB).WithCipher(cipher)
+	outboxRepository := repository.NewSQLiteOutboxRepository(db).WithCipher(cipher)
+	transactor := repository.NewSQLTransactor(db)
+
+	jobScheduler := scheduler.New(jobRepository, scheduler.Options{PollInterval: cfg.SchedulerPollInterval})
+	notifiers := make(map[string]notify.Notifier)
+	var emailNotifier *notify.EmailNotifier
+	for _, name := range cfg.Notifiers {
+		switch name {
+		case "log":
+			notifiers[name] = notify.LogNotifier{}
+		case "email":
+			emailNotifier = notify.NewEmailNotifier(newMailer(cfg), cfg.SMTPFrom, notificationRepository)
+			notifiers[name] = emailNotifier
+		default:
+			log.Fatalf("unknown notifier %q", name)
+		}
+	}
+
+	// Task and comment events are written to the outbox together with the
+	// change and relayed to the sinks afterwards.
+	relay := outbox.New(outboxRepository, transactor, outbox.Options{
+		PollInterval: cfg.OutboxPollInterval,
+		MaxAttempts:  cfg.OutboxMaxAttempts,
+		Retention:    cfg.OutboxRetention,
+	})
+	taskService := service.NewTaskService(taskRepository, workflowRepository, relay, transactor)
+	importService := service.NewImportService(taskRepository, commentRepository, workflowRepository, relay, transactor)
+	workflowService := service.NewWorkflowService(workflowRepository, taskRepository, transactor)
+	recurrenceService := service.NewRecurrenceService(recurrenceRepository, taskRepository, workflowRepository, relay, transactor)
+	reminderService := service.NewReminderService(taskRepository, workflowRepository, jobScheduler, notifiers, cfg.ReminderLeadTime)
+	jobService := service.NewJobService(jobRepository)
+	commentService := service.NewCommentService(commentRepository, taskRepository, relay, transactor)
+	notificationService := service.NewNotificationService(notificationRepository, jobScheduler, notifiers)
+	calendarService := service.NewCalendarService(repository.NewSQLiteCalendarFeedRepository(db), taskRepository, workflowRepository)
+	webhookService := service.NewWebhookService(webhookRepository, jobScheduler, webhook.NewClient(cfg.WebhookTimeout), cfg.WebhookMaxAttempts)
+	eventStreamService := service.NewEventStreamService(eventLogRepository, cfg.EventLogRetention)
+	activityService := service.NewActivityService(eventLogRepository, workflowRepository)
+	calDAVService := service.NewCalDAVService(taskService, taskRepository, workflowRepository, eventLogRepository, transactor)
+	relay.AddSink("notifications", notificationService.HandleEvent)
+	relay.AddSink("webhooks", webhookService.HandleEvent)
+	relay.AddSink("event-log", eventStreamService.HandleEvent)
 	taskHandler := handler.NewTaskHandler(taskService)
+	importHandler := handler.NewImportHandler(importService)
+	workflowHandler := handler.NewWorkflowHandler(workflowService)
+	recurrenceHandler := handler.NewRecurrenceHandler(recurrenceService)
+	jobHandler := handler.NewJobHandler(jobService)
+	commentHandler := handler.NewCommentHandler(commentService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	calendarHandler := handler.NewCalendarHandler(calendarService)
+	calDAVHandler := handler.NewCalDAVHandler(calDAVService)
+	activityHandler := handler.NewActivityHandler(activityService)
+	graphQLHandler := handler.NewGraphQLHandler(taskService, commentService, workflowService, graphql.Limits{
+		MaxDepth:      cfg.GraphQLMaxDepth,
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+	})
+	eventHandler := handler.NewEventHandler(eventStreamService)
+	webSocketHandler := handler.NewWebSocketHandler(taskService, eventStreamService)
 
 	router := http.NewServeMux()
 	taskHandler.RegisterRoutes(router)
+	importHandler.RegisterRoutes(router)
+	workflowHandler.RegisterRoutes(router)
+	recurrenceHandler.RegisterRoutes(router)
+	jobHandler.RegisterRoutes(router)
+	commentHandler.RegisterRoutes(router)
+	notificationHandler.RegisterRoutes(router)
+	webhookHandler.RegisterRoutes(router)
+	calendarHandler.RegisterRoutes(router)
+	calDAVHandler.RegisterRoutes(router)
+	activityHandler.RegisterRoutes(router)
+	graphQLHandler.RegisterRoutes(router)
+	eventHandler.RegisterRoutes(router)
+	webSocketHandler.RegisterRoutes(router)
+
+	var backups *backup.Manager
+	if cfg.DBDriver == "sqlite" {
+		backups = backup.NewManager(cfg.SQLitePath, cfg.BackupDir, cfg.BackupKeep)
+		if cfg.AdminToken != "" {
+			handler.NewAdminHandler(backups, cfg.AdminToken).RegisterRoutes(router)
+		}
+	}
+
+	jobScheduler.Handle(service.JobKindReminder, reminderService.HandleReminder)
+	jobScheduler.Handle(service.JobKindNotification, notificationService.HandleNotification)
+	jobScheduler.Handle(service.JobKindWebhookDelivery, webhookService.HandleDelivery)
+	jobScheduler.Every("recurrences", cfg.RecurrenceInterval, func(ctx context.Context) error {
+		created, err := recurrenceService.MaterialiseDue(ctx, time.Now())
+		if created > 0 {
+			log.Printf("materialised %d recurring tasks", created)
+		}
+		return err
+	})
+	jobScheduler.Every("reminders", cfg.SchedulerPollInterval, func(ctx context.Context) error {
+		_, err := reminderService.PlanReminders(ctx, time.Now())
+		return err
+	})
+
+	jobScheduler.Every("event-log", time.Hour, func(ctx context.Context) error {
+		_, err := eventStreamService.Prune(ctx, time.Now())
+		return err
+	})
+	jobScheduler.Every("outbox", time.Hour, func(ctx context.Context) error {
+		_, err := relay.Prune(ctx, time.Now())
+		return err
+	})
+
+	// Backups are checked for at least hourly, so that one falls due soon
+	// after the interval has passed even across restarts.
+	if backups != nil && cfg.BackupDir != "" {
+		jobScheduler.Every("backup", min(cfg.BackupInterval, time.Hour), func(ctx context.Context) error {
+			created, err := backups.CreateIfDue(ctx, time.Now(), cfg.BackupInterval)
+			if created != nil {
+				log.Printf("backed up database to %s", created.Name)
+			}
+			return err
+		})
+	}
+
+	// Picks up keys added to the key file, and a new primary key, without a
+	// restart.
+	if keyring != nil {
+		jobScheduler.Every("encryption-keys", time.Minute, func(ctx context.Context) error {
+			reloaded, err := keyring.Reload()
+			if reloaded {
+				log.Printf("reloaded encryption keys, primary key %s", keyring.PrimaryKeyID())
+			}
+			return err
+		})
+	}
+
+	if emailNotifier != nil {
+		jobScheduler.Every("digests", cfg.DigestInterval, func(ctx context.Context) error {
+			_, err := emailNotifier.FlushDigests(ctx, time.Now())
+			return err
+		})
+	}
+
+	// Background workers run until shutdown starts.
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	jobScheduler.Start(backgroundCtx)
+	relay.Start(backgroundCtx)
 
 	server := &http.Server{
 		Addr:         cfg.Addr,
@@ -70,6 +292,21 @@ func main() {
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	// Event streams and WebSockets outlive requests, and Shutdown doesn't
+	// track hijacked connections; end them when shutdown starts.
+	server.RegisterOnShutdown(eventStreamService.Close)
+
+	grpcServer := grpcapi.NewServer(taskService, eventStreamService)
+	grpcListener, err := net.Listen("tcp", cfg.GRPCAddr)
+	if err != nil {
+		log.Fatalf("listen on %s: %v", cfg.GRPCAddr, err)
+	}
+	go func() {
+		log.Printf("grpc listening on %s", cfg.GRPCAddr)
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("grpc Serve: %v", err)
+		}
+	}()
 
 	// Graceful shutdown
 	idleConnsClosed := make(chan struct{})
@@ -78,6 +315,7 @@ func main() {
 		signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 		<-signalChan
 		log.Println("shutting down http server")
+		stopBackground()
 
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -85,6 +323,18 @@ func main() {
 		if err := server.Shutdown(shutdownCtx); err != nil {
 			log.Printf("http server Shutdown: %v", err)
 		}
+		// Watches end when the event stream closes; calls still running
+		// when the timeout is up are cancelled.
+		grpcStopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(grpcStopped)
+		}()
+		select {
+		case <-grpcStopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
 		close(idleConnsClosed)
 	}()
 
@@ -94,4 +344,77 @@ func main() {
 	}
 
 	<-idleConnsClosed
+	relay.Wait()
+	jobScheduler.Wait()
+
+	if memoryTasks != nil && cfg.MemorySnapshotPath != "" {
+		if err := memoryTasks.SaveSnapshot(cfg.MemorySnapshotPath); err != nil {
+			log.Printf("save snapshot: %v", err)
+		} else {
+			log.Printf("saved tasks to snapshot %s", cfg.MemorySnapshotPath)
+		}
+	}
+	if memoryDBSnapshotPath != "" {
+		if err := backup.SaveMemory(context.Background(), db, memoryDBSnapshotPath); err != nil {
+			log.Printf("save snapshot: %v", err)
+		} else {
+			log.Printf("saved database to snapshot %s", memoryDBSnapshotPath)
+		}
+	}
+}
+
+// newMailer returns the mailer used by the email notifier: a local Maildir in
+// test mode, SMTP otherwise.
+func newMailer(cfg config.Config) notify.Mailer {
+	if cfg.Maildir != "" {
+		mailer, err := notify.NewMaildirMailer(cfg.Maildir)
+		if err != nil {
+			log.Fatalf("maildir: %v", err)
+		}
+		log.Printf("email notifications are written to maildir %s", cfg.Maildir)
+		return mailer
+	}
+	if cfg.SMTPHost == "" {
+		log.Fatalf("email notifier requires %s or %s", config.TaskManagerSMTPHost, config.TaskManagerMaildir)
+	}
+	return notify.NewSMTPMailer(notify.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		TLS:      notify.TLSMode(cfg.SMTPTLS),
+	})
+}
+
+// newTaskRepository returns the task repository of the SQL database of cfg.
+func newTaskRepository(cfg config.Config, db, readDB *sql.DB, cipher repository.FieldCipher) repository.TaskRepository {
+	if cfg.DBDriver == string(database.Postgres) {
+		return repository.NewPostgresTaskRepository(db).WithCipher(cipher)
+	}
+	return repository.NewSQLiteTaskRepository(db).WithReader(readDB).WithCipher(cipher)
+}
+
+// openDB opens the database of the configured driver. Only the sqlite
+// driver has a separate pool for reads; the second result is nil otherwise.
+func openDB(cfg config.Config) (*sql.DB, *sql.DB, error) {
+	switch cfg.DBDriver {
+	case string(database.Postgres):
+		if cfg.PostgresURL == "" {
+			return nil, nil, fmt.Errorf("%s is required with %s=postgres", config.TaskManagerPostgresURL, config.TaskManagerDBDriver)
+		}
+		db, err := sql.Open(database.Postgres.DriverName(), cfg.PostgresURL)
+		return db, nil, err
+	case "memory":
+		// Everything but tasks lives in a private in-memory SQLite database.
+		// Each connection would get its own, so the pool holds exactly one.
+		// Comments reference tasks, which aren't in it, so foreign keys are
+		// left off.
+		db, err := sql.Open(database.SQLite.DriverName(), ":memory:?_txlock=immediate")
+		if err != nil {
+			return nil, nil, err
+		}
+		db.SetMaxOpenConns(1)
+		return db, nil, nil
+	}
+	return database.OpenSQLite(cfg.SQLitePath, cfg.SQLite)
 }